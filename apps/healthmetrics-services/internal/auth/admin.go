@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// isAdminFunc is swapped in tests (no DB needed).
+var isAdminFunc = isAdmin
+
+// isAdmin reads users.is_admin (a missing user is simply not an admin).
+func isAdmin(ctx context.Context, pool *pgxpool.Pool, userID string) (bool, error) {
+	var admin bool
+	err := pool.QueryRow(ctx, `SELECT is_admin FROM users WHERE id = $1`, userID).Scan(&admin)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return admin, err
+}
+
+// RequireAdmin guards moderation routes (/v1/admin/...). It runs after New, which has
+// already authenticated the user; this only checks users.is_admin.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		userID := c.GetString("userID")
+		if userID == "" {
+			c.JSON(401, gin.H{"error": map[string]interface{}{
+				"code":    "UNAUTHORIZED",
+				"message": "Missing authenticated user",
+			}})
+			c.Abort()
+			return
+		}
+
+		poolValue, _ := c.Get("db")
+		pool, ok := poolValue.(*pgxpool.Pool)
+		if !ok || pool == nil {
+			c.JSON(500, gin.H{"error": map[string]interface{}{
+				"code":    "INTERNAL_ERROR",
+				"message": "DB not available",
+			}})
+			c.Abort()
+			return
+		}
+
+		queryCtx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+
+		admin, err := isAdminFunc(queryCtx, pool, userID)
+		if err != nil {
+			log.Printf("auth_error request_id=%s reason=admin_lookup_failed err=%v", requestID, err)
+			c.JSON(500, gin.H{"error": map[string]interface{}{
+				"code":    "INTERNAL_ERROR",
+				"message": "Admin check failed",
+			}})
+			c.Abort()
+			return
+		}
+		if !admin {
+			log.Printf("auth_error request_id=%s reason=not_admin user_id=%s", requestID, userID)
+			c.JSON(403, gin.H{"error": map[string]interface{}{
+				"code":    "FORBIDDEN",
+				"message": "Admin access required",
+			}})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
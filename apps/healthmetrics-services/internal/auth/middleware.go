@@ -17,6 +17,9 @@ import (
 type Config struct {
 	APIKey     string
 	CookieName string
+	// PublicPaths skip API key + session auth (and rate limiting). Only for routes that
+	// authenticate the caller themselves, like signed provider webhooks.
+	PublicPaths []string
 }
 
 // parseSessionToken extracts the raw token from "token.signature" cookie values.
@@ -75,6 +78,13 @@ func New(cfg Config) gin.HandlerFunc {
 			c.Next()
 			return
 		}
+		for _, path := range cfg.PublicPaths {
+			if c.Request.URL.Path == path {
+				c.Set("skipRateLimit", true)
+				c.Next()
+				return
+			}
+		}
 
 		// Read the request ID from the incoming headers (sent by the TS server action).
 		requestID := c.GetHeader("X-Request-ID")
@@ -0,0 +1,375 @@
+package barcode
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/httpx"
+	"healthmetrics-services/internal/units"
+)
+
+// Alternatives tuning.
+const (
+	defaultAlternativesLimit = 5
+	maxAlternativesLimit     = 20
+	maxCategoryCandidates    = 200 // rows scored per category level
+	maxCategoryLevels        = 3   // most specific category, then up to 2 broader parents
+	minScoreImprovement      = 5.0 // points on the 0-100 health score; smaller gaps aren't worth suggesting
+)
+
+// Health score components (also the keys of HealthWeights / HEALTH_SCORE_WEIGHTS).
+const (
+	componentNutriscore = "nutriscore"
+	componentSugar      = "sugar"
+	componentSodium     = "sodium"
+	componentNova       = "nova"
+)
+
+// Sub-score scales (per 100 g). Values at or above these score 0; zero scores 100.
+// They are twice the UK front-of-pack "high" thresholds (22.5 g sugar, 0.6 g sodium).
+const (
+	sugarZeroScoreG  = 45.0
+	sodiumZeroScoreG = 1.2
+)
+
+// HealthWeights sets how much each component counts in the health score.
+// Weights are relative (they don't need to sum to 1); unknown components are left out and
+// the rest re-weighted, so a product without a Nutri-Score is judged on sugar/sodium/NOVA.
+type HealthWeights struct {
+	Nutriscore float64
+	Sugar      float64
+	Sodium     float64
+	Nova       float64
+}
+
+// DefaultHealthWeights favors Nutri-Score, which already blends many nutrients.
+var DefaultHealthWeights = HealthWeights{Nutriscore: 0.4, Sugar: 0.25, Sodium: 0.2, Nova: 0.15}
+
+// ParseHealthWeights reads "nutriscore=0.4,sugar=0.25,sodium=0.2,nova=0.15".
+// Missing keys keep their default; a key set to 0 is ignored in scoring.
+func ParseHealthWeights(raw string) (HealthWeights, error) {
+	weights := DefaultHealthWeights
+	if strings.TrimSpace(raw) == "" {
+		return weights, nil
+	}
+
+	for _, part := range strings.Split(raw, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return HealthWeights{}, fmt.Errorf("invalid weight %q (want key=value)", part)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return HealthWeights{}, fmt.Errorf("invalid weight %q (want a number >= 0)", part)
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case componentNutriscore:
+			weights.Nutriscore = weight
+		case componentSugar:
+			weights.Sugar = weight
+		case componentSodium:
+			weights.Sodium = weight
+		case componentNova:
+			weights.Nova = weight
+		default:
+			return HealthWeights{}, fmt.Errorf("unknown weight %q", key)
+		}
+	}
+
+	if weights.Nutriscore+weights.Sugar+weights.Sodium+weights.Nova == 0 {
+		return HealthWeights{}, fmt.Errorf("at least one weight must be positive")
+	}
+	return weights, nil
+}
+
+// HealthScore is a product's 0-100 health score (higher is healthier) and the parts it came from.
+type HealthScore struct {
+	Score      float64            `json:"score"`
+	Components map[string]float64 `json:"components"` // known sub-scores, each 0-100
+}
+
+// Score rates a food item; ok=false when none of the weighted components is known.
+// Example (default weights): Nutri-Score B (75), 10 g sugar (77.8), no sodium, NOVA 3 (33.3)
+// -> (0.4*75 + 0.25*77.8 + 0.15*33.3) / 0.8 = 68.1
+func (w HealthWeights) Score(item FoodItem) (HealthScore, bool) {
+	score := HealthScore{Components: map[string]float64{}}
+	total, weightSum := 0.0, 0.0
+
+	add := func(component string, weight float64, value float64) {
+		score.Components[component] = roundTo(value, 1)
+		if weight > 0 {
+			total += weight * value
+			weightSum += weight
+		}
+	}
+
+	if grade := item.Nutriscore; grade != "" {
+		add(componentNutriscore, w.Nutriscore, float64('e'-grade[0])*25) // a=100 ... e=0
+	}
+	if sugar := item.Nutrients.SugarG; sugar != nil {
+		add(componentSugar, w.Sugar, 100*(1-math.Min(*sugar/sugarZeroScoreG, 1)))
+	}
+	if sodium := item.Nutrients.SodiumG; sodium != nil {
+		add(componentSodium, w.Sodium, 100*(1-math.Min(*sodium/sodiumZeroScoreG, 1)))
+	}
+	if group := item.NovaGroup; group >= 1 && group <= 4 {
+		add(componentNova, w.Nova, float64(4-group)*100/3) // NOVA 1=100 ... 4=0
+	}
+
+	if weightSum == 0 {
+		return HealthScore{}, false
+	}
+	score.Score = roundTo(total/weightSum, 1)
+	return score, true
+}
+
+// Alternative is one suggested replacement for the scanned product.
+type Alternative struct {
+	FoodItem    FoodItem    `json:"food_item"`
+	HealthScore HealthScore `json:"health_score"`
+	Improvement float64     `json:"improvement"` // score points over the scanned product
+	Category    string      `json:"category"`    // shared category tag it was found through
+	Reasons     []string    `json:"reasons"`     // why it's better, most important first
+}
+
+// Allow tests to swap DB helpers without changing production logic.
+var listCategoryCandidatesFunc = listCategoryCandidates // default: real DB fetch
+
+// NewAlternativesHandler handles GET /v1/barcodes/:code/alternatives?limit=5.
+// Flow: look up the scanned product (same path as GET /v1/barcodes/:code) -> walk its categories
+// from most specific to broader -> score cached same-category products -> keep the ones that
+// beat it by minScoreImprovement -> best first, each with reasons.
+func NewAlternativesHandler(resolver Resolver, weights HealthWeights) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		barcode := c.Param("code")
+		if !validateBarcode(c, barcode) {
+			return
+		}
+
+		limit := defaultAlternativesLimit
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxAlternativesLimit {
+				httpx.WriteError(c, 400, "INVALID_REQUEST", fmt.Sprintf("limit must be between 1 and %d", maxAlternativesLimit))
+				return
+			}
+			limit = parsed
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		source, ok := resolver.lookup(c, pool, barcode)
+		if !ok {
+			return
+		}
+
+		response := gin.H{
+			"barcode":      source.Barcode,
+			"alternatives": []Alternative{},
+		}
+		sourceScore, scored := weights.Score(source)
+		if scored {
+			response["health_score"] = sourceScore
+		}
+		// Nothing to compare against: say why instead of returning a silent empty list.
+		if len(source.Categories) == 0 {
+			response["reason"] = "no_category"
+			c.JSON(200, response)
+			return
+		}
+		if !scored {
+			response["reason"] = "no_health_data"
+			c.JSON(200, response)
+			return
+		}
+
+		alternatives, err := findAlternatives(c.Request.Context(), pool, source, sourceScore, weights, limit)
+		if err != nil {
+			requestID := c.GetHeader("X-Request-ID")
+			log.Printf("alternatives_read_error request_id=%s barcode=%s err=%v", requestID, barcode, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load alternatives")
+			return
+		}
+
+		system := units.Resolve(c, pool) // cached by lookup above
+		for i := range alternatives {
+			applyUnits(&alternatives[i].FoodItem, system)
+		}
+		response["alternatives"] = alternatives
+		c.JSON(200, response)
+	}
+}
+
+// findAlternatives walks categories from most specific ("en:dark-chocolates") to broader
+// ("en:chocolates"), stopping once enough better products are found.
+func findAlternatives(ctx context.Context, pool *pgxpool.Pool, source FoodItem, sourceScore HealthScore, weights HealthWeights, limit int) ([]Alternative, error) {
+	seen := map[string]bool{source.Barcode: true}
+	alternatives := []Alternative{}
+
+	for level := 0; level < maxCategoryLevels && level < len(source.Categories); level++ {
+		category := source.Categories[len(source.Categories)-1-level] // OFF tags go general -> specific
+
+		candidates, err := listCategoryCandidatesFunc(ctx, pool, category, source.Barcode, maxCategoryCandidates)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, candidate := range candidates {
+			if seen[candidate.Barcode] {
+				continue
+			}
+			seen[candidate.Barcode] = true
+
+			score, ok := weights.Score(candidate)
+			if !ok || score.Score-sourceScore.Score < minScoreImprovement {
+				continue
+			}
+			alternatives = append(alternatives, Alternative{
+				FoodItem:    candidate,
+				HealthScore: score,
+				Improvement: roundTo(score.Score-sourceScore.Score, 1),
+				Category:    category,
+				Reasons:     explainAlternative(source, candidate, sourceScore, score),
+			})
+		}
+
+		if len(alternatives) >= limit {
+			break
+		}
+	}
+
+	// Best score first; ties keep the closer category (found earlier).
+	sort.SliceStable(alternatives, func(i, j int) bool {
+		return alternatives[i].HealthScore.Score > alternatives[j].HealthScore.Score
+	})
+	if len(alternatives) > limit {
+		alternatives = alternatives[:limit]
+	}
+	return alternatives, nil
+}
+
+// explainAlternative lists what makes the candidate better than the scanned product.
+// Example: ["Nutri-Score A instead of D", "62% less sugar (4.1 g vs 10.8 g per 100 g)"]
+func explainAlternative(source, candidate FoodItem, sourceScore, candidateScore HealthScore) []string {
+	reasons := []string{}
+
+	if source.Nutriscore != "" && candidate.Nutriscore != "" && candidate.Nutriscore < source.Nutriscore {
+		reasons = append(reasons, fmt.Sprintf("Nutri-Score %s instead of %s",
+			strings.ToUpper(candidate.Nutriscore), strings.ToUpper(source.Nutriscore)))
+	}
+	if reason, ok := lessOf("sugar", source.Nutrients.SugarG, candidate.Nutrients.SugarG, 1, "g"); ok {
+		reasons = append(reasons, reason)
+	}
+	if reason, ok := lessOf("sodium", source.Nutrients.SodiumG, candidate.Nutrients.SodiumG, 1000, "mg"); ok {
+		reasons = append(reasons, reason)
+	}
+	if source.NovaGroup != 0 && candidate.NovaGroup != 0 && candidate.NovaGroup < source.NovaGroup {
+		reasons = append(reasons, fmt.Sprintf("Less processed (NOVA %d vs %d)", candidate.NovaGroup, source.NovaGroup))
+	}
+
+	if len(reasons) == 0 { // better overall without one standout difference
+		reasons = append(reasons, fmt.Sprintf("Higher overall health score (%g vs %g)", candidateScore.Score, sourceScore.Score))
+	}
+	return reasons
+}
+
+// lessOf describes a meaningful drop in a nutrient (at least 20% less).
+// scale converts grams for display (1000 -> mg).
+func lessOf(nutrient string, source, candidate *float64, scale float64, unit string) (string, bool) {
+	if source == nil || candidate == nil || *source <= 0 {
+		return "", false
+	}
+	drop := (*source - *candidate) / *source
+	if drop < 0.2 {
+		return "", false
+	}
+	return fmt.Sprintf("%.0f%% less %s (%g %s vs %g %s per 100 g)",
+		drop*100, nutrient, roundTo(*candidate*scale, 1), unit, roundTo(*source*scale, 1), unit), true
+}
+
+// listCategoryCandidates loads cached products sharing a category tag (excluding the scanned one).
+// Rows flagged nutrition_missing are skipped: all-zero nutrients would look "healthy".
+// Merged duplicates are skipped too; their survivor is suggested instead.
+func listCategoryCandidates(ctx context.Context, pool *pgxpool.Pool, category string, excludeBarcode string, limit int) ([]FoodItem, error) {
+	const query = `
+		SELECT
+			id,
+			barcode,
+			name,
+			COALESCE(brand, ''),
+			serving_size_g::text || COALESCE(serving_size_unit, 'g'),
+			calories_per_100g::float8,
+			protein_g::float8,
+			carbs_g::float8,
+			fat_g::float8,
+			fiber_g::float8,
+			sugar_g::float8,
+			(sodium_mg / 1000.0)::float8,
+			category_tags,
+			COALESCE(nutriscore_grade, ''),
+			COALESCE(nova_group, 0)
+		FROM food_items
+		WHERE category_tags @> ARRAY[$1]::text[]
+			AND barcode IS NOT NULL
+			AND barcode <> $2
+			AND merged_into_id IS NULL
+			AND NOT ('nutrition_missing' = ANY(COALESCE(data_quality_flags, ARRAY[]::text[])))
+		ORDER BY verified DESC, updated_at DESC
+		LIMIT $3
+	`
+
+	rows, err := pool.Query(ctx, query, category, excludeBarcode, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query food_items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []FoodItem
+	for rows.Next() {
+		var (
+			item                 FoodItem
+			fiber, sugar, sodium sql.NullFloat64
+			novaGroup            int32
+		)
+		if err := rows.Scan(
+			&item.ID,
+			&item.Barcode,
+			&item.Name,
+			&item.Brand,
+			&item.ServingSize,
+			&item.Nutrients.CaloriesKcal,
+			&item.Nutrients.ProteinG,
+			&item.Nutrients.CarbsG,
+			&item.Nutrients.FatG,
+			&fiber,
+			&sugar,
+			&sodium,
+			&item.Categories,
+			&item.Nutriscore,
+			&novaGroup,
+		); err != nil {
+			return nil, fmt.Errorf("scan food_items: %w", err)
+		}
+		item.Nutrients.FiberG = NullFloat64ToPtr(fiber)
+		item.Nutrients.SugarG = NullFloat64ToPtr(sugar)
+		item.Nutrients.SodiumG = NullFloat64ToPtr(sodium)
+		item.NovaGroup = int(novaGroup)
+		items = append(items, item)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("iterate food_items: %w", rows.Err())
+	}
+	return items, nil
+}
@@ -0,0 +1,181 @@
+package barcode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/openfoodfacts/openfoodfacts-go"
+)
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+// sweetCereal is the scanned product in these tests (Nutri-Score D, sugary, ultra-processed).
+var sweetCereal = FoodItem{
+	ID:         "food_sweet",
+	Barcode:    "4006381333931",
+	Name:       "Frosted Flakes",
+	Categories: []string{"en:cereals-and-potatoes", "en:breakfast-cereals", "en:flakes"},
+	Nutriscore: "d",
+	NovaGroup:  4,
+	Nutrients:  FoodItemNutrients{SugarG: floatPtr(37), SodiumG: floatPtr(0.4)},
+}
+
+func TestParseHealthWeights(t *testing.T) {
+	weights, err := ParseHealthWeights("sugar=0.5, nova=0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if weights.Sugar != 0.5 || weights.Nova != 0 || weights.Nutriscore != DefaultHealthWeights.Nutriscore {
+		t.Fatalf("unexpected weights: %+v", weights)
+	}
+
+	for _, raw := range []string{"sugar", "sugar=-1", "fat=0.2", "nutriscore=0,sugar=0,sodium=0,nova=0"} {
+		if _, err := ParseHealthWeights(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestHealthScore(t *testing.T) {
+	item := FoodItem{Nutriscore: "b", NovaGroup: 3, Nutrients: FoodItemNutrients{SugarG: floatPtr(10)}}
+
+	score, ok := DefaultHealthWeights.Score(item)
+	if !ok || score.Score != 68.1 {
+		t.Fatalf("expected 68.1, got %+v (ok=%t)", score, ok)
+	}
+	if _, known := score.Components[componentSodium]; known {
+		t.Fatalf("unknown sodium must not be scored: %+v", score.Components)
+	}
+
+	// Only sugar weighted: score is the sugar sub-score.
+	sugarOnly := HealthWeights{Sugar: 1}
+	if score, _ := sugarOnly.Score(item); score.Score != 77.8 {
+		t.Fatalf("expected sugar-only 77.8, got %v", score.Score)
+	}
+
+	if _, ok := DefaultHealthWeights.Score(FoodItem{}); ok {
+		t.Fatalf("expected no score without health data")
+	}
+}
+
+func TestExplainAlternative(t *testing.T) {
+	candidate := FoodItem{Nutriscore: "a", NovaGroup: 1, Nutrients: FoodItemNutrients{SugarG: floatPtr(1.2), SodiumG: floatPtr(0.38)}}
+	reasons := explainAlternative(sweetCereal, candidate, HealthScore{Score: 20}, HealthScore{Score: 90})
+
+	expected := []string{
+		"Nutri-Score A instead of D",
+		"97% less sugar (1.2 g vs 37 g per 100 g)",
+		"Less processed (NOVA 1 vs 4)",
+	}
+	if strings.Join(reasons, "|") != strings.Join(expected, "|") {
+		t.Fatalf("unexpected reasons: %v", reasons) // sodium only 5% lower: not a reason
+	}
+
+	fallback := explainAlternative(sweetCereal, FoodItem{Nutriscore: "d"}, HealthScore{Score: 20}, HealthScore{Score: 30})
+	if len(fallback) != 1 || !strings.HasPrefix(fallback[0], "Higher overall health score") {
+		t.Fatalf("expected overall-score fallback, got %v", fallback)
+	}
+}
+
+func TestMapProductToFoodItem_HealthAttributes(t *testing.T) {
+	product := &openfoodfacts.Product{
+		CategoriesTags:  []string{"en:snacks", "en:chips"},
+		NutritionGrades: "not-applicable",
+	}
+	product.Nutriments.NovaGroup = 4
+
+	item := mapProductToFoodItem(product)
+	if len(item.Categories) != 2 || item.Nutriscore != "" || item.NovaGroup != 4 {
+		t.Fatalf("unexpected health attributes: %+v", item)
+	}
+}
+
+func TestAlternativesHandler(t *testing.T) {
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return sweetCereal, time.Now(), true, nil // fresh cache hit, no upstream call
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			return nil
+		},
+	)
+	defer cleanup()
+
+	origCandidates := listCategoryCandidatesFunc
+	defer func() { listCategoryCandidatesFunc = origCandidates }()
+	var categories []string
+	listCategoryCandidatesFunc = func(_ context.Context, _ *pgxpool.Pool, category string, exclude string, _ int) ([]FoodItem, error) {
+		categories = append(categories, category)
+		if exclude != sweetCereal.Barcode {
+			t.Fatalf("expected the scanned product to be excluded, got %s", exclude)
+		}
+		switch category {
+		case "en:flakes":
+			return []FoodItem{
+				{Barcode: "1", Name: "Bran Flakes", Nutriscore: "a", NovaGroup: 3, Nutrients: FoodItemNutrients{SugarG: floatPtr(14), SodiumG: floatPtr(0.3)}},
+				{Barcode: "2", Name: "Honey Flakes", Nutriscore: "d", NovaGroup: 4, Nutrients: FoodItemNutrients{SugarG: floatPtr(35), SodiumG: floatPtr(0.4)}}, // not better
+			}, nil
+		case "en:breakfast-cereals":
+			return []FoodItem{
+				{Barcode: "1", Name: "Bran Flakes (dup)", Nutriscore: "a"},
+				{Barcode: "3", Name: "Rolled Oats", Nutriscore: "a", NovaGroup: 1, Nutrients: FoodItemNutrients{SugarG: floatPtr(1), SodiumG: floatPtr(0.01)}},
+			}, nil
+		}
+		return nil, nil
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		c.Next()
+	})
+	fetcher := &fakeFetcher{}
+	resolver := Resolver{API: fetcher, Retry: RetryConfig{MaxAttempts: 1}, CacheTTL: time.Hour}
+	router.GET("/v1/barcodes/:code/alternatives", NewAlternativesHandler(resolver, DefaultHealthWeights))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/barcodes/4006381333931/alternatives?limit=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body struct {
+		HealthScore  HealthScore   `json:"health_score"`
+		Alternatives []Alternative `json:"alternatives"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(body.Alternatives) != 2 || body.Alternatives[0].FoodItem.Name != "Rolled Oats" || body.Alternatives[1].FoodItem.Name != "Bran Flakes" {
+		t.Fatalf("expected oats then bran flakes, got %+v", body.Alternatives)
+	}
+	if body.Alternatives[0].Category != "en:breakfast-cereals" || len(body.Alternatives[0].Reasons) == 0 {
+		t.Fatalf("expected category + reasons, got %+v", body.Alternatives[0])
+	}
+	if body.Alternatives[1].Improvement <= minScoreImprovement {
+		t.Fatalf("expected a real improvement, got %v", body.Alternatives[1].Improvement)
+	}
+	// Only 1 better product in the most specific category -> broadened once, then stopped.
+	if strings.Join(categories, ",") != "en:flakes,en:breakfast-cereals" {
+		t.Fatalf("unexpected category walk: %v", categories)
+	}
+	if fetcher.calls != 0 {
+		t.Fatalf("expected cached source, got %d upstream calls", fetcher.calls)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/barcodes/4006381333931/alternatives?limit=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid limit, got %d", rec.Code)
+	}
+}
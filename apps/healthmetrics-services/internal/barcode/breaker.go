@@ -0,0 +1,189 @@
+package barcode
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/openfoodfacts/openfoodfacts-go"
+)
+
+// ErrCircuitOpen is returned instead of calling OpenFoodFacts while the breaker is open.
+var ErrCircuitOpen = errors.New("openfoodfacts circuit open")
+
+// Breaker states (also what /healthz and metrics report).
+const (
+	BreakerClosed   = "closed"    // normal: calls go through
+	BreakerOpen     = "open"      // failing: calls are rejected until OpenTimeout passes
+	BreakerHalfOpen = "half_open" // probing: one call is let through to test recovery
+)
+
+// BreakerConfig controls when the breaker trips and how long it stays open.
+// Example with FailureThreshold=5, OpenTimeout=30s:
+// 5 failed calls in a row -> open for 30s -> one probe call -> success closes, failure re-opens for 30s.
+type BreakerConfig struct {
+	FailureThreshold int           // consecutive failures (errors or timeouts) before opening
+	OpenTimeout      time.Duration // how long to reject calls before probing again
+}
+
+// CircuitBreaker is a small consecutive-failure breaker shared by every OFF lookup in this instance.
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	cfg BreakerConfig
+	now func() time.Time // swapped in tests
+
+	state               string    // closed / open / half_open
+	consecutiveFailures int       // failures since the last success
+	openedAt            time.Time // when we last tripped
+	probeInFlight       bool      // half-open allows a single probe
+
+	opensTotal    uint64 // how many times we tripped
+	rejectedTotal uint64 // calls short-circuited while open
+}
+
+// NewCircuitBreaker applies safe defaults for missing config values.
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	return &CircuitBreaker{cfg: cfg, now: time.Now, state: BreakerClosed}
+}
+
+// Allow reports whether a call may go upstream right now.
+// Open -> half-open happens lazily here once OpenTimeout has passed.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			b.rejectedTotal++
+			return false
+		}
+		b.state = BreakerHalfOpen // time to probe
+		b.probeInFlight = true
+		return true
+	case BreakerHalfOpen:
+		if b.probeInFlight { // someone else is already probing
+			b.rejectedTotal++
+			return false
+		}
+		b.probeInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess closes the breaker and resets the failure streak.
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.consecutiveFailures = 0
+	b.probeInFlight = false
+}
+
+// Abandon releases a half-open probe without judging upstream (the caller cancelled).
+func (b *CircuitBreaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probeInFlight = false
+}
+
+// RecordFailure counts a failure and trips the breaker at the threshold (or on a failed probe).
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveFailures++
+	if b.state == BreakerHalfOpen || b.consecutiveFailures >= b.cfg.FailureThreshold {
+		if b.state != BreakerOpen {
+			b.opensTotal++
+		}
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+	b.probeInFlight = false
+}
+
+// State returns the current state, reporting half_open once the open window has elapsed.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return BreakerHalfOpen // next call will probe
+	}
+	return b.state
+}
+
+// Snapshot returns a stable view of breaker state for health + metrics output.
+func (b *CircuitBreaker) Snapshot() map[string]any {
+	state := b.State()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := map[string]any{
+		"state":                state,
+		"consecutive_failures": b.consecutiveFailures,
+		"failure_threshold":    b.cfg.FailureThreshold,
+		"open_timeout_ms":      b.cfg.OpenTimeout.Milliseconds(),
+		"opens_total":          b.opensTotal,
+		"rejected_total":       b.rejectedTotal,
+	}
+	if !b.openedAt.IsZero() {
+		snapshot["last_opened_at"] = b.openedAt.UTC().Format(time.RFC3339)
+	}
+	return snapshot
+}
+
+// breakerFetcher guards a ProductFetcher with a CircuitBreaker.
+// It also implements LocaleFetcher so locale-scoped lookups share the same breaker.
+type breakerFetcher struct {
+	next    ProductFetcher
+	breaker *CircuitBreaker
+}
+
+// WithCircuitBreaker wraps api so every upstream call goes through breaker.
+func WithCircuitBreaker(api ProductFetcher, breaker *CircuitBreaker) ProductFetcher {
+	return &breakerFetcher{next: api, breaker: breaker}
+}
+
+func (f *breakerFetcher) Product(code string) (*openfoodfacts.Product, error) {
+	return f.ProductContext(context.Background(), code)
+}
+
+func (f *breakerFetcher) ProductContext(ctx context.Context, code string) (*openfoodfacts.Product, error) {
+	if !f.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	product, err := productWithContext(ctx, f.next, code)
+	// A cancelled request says nothing about OFF health; don't count it either way.
+	// (A blown retry budget is different: OFF was too slow, so that still counts.)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		f.breaker.Abandon()
+		return nil, err
+	}
+	// "Product missing" means OFF answered fine; only transport/server failures count against it.
+	if err != nil && !errors.Is(err, openfoodfacts.ErrNoProduct) {
+		f.breaker.RecordFailure()
+		return nil, err
+	}
+	f.breaker.RecordSuccess()
+	return product, err
+}
+
+func (f *breakerFetcher) ForLocale(loc Locale) ProductFetcher {
+	return &breakerFetcher{next: fetcherForLocale(f.next, loc), breaker: f.breaker}
+}
@@ -0,0 +1,140 @@
+package barcode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/openfoodfacts/openfoodfacts-go"
+)
+
+// newTestBreaker returns a breaker with a controllable clock.
+func newTestBreaker(threshold int, openTimeout time.Duration) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(BreakerConfig{FailureThreshold: threshold, OpenTimeout: openTimeout})
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	breaker, now := newTestBreaker(2, 30*time.Second)
+	fetcher := &fakeFetcher{err: errors.New("upstream boom")}
+	guarded := WithCircuitBreaker(fetcher, breaker)
+
+	// Two failures trip the breaker.
+	_, _ = guarded.Product("12345678")
+	_, _ = guarded.Product("12345678")
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("expected open after threshold, got %s", breaker.State())
+	}
+
+	// While open, calls fail fast without touching upstream.
+	if _, err := guarded.Product("12345678"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if fetcher.calls != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", fetcher.calls)
+	}
+
+	// After the open window, one probe goes through; a failed probe re-opens.
+	*now = now.Add(31 * time.Second)
+	if breaker.State() != BreakerHalfOpen {
+		t.Fatalf("expected half_open after timeout, got %s", breaker.State())
+	}
+	_, _ = guarded.Product("12345678")
+	if breaker.State() != BreakerOpen || fetcher.calls != 3 {
+		t.Fatalf("expected failed probe to re-open (calls=%d state=%s)", fetcher.calls, breaker.State())
+	}
+
+	// Next probe succeeds and closes the breaker.
+	*now = now.Add(31 * time.Second)
+	fetcher.err = nil
+	fetcher.product = &openfoodfacts.Product{Id: "id_1"}
+	if _, err := guarded.Product("12345678"); err != nil {
+		t.Fatalf("expected probe success, got %v", err)
+	}
+	if breaker.State() != BreakerClosed {
+		t.Fatalf("expected closed after successful probe, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreaker_NotFoundIsHealthy(t *testing.T) {
+	breaker, _ := newTestBreaker(1, time.Minute)
+	guarded := WithCircuitBreaker(&fakeFetcher{err: openfoodfacts.ErrNoProduct}, breaker)
+
+	// A missing product is a valid answer from OFF, not an outage.
+	if _, err := guarded.Product("12345678"); !errors.Is(err, openfoodfacts.ErrNoProduct) {
+		t.Fatalf("expected ErrNoProduct, got %v", err)
+	}
+	if breaker.State() != BreakerClosed {
+		t.Fatalf("expected breaker to stay closed, got %s", breaker.State())
+	}
+}
+
+func TestHandler_CircuitOpenServesStaleCache(t *testing.T) {
+	breaker, _ := newTestBreaker(1, time.Minute)
+	breaker.RecordFailure() // trip it
+	fetcher := &fakeFetcher{product: &openfoodfacts.Product{Id: "id_1"}}
+	retryCfg := RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second} // would be slow if retried
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{Name: "Old"}, time.Now().Add(-48 * time.Hour), true, nil // stale cached item
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			return nil // no-op cache write
+		},
+	)
+	defer cleanup()
+	router := makeRouter(WithCircuitBreaker(fetcher, breaker), retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil) // 9 digits skips checksum
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if !body.Degraded || body.Name != "Old" {
+		t.Fatalf("expected degraded stale item, got %+v", body)
+	}
+	if fetcher.calls != 0 {
+		t.Fatalf("expected 0 upstream calls, got %d", fetcher.calls)
+	}
+}
+
+func TestHandler_CircuitOpenCacheMissFailsFast(t *testing.T) {
+	breaker, _ := newTestBreaker(1, time.Minute)
+	breaker.RecordFailure() // trip it
+	retryCfg := RetryConfig{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{}, time.Time{}, false, nil // cache miss
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			return nil // no-op cache write
+		},
+	)
+	defer cleanup()
+	router := makeRouter(WithCircuitBreaker(&fakeFetcher{}, breaker), retryCfg, time.Hour)
+
+	start := time.Now()
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	if time.Since(start) > 500*time.Millisecond { // no retry backoff while open
+		t.Fatalf("expected fail-fast, took %s", time.Since(start))
+	}
+}
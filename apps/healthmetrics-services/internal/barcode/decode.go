@@ -0,0 +1,118 @@
+package barcode
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"healthmetrics-services/internal/httpx"
+	"healthmetrics-services/internal/scanner"
+)
+
+// maxImageBytes caps uploads for POST /v1/barcodes/decode (phone photos are ~2-6 MB).
+const maxImageBytes = 10 << 20
+
+// decodeImageFunc is stubbed in tests (the real decoder is covered in internal/scanner).
+var decodeImageFunc = scanner.DecodeBytes
+
+// NewDecodeImageHandler serves POST /v1/barcodes/decode.
+// The image comes either as multipart form field "image" or as a raw image/jpeg|image/png body.
+// The decoded code goes through the same lookup as GET /v1/barcodes/:code.
+func NewDecodeImageHandler(resolver Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, ok := readUploadedImage(c)
+		if !ok {
+			return
+		}
+
+		start := time.Now()
+		result, err := decodeImageFunc(data)
+		requestID := c.GetHeader("X-Request-ID")
+		switch {
+		case errors.Is(err, scanner.ErrUnsupportedImage):
+			httpx.WriteError(c, 400, "INVALID_IMAGE", "Image must be a JPEG or PNG")
+			return
+		case errors.Is(err, scanner.ErrImageTooLarge):
+			httpx.WriteError(c, 413, "IMAGE_TOO_LARGE", "Image dimensions are too large")
+			return
+		case errors.Is(err, scanner.ErrNotFound):
+			log.Printf("barcode_decode request_id=%s found=false bytes=%d duration_ms=%d", requestID, len(data), time.Since(start).Milliseconds())
+			httpx.WriteError(c, 422, "BARCODE_NOT_FOUND", "No readable barcode found in image")
+			return
+		case err != nil:
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to decode image")
+			return
+		}
+		log.Printf("barcode_decode request_id=%s found=true symbology=%s barcode=%s bytes=%d duration_ms=%d",
+			requestID, result.Symbology, result.Code, len(data), time.Since(start).Milliseconds())
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		foodItem, ok := resolver.Resolve(c, pool, result.Code)
+		if !ok {
+			return
+		}
+		c.JSON(200, gin.H{
+			"symbology": result.Symbology, // ean_13, ean_8, upc_a, upc_e or itf
+			"text":      result.Text,      // digits as printed (UPC-E stays 8 digits)
+			"barcode":   result.Code,      // lookup form (UPC-E expanded, ITF-14 indicator 0 dropped)
+			"food_item": foodItem,
+		})
+	}
+}
+
+// readUploadedImage returns the upload bytes, writing a 4xx when the request has no usable image.
+func readUploadedImage(c *gin.Context) ([]byte, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageBytes)
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	var body io.Reader
+	switch {
+	case mediaType == "multipart/form-data":
+		file, _, err := c.Request.FormFile("image")
+		if err != nil {
+			if isBodyTooLarge(err) {
+				httpx.WriteError(c, 413, "IMAGE_TOO_LARGE", "Image must be at most 10 MB")
+				return nil, false
+			}
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "Multipart field \"image\" is required")
+			return nil, false
+		}
+		defer file.Close()
+		body = file
+	case strings.HasPrefix(mediaType, "image/"):
+		body = c.Request.Body
+	default:
+		httpx.WriteError(c, 415, "UNSUPPORTED_MEDIA_TYPE", "Send multipart/form-data with an \"image\" field, or an image/jpeg or image/png body")
+		return nil, false
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if isBodyTooLarge(err) {
+			httpx.WriteError(c, 413, "IMAGE_TOO_LARGE", "Image must be at most 10 MB")
+			return nil, false
+		}
+		httpx.WriteError(c, 400, "INVALID_REQUEST", "Failed to read image")
+		return nil, false
+	}
+	if len(data) == 0 {
+		httpx.WriteError(c, 400, "INVALID_REQUEST", "Image is empty")
+		return nil, false
+	}
+	return data, true
+}
+
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
@@ -0,0 +1,163 @@
+package barcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/openfoodfacts/openfoodfacts-go"
+
+	"healthmetrics-services/internal/scanner"
+)
+
+func newDecodeRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	cleanup := setupCacheStubs(
+		func(_ context.Context, _ *pgxpool.Pool, barcode string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{ID: "food-1", Barcode: barcode, Name: "Scanned Product"}, time.Now(), true, nil
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			return nil
+		},
+	)
+	t.Cleanup(cleanup)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		c.Next()
+	})
+	resolver := Resolver{API: &fakeFetcher{}, Retry: RetryConfig{MaxAttempts: 1}, CacheTTL: time.Hour}
+	router.POST("/v1/barcodes/decode", NewDecodeImageHandler(resolver))
+	return router
+}
+
+func multipartImage(t *testing.T, field string, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, "photo.jpg")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+	return &body, writer.FormDataContentType()
+}
+
+func TestDecodeImageHandler(t *testing.T) {
+	router := newDecodeRouter(t)
+
+	// Real decoder, real photo-like JPEG from the scanner corpus.
+	photo, err := os.ReadFile(filepath.Join("..", "scanner", "testdata", "corpus", "upc_a_036000291452_shadow.jpg"))
+	if err != nil {
+		t.Fatalf("read corpus image: %v", err)
+	}
+	body, contentType := multipartImage(t, "image", photo)
+	req := httptest.NewRequest(http.MethodPost, "/v1/barcodes/decode", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Symbology string   `json:"symbology"`
+		Text      string   `json:"text"`
+		Barcode   string   `json:"barcode"`
+		FoodItem  FoodItem `json:"food_item"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.Symbology != scanner.SymbologyUPCA || resp.Barcode != "036000291452" || resp.FoodItem.Name != "Scanned Product" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	// Cache key is the normalized (EAN-13) form, same as GET /v1/barcodes/:code.
+	if resp.FoodItem.Barcode != "0036000291452" {
+		t.Fatalf("expected lookup by normalized barcode, got %q", resp.FoodItem.Barcode)
+	}
+}
+
+func TestDecodeImageHandler_RawBodyAndUPCE(t *testing.T) {
+	router := newDecodeRouter(t)
+
+	origDecode := decodeImageFunc
+	defer func() { decodeImageFunc = origDecode }()
+	decodeImageFunc = func(data []byte) (scanner.Result, error) {
+		if string(data) != "fake-png" {
+			t.Fatalf("unexpected payload %q", data)
+		}
+		return scanner.Result{Symbology: scanner.SymbologyUPCE, Text: "01234565", Code: "012345000065"}, nil
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/barcodes/decode", bytes.NewBufferString("fake-png"))
+	req.Header.Set("Content-Type", "image/png")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp["symbology"] != "upc_e" || resp["text"] != "01234565" || resp["barcode"] != "012345000065" {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+}
+
+func TestDecodeImageHandler_Errors(t *testing.T) {
+	router := newDecodeRouter(t)
+
+	origDecode := decodeImageFunc
+	defer func() { decodeImageFunc = origDecode }()
+	decodeErr := scanner.ErrNotFound
+	decodeImageFunc = func([]byte) (scanner.Result, error) { return scanner.Result{}, decodeErr }
+
+	post := func(body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/barcodes/decode", body)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	expectCode := func(rec *httptest.ResponseRecorder, status int, code string) {
+		t.Helper()
+		if rec.Code != status {
+			t.Fatalf("expected %d, got %d: %s", status, rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if body.Error.Code != code {
+			t.Fatalf("expected %s, got %s", code, body.Error.Code)
+		}
+	}
+
+	body, contentType := multipartImage(t, "image", []byte("photo"))
+	expectCode(post(body, contentType), 422, "BARCODE_NOT_FOUND")
+
+	decodeErr = scanner.ErrUnsupportedImage
+	body, contentType = multipartImage(t, "image", []byte("photo"))
+	expectCode(post(body, contentType), 400, "INVALID_IMAGE")
+
+	body, contentType = multipartImage(t, "photo", []byte("photo"))
+	expectCode(post(body, contentType), 400, "INVALID_REQUEST")
+
+	expectCode(post(bytes.NewBufferString("{}"), "application/json"), 415, "UNSUPPORTED_MEDIA_TYPE")
+
+	expectCode(post(bytes.NewBuffer(make([]byte, maxImageBytes+1)), "image/jpeg"), 413, "IMAGE_TOO_LARGE")
+}
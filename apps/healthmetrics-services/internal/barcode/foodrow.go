@@ -0,0 +1,95 @@
+package barcode
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FoodRow is the slice of a food_items row that serving and nutrition math needs
+// (diary entries, recipe analysis).
+type FoodRow struct {
+	ID              string
+	Name            string
+	Brand           string
+	Barcode         string
+	ServingSizeG    float64
+	ServingSizeUnit string
+	Per100g         FoodItemNutrients // sodium in g, like the barcode API
+}
+
+// FoodRowColumns selects a FoodRow from food_items (read it back with ScanFoodRow).
+const FoodRowColumns = `
+	id,
+	name,
+	COALESCE(brand, ''),
+	COALESCE(barcode, ''),
+	serving_size_g::float8,
+	COALESCE(serving_size_unit, ''),
+	calories_per_100g::float8,
+	protein_g::float8,
+	carbs_g::float8,
+	fat_g::float8,
+	fiber_g::float8,
+	sugar_g::float8,
+	(sodium_mg / 1000.0)::float8
+`
+
+// ScanFoodRow reads one FoodRowColumns row.
+func ScanFoodRow(row pgx.Row) (FoodRow, error) {
+	var food FoodRow
+	var fiber, sugar, sodium sql.NullFloat64
+	err := row.Scan(
+		&food.ID,
+		&food.Name,
+		&food.Brand,
+		&food.Barcode,
+		&food.ServingSizeG,
+		&food.ServingSizeUnit,
+		&food.Per100g.CaloriesKcal,
+		&food.Per100g.ProteinG,
+		&food.Per100g.CarbsG,
+		&food.Per100g.FatG,
+		&fiber,
+		&sugar,
+		&sodium,
+	)
+	if err != nil {
+		return FoodRow{}, err
+	}
+	food.Per100g.FiberG = NullFloat64ToPtr(fiber)
+	food.Per100g.SugarG = NullFloat64ToPtr(sugar)
+	food.Per100g.SodiumG = NullFloat64ToPtr(sodium)
+	return food, nil
+}
+
+// GetFoodRowByID loads a food item by id (found=false when missing).
+// An id merged into another row (duplicate cleanup) loads the surviving row.
+func GetFoodRowByID(ctx context.Context, pool *pgxpool.Pool, id string) (FoodRow, bool, error) {
+	query := `SELECT ` + FoodRowColumns + ` FROM food_items
+		WHERE id = (SELECT COALESCE(merged_into_id, id) FROM food_items WHERE id = $1)`
+	return getFoodRow(pool.QueryRow(ctx, query, id))
+}
+
+// GetFoodRowByBarcode loads a cached food item by (normalized) barcode.
+// A merged duplicate's barcode loads the surviving row.
+func GetFoodRowByBarcode(ctx context.Context, pool *pgxpool.Pool, code string) (FoodRow, bool, error) {
+	query := `SELECT ` + FoodRowColumns + ` FROM food_items
+		WHERE id = (SELECT COALESCE(merged_into_id, id) FROM food_items WHERE barcode = $1)`
+	return getFoodRow(pool.QueryRow(ctx, query, code))
+}
+
+func getFoodRow(row pgx.Row) (FoodRow, bool, error) {
+	food, err := ScanFoodRow(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return FoodRow{}, false, nil
+		}
+		return FoodRow{}, false, fmt.Errorf("query food_items: %w", err)
+	}
+	return food, true, nil
+}
@@ -1,17 +1,25 @@
 package barcode
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net"
+	"net/http"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/openfoodfacts/openfoodfacts-go"
+
+	"healthmetrics-services/internal/httpx"
+	"healthmetrics-services/internal/retry"
+	"healthmetrics-services/internal/units"
 )
 
 type FoodItemNutrients struct {
@@ -32,33 +40,65 @@ type FoodItem struct {
 	ServingSize string            `json:"serving_size"`
 	Nutrients   FoodItemNutrients `json:"nutrients"`
 	ImageUrl    string            `json:"image_url"`
+	Ingredients string            `json:"ingredients,omitempty"`  // localized ingredients text (when known)
+	Language    string            `json:"language,omitempty"`     // language of name/ingredients ("en", "fr")
+	Degraded    bool              `json:"degraded,omitempty"`     // served from cache (maybe stale) while OFF is unavailable
+	DataQuality *DataQuality      `json:"data_quality,omitempty"` // plausibility verdict (nil for rows cached before validation)
+	Categories  []string          `json:"categories,omitempty"`   // OFF category tags, general -> specific ("en:breakfast-cereals")
+	Nutriscore  string            `json:"nutriscore,omitempty"`   // Nutri-Score grade "a".."e" (empty when unknown)
+	NovaGroup   int               `json:"nova_group,omitempty"`   // NOVA processing group 1..4 (0 when unknown)
+
+	// Unit-system view of the serving (see internal/units); serving_size stays the upstream text.
+	Units              string          `json:"units,omitempty"`                // "metric" or "imperial"
+	ServingSizeDisplay *units.Quantity `json:"serving_size_display,omitempty"` // nil when the serving has no g/ml amount
+
+	merged bool // the scanned barcode's row was merged into this one (cache reads only)
+	// OFF country database the cached nutrients came from (cache reads only). "" with
+	// fromUpstream=true is a row cached before countries were recorded.
+	country      string
+	fromUpstream bool // cached row is an OpenFoodFacts copy (not a user contribution)
 }
 
-// baseDelay = the starting wait time before the first retry. It sets how quickly you retry after the first failure.
-// maxDelay = the cap on exponential backoff. As retries keep failing, the delay doubles until it hits this ceiling.
+// RetryConfig is the shared retry policy (see internal/retry).
+// baseDelay = the backoff ceiling for the first retry; the real wait is a random value below it (full jitter).
+// maxDelay = the cap on the exponential ceiling. As retries keep failing, the ceiling doubles until it hits this.
+// budget = the total time for all attempts + waits, so a lookup never hangs for MaxAttempts * timeout.
 // Example with baseDelay=200ms, maxDelay=2s:
-// attempt 1 fails → wait 200ms
-// attempt 2 fails → wait 400ms
-// attempt 3 fails → wait 800ms
-// attempt 4 fails → wait 1.6s
-// attempt 5 fails → would be 3.2s, but we cap at 2s
-type RetryConfig struct {
-	MaxAttempts int
-	BaseDelay   time.Duration
-	MaxDelay    time.Duration
-}
+// attempt 1 fails → wait rand(0..200ms)
+// attempt 2 fails → wait rand(0..400ms)
+// attempt 3 fails (429, Retry-After: 1) → wait 1s (server hint wins)
+type RetryConfig = retry.Policy
 
 // ProductFetcher lets us swap the upstream client in tests (real client in prod, fake in tests)
 type ProductFetcher interface {
 	Product(code string) (*openfoodfacts.Product, error)
 }
 
+// ContextFetcher is implemented by fetchers that can abandon a request when ctx ends.
+// The handler checks for it (like LocaleFetcher) so plain ProductFetchers keep working.
+type ContextFetcher interface {
+	ProductContext(ctx context.Context, code string) (*openfoodfacts.Product, error)
+}
+
+// productWithContext calls the context-aware method when the fetcher has one.
+func productWithContext(ctx context.Context, api ProductFetcher, code string) (*openfoodfacts.Product, error) {
+	if withContext, ok := api.(ContextFetcher); ok {
+		return withContext.ProductContext(ctx, code)
+	}
+	return api.Product(code)
+}
+
 var digitOnlyRegex = regexp.MustCompile("^[0-9]+$")
 
 // Allow tests to swap DB helpers without changing production logic.
 var (
 	getFoodItemByBarcodeFunc = getFoodItemByBarcode // default: real DB fetch
 	upsertFoodItemFunc       = upsertFoodItem       // default: real DB write
+	quarantineFoodItemFunc   = quarantineFoodItem   // default: real DB write
+	hasContributionFunc      = hasContribution      // default: real DB fetch
+
+	getFoodItemLocalizationFunc    = getFoodItemLocalization    // default: real DB fetch
+	upsertFoodItemLocalizationFunc = upsertFoodItemLocalization // default: real DB write
 )
 
 // normalizeBarcode ensures a single canonical key for cache and DB storage.
@@ -72,6 +112,11 @@ func normalizeBarcode(code string) string {
 	return code
 }
 
+// NormalizeBarcode is normalizeBarcode for other packages resolving barcodes against food_items.
+func NormalizeBarcode(code string) string {
+	return normalizeBarcode(code)
+}
+
 func supportsChecksum(length int) bool {
 	switch length {
 	case 8, 12, 13, 14:
@@ -108,76 +153,25 @@ func isValidChecksum(code string) bool {
 	return checkDigit == int(code[len(code)-1]-'0')
 }
 
-func fetchProductWithRetry(api ProductFetcher, code string, cfg RetryConfig) (*openfoodfacts.Product, error) {
-	// Goal: call OpenFoodFacts with retry + exponential backoff for transient errors.
-	// "Transient" = network/server errors (not "product missing").
+func fetchProductWithRetry(ctx context.Context, api ProductFetcher, code string, cfg RetryConfig) (*openfoodfacts.Product, error) {
+	// Goal: call OpenFoodFacts with retry + jittered backoff for transient errors.
+	// "Transient" = timeouts, dropped connections, 429/5xx (see retry.IsTransient).
 	// Example (MaxAttempts=3, BaseDelay=200ms, MaxDelay=2s):
-	// - attempt 1: timeout -> wait 200ms
-	// - attempt 2: 502 error -> wait 400ms
+	// - attempt 1: timeout -> wait rand(0..200ms)
+	// - attempt 2: 503 Retry-After: 1 -> wait 1s
 	// - attempt 3: success -> return product
-	// If ErrNoProduct happens on attempt 1, return immediately (no retries).
-
-	// Start with the config values (these may be zero if not set).
-	maxAttempts := cfg.MaxAttempts
-	if maxAttempts < 1 { // ensure we always try at least once
-		maxAttempts = 1
+	// ErrNoProduct (product missing) and ErrCircuitOpen (breaker already tripped) are not
+	// transient, so they return after attempt 1. A cancelled request stops retrying right away.
+	var product *openfoodfacts.Product
+	err := cfg.Do(ctx, func(ctx context.Context) error {
+		var err error
+		product, err = productWithContext(ctx, api, code)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	// Base delay is the first backoff duration before retrying.
-	baseDelay := cfg.BaseDelay
-	if baseDelay <= 0 { // use a safe default if not provided
-		baseDelay = 200 * time.Millisecond
-	}
-
-	// Max delay is the cap for exponential backoff.
-	maxDelay := cfg.MaxDelay
-	if maxDelay <= 0 { // use a safe default if not provided
-		maxDelay = 2 * time.Second
-	}
-
-	var lastErr error // keep the last error so we can return it after retries
-	// attempt starts at 1 so "attempt 1" means the very first call (no wait).
-	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		// Timeline example with MaxAttempts=3, BaseDelay=200ms:
-		// t=0ms attempt1 -> error -> sleep 200ms
-		// t=200ms attempt2 -> error -> sleep 400ms
-		// t=600ms attempt3 -> success -> return
-		// Try to fetch the product from OpenFoodFacts.
-		product, err := api.Product(code)
-		if err == nil { // success path
-			return product, nil
-		}
-		// ErrNoProduct is not transient; do not retry it.
-		if errors.Is(err, openfoodfacts.ErrNoProduct) {
-			return nil, err
-		}
-
-		// Keep the last error so we can return it if all attempts fail.
-		lastErr = err
-		if attempt == maxAttempts { // no retries left
-			break
-		}
-
-		// Exponential backoff: baseDelay * 2^(attempt-1), capped at maxDelay.
-		// attempt=1 -> delay=baseDelay
-		// attempt=2 -> delay=baseDelay*2
-		// attempt=3 -> delay=baseDelay*4
-		delay := baseDelay
-		for i := 1; i < attempt; i++ { // double per attempt
-			if delay >= maxDelay/2 { // avoid overflow and cap cleanly
-				delay = maxDelay
-				break
-			}
-			delay *= 2
-		}
-		if delay > maxDelay { // final safety cap
-			delay = maxDelay
-		}
-		// Wait before retrying to avoid hammering the upstream API.
-		time.Sleep(delay)
-	}
-
-	return nil, lastErr // return the final error if all retries fail
+	return product, nil
 }
 
 func mapProductToFoodItem(product *openfoodfacts.Product) FoodItem {
@@ -197,10 +191,97 @@ func mapProductToFoodItem(product *openfoodfacts.Product) FoodItem {
 			SugarG:       floatOrNil(product.Nutriments.Sugars100G), // per-100g sugars (g) or null
 			SodiumG:      floatOrNil(product.Nutriments.Sodium100G), // per-100g sodium (g) or null
 		},
-		ImageUrl: product.ImageURL.String(), // upstream image URL (may be empty)
+		ImageUrl:    product.ImageURL.String(),                        // upstream image URL (may be empty)
+		Ingredients: product.IngredientsText,                          // localized ingredients (may be empty)
+		Language:    product.Lang,                                     // language the name came back in
+		Categories:  product.CategoriesTags,                           // category tags (drive alternatives)
+		Nutriscore:  normalizeNutriscore(product.NutritionGrades),     // "a".."e" or ""
+		NovaGroup:   normalizeNovaGroup(product.Nutriments.NovaGroup), // 1..4 or 0
 	}
 }
 
+// normalizeNutriscore keeps real grades only (OFF also sends "unknown" / "not-applicable").
+func normalizeNutriscore(grade string) string {
+	grade = strings.ToLower(strings.TrimSpace(grade))
+	if len(grade) == 1 && grade >= "a" && grade <= "e" {
+		return grade
+	}
+	return ""
+}
+
+// normalizeNovaGroup keeps NOVA groups 1..4 (0 = unknown).
+func normalizeNovaGroup(group float64) int {
+	if group >= 1 && group <= 4 && group == math.Trunc(group) {
+		return int(group)
+	}
+	return 0
+}
+
+// statusClientClosedRequest is nginx's non-standard 499: the client gave up before we answered.
+const statusClientClosedRequest = 499
+
+// applyLocalization swaps in the cached localized name for the requested language.
+// Fallback chain: requested language -> English -> base food_items.name (world).
+// Returns whether the requested language itself has a row (a cached fallback counts), so the
+// caller knows if that language was never fetched from OFF.
+func applyLocalization(ctx context.Context, pool *pgxpool.Pool, item *FoodItem, language string) (bool, error) {
+	languages := []string{language}
+	if language != defaultLocale.Language {
+		languages = append(languages, defaultLocale.Language)
+	}
+
+	for _, lang := range languages {
+		name, ingredients, contentLanguage, found, err := getFoodItemLocalizationFunc(ctx, pool, item.ID, lang)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			item.Name = name
+			item.Ingredients = ingredients
+			item.Language = contentLanguage
+			return lang == language, nil
+		}
+	}
+
+	return false, nil // keep the base (world) name
+}
+
+// cacheLocalization stores the localized name OFF returned for a request in this language.
+// product.Lang is the language we actually got; when OFF had no translation it differs from
+// the requested language, and the fallback is cached under the requested language too.
+// Example: requested "de", OFF only has French -> rows (fr: fr name) and (de: fr name, content fr).
+func cacheLocalization(ctx context.Context, pool *pgxpool.Pool, barcode string, language string, product *openfoodfacts.Product) error {
+	if err := upsertFoodItemLocalizationFunc(ctx, pool, barcode, product.Lang, product.Lang, product.ProductName, product.IngredientsText); err != nil {
+		return err
+	}
+	if language == product.Lang {
+		return nil
+	}
+	return upsertFoodItemLocalizationFunc(ctx, pool, barcode, language, product.Lang, product.ProductName, product.IngredientsText)
+}
+
+// fetchLocalization fills in a language the cache has never seen for a fresh cached item.
+// Only the name/ingredients are taken from the response: the cached nutrients are already
+// this country's (lookupItem refetches the whole product when the country differs).
+// Best-effort: on any upstream error the cached item is served as it is.
+func (r Resolver) fetchLocalization(c *gin.Context, pool *pgxpool.Pool, item *FoodItem, barcode string, locale Locale) {
+	requestID := c.GetHeader("X-Request-ID")
+	product, err := fetchProductWithRetry(c.Request.Context(), fetcherForLocale(r.API, locale), barcode, r.Retry)
+	if err != nil {
+		log.Printf("localization_fetch_error request_id=%s barcode=%s locale=%s type=%s err=%v", requestID, barcode, locale, classifyUpstreamError(err), err)
+		return
+	}
+	if product == nil || product.ProductName == "" {
+		return
+	}
+	if err := cacheLocalization(c.Request.Context(), pool, barcode, locale.Language, product); err != nil {
+		log.Printf("cache_write_error request_id=%s barcode=%s locale=%s err=%v", requestID, barcode, locale, err)
+	}
+	item.Name = product.ProductName
+	item.Ingredients = product.IngredientsText
+	item.Language = product.Lang
+}
+
 func floatOrNil(value float64) *float64 {
 	// Treat zero as "missing" for optional nutrients (matches FE null handling).
 	if value == 0 {
@@ -216,6 +297,16 @@ func classifyUpstreamError(err error) string {
 	if errors.Is(err, openfoodfacts.ErrNoProduct) {
 		return "not_found"
 	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return "circuit_open"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	var httpErr *retry.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return "rate_limited"
+	}
 	var netErr net.Error
 	if errors.As(err, &netErr) && netErr.Timeout() {
 		return "timeout"
@@ -231,94 +322,217 @@ func classifyUpstreamError(err error) string {
 	return "upstream_error"
 }
 
-func writeError(c *gin.Context, status int, code string, message string) {
-	// Helper to keep error responses consistent across the handler.
-	c.JSON(status, gin.H{"error": map[string]interface{}{ // wrap error in a predictable envelope
-		"code":    code,    // short error code for programmatic checks
-		"message": message, // human-readable message for debugging/UI
-	}})
+// validateBarcode checks format + checksum and writes a 400 when the barcode is invalid.
+func validateBarcode(c *gin.Context, barcode string) bool {
+	if message := BarcodeError(barcode); message != "" {
+		httpx.WriteError(c, 400, "INVALID_BARCODE", message)
+		return false
+	}
+	return true
+}
+
+// BarcodeError returns "" for a valid barcode, otherwise the INVALID_BARCODE message.
+// Other packages use it to validate barcodes in request bodies the same way as the URL param.
+func BarcodeError(barcode string) string {
+	isBarCodeValid := len(barcode) >= 8 && len(barcode) <= 14 && digitOnlyRegex.MatchString(barcode)
+
+	if !isBarCodeValid {
+		return "Barcode must be 8-14 digits" // invalid format
+	}
+	if supportsChecksum(len(barcode)) && !isValidChecksum(barcode) {
+		return "Invalid barcode checksum" // checksum failed
+	}
+	return ""
+}
+
+// Resolver is the barcode lookup shared by every endpoint that takes a barcode
+// (GET /v1/barcodes/:code, POST /v1/diary/entries):
+// cache (food_items) -> OpenFoodFacts -> validate -> cache write.
+type Resolver struct {
+	API      ProductFetcher
+	Retry    RetryConfig
+	CacheTTL time.Duration
+}
+
+// Resolve validates and looks up a barcode.
+// Like validateBarcode, it writes the error response itself; ok=false means stop handling
+// (the response is written, or the client went away).
+func (r Resolver) Resolve(c *gin.Context, pool *pgxpool.Pool, barcode string) (FoodItem, bool) {
+	if !validateBarcode(c, barcode) {
+		return FoodItem{}, false
+	}
+	return r.lookup(c, pool, barcode)
 }
 
 func NewHandler(api ProductFetcher, retryCfg RetryConfig, cacheTTL time.Duration) gin.HandlerFunc {
+	resolver := Resolver{API: api, Retry: retryCfg, CacheTTL: cacheTTL}
+
 	return func(c *gin.Context) {
 		// This comes from the frontend when the user scans a barcode
 		barcode := c.Param("code") // raw barcode from the URL
-		isBarCodeValid := len(barcode) >= 8 && len(barcode) <= 14 && digitOnlyRegex.MatchString(barcode)
-
-		if !isBarCodeValid {
-			writeError(c, 400, "INVALID_BARCODE", "Barcode must be 8-14 digits") // invalid format
-			return
-		}
-		if supportsChecksum(len(barcode)) && !isValidChecksum(barcode) {
-			writeError(c, 400, "INVALID_BARCODE", "Invalid barcode checksum") // checksum failed
+		if !validateBarcode(c, barcode) {
 			return
 		}
 
-		normalizedBarcode := normalizeBarcode(barcode) // normalize to a consistent cache key
-
-		// Pull the DB pool out of Gin context (set in main.go)
-		poolValue, ok := c.Get("db")
+		pool, ok := httpx.PoolFromContext(c)
 		if !ok {
-			writeError(c, 500, "INTERNAL_ERROR", "Database not configured")
 			return
 		}
 
-		pool, ok := poolValue.(*pgxpool.Pool)
-		if !ok || pool == nil {
-			writeError(c, 500, "INTERNAL_ERROR", "Invalid database handle")
+		foodItem, ok := resolver.lookup(c, pool, barcode)
+		if !ok {
 			return
 		}
+		c.JSON(200, foodItem)
+	}
+}
 
-		// Look for a cached food item
-		cachedItem, updatedAt, found, err := getFoodItemByBarcodeFunc(c.Request.Context(), pool, normalizedBarcode)
-		if err != nil {
-			writeError(c, 500, "INTERNAL_ERROR", "Failed to load cached item")
-			return
-		}
+// lookup is Resolve for an already-validated barcode, with display units for this request.
+func (r Resolver) lookup(c *gin.Context, pool *pgxpool.Pool, barcode string) (FoodItem, bool) {
+	item, ok := r.lookupItem(c, pool, barcode)
+	if ok {
+		applyUnits(&item, units.Resolve(c, pool))
+	}
+	return item, ok
+}
 
-		if found {
-			// If updated_at is within the TTL window, serve the cached item.
-			if time.Since(updatedAt) <= cacheTTL {
-				c.JSON(200, cachedItem)
-				return
+// lookupItem resolves the food item itself.
+// Cached rows are served while fresh; stale/missing rows are refetched from OpenFoodFacts,
+// and a known-down upstream serves the stale row instead (marked Degraded).
+func (r Resolver) lookupItem(c *gin.Context, pool *pgxpool.Pool, barcode string) (FoodItem, bool) {
+	normalizedBarcode := normalizeBarcode(barcode) // normalize to a consistent cache key
+
+	// Pick the language/country for this request (headers -> profile -> world/English).
+	locale := resolveLocale(c, pool)
+
+	// Look for a cached food item
+	cachedItem, updatedAt, found, err := getFoodItemByBarcodeFunc(c.Request.Context(), pool, normalizedBarcode)
+	if err != nil {
+		httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load cached item")
+		return FoodItem{}, false
+	}
+
+	// The cached nutrients are another country's formulation (one row per barcode):
+	// refetch them for this country even inside the TTL.
+	// Example: row cached from us.openfoodfacts.org, request resolves to "gb" -> refetch from gb.
+	countryChanged := found && cachedItem.fromUpstream && cachedItem.country != locale.Country
+
+	if found {
+		// If updated_at is within the TTL window, serve the cached item. A merged barcode always
+		// serves its survivor: a refetch would refresh the duplicate row (ON CONFLICT barcode)
+		// and answer with the raw OFF product instead of the merged one. The survivor is
+		// refreshed when its own barcode is scanned.
+		if (time.Since(updatedAt) <= r.CacheTTL && !countryChanged) || cachedItem.merged {
+			// Best-effort: a failed localization lookup still serves the world name.
+			localized, err := applyLocalization(c.Request.Context(), pool, &cachedItem, locale.Language)
+			if err != nil {
+				requestID := c.GetHeader("X-Request-ID")
+				log.Printf("cache_read_error request_id=%s barcode=%s locale=%s err=%v", requestID, barcode, locale, err)
+			} else if !localized && locale.Language != defaultLocale.Language {
+				// First request in this language: the row was cached by someone else's locale.
+				r.fetchLocalization(c, pool, &cachedItem, normalizedBarcode, locale)
 			}
-			// Cache is stale -> fall through to upstream fetch.
+			return cachedItem, true
 		}
+		// Cache is stale (or for another country) -> fall through to upstream fetch.
+	}
 
-		// Make external API call to OpenFoodFacts
-		product, err := fetchProductWithRetry(api, normalizedBarcode, retryCfg)
-		if err != nil {
-			// Log a simple upstream error classification for debugging.
-			errorType := classifyUpstreamError(err)                                              // timeout/parse_error/upstream_error
-			requestID := c.GetHeader("X-Request-ID")                                             // request ID for log correlation
-			log.Printf("upstream_error request_id=%s type=%s err=%v", requestID, errorType, err) // log classification for debugging
-			// ErrNoProduct is a sentinel error value (errors.New), so use errors.Is to detect it even if the library wraps the error.
-			// ErrNoProduct is an error returned by Client.Product when the product could not be retrieved successfully.
-			if errors.Is(err, openfoodfacts.ErrNoProduct) {
-				writeError(c, 404, "NOT_FOUND", "Product not found") // upstream returned no product
-				return
+	// Make external API call to OpenFoodFacts
+	// (user's country database + language; falls back to world/English upstream).
+	product, err := fetchProductWithRetry(c.Request.Context(), fetcherForLocale(r.API, locale), normalizedBarcode, r.Retry)
+	if err != nil {
+		// Log a simple upstream error classification for debugging.
+		errorType := classifyUpstreamError(err)                                              // timeout/parse_error/upstream_error
+		requestID := c.GetHeader("X-Request-ID")                                             // request ID for log correlation
+		log.Printf("upstream_error request_id=%s type=%s err=%v", requestID, errorType, err) // log classification for debugging
+		// ErrNoProduct is a sentinel error value (errors.New), so use errors.Is to detect it even if the library wraps the error.
+		// ErrNoProduct is an error returned by Client.Product when the product could not be retrieved successfully.
+		if errors.Is(err, openfoodfacts.ErrNoProduct) {
+			// Our row can be ahead of OFF (an approved user contribution still waiting for
+			// write-back, or for OFF's moderation), so that stale row beats a 404. Any other
+			// row was OFF's own data, and OFF has since removed the product.
+			contributed := false
+			if found {
+				if contributed, err = hasContributionFunc(c.Request.Context(), pool, normalizedBarcode); err != nil {
+					log.Printf("cache_read_error request_id=%s barcode=%s err=%v", requestID, barcode, err)
+				}
 			}
-			writeError(c, 502, "UPSTREAM_ERROR", fmt.Sprintf("Failed to fetch product from OpenFoodFacts: %v", err))
-			return
+			if contributed {
+				if _, err := applyLocalization(c.Request.Context(), pool, &cachedItem, locale.Language); err != nil {
+					log.Printf("cache_read_error request_id=%s barcode=%s locale=%s err=%v", requestID, barcode, locale, err)
+				}
+				return cachedItem, true
+			}
+			httpx.WriteError(c, 404, "NOT_FOUND", "Product not found") // upstream returned no product
+			return FoodItem{}, false
 		}
-		if product == nil {
-			writeError(c, 500, "INTERNAL_ERROR", "Unexpected empty product") // safety guard
-			return
+		// The client went away mid-lookup: nobody is listening, so skip the response body.
+		// The status still lands in access logs/metrics (without it Gin records a 200).
+		if errors.Is(err, context.Canceled) {
+			c.AbortWithStatus(statusClientClosedRequest)
+			return FoodItem{}, false
 		}
+		// Degraded mode: OFF is known to be down, so serve whatever we have cached (even stale).
+		// Same when only the country changed: the cached row is fresh, just not this country's.
+		if errors.Is(err, ErrCircuitOpen) || countryChanged {
+			if found {
+				if _, err := applyLocalization(c.Request.Context(), pool, &cachedItem, locale.Language); err != nil {
+					log.Printf("cache_read_error request_id=%s barcode=%s locale=%s err=%v", requestID, barcode, locale, err)
+				}
+				cachedItem.Degraded = true
+				c.Header("Warning", `110 - "Response is stale"`) // RFC 7234 stale warning for caches/clients
+				return cachedItem, true
+			}
+			httpx.WriteError(c, 503, "UPSTREAM_UNAVAILABLE", "OpenFoodFacts is temporarily unavailable")
+			return FoodItem{}, false
+		}
+		httpx.WriteError(c, 502, "UPSTREAM_ERROR", fmt.Sprintf("Failed to fetch product from OpenFoodFacts: %v", err))
+		return FoodItem{}, false
+	}
+	if product == nil {
+		httpx.WriteError(c, 500, "INTERNAL_ERROR", "Unexpected empty product") // safety guard
+		return FoodItem{}, false
+	}
 
-		// Parse serving size for DB storage (fallback to 100g if unclear).
-		servingSizeG, servingSizeUnit := parseServingSize(product.ServingSize)
-
-		// Best-effort cache write: log and continue on error.
-		if err := upsertFoodItemFunc(c.Request.Context(), pool, product, normalizedBarcode, servingSizeG, servingSizeUnit); err != nil {
-			requestID := c.GetHeader("X-Request-ID")
-			log.Printf("cache_write_error request_id=%s barcode=%s err=%v", requestID, barcode, err)
+	// Parse serving size for DB storage (fallback to 100g if unclear).
+	servingSizeG, servingSizeUnit := parseServingSize(product.ServingSize)
+
+	// Score the upstream data (and fix obvious unit errors) before it can reach the cache.
+	quality := validateProductNutrition(product)
+	if !quality.Cacheable() {
+		// Clearly invalid: don't cache it for a week; park it for review.
+		requestID := c.GetHeader("X-Request-ID")
+		log.Printf("data_quality_rejected request_id=%s barcode=%s score=%d flags=%v", requestID, barcode, quality.Score, quality.Flags)
+		if err := quarantineFoodItemFunc(c.Request.Context(), pool, product, normalizedBarcode, quality); err != nil {
+			log.Printf("quarantine_write_error request_id=%s barcode=%s err=%v", requestID, barcode, err)
 		}
+		// Don't serve impossible numbers either (a diary entry would log them). A stale
+		// cached row passed validation when it was written, so it beats nothing.
+		if found {
+			if _, err := applyLocalization(c.Request.Context(), pool, &cachedItem, locale.Language); err != nil {
+				log.Printf("cache_read_error request_id=%s barcode=%s locale=%s err=%v", requestID, barcode, locale, err)
+			}
+			cachedItem.Degraded = true
+			c.Header("Warning", `110 - "Response is stale"`) // RFC 7234 stale warning for caches/clients
+			return cachedItem, true
+		}
+		httpx.WriteError(c, 422, "INVALID_PRODUCT_DATA", "OpenFoodFacts nutrition data failed validation: "+strings.Join(quality.Flags, ", "))
+		return FoodItem{}, false
+	}
+	if err := upsertFoodItemFunc(c.Request.Context(), pool, product, normalizedBarcode, locale.Country, servingSizeG, servingSizeUnit, quality); err != nil {
+		// Best-effort cache write: log and continue on error.
+		requestID := c.GetHeader("X-Request-ID")
+		log.Printf("cache_write_error request_id=%s barcode=%s err=%v", requestID, barcode, err)
+	} else if err := cacheLocalization(c.Request.Context(), pool, normalizedBarcode, locale.Language, product); err != nil {
+		// Localized names are a cache too: log and continue.
+		requestID := c.GetHeader("X-Request-ID")
+		log.Printf("cache_write_error request_id=%s barcode=%s locale=%s err=%v", requestID, barcode, locale, err)
+	}
 
-		// Need to transform the OpenFoodFacts response into the FoodItem struct.
-		foodItem := mapProductToFoodItem(product)
-		foodItem.Barcode = normalizedBarcode // return the canonical barcode format
+	// Need to transform the OpenFoodFacts response into the FoodItem struct.
+	foodItem := mapProductToFoodItem(product)
+	foodItem.Barcode = normalizedBarcode // return the canonical barcode format
+	foodItem.DataQuality = &quality      // lets the UI show a data warning
 
-		c.JSON(200, foodItem)
-	}
+	return foodItem, true
 }
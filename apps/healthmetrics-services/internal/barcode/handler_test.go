@@ -12,6 +12,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/openfoodfacts/openfoodfacts-go"
+
+	"healthmetrics-services/internal/retry"
 )
 
 // fakeFetcher returns a fixed product/error and tracks how many times it was called.
@@ -30,15 +32,35 @@ func (f *fakeFetcher) Product(code string) (*openfoodfacts.Product, error) {
 // setupCacheStubs swaps cache helpers for tests and returns a cleanup function.
 func setupCacheStubs(
 	getFn func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error), // fake cache read
-	upsertFn func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, float64, string) error, // fake cache write
+	upsertFn func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error, // fake cache write
 ) func() {
 	origGet := getFoodItemByBarcodeFunc     // keep the real function
 	origUpsert := upsertFoodItemFunc        // keep the real function
+	origGetLocalization := getFoodItemLocalizationFunc       // keep the real function
+	origUpsertLocalization := upsertFoodItemLocalizationFunc // keep the real function
+	origQuarantine := quarantineFoodItemFunc                 // keep the real function
+	origHasContribution := hasContributionFunc               // keep the real function
 	getFoodItemByBarcodeFunc = getFn        // install test stub
 	upsertFoodItemFunc = upsertFn           // install test stub
+	getFoodItemLocalizationFunc = func(context.Context, *pgxpool.Pool, string, string) (string, string, string, bool, error) {
+		return "", "", "", false, nil // no localized names cached
+	}
+	upsertFoodItemLocalizationFunc = func(context.Context, *pgxpool.Pool, string, string, string, string, string) error {
+		return nil // no-op localized cache write
+	}
+	quarantineFoodItemFunc = func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, DataQuality) error {
+		return nil // no-op quarantine write
+	}
+	hasContributionFunc = func(context.Context, *pgxpool.Pool, string) (bool, error) {
+		return false, nil // cached rows are OFF data unless a test says otherwise
+	}
 	return func() {                         // return a cleanup func
 		getFoodItemByBarcodeFunc = origGet // restore real fetcher
 		upsertFoodItemFunc = origUpsert    // restore real upsert
+		getFoodItemLocalizationFunc = origGetLocalization       // restore real localized fetch
+		upsertFoodItemLocalizationFunc = origUpsertLocalization // restore real localized upsert
+		quarantineFoodItemFunc = origQuarantine                 // restore real quarantine write
+		hasContributionFunc = origHasContribution               // restore real contribution check
 	}
 }
 
@@ -137,7 +159,7 @@ func TestHandler_UpstreamNotFound(t *testing.T) {
 		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
 			return FoodItem{}, time.Time{}, false, nil // cache miss
 		},
-		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, float64, string) error {
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
 			return nil // no-op cache write
 		},
 	)
@@ -162,7 +184,7 @@ func TestHandler_UpstreamError(t *testing.T) {
 		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
 			return FoodItem{}, time.Time{}, false, nil // cache miss
 		},
-		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, float64, string) error {
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
 			return nil // no-op cache write
 		},
 	)
@@ -191,7 +213,7 @@ func TestHandler_UpstreamSuccess(t *testing.T) {
 		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
 			return FoodItem{}, time.Time{}, false, nil // cache miss
 		},
-		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, float64, string) error {
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
 			return nil // no-op cache write
 		},
 	)
@@ -243,8 +265,8 @@ func TestFetchProductWithRetry_RetriesThenSucceeds(t *testing.T) {
 	// First two calls fail, third succeeds.
 	fetcher := &scriptedFetcher{
 		results: []fetchResult{
-			{err: errors.New("timeout")},
-			{err: errors.New("timeout")},
+			{err: &retry.HTTPError{Service: "openfoodfacts", StatusCode: 503, Status: "503 Service Unavailable"}},
+			{err: &retry.HTTPError{Service: "openfoodfacts", StatusCode: 502, Status: "502 Bad Gateway"}},
 			{product: &openfoodfacts.Product{Id: "id_1"}},
 		},
 	}
@@ -256,7 +278,7 @@ func TestFetchProductWithRetry_RetriesThenSucceeds(t *testing.T) {
 		MaxDelay:    time.Millisecond,
 	}
 
-	product, err := fetchProductWithRetry(fetcher, "12345678", cfg)
+	product, err := fetchProductWithRetry(context.Background(), fetcher, "12345678", cfg)
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
@@ -279,7 +301,7 @@ func TestHandler_CacheHitFresh(t *testing.T) {
 		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
 			return cached, updatedAt, true, nil // return a fresh cached item
 		},
-		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, float64, string) error {
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
 			return nil // no-op cache write
 		},
 	)
@@ -309,7 +331,7 @@ func TestHandler_CacheHitStale(t *testing.T) {
 		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
 			return FoodItem{Name: "Old"}, updatedAt, true, nil // stale cached item
 		},
-		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, float64, string) error {
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
 			upsertCalls++ // record that we attempted a cache write
 			return nil    // no-op cache write
 		},
@@ -332,6 +354,39 @@ func TestHandler_CacheHitStale(t *testing.T) {
 	}
 }
 
+func TestHandler_MergedBarcodePastTTLServesSurvivor(t *testing.T) {
+	// A barcode merged into another row must not be refreshed from OFF: that would overwrite
+	// the duplicate row and answer with the raw OFF product.
+	fetcher := &fakeFetcher{product: &openfoodfacts.Product{Id: "id_1", ProductName: "Raw OFF"}}
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			survivor := FoodItem{ID: "food_survivor", Name: "Merged", merged: true}
+			return survivor, time.Now().Add(-30 * 24 * time.Hour), true, nil // survivor never bumped by the merge
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			t.Fatal("a merged barcode must not be written from OFF")
+			return nil
+		},
+	)
+	defer cleanup()
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil))
+
+	if rec.Code != http.StatusOK || fetcher.calls != 0 {
+		t.Fatalf("expected the survivor without upstream calls, got %d after %d calls", rec.Code, fetcher.calls)
+	}
+	var item FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &item); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if item.ID != "food_survivor" || item.Name != "Merged" {
+		t.Fatalf("expected the merge survivor, got %+v", item)
+	}
+}
+
 // fakeTimeoutError simulates a net.Error timeout for classification tests.
 type fakeTimeoutError struct{}
 
@@ -371,3 +426,349 @@ func TestNormalizeBarcode(t *testing.T) {
 		t.Fatalf("expected no change, got %s", got)
 	}
 }
+
+func TestHandler_CacheHitLocalized(t *testing.T) {
+	// Fresh cache hit should swap in the cached name for the requested language.
+	fetcher := &fakeFetcher{product: &openfoodfacts.Product{Id: "id_1"}} // upstream should not be called
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{ID: "item_1", Name: "Hazelnut spread"}, time.Now(), true, nil // fresh world row
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			return nil // no-op cache write
+		},
+	)
+	defer cleanup() // restore real helpers
+	getFoodItemLocalizationFunc = func(_ context.Context, _ *pgxpool.Pool, foodItemID, language string) (string, string, string, bool, error) {
+		if foodItemID == "item_1" && language == "fr" {
+			return "Pâte à tartiner", "", "fr", true, nil // cached French name
+		}
+		return "", "", "", false, nil
+	}
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil) // 9 digits skips checksum
+	req.Header.Set("X-User-Locale", "fr-FR")                                  // ask for French
+	rec := httptest.NewRecorder()                                             // capture the HTTP response
+	router.ServeHTTP(rec, req)                                                // execute the request
+
+	var body FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.Name != "Pâte à tartiner" || body.Language != "fr" {
+		t.Fatalf("expected French name, got %q (%s)", body.Name, body.Language)
+	}
+	if fetcher.calls != 0 {
+		t.Fatalf("expected 0 upstream calls, got %d", fetcher.calls)
+	}
+}
+
+func TestHandler_CacheHitFetchesMissingLanguage(t *testing.T) {
+	// A fresh row cached by another locale has no German name yet: fetch it once, keep the nutrients.
+	fetcher := &fakeFetcher{product: &openfoodfacts.Product{
+		Id:          "id_1",
+		ProductName: "Nuss-Nougat-Creme",
+		Lang:        "de",
+		Nutriments:  openfoodfacts.Nutriment{Energy100G: 999}, // must not replace the cached nutrients
+	}}
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			cached := FoodItem{ID: "item_1", Name: "Hazelnut spread", Nutrients: FoodItemNutrients{CaloriesKcal: 539}}
+			cached.fromUpstream, cached.country = true, "de" // already the German formulation
+			return cached, time.Now(), true, nil
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			t.Fatalf("expected food_items to be left alone on a localization fetch")
+			return nil
+		},
+	)
+	defer cleanup() // restore real helpers
+	var written []string
+	upsertFoodItemLocalizationFunc = func(_ context.Context, _ *pgxpool.Pool, _, language, contentLanguage, name, _ string) error {
+		written = append(written, language+"/"+contentLanguage+"/"+name)
+		return nil
+	}
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil) // 9 digits skips checksum
+	req.Header.Set("X-User-Locale", "de-DE")                                  // ask for German
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var body FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.Name != "Nuss-Nougat-Creme" || body.Language != "de" {
+		t.Fatalf("expected German name, got %q (%s)", body.Name, body.Language)
+	}
+	if body.Nutrients.CaloriesKcal != 539 {
+		t.Fatalf("expected cached nutrients, got %v kcal", body.Nutrients.CaloriesKcal)
+	}
+	if fetcher.calls != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", fetcher.calls)
+	}
+	if len(written) != 1 || written[0] != "de/de/Nuss-Nougat-Creme" {
+		t.Fatalf("expected German name cached, got %v", written)
+	}
+}
+
+func TestHandler_CacheHitCachedFallbackSkipsFetch(t *testing.T) {
+	// OFF had no German name last time; the cached fallback row stops us asking again.
+	fetcher := &fakeFetcher{product: &openfoodfacts.Product{Id: "id_1"}} // upstream should not be called
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{ID: "item_1", Name: "Hazelnut spread"}, time.Now(), true, nil
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			return nil
+		},
+	)
+	defer cleanup() // restore real helpers
+	getFoodItemLocalizationFunc = func(_ context.Context, _ *pgxpool.Pool, _, language string) (string, string, string, bool, error) {
+		if language == "de" {
+			return "Pâte à tartiner", "", "fr", true, nil // French fallback cached under "de"
+		}
+		return "", "", "", false, nil
+	}
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil) // 9 digits skips checksum
+	req.Header.Set("X-User-Locale", "de-DE")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var body FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.Name != "Pâte à tartiner" || body.Language != "fr" {
+		t.Fatalf("expected cached French fallback, got %q (%s)", body.Name, body.Language)
+	}
+	if fetcher.calls != 0 {
+		t.Fatalf("expected 0 upstream calls, got %d", fetcher.calls)
+	}
+}
+
+func TestHandler_CacheHitOtherCountryRefetches(t *testing.T) {
+	// A fresh row holds the US formulation; a UK request refetches and caches the UK one.
+	fetcher := &fakeFetcher{product: &openfoodfacts.Product{
+		Id:          "id_1",
+		ProductName: "Hazelnut spread",
+		Lang:        "en",
+		Nutriments:  openfoodfacts.Nutriment{Energy100G: 520, Proteins100G: 6, Carbohydrates100G: 57, Fat100G: 30},
+	}}
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	var upsertCountries []string
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			cached := FoodItem{ID: "item_1", Name: "Hazelnut spread", Nutrients: FoodItemNutrients{CaloriesKcal: 539}}
+			cached.fromUpstream, cached.country = true, "us"
+			return cached, time.Now(), true, nil
+		},
+		func(_ context.Context, _ *pgxpool.Pool, _ *openfoodfacts.Product, _ string, country string, _ float64, _ string, _ DataQuality) error {
+			upsertCountries = append(upsertCountries, country)
+			return nil
+		},
+	)
+	defer cleanup() // restore real helpers
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil) // 9 digits skips checksum
+	req.Header.Set("X-User-Locale", "en-GB")                                  // a different country, same language
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var body FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.Nutrients.CaloriesKcal != 520 {
+		t.Fatalf("expected the UK nutrients, got %v kcal", body.Nutrients.CaloriesKcal)
+	}
+	if fetcher.calls != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", fetcher.calls)
+	}
+	if len(upsertCountries) != 1 || upsertCountries[0] != "gb" {
+		t.Fatalf("expected the row cached for gb, got %v", upsertCountries)
+	}
+}
+
+func TestHandler_OtherCountryUpstreamErrorServesCachedRow(t *testing.T) {
+	// The refetch for another country failed: the fresh row is still better than a 502.
+	fetcher := &fakeFetcher{err: errors.New("boom")}
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			cached := FoodItem{ID: "item_1", Name: "Hazelnut spread", Nutrients: FoodItemNutrients{CaloriesKcal: 539}}
+			cached.fromUpstream, cached.country = true, "us"
+			return cached, time.Now(), true, nil
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			return nil
+		},
+	)
+	defer cleanup() // restore real helpers
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil) // 9 digits skips checksum
+	req.Header.Set("X-User-Locale", "en-GB")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var body FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if rec.Code != http.StatusOK || !body.Degraded || body.Nutrients.CaloriesKcal != 539 {
+		t.Fatalf("expected the cached row marked degraded, got %d %+v", rec.Code, body)
+	}
+}
+
+func TestCacheLocalization_FallbackCachedUnderRequestedLanguage(t *testing.T) {
+	// OFF answered a German request in French: cache it as French and as the "de" fallback.
+	orig := upsertFoodItemLocalizationFunc
+	defer func() { upsertFoodItemLocalizationFunc = orig }()
+	var written []string
+	upsertFoodItemLocalizationFunc = func(_ context.Context, _ *pgxpool.Pool, _, language, contentLanguage, _, _ string) error {
+		written = append(written, language+"/"+contentLanguage)
+		return nil
+	}
+
+	product := &openfoodfacts.Product{ProductName: "Pâte à tartiner", Lang: "fr"}
+	if err := cacheLocalization(context.Background(), nil, "123456789", "de", product); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(written) != 2 || written[0] != "fr/fr" || written[1] != "de/fr" {
+		t.Fatalf("expected fr and de fallback rows, got %v", written)
+	}
+}
+
+func TestFetchProductWithRetry_PermanentErrorNotRetried(t *testing.T) {
+	// A 400 (or any unrecognized error) won't get better by asking again.
+	fetcher := &scriptedFetcher{
+		results: []fetchResult{
+			{err: &retry.HTTPError{Service: "openfoodfacts", StatusCode: 400, Status: "400 Bad Request"}},
+			{product: &openfoodfacts.Product{Id: "id_1"}},
+		},
+	}
+	cfg := RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	if _, err := fetchProductWithRetry(context.Background(), fetcher, "12345678", cfg); err == nil {
+		t.Fatalf("expected error")
+	}
+	if fetcher.calls != 1 {
+		t.Fatalf("expected 1 attempt, got %d", fetcher.calls)
+	}
+}
+
+func TestHandler_ClientCancelRecords499(t *testing.T) {
+	// A cancelled lookup writes no body but must not be logged as a 200.
+	fetcher := &fakeFetcher{err: context.Canceled}
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{}, time.Time{}, false, nil // cache miss
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			return nil
+		},
+	)
+	defer cleanup() // restore real helpers
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil) // 9 digits skips checksum
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != statusClientClosedRequest {
+		t.Fatalf("expected 499, got %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("expected no body, got %s", rec.Body.String())
+	}
+}
+
+func TestFetchProductWithRetry_StopsOnCancel(t *testing.T) {
+	// Client disconnects during the first backoff: no further attempts.
+	fetcher := &scriptedFetcher{
+		results: []fetchResult{
+			{err: &retry.HTTPError{Service: "openfoodfacts", StatusCode: 503, Status: "503 Service Unavailable"}},
+		},
+	}
+	cfg := RetryConfig{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := fetchProductWithRetry(ctx, fetcher, "12345678", cfg)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if fetcher.calls != 1 {
+		t.Fatalf("expected 1 attempt, got %d", fetcher.calls)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("expected prompt return after cancel, took %s", time.Since(start))
+	}
+}
+
+func TestHandler_UpstreamNotFoundServesCachedRow(t *testing.T) {
+	// An approved contribution is cached before OFF has it: stale + upstream 404 still resolves.
+	fetcher := &fakeFetcher{err: openfoodfacts.ErrNoProduct}
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{ID: "food_1", Name: "Contributed"}, time.Now().Add(-48 * time.Hour), true, nil
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			t.Fatal("nothing to write when upstream has no product")
+			return nil
+		},
+	)
+	defer cleanup()
+	hasContributionFunc = func(context.Context, *pgxpool.Pool, string) (bool, error) { return true, nil }
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var item FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &item); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if item.Name != "Contributed" || item.Degraded {
+		t.Fatalf("expected the cached row, got %+v", item)
+	}
+}
+
+func TestHandler_UpstreamNotFoundDropsStaleOFFRow(t *testing.T) {
+	// OFF removed a product we cached from OFF: the stale copy is not served.
+	fetcher := &fakeFetcher{err: openfoodfacts.ErrNoProduct}
+	retryCfg := RetryConfig{MaxAttempts: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{ID: "food_1", Name: "Removed upstream"}, time.Now().Add(-48 * time.Hour), true, nil
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			t.Fatal("nothing to write when upstream has no product")
+			return nil
+		},
+	)
+	defer cleanup()
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
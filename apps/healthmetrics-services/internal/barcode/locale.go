@@ -0,0 +1,158 @@
+package barcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Locale is the language + country pair used to query OpenFoodFacts.
+// Language picks which localized fields we prefer (product_name_fr, ingredients_text_fr).
+// Country picks the OFF country database, which holds country-specific formulations.
+// Example: Locale{Language: "fr", Country: "ca"} -> https://ca.openfoodfacts.org/...?lc=fr
+type Locale struct {
+	Language string // ISO 639-1 code, lowercase ("en", "fr")
+	Country  string // ISO 3166-1 alpha-2 code, lowercase ("us", "ca"), or "world"
+}
+
+// defaultLocale matches the old hard-coded NewClient("world", ...) behavior with English names.
+var defaultLocale = Locale{Language: "en", Country: "world"}
+
+var (
+	languageRegex = regexp.MustCompile(`^[a-z]{2}$`) // two-letter language code
+	countryRegex  = regexp.MustCompile(`^[a-z]{2}$`) // two-letter country code
+)
+
+// Allow tests to swap the profile lookup without touching the DB.
+var getUserLocaleFunc = getUserLocale // default: real DB fetch
+
+// IsDefault reports whether this locale is the world/English fallback.
+func (l Locale) IsDefault() bool {
+	return l == defaultLocale
+}
+
+// String renders the locale as "fr-ca" for logs.
+func (l Locale) String() string {
+	return l.Language + "-" + l.Country
+}
+
+// parseLanguageTag splits a BCP 47-ish tag ("fr-CA", "fr_ca", "fr") into language + country.
+// Anything we can't recognise comes back empty so callers can fall through to the next source.
+func parseLanguageTag(raw string) (string, string) {
+	tag := strings.ToLower(strings.TrimSpace(raw))
+	tag = strings.ReplaceAll(tag, "_", "-")
+	if tag == "" {
+		return "", ""
+	}
+
+	parts := strings.Split(tag, "-")
+	language := ""
+	if languageRegex.MatchString(parts[0]) {
+		language = parts[0]
+	}
+
+	country := ""
+	if len(parts) > 1 && countryRegex.MatchString(parts[1]) {
+		country = parts[1]
+	}
+
+	return language, country
+}
+
+// parseAcceptLanguage returns the first usable tag from an Accept-Language header.
+// Example: "fr-CA,fr;q=0.9,en;q=0.8" -> ("fr", "ca")
+func parseAcceptLanguage(header string) (string, string) {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0]) // drop the q= weight
+		if tag == "" || tag == "*" {
+			continue
+		}
+		if language, country := parseLanguageTag(tag); language != "" {
+			return language, country
+		}
+	}
+	return "", ""
+}
+
+// normalizeCountry accepts "US", "us" or "world" and returns a lowercase code (or "").
+func normalizeCountry(raw string) string {
+	country := strings.ToLower(strings.TrimSpace(raw))
+	if country == "world" || countryRegex.MatchString(country) {
+		return country
+	}
+	return ""
+}
+
+// resolveLocale picks the locale for this request.
+// Precedence (first non-empty wins, per field):
+// 1) X-User-Locale / X-User-Country headers (sent by the TS server)
+// 2) Accept-Language header
+// 3) users.locale / users.country on the profile
+// 4) world + English
+func resolveLocale(c *gin.Context, pool *pgxpool.Pool) Locale {
+	language, country := parseLanguageTag(c.GetHeader("X-User-Locale"))
+	if headerCountry := normalizeCountry(c.GetHeader("X-User-Country")); headerCountry != "" {
+		country = headerCountry
+	}
+
+	if language == "" {
+		acceptLanguage, acceptCountry := parseAcceptLanguage(c.GetHeader("Accept-Language"))
+		language = acceptLanguage
+		if country == "" {
+			country = acceptCountry
+		}
+	}
+
+	// Only hit the DB when headers left a gap and auth told us who the user is.
+	userID := c.GetString("userID")
+	if (language == "" || country == "") && userID != "" && pool != nil {
+		profileLocale, err := getUserLocaleFunc(c.Request.Context(), pool, userID)
+		if err == nil {
+			if language == "" {
+				language = profileLocale.Language
+			}
+			if country == "" {
+				country = profileLocale.Country
+			}
+		}
+	}
+
+	if language == "" {
+		language = defaultLocale.Language
+	}
+	if country == "" {
+		country = defaultLocale.Country
+	}
+
+	return Locale{Language: language, Country: country}
+}
+
+// getUserLocale loads the preferred locale/country from the user profile.
+func getUserLocale(ctx context.Context, pool *pgxpool.Pool, userID string) (Locale, error) {
+	const query = `
+		SELECT COALESCE(locale, ''), COALESCE(country, '')
+		FROM users
+		WHERE id = $1
+	`
+
+	var rawLocale, rawCountry string
+	if err := pool.QueryRow(ctx, query, userID).Scan(&rawLocale, &rawCountry); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) { // no profile yet -> defaults
+			return Locale{}, nil
+		}
+		return Locale{}, fmt.Errorf("query user locale: %w", err)
+	}
+
+	language, country := parseLanguageTag(rawLocale)
+	if profileCountry := normalizeCountry(rawCountry); profileCountry != "" {
+		country = profileCountry
+	}
+
+	return Locale{Language: language, Country: country}, nil
+}
@@ -0,0 +1,73 @@
+package barcode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseLanguageTag(t *testing.T) {
+	testCases := []struct {
+		input       string // raw header value
+		wantLang    string // expected language
+		wantCountry string // expected country
+	}{
+		{input: "fr-CA", wantLang: "fr", wantCountry: "ca"},    // language + region
+		{input: "de_de", wantLang: "de", wantCountry: "de"},    // underscore separator
+		{input: "es", wantLang: "es", wantCountry: ""},         // language only
+		{input: "zh-Hant-TW", wantLang: "zh", wantCountry: ""}, // script subtag is ignored
+		{input: "english", wantLang: "", wantCountry: ""},      // not a code
+		{input: "", wantLang: "", wantCountry: ""},             // empty
+	}
+
+	for _, tc := range testCases {
+		gotLang, gotCountry := parseLanguageTag(tc.input)
+		if gotLang != tc.wantLang || gotCountry != tc.wantCountry {
+			t.Fatalf("input=%q want=%s/%s got=%s/%s", tc.input, tc.wantLang, tc.wantCountry, gotLang, gotCountry)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	// The first usable tag wins (q weights are already sorted by browsers).
+	lang, country := parseAcceptLanguage("*, fr-CA;q=0.9, en;q=0.8")
+	if lang != "fr" || country != "ca" {
+		t.Fatalf("expected fr/ca, got %s/%s", lang, country)
+	}
+}
+
+// resolveLocaleForHeaders runs resolveLocale against a request with the given headers.
+func resolveLocaleForHeaders(headers map[string]string) Locale {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil)
+	for key, value := range headers {
+		c.Request.Header.Set(key, value)
+	}
+	return resolveLocale(c, nil) // nil pool: no profile lookup
+}
+
+func TestResolveLocale(t *testing.T) {
+	// No headers -> world + English (old behavior).
+	if got := resolveLocaleForHeaders(nil); got != defaultLocale {
+		t.Fatalf("expected default locale, got %s", got)
+	}
+
+	// Explicit country header overrides the region in X-User-Locale.
+	got := resolveLocaleForHeaders(map[string]string{
+		"X-User-Locale":  "fr-FR",
+		"X-User-Country": "CA",
+	})
+	if got != (Locale{Language: "fr", Country: "ca"}) {
+		t.Fatalf("expected fr-ca, got %s", got)
+	}
+
+	// Accept-Language fills the gap when the TS server sends nothing.
+	got = resolveLocaleForHeaders(map[string]string{"Accept-Language": "de-AT,de;q=0.9"})
+	if got != (Locale{Language: "de", Country: "at"}) {
+		t.Fatalf("expected de-at, got %s", got)
+	}
+}
@@ -0,0 +1,213 @@
+package barcode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openfoodfacts/openfoodfacts-go"
+
+	"healthmetrics-services/internal/retry"
+)
+
+// LocaleFetcher is implemented by upstream clients that can scope a lookup to a locale.
+// The handler checks for it so tests can keep passing a plain ProductFetcher.
+type LocaleFetcher interface {
+	ForLocale(loc Locale) ProductFetcher
+}
+
+// OFFClient talks to the OpenFoodFacts product API directly.
+// The openfoodfacts-go client only takes a subdomain and drops product_name_<lang> fields,
+// so we call the same endpoint ourselves and reuse the library's Product type for decoding.
+type OFFClient struct {
+	HTTPClient *http.Client // shared HTTP client (timeout lives here)
+	UserAgent  string       // identifies this service to OFF (optional)
+	Sandbox    bool         // true -> openfoodfacts.net test server
+
+	baseURL string // tests point this at httptest instead of https://<country>.openfoodfacts.org
+}
+
+// NewOFFClient builds a client from the OPENFOODFACTS_* settings.
+// baseURL only matters for sandbox detection (".net" -> sandbox), matching the old behavior.
+func NewOFFClient(timeout time.Duration, userAgent, baseURL string) *OFFClient {
+	return &OFFClient{
+		HTTPClient: &http.Client{Timeout: timeout},
+		UserAgent:  userAgent,
+		Sandbox:    strings.Contains(baseURL, "openfoodfacts.net"),
+	}
+}
+
+// Product fetches with the world database + English names (ProductFetcher).
+func (o *OFFClient) Product(code string) (*openfoodfacts.Product, error) {
+	return o.productForLocale(context.Background(), code, defaultLocale)
+}
+
+// ProductContext is Product, abandoned when ctx ends (ContextFetcher).
+func (o *OFFClient) ProductContext(ctx context.Context, code string) (*openfoodfacts.Product, error) {
+	return o.productForLocale(ctx, code, defaultLocale)
+}
+
+// ForLocale returns a fetcher bound to a country database + preferred language.
+func (o *OFFClient) ForLocale(loc Locale) ProductFetcher {
+	return &localeFetcher{client: o, locale: loc}
+}
+
+// localeFetcher adapts OFFClient + Locale to the ProductFetcher interface (so retries work unchanged).
+type localeFetcher struct {
+	client *OFFClient
+	locale Locale
+}
+
+func (f *localeFetcher) Product(code string) (*openfoodfacts.Product, error) {
+	return f.client.productForLocale(context.Background(), code, f.locale)
+}
+
+func (f *localeFetcher) ProductContext(ctx context.Context, code string) (*openfoodfacts.Product, error) {
+	return f.client.productForLocale(ctx, code, f.locale)
+}
+
+// fetcherForLocale picks a locale-scoped fetcher when the upstream client supports it.
+func fetcherForLocale(api ProductFetcher, loc Locale) ProductFetcher {
+	if localized, ok := api.(LocaleFetcher); ok {
+		return localized.ForLocale(loc)
+	}
+	return api
+}
+
+// productEndpoint builds the product URL for a locale.
+// Example: Locale{fr, ca} -> https://ca.openfoodfacts.org/api/v0/product/0072745068393.json?lc=fr
+func (o *OFFClient) productEndpoint(code string, loc Locale) string {
+	base := o.baseURL
+	if base == "" {
+		tld := "org"
+		if o.Sandbox {
+			tld = "net"
+		}
+		base = fmt.Sprintf("https://%s.openfoodfacts.%s", loc.Country, tld)
+	}
+
+	params := url.Values{}
+	params.Set("lc", loc.Language) // language for computed fields
+	params.Set("cc", loc.Country)  // country for country-specific data
+	return fmt.Sprintf("%s/api/v0/product/%s.json?%s", base, url.PathEscape(code), params.Encode())
+}
+
+func (o *OFFClient) productForLocale(ctx context.Context, code string, loc Locale) (*openfoodfacts.Product, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.productEndpoint(code, loc), nil)
+	if err != nil {
+		return nil, err
+	}
+	if o.UserAgent != "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	}
+	if o.Sandbox {
+		req.SetBasicAuth("off", "off") // the sandbox sits behind basic auth
+	}
+
+	httpClient := o.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// OFF answers unknown barcodes with 404 + status=0, so only treat other 4xx/5xx as failures.
+	// The typed error carries the status + Retry-After so the retry policy can classify it.
+	if resp.StatusCode >= 400 && resp.StatusCode != http.StatusNotFound {
+		return nil, retry.NewHTTPError("openfoodfacts", resp)
+	}
+
+	var result openfoodfacts.ProductResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if result.Status != 1 || result.Product == nil {
+		return nil, openfoodfacts.ErrNoProduct
+	}
+
+	// Second pass over the same payload for the per-language fields the library struct doesn't declare.
+	var raw struct {
+		Product map[string]any `json:"product"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+
+	localizeProduct(result.Product, raw.Product, loc.Language)
+	return result.Product, nil
+}
+
+// localizeProduct rewrites the display fields on product for the requested language.
+// After this:
+//   - ProductName / IngredientsText hold the best match for language
+//   - Lang records which language we actually got (so we cache it under the right key)
+//   - ProductNameEn holds the world/English name we store on food_items
+func localizeProduct(product *openfoodfacts.Product, fields map[string]any, language string) {
+	mainLanguage := product.Locale // "lc" = language of the un-suffixed fields
+
+	name, nameLanguage := pickLocalized(fields, "product_name", language, mainLanguage)
+	ingredients, _ := pickLocalized(fields, "ingredients_text", language, mainLanguage)
+	worldName, _ := pickLocalized(fields, "product_name", "en", mainLanguage)
+
+	if name != "" {
+		product.ProductName = name
+	}
+	if ingredients != "" {
+		product.IngredientsText = ingredients
+	}
+	if worldName != "" {
+		product.ProductNameEn = worldName
+	}
+	product.Lang = nameLanguage
+}
+
+// pickLocalized walks the fallback chain for a localized OFF field.
+// Example with key="product_name", language="fr", mainLanguage="en":
+// product_name_fr -> product_name_en -> product_name
+func pickLocalized(fields map[string]any, key, language, mainLanguage string) (string, string) {
+	if value := stringField(fields, key+"_"+language); value != "" {
+		return value, language
+	}
+	if mainLanguage == language {
+		if value := stringField(fields, key); value != "" {
+			return value, language
+		}
+	}
+	if value := stringField(fields, key+"_en"); value != "" {
+		return value, "en"
+	}
+	if value := stringField(fields, key); value != "" {
+		return value, mainLanguage
+	}
+	return "", ""
+}
+
+func stringField(fields map[string]any, key string) string {
+	value, ok := fields[key].(string)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// baseProductName is the world/English name stored on food_items.name.
+func baseProductName(product *openfoodfacts.Product) string {
+	if product.ProductNameEn != "" {
+		return product.ProductNameEn
+	}
+	return product.ProductName
+}
@@ -0,0 +1,105 @@
+package barcode
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openfoodfacts/openfoodfacts-go"
+
+	"healthmetrics-services/internal/retry"
+)
+
+// newTestOFFClient points an OFFClient at a stub server and records the last query string.
+func newTestOFFClient(t *testing.T, status int, payload string, lastQuery *string) *OFFClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lastQuery = r.URL.RawQuery // capture lc/cc for assertions
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(payload))
+	}))
+	t.Cleanup(server.Close)
+
+	return &OFFClient{HTTPClient: server.Client(), baseURL: server.URL}
+}
+
+func TestOFFClient_LocalizedName(t *testing.T) {
+	var query string
+	client := newTestOFFClient(t, http.StatusOK, `{
+		"status": 1,
+		"product": {
+			"code": "3017620422003",
+			"lc": "fr",
+			"product_name": "Pâte à tartiner",
+			"product_name_en": "Hazelnut spread",
+			"product_name_de": "Nuss-Nougat-Creme",
+			"ingredients_text": "sucre, huile de palme"
+		}
+	}`, &query)
+
+	product, err := client.ForLocale(Locale{Language: "de", Country: "de"}).Product("3017620422003")
+	if err != nil {
+		t.Fatalf("expected product, got error: %v", err)
+	}
+	if query != "cc=de&lc=de" {
+		t.Fatalf("expected locale query params, got %q", query)
+	}
+	if product.ProductName != "Nuss-Nougat-Creme" || product.Lang != "de" {
+		t.Fatalf("expected German name, got %q (%s)", product.ProductName, product.Lang)
+	}
+	if product.ProductNameEn != "Hazelnut spread" {
+		t.Fatalf("expected English world name, got %q", product.ProductNameEn)
+	}
+	// No ingredients_text_de/en: fall back to the main-language text.
+	if product.IngredientsText != "sucre, huile de palme" {
+		t.Fatalf("expected fallback ingredients, got %q", product.IngredientsText)
+	}
+}
+
+func TestOFFClient_FallsBackToEnglish(t *testing.T) {
+	var query string
+	client := newTestOFFClient(t, http.StatusOK, `{
+		"status": 1,
+		"product": {"lc": "en", "product_name": "Oat Milk"}
+	}`, &query)
+
+	product, err := client.ForLocale(Locale{Language: "es", Country: "us"}).Product("12345678")
+	if err != nil {
+		t.Fatalf("expected product, got error: %v", err)
+	}
+	if product.ProductName != "Oat Milk" || product.Lang != "en" {
+		t.Fatalf("expected English fallback, got %q (%s)", product.ProductName, product.Lang)
+	}
+}
+
+func TestOFFClient_NotFound(t *testing.T) {
+	var query string
+	client := newTestOFFClient(t, http.StatusNotFound, `{"status": 0, "status_verbose": "product not found"}`, &query)
+
+	if _, err := client.Product("12345678"); !errors.Is(err, openfoodfacts.ErrNoProduct) {
+		t.Fatalf("expected ErrNoProduct, got %v", err)
+	}
+}
+
+func TestOFFClient_RateLimitedCarriesRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	client := &OFFClient{HTTPClient: server.Client(), baseURL: server.URL}
+
+	_, err := client.Product("12345678")
+	var httpErr *retry.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected *retry.HTTPError, got %v", err)
+	}
+	if httpErr.StatusCode != http.StatusTooManyRequests || httpErr.RetryAfter != 3*time.Second {
+		t.Fatalf("expected 429 with 3s Retry-After, got %d %s", httpErr.StatusCode, httpErr.RetryAfter)
+	}
+	if !retry.IsTransient(err) {
+		t.Fatalf("expected 429 to be transient")
+	}
+}
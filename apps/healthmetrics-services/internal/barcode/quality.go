@@ -0,0 +1,215 @@
+package barcode
+
+import (
+	"math"
+
+	"github.com/openfoodfacts/openfoodfacts-go"
+)
+
+// Data-quality statuses (worst wins).
+const (
+	QualityOK        = "ok"        // nothing to report
+	QualityCorrected = "corrected" // we fixed an obvious unit error; values are now plausible
+	QualitySuspect   = "suspect"   // plausible enough to cache, but the UI should warn
+	QualityInvalid   = "invalid"   // physically impossible; never cached, quarantined for review
+)
+
+// Data-quality flags attached to responses (stable codes for the UI).
+const (
+	FlagEnergyConvertedFromKJ = "energy_converted_from_kj" // fix: energy looked like kJ, converted to kcal
+	FlagSodiumConvertedFromMg = "sodium_converted_from_mg" // fix: sodium looked like mg, converted to g
+	FlagSodiumDerivedFromSalt = "sodium_derived_from_salt" // fix: sodium missing, derived from salt / 2.5
+	FlagSugarExceedsCarbs     = "sugar_exceeds_carbs"      // warning: sugar > carbohydrates
+	FlagEnergyMacroMismatch   = "energy_macro_mismatch"    // warning: kcal far from 4p + 4c + 9f
+	FlagNutritionMissing      = "nutrition_missing"        // warning: every core value is zero
+	FlagNegativeNutrient      = "negative_nutrient"        // invalid: a value below zero
+	FlagMacrosExceed100G      = "macros_exceed_100g"       // invalid: protein + carbs + fat > 100 g per 100 g
+	FlagEnergyOutOfRange      = "energy_out_of_range"      // invalid: > 900 kcal per 100 g (pure fat)
+)
+
+// Score penalties per flag kind. Example: one fix + one warning -> 100 - 10 - 25 = 65.
+const (
+	penaltyFix     = 10
+	penaltyWarning = 25
+	penaltyInvalid = 60
+)
+
+// Plausibility limits (per 100 g).
+const (
+	macroSumToleranceG = 105.0 // rounding on labels can push a pure product slightly past 100 g
+	maxEnergyKcal      = 905.0 // 100 g of fat = 900 kcal, plus rounding
+	maxSodiumG         = 40.0  // table salt is ~39 g sodium per 100 g; anything above is mg
+	kjPerKcal          = 4.184
+	saltToSodium       = 2.5 // salt (NaCl) = sodium * 2.5
+)
+
+// DataQuality is the plausibility verdict attached to a food item.
+// Example: {"score": 65, "status": "suspect", "flags": ["sodium_converted_from_mg", "sugar_exceeds_carbs"]}
+type DataQuality struct {
+	Score  int      `json:"score"`  // 0-100, higher is better
+	Status string   `json:"status"` // ok / corrected / suspect / invalid
+	Flags  []string `json:"flags"`  // flag codes, in the order they were found
+}
+
+// Cacheable reports whether the product may be written to food_items.
+func (q DataQuality) Cacheable() bool {
+	return q.Status != QualityInvalid
+}
+
+// add records a flag, lowers the score, and raises the status if needed.
+func (q *DataQuality) add(flag string, penalty int, status string) {
+	q.Flags = append(q.Flags, flag)
+	q.Score -= penalty
+	if q.Score < 0 {
+		q.Score = 0
+	}
+	if qualityRank(status) > qualityRank(q.Status) {
+		q.Status = status
+	}
+}
+
+func qualityRank(status string) int {
+	switch status {
+	case QualityCorrected:
+		return 1
+	case QualitySuspect:
+		return 2
+	case QualityInvalid:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// validateProductNutrition scores an upstream product and fixes obvious unit errors in place.
+// After this, Nutriments.Energy100G holds kcal and Sodium100G holds grams (what the rest of
+// this package assumes). Pipeline:
+// 1) auto-fix units (kJ -> kcal, sodium mg -> g, sodium from salt)
+// 2) hard checks (negative values, macros > 100 g, energy > 900 kcal) -> invalid
+// 3) soft checks (sugar > carbs, kcal vs macros, all zeros) -> suspect
+func validateProductNutrition(product *openfoodfacts.Product) DataQuality {
+	quality := DataQuality{Score: 100, Status: QualityOK, Flags: []string{}}
+	n := &product.Nutriments
+
+	// --- 1) Unit fixes ---
+	macroKcal := 4*n.Proteins100G + 4*n.Carbohydrates100G + 9*n.Fat100G
+
+	// OFF's energy_100g is kJ; energy-kcal_100g is the kcal value when the label had one.
+	if n.EnergyKcal100G > 0 {
+		n.Energy100G = n.EnergyKcal100G
+	} else if n.Energy100G > 0 && macroKcal > 0 && looksLikeKJ(n.Energy100G, macroKcal) {
+		n.Energy100G = roundTo(n.Energy100G/kjPerKcal, 1)
+		quality.add(FlagEnergyConvertedFromKJ, penaltyFix, QualityCorrected)
+	}
+
+	if n.Sodium100G > maxSodiumG {
+		n.Sodium100G = n.Sodium100G / 1000 // e.g. 450 "g" is really 450 mg
+		quality.add(FlagSodiumConvertedFromMg, penaltyFix, QualityCorrected)
+	} else if n.Sodium100G == 0 && n.Salt100G > 0 {
+		n.Sodium100G = roundTo(n.Salt100G/saltToSodium, 3)
+		quality.add(FlagSodiumDerivedFromSalt, penaltyFix, QualityCorrected)
+	}
+
+	// --- 2) Hard checks ---
+	values := []float64{n.Energy100G, n.Proteins100G, n.Carbohydrates100G, n.Fat100G, n.Fiber100G, n.Sugars100G, n.Sodium100G}
+	for _, value := range values {
+		if value < 0 {
+			quality.add(FlagNegativeNutrient, penaltyInvalid, QualityInvalid)
+			break
+		}
+	}
+	if n.Proteins100G+n.Carbohydrates100G+n.Fat100G > macroSumToleranceG {
+		quality.add(FlagMacrosExceed100G, penaltyInvalid, QualityInvalid)
+	}
+	if n.Energy100G > maxEnergyKcal {
+		quality.add(FlagEnergyOutOfRange, penaltyInvalid, QualityInvalid)
+	}
+
+	// --- 3) Soft checks ---
+	if n.Sugars100G > n.Carbohydrates100G+1 { // 1 g slack for label rounding
+		quality.add(FlagSugarExceedsCarbs, penaltyWarning, QualitySuspect)
+	}
+	if n.Energy100G == 0 && n.Proteins100G == 0 && n.Carbohydrates100G == 0 && n.Fat100G == 0 {
+		quality.add(FlagNutritionMissing, penaltyWarning, QualitySuspect)
+	} else if energyMismatch(n.Energy100G, macroKcal) {
+		quality.add(FlagEnergyMacroMismatch, penaltyWarning, QualitySuspect)
+	}
+
+	return quality
+}
+
+// ValidateNutrients runs the same plausibility checks on nutrients that did not come from
+// OpenFoodFacts (user contributions). Per 100 g, sodium in g; nil fiber/sugar/sodium = unknown.
+// Returns the nutrients after unit fixes (e.g. energy entered in kJ, sodium in mg) plus the verdict.
+func ValidateNutrients(n FoodItemNutrients) (FoodItemNutrients, DataQuality) {
+	// Negative values are rejected as entered, before a unit fix could rescale them.
+	if hasNegativeNutrient(n) {
+		quality := DataQuality{Score: 100, Status: QualityOK, Flags: []string{}}
+		quality.add(FlagNegativeNutrient, penaltyInvalid, QualityInvalid)
+		return n, quality
+	}
+
+	// The submitted calories go in Energy100G (not EnergyKcal100G) so the kJ heuristic and
+	// the range checks see the raw value: 700 "kcal" with 167 kcal of macros is 700 kJ.
+	product := &openfoodfacts.Product{}
+	product.Nutriments.Energy100G = n.CaloriesKcal
+	product.Nutriments.Proteins100G = n.ProteinG
+	product.Nutriments.Carbohydrates100G = n.CarbsG
+	product.Nutriments.Fat100G = n.FatG
+	if n.FiberG != nil {
+		product.Nutriments.Fiber100G = *n.FiberG
+	}
+	if n.SugarG != nil {
+		product.Nutriments.Sugars100G = *n.SugarG
+	}
+	if n.SodiumG != nil {
+		product.Nutriments.Sodium100G = *n.SodiumG
+	}
+
+	quality := validateProductNutrition(product)
+
+	fixed := n
+	fixed.CaloriesKcal = product.Nutriments.Energy100G
+	if n.SodiumG != nil {
+		sodium := product.Nutriments.Sodium100G
+		fixed.SodiumG = &sodium
+	}
+	return fixed, quality
+}
+
+// hasNegativeNutrient reports whether any submitted value is below zero.
+func hasNegativeNutrient(n FoodItemNutrients) bool {
+	values := []float64{n.CaloriesKcal, n.ProteinG, n.CarbsG, n.FatG}
+	for _, optional := range []*float64{n.FiberG, n.SugarG, n.SodiumG} {
+		if optional != nil {
+			values = append(values, *optional)
+		}
+	}
+	for _, value := range values {
+		if value < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeKJ reports whether energy is closer to the macro estimate once read as kJ.
+// Example: energy=1500, macros=360 kcal -> 1500/4.184=358 is a much better fit -> kJ.
+func looksLikeKJ(energy, macroKcal float64) bool {
+	return math.Abs(energy/kjPerKcal-macroKcal) < math.Abs(energy-macroKcal)
+}
+
+// energyMismatch reports kcal that disagrees with 4p + 4c + 9f by more than 25% (min 40 kcal).
+// Fiber, polyols and alcohol explain smaller gaps, so the tolerance is deliberately loose.
+func energyMismatch(kcal, macroKcal float64) bool {
+	if macroKcal == 0 {
+		return kcal > 40 // real energy with no macros at all (diet drinks stay under this)
+	}
+	tolerance := math.Max(40, 0.25*macroKcal)
+	return math.Abs(kcal-macroKcal) > tolerance
+}
+
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,217 @@
+package barcode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/openfoodfacts/openfoodfacts-go"
+)
+
+func productWith(n openfoodfacts.Nutriment) *openfoodfacts.Product {
+	return &openfoodfacts.Product{Id: "id_1", Nutriments: n}
+}
+
+func TestValidateProductNutrition_Plausible(t *testing.T) {
+	// Rolled oats: 13p + 60c + 7f -> 355 kcal from macros, label says 379.
+	product := productWith(openfoodfacts.Nutriment{
+		EnergyKcal100G: 379, Energy100G: 1586, Proteins100G: 13, Carbohydrates100G: 60, Fat100G: 7, Sugars100G: 1, Sodium100G: 0.006,
+	})
+
+	quality := validateProductNutrition(product)
+	if quality.Status != QualityOK || quality.Score != 100 || len(quality.Flags) != 0 {
+		t.Fatalf("expected clean verdict, got %+v", quality)
+	}
+	if product.Nutriments.Energy100G != 379 {
+		t.Fatalf("expected kcal to replace kJ, got %v", product.Nutriments.Energy100G)
+	}
+}
+
+func TestValidateProductNutrition_FixesUnits(t *testing.T) {
+	// No kcal field, energy in kJ, sodium in mg.
+	product := productWith(openfoodfacts.Nutriment{
+		Energy100G: 1500, Proteins100G: 10, Carbohydrates100G: 60, Fat100G: 9, Sodium100G: 450,
+	})
+
+	quality := validateProductNutrition(product)
+	if quality.Status != QualityCorrected || quality.Score != 80 {
+		t.Fatalf("expected corrected verdict, got %+v", quality)
+	}
+	if !slices.Equal(quality.Flags, []string{FlagEnergyConvertedFromKJ, FlagSodiumConvertedFromMg}) {
+		t.Fatalf("unexpected flags: %v", quality.Flags)
+	}
+	if product.Nutriments.Energy100G != 358.5 || product.Nutriments.Sodium100G != 0.45 {
+		t.Fatalf("expected fixed values, got kcal=%v sodium=%v", product.Nutriments.Energy100G, product.Nutriments.Sodium100G)
+	}
+}
+
+func TestValidateProductNutrition_SodiumFromSalt(t *testing.T) {
+	product := productWith(openfoodfacts.Nutriment{
+		EnergyKcal100G: 100, Proteins100G: 5, Carbohydrates100G: 15, Fat100G: 2, Salt100G: 1.25,
+	})
+
+	quality := validateProductNutrition(product)
+	if !slices.Contains(quality.Flags, FlagSodiumDerivedFromSalt) || product.Nutriments.Sodium100G != 0.5 {
+		t.Fatalf("expected sodium derived from salt, got %v (%+v)", product.Nutriments.Sodium100G, quality)
+	}
+}
+
+func TestValidateProductNutrition_Invalid(t *testing.T) {
+	cases := map[string]struct {
+		nutriments openfoodfacts.Nutriment
+		flag       string
+	}{
+		"negative": {openfoodfacts.Nutriment{EnergyKcal100G: 100, Proteins100G: -2, Carbohydrates100G: 20, Fat100G: 1}, FlagNegativeNutrient},
+		"macros":   {openfoodfacts.Nutriment{EnergyKcal100G: 500, Proteins100G: 40, Carbohydrates100G: 60, Fat100G: 20}, FlagMacrosExceed100G},
+		"energy":   {openfoodfacts.Nutriment{EnergyKcal100G: 2000, Fat100G: 100}, FlagEnergyOutOfRange},
+	}
+	for name, tc := range cases {
+		quality := validateProductNutrition(productWith(tc.nutriments))
+		if quality.Status != QualityInvalid || quality.Cacheable() || !slices.Contains(quality.Flags, tc.flag) {
+			t.Fatalf("%s: expected invalid with %s, got %+v", name, tc.flag, quality)
+		}
+	}
+}
+
+func TestValidateProductNutrition_Suspect(t *testing.T) {
+	// Sugar above carbs and kcal nowhere near macros (4*5 + 4*10 + 9*1 = 69).
+	product := productWith(openfoodfacts.Nutriment{
+		EnergyKcal100G: 300, Proteins100G: 5, Carbohydrates100G: 10, Fat100G: 1, Sugars100G: 20,
+	})
+
+	quality := validateProductNutrition(product)
+	if quality.Status != QualitySuspect || !quality.Cacheable() {
+		t.Fatalf("expected cacheable suspect verdict, got %+v", quality)
+	}
+	if !slices.Equal(quality.Flags, []string{FlagSugarExceedsCarbs, FlagEnergyMacroMismatch}) {
+		t.Fatalf("unexpected flags: %v", quality.Flags)
+	}
+}
+
+func TestValidateNutrients(t *testing.T) {
+	sodium := func(g float64) *float64 { return &g }
+	cases := map[string]struct {
+		in       FoodItemNutrients
+		status   string
+		flag     string  // expected among the flags ("" = none)
+		wantKcal float64 // calories after unit fixes
+	}{
+		"plausible":         {FoodItemNutrients{CaloriesKcal: 379, ProteinG: 13, CarbsG: 60, FatG: 7}, QualityOK, "", 379},
+		"negative kcal":     {FoodItemNutrients{CaloriesKcal: -50, ProteinG: 5, CarbsG: 10, FatG: 1}, QualityInvalid, FlagNegativeNutrient, -50},
+		"negative sodium":   {FoodItemNutrients{CaloriesKcal: 100, ProteinG: 5, CarbsG: 15, FatG: 2, SodiumG: sodium(-500)}, QualityInvalid, FlagNegativeNutrient, 100},
+		"kJ-sized":          {FoodItemNutrients{CaloriesKcal: 1500, ProteinG: 10, CarbsG: 60, FatG: 9}, QualityCorrected, FlagEnergyConvertedFromKJ, 358.5},
+		"small kJ":          {FoodItemNutrients{CaloriesKcal: 700, ProteinG: 10, CarbsG: 25, FatG: 3}, QualityCorrected, FlagEnergyConvertedFromKJ, 167.3},
+		"max energy":        {FoodItemNutrients{CaloriesKcal: 905, FatG: 100}, QualityOK, "", 905},
+		"above max energy":  {FoodItemNutrients{CaloriesKcal: 906, FatG: 100}, QualityInvalid, FlagEnergyOutOfRange, 906},
+		"kJ without macros": {FoodItemNutrients{CaloriesKcal: 1500}, QualityInvalid, FlagEnergyOutOfRange, 1500},
+		"all zero":          {FoodItemNutrients{}, QualitySuspect, FlagNutritionMissing, 0},
+	}
+	for name, tc := range cases {
+		fixed, quality := ValidateNutrients(tc.in)
+		if quality.Status != tc.status || (tc.flag == "" && len(quality.Flags) != 0) || (tc.flag != "" && !slices.Contains(quality.Flags, tc.flag)) {
+			t.Fatalf("%s: expected %s with %q, got %+v", name, tc.status, tc.flag, quality)
+		}
+		if fixed.CaloriesKcal != tc.wantKcal {
+			t.Fatalf("%s: expected %v kcal, got %v", name, tc.wantKcal, fixed.CaloriesKcal)
+		}
+	}
+}
+
+func TestDataQualityFromColumns(t *testing.T) {
+	quality := dataQualityFromColumns(65, []string{FlagSodiumConvertedFromMg, FlagSugarExceedsCarbs})
+	if quality.Status != QualitySuspect || quality.Score != 65 {
+		t.Fatalf("expected suspect, got %+v", quality)
+	}
+	if clean := dataQualityFromColumns(100, nil); clean.Status != QualityOK || clean.Flags == nil {
+		t.Fatalf("expected ok with empty flags, got %+v", clean)
+	}
+}
+
+func TestHandler_InvalidProductIsQuarantined(t *testing.T) {
+	fetcher := &fakeFetcher{product: &openfoodfacts.Product{
+		Id:          "id_1",
+		ProductName: "Broken Bar",
+		Nutriments:  openfoodfacts.Nutriment{EnergyKcal100G: 500, Proteins100G: 40, Carbohydrates100G: 60, Fat100G: 20},
+	}}
+	retryCfg := RetryConfig{MaxAttempts: 1}
+	upserts := 0
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			return FoodItem{}, time.Time{}, false, nil // cache miss
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			upserts++
+			return nil
+		},
+	)
+	defer cleanup()
+	quarantined := 0
+	quarantineFoodItemFunc = func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, DataQuality) error {
+		quarantined++
+		return nil
+	}
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	if upserts != 0 || quarantined != 1 {
+		t.Fatalf("expected quarantine instead of cache write (upserts=%d quarantined=%d)", upserts, quarantined)
+	}
+	if !strings.Contains(rec.Body.String(), `"INVALID_PRODUCT_DATA"`) || !strings.Contains(rec.Body.String(), FlagMacrosExceed100G) {
+		t.Fatalf("expected error envelope naming the flag, got %s", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "nutrients") {
+		t.Fatalf("expected invalid nutrients to be withheld, got %s", rec.Body.String())
+	}
+}
+
+func TestHandler_InvalidRefreshServesStaleRow(t *testing.T) {
+	// The cached row passed validation when it was written; an invalid refresh doesn't replace it.
+	fetcher := &fakeFetcher{product: &openfoodfacts.Product{
+		Id:          "id_1",
+		ProductName: "Broken Bar",
+		Nutriments:  openfoodfacts.Nutriment{EnergyKcal100G: 500, Proteins100G: 40, Carbohydrates100G: 60, Fat100G: 20},
+	}}
+	retryCfg := RetryConfig{MaxAttempts: 1}
+	cleanup := setupCacheStubs(
+		func(context.Context, *pgxpool.Pool, string) (FoodItem, time.Time, bool, error) {
+			stale := time.Now().Add(-48 * time.Hour) // past the TTL below
+			return FoodItem{ID: "item_1", Name: "Granola Bar", Nutrients: FoodItemNutrients{CaloriesKcal: 450}}, stale, true, nil
+		},
+		func(context.Context, *pgxpool.Pool, *openfoodfacts.Product, string, string, float64, string, DataQuality) error {
+			t.Fatalf("expected no cache write for an invalid product")
+			return nil
+		},
+	)
+	defer cleanup()
+	router := makeRouter(fetcher, retryCfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body FoodItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.Nutrients.CaloriesKcal != 450 || !body.Degraded {
+		t.Fatalf("expected the stale cached row marked degraded, got %+v", body)
+	}
+	if rec.Header().Get("Warning") == "" {
+		t.Fatalf("expected stale Warning header")
+	}
+}
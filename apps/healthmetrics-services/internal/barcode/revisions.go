@@ -0,0 +1,317 @@
+package barcode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/httpx"
+)
+
+// Change sources recorded on food_item_revisions.change_source.
+// The food_items trigger falls back to "app" when a writer doesn't tag its transaction
+// (e.g. a user correction saved by the TS app without set_config).
+const changeSourceUpstreamRefresh = "open_food_facts_refresh" // cache refresh from OpenFoodFacts
+
+// changedByBarcodeService is the actor recorded for writes made by this service on its own.
+const changedByBarcodeService = "barcode-service"
+
+// FoodItemRevision is one entry in a product's history (newest first in API responses).
+type FoodItemRevision struct {
+	Revision  int               `json:"revision"`   // 1-based, per food item
+	Source    string            `json:"source"`     // open_food_facts_refresh, user_correction, app, ...
+	ChangedBy *string           `json:"changed_by"` // user id or service name (null when unknown)
+	ChangedAt time.Time         `json:"changed_at"` // when the change was written
+	Changes   json.RawMessage   `json:"changes"`    // {"protein_g": {"from": 3, "to": 4}, ...}
+	Nutrients FoodItemNutrients `json:"nutrients"`  // nutrients after this change
+}
+
+// revisionRow is a food_item_revisions row with the raw row snapshots.
+type revisionRow struct {
+	foodItemID string
+	revision   FoodItemRevision
+	snapshot   map[string]any // food_items row after the change
+	previous   map[string]any // food_items row before the change (nil for the insert)
+	createdAt  time.Time
+}
+
+// Allow tests to swap DB helpers without changing production logic.
+var listFoodItemRevisionsFunc = listFoodItemRevisions // default: real DB fetch
+
+// withChangeContext runs fn in a transaction tagged with who/what is changing food_items.
+// The food_items revision trigger reads these settings (SET LOCAL scope) when it writes history.
+// Example: withChangeContext(ctx, pool, "open_food_facts_refresh", "barcode-service", upsert)
+func withChangeContext(ctx context.Context, pool *pgxpool.Pool, source, changedBy string, fn func(pgx.Tx) error) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin food_items change: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after Commit
+
+	// set_config(..., true) = transaction-local, so pooled connections don't leak the tag.
+	const query = `SELECT set_config('app.change_source', $1, true), set_config('app.changed_by', $2, true)`
+	if _, err := tx.Exec(ctx, query, source, changedBy); err != nil {
+		return fmt.Errorf("tag food_items change: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit food_items change: %w", err)
+	}
+	return nil
+}
+
+// WithChangeContext is withChangeContext for other packages writing food_items
+// (e.g. approved product contributions), so their writes show up correctly in revisions.
+func WithChangeContext(ctx context.Context, pool *pgxpool.Pool, source, changedBy string, fn func(pgx.Tx) error) error {
+	return withChangeContext(ctx, pool, source, changedBy, fn)
+}
+
+// listFoodItemRevisions loads the food item id + full history for a barcode (oldest first).
+func listFoodItemRevisions(ctx context.Context, pool *pgxpool.Pool, barcode string) (string, []revisionRow, bool, error) {
+	var foodItemID string
+	err := pool.QueryRow(ctx, `SELECT id FROM food_items WHERE barcode = $1 LIMIT 1`, barcode).Scan(&foodItemID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) { // unknown barcode
+			return "", nil, false, nil
+		}
+		return "", nil, false, fmt.Errorf("query food_items: %w", err)
+	}
+
+	rows, err := loadRevisions(ctx, pool, []string{foodItemID})
+	if err != nil {
+		return "", nil, false, err
+	}
+	return foodItemID, rows, true, nil
+}
+
+// loadRevisions reads every revision for these food items, oldest first per item.
+func loadRevisions(ctx context.Context, pool *pgxpool.Pool, foodItemIDs []string) ([]revisionRow, error) {
+	const query = `
+		SELECT food_item_id, revision, change_source, changed_by, changes, snapshot, previous, created_at
+		FROM food_item_revisions
+		WHERE food_item_id = ANY($1)
+		ORDER BY food_item_id, revision ASC
+	`
+
+	rows, err := pool.Query(ctx, query, foodItemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query food_item_revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var items []revisionRow
+	for rows.Next() {
+		var (
+			row      revisionRow
+			changes  []byte
+			snapshot []byte
+			previous []byte
+		)
+		if err := rows.Scan(
+			&row.foodItemID,
+			&row.revision.Revision,
+			&row.revision.Source,
+			&row.revision.ChangedBy,
+			&changes,
+			&snapshot,
+			&previous,
+			&row.createdAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan food_item_revisions: %w", err)
+		}
+
+		if err := json.Unmarshal(snapshot, &row.snapshot); err != nil {
+			return nil, fmt.Errorf("decode revision snapshot: %w", err)
+		}
+		if len(previous) > 0 {
+			if err := json.Unmarshal(previous, &row.previous); err != nil {
+				return nil, fmt.Errorf("decode revision previous: %w", err)
+			}
+		}
+
+		row.revision.Changes = json.RawMessage(changes)
+		row.revision.ChangedAt = row.createdAt
+		row.revision.Nutrients = nutrientsFromSnapshot(row.snapshot)
+		items = append(items, row)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("iterate food_item_revisions: %w", rows.Err())
+	}
+	return items, nil
+}
+
+// NutrientHistory is the revision history of a set of food items, loaded up front so
+// resolving nutrients as of a point in time doesn't cost a query per lookup.
+type NutrientHistory struct {
+	revisions map[string][]revisionRow  // food item id -> revisions, oldest first
+	current   map[string]map[string]any // food item id -> current food_items row
+}
+
+// LoadNutrientHistory loads the revisions and current rows of these food items (two queries).
+// Diary code uses it so old entries keep the totals the user saw when they logged them.
+func LoadNutrientHistory(ctx context.Context, pool *pgxpool.Pool, foodItemIDs []string) (NutrientHistory, error) {
+	history := NutrientHistory{
+		revisions: make(map[string][]revisionRow),
+		current:   make(map[string]map[string]any),
+	}
+	if len(foodItemIDs) == 0 {
+		return history, nil
+	}
+
+	revisions, err := loadRevisions(ctx, pool, foodItemIDs)
+	if err != nil {
+		return NutrientHistory{}, err
+	}
+	for _, row := range revisions {
+		history.revisions[row.foodItemID] = append(history.revisions[row.foodItemID], row)
+	}
+
+	const query = `SELECT id, to_jsonb(food_items) FROM food_items WHERE id = ANY($1)`
+	rows, err := pool.Query(ctx, query, foodItemIDs)
+	if err != nil {
+		return NutrientHistory{}, fmt.Errorf("query food_items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id      string
+			current []byte
+		)
+		if err := rows.Scan(&id, &current); err != nil {
+			return NutrientHistory{}, fmt.Errorf("scan food_items: %w", err)
+		}
+		var snapshot map[string]any
+		if err := json.Unmarshal(current, &snapshot); err != nil {
+			return NutrientHistory{}, fmt.Errorf("decode food_items row: %w", err)
+		}
+		history.current[id] = snapshot
+	}
+	if rows.Err() != nil {
+		return NutrientHistory{}, fmt.Errorf("iterate food_items: %w", rows.Err())
+	}
+	return history, nil
+}
+
+// NutrientsAsOf resolves a food item's per-100g nutrients as they were at a point in time.
+// found=false means the item has no history and no current row (or wasn't loaded).
+func (h NutrientHistory) NutrientsAsOf(foodItemID string, at time.Time) (FoodItemNutrients, bool) {
+	if snapshot, ok := snapshotAsOf(h.revisions[foodItemID], at); ok {
+		return nutrientsFromSnapshot(snapshot), true
+	}
+
+	// No history yet (row predates revision tracking and never changed): current values are correct.
+	if current, ok := h.current[foodItemID]; ok {
+		return nutrientsFromSnapshot(current), true
+	}
+	return FoodItemNutrients{}, false
+}
+
+// snapshotAsOf picks the row snapshot that was live at `at`.
+// Example (revisions at Jan 1, Jan 10):
+//   - at=Jan 5  -> snapshot of the Jan 1 revision
+//   - at=Dec 31 -> "previous" of the Jan 1 revision (the row before tracking saw it change)
+//
+// Returns false when there is no history to consult.
+func snapshotAsOf(revisions []revisionRow, at time.Time) (map[string]any, bool) {
+	if len(revisions) == 0 {
+		return nil, false
+	}
+
+	// Walk newest -> oldest and take the first revision written at or before `at`.
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if !revisions[i].createdAt.After(at) {
+			return revisions[i].snapshot, true
+		}
+	}
+
+	// Every revision is newer than `at`: use the pre-change row of the first one.
+	first := revisions[0]
+	if first.previous != nil {
+		return first.previous, true
+	}
+	// The item didn't exist yet; the closest answer is what it was created with.
+	return first.snapshot, true
+}
+
+// nutrientsFromSnapshot maps a to_jsonb(food_items) row into the API nutrient shape.
+func nutrientsFromSnapshot(snapshot map[string]any) FoodItemNutrients {
+	calories, _ := getSnapshotFloat(snapshot, "calories_per_100g")
+	protein, _ := getSnapshotFloat(snapshot, "protein_g")
+	carbs, _ := getSnapshotFloat(snapshot, "carbs_g")
+	fat, _ := getSnapshotFloat(snapshot, "fat_g")
+
+	nutrients := FoodItemNutrients{
+		CaloriesKcal: calories,
+		ProteinG:     protein,
+		CarbsG:       carbs,
+		FatG:         fat,
+	}
+	if fiber, ok := getSnapshotFloat(snapshot, "fiber_g"); ok {
+		nutrients.FiberG = &fiber
+	}
+	if sugar, ok := getSnapshotFloat(snapshot, "sugar_g"); ok {
+		nutrients.SugarG = &sugar
+	}
+	if sodiumMg, ok := getSnapshotFloat(snapshot, "sodium_mg"); ok {
+		sodiumG := sodiumMg / 1000 // DB stores mg, API returns g
+		nutrients.SodiumG = &sodiumG
+	}
+	return nutrients
+}
+
+// getSnapshotFloat reads a numeric column from a JSON row (null/missing -> false).
+func getSnapshotFloat(snapshot map[string]any, key string) (float64, bool) {
+	value, ok := snapshot[key].(float64)
+	return value, ok
+}
+
+// NewRevisionsHandler serves GET /v1/barcodes/:code/revisions (newest first).
+func NewRevisionsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		barcode := c.Param("code")
+		if !validateBarcode(c, barcode) {
+			return
+		}
+		normalizedBarcode := normalizeBarcode(barcode)
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		foodItemID, rows, found, err := listFoodItemRevisionsFunc(c.Request.Context(), pool, normalizedBarcode)
+		if err != nil {
+			requestID := c.GetHeader("X-Request-ID")
+			log.Printf("revision_read_error request_id=%s barcode=%s err=%v", requestID, barcode, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load revisions")
+			return
+		}
+		if !found {
+			httpx.WriteError(c, 404, "NOT_FOUND", "Product not found")
+			return
+		}
+
+		revisions := make([]FoodItemRevision, 0, len(rows))
+		for i := len(rows) - 1; i >= 0; i-- { // newest first for the UI
+			revisions = append(revisions, rows[i].revision)
+		}
+
+		c.JSON(200, gin.H{
+			"food_item_id": foodItemID,
+			"barcode":      normalizedBarcode,
+			"revisions":    revisions,
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package barcode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// revisionAt builds a revision row with a protein value (enough to tell snapshots apart).
+func revisionAt(revision int, createdAt time.Time, protein float64, previousProtein *float64) revisionRow {
+	row := revisionRow{
+		revision:  FoodItemRevision{Revision: revision},
+		snapshot:  map[string]any{"protein_g": protein},
+		createdAt: createdAt,
+	}
+	if previousProtein != nil {
+		row.previous = map[string]any{"protein_g": *previousProtein}
+	}
+	return row
+}
+
+func TestNutrientHistory_NutrientsAsOf(t *testing.T) {
+	jan1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	jan10 := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	before := 2.0 // protein before the first tracked change
+
+	history := NutrientHistory{
+		revisions: map[string][]revisionRow{
+			"item_1": {
+				revisionAt(1, jan1, 3, &before), // first tracked change (update of an older row)
+				revisionAt(2, jan10, 4, nil),    // later refresh
+			},
+		},
+		current: map[string]map[string]any{
+			"item_1": {"protein_g": 4.0},
+			"item_2": {"protein_g": 7.0}, // predates revision tracking, never changed
+		},
+	}
+
+	testCases := []struct {
+		name   string    // case label
+		itemID string    // food item to resolve
+		at     time.Time // point in time to resolve
+		want   float64   // expected protein_g
+	}{
+		{name: "between revisions", itemID: "item_1", at: jan1.Add(48 * time.Hour), want: 3},
+		{name: "exactly at revision", itemID: "item_1", at: jan10, want: 4},
+		{name: "after all revisions", itemID: "item_1", at: jan10.Add(time.Hour), want: 4},
+		{name: "before history", itemID: "item_1", at: jan1.Add(-time.Hour), want: 2},
+		{name: "no history uses current row", itemID: "item_2", at: jan1, want: 7},
+	}
+
+	for _, tc := range testCases {
+		nutrients, ok := history.NutrientsAsOf(tc.itemID, tc.at)
+		if !ok {
+			t.Fatalf("%s: expected nutrients", tc.name)
+		}
+		if nutrients.ProteinG != tc.want {
+			t.Fatalf("%s: want protein %.1f got %.1f", tc.name, tc.want, nutrients.ProteinG)
+		}
+	}
+
+	if _, ok := history.NutrientsAsOf("item_unknown", jan1); ok {
+		t.Fatalf("expected no nutrients for an unknown item")
+	}
+}
+
+func TestNutrientsFromSnapshot(t *testing.T) {
+	// Decode the way Postgres hands us to_jsonb(food_items).
+	var snapshot map[string]any
+	raw := `{"calories_per_100g": 120, "protein_g": 3, "carbs_g": 20, "fat_g": 5, "fiber_g": null, "sugar_g": 8, "sodium_mg": 500}`
+	if err := json.Unmarshal([]byte(raw), &snapshot); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+
+	nutrients := nutrientsFromSnapshot(snapshot)
+	if nutrients.CaloriesKcal != 120 || nutrients.ProteinG != 3 {
+		t.Fatalf("expected macros to map, got %+v", nutrients)
+	}
+	if nutrients.FiberG != nil {
+		t.Fatalf("expected null fiber to stay nil")
+	}
+	if nutrients.SodiumG == nil || *nutrients.SodiumG != 0.5 {
+		t.Fatalf("expected sodium mg -> g conversion")
+	}
+}
+
+func TestRevisionsHandler_NewestFirst(t *testing.T) {
+	orig := listFoodItemRevisionsFunc
+	defer func() { listFoodItemRevisionsFunc = orig }()
+	listFoodItemRevisionsFunc = func(context.Context, *pgxpool.Pool, string) (string, []revisionRow, bool, error) {
+		now := time.Now()
+		return "item_1", []revisionRow{
+			revisionAt(1, now.Add(-time.Hour), 3, nil),
+			revisionAt(2, now, 4, nil),
+		}, true, nil
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		c.Next()
+	})
+	router.GET("/v1/barcodes/:code/revisions", NewRevisionsHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/barcodes/123456789/revisions", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		FoodItemID string             `json:"food_item_id"`
+		Revisions  []FoodItemRevision `json:"revisions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.FoodItemID != "item_1" || len(body.Revisions) != 2 {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+	if body.Revisions[0].Revision != 2 {
+		t.Fatalf("expected newest revision first, got %d", body.Revisions[0].Revision)
+	}
+}
@@ -3,6 +3,7 @@ package barcode
 import (
 	"context"      // request-scoped context for DB calls
 	"database/sql" // NullFloat64/NullString for nullable DB columns
+	"encoding/json"
 	"errors"
 	"fmt" // formatted errors
 	"regexp"
@@ -44,12 +45,12 @@ func parseServingSize(raw string) (float64, string) {
 // In Go float64 and string are value types, so they always have a default value (0 and ""). They can’t be nil
 // To represent “missing,” you use a pointer (*float64, *string) or a nullable wrapper (sql.NullFloat64, sql.NullString).
 // A *float64 can be nil, which JSON will emit as null.
-// nullFloat64ToPtr converts a nullable float into a *float64 for JSON
+// NullFloat64ToPtr converts a nullable float into a *float64 for JSON
 // Example:
 //
 //	value=sql.NullFloat64{Float64: 12.5, Valid: true}  -> *float64(12.5)
 //	value=sql.NullFloat64{Valid: false}                -> nil
-func nullFloat64ToPtr(value sql.NullFloat64) *float64 {
+func NullFloat64ToPtr(value sql.NullFloat64) *float64 {
 	if !value.Valid { // NULL in DB means unknown
 		return nil
 	}
@@ -70,11 +71,13 @@ func nullStringToValue(value sql.NullString) string {
 }
 
 // getFoodItemByBarcode loads a cached food item and updated_at by barcode.
+// A row merged into another (duplicate cleanup) resolves to the surviving row.
 func getFoodItemByBarcode(ctx context.Context, pool *pgxpool.Pool, barcode string) (FoodItem, time.Time, bool, error) {
 	const query = `
 		SELECT
 			id,
-			barcode,
+			-- The scanned barcode: a merge survivor may have a different one (or none).
+			$1::text AS barcode,
 			name,
 			brand,
 			-- COALESCE picks the first non-NULL value, so we default unit to "g" when missing.
@@ -87,42 +90,66 @@ func getFoodItemByBarcode(ctx context.Context, pool *pgxpool.Pool, barcode strin
 			fiber_g::float8,
 			sugar_g::float8,
 			(sodium_mg / 1000.0)::float8 AS sodium_g,
-			updated_at
+			data_quality_score,
+			data_quality_flags,
+			category_tags,
+			COALESCE(nutriscore_grade, ''),
+			COALESCE(nova_group, 0),
+			updated_at,
+			COALESCE(off_country, ''),
+			source = 'open_food_facts' AS from_upstream,
+			-- The scanned row was merged into this one (see lookupItem: never refreshed from OFF).
+			id <> (SELECT id FROM food_items WHERE barcode = $1) AS merged
 		FROM food_items
-		WHERE barcode = $1
-		LIMIT 1
+		WHERE id = (SELECT COALESCE(merged_into_id, id) FROM food_items WHERE barcode = $1)
 	` // SQL query for cached food item (sodium mg -> g)
 
 	var (
-		id          string          // food_items.id
-		dbBarcode   string          // food_items.barcode
-		name        string          // food_items.name
-		brand       sql.NullString  // nullable brand
-		servingSize string          // composed serving size string
-		calories    float64         // calories_per_100g
-		protein     float64         // protein_g
-		carbs       float64         // carbs_g
-		fat         float64         // fat_g
-		fiber       sql.NullFloat64 // fiber_g (nullable)
-		sugar       sql.NullFloat64 // sugar_g (nullable)
-		sodium      sql.NullFloat64 // sodium_g (nullable)
-		updatedAt   time.Time       // updated_at
+		id           string          // food_items.id
+		dbBarcode    string          // food_items.barcode
+		name         string          // food_items.name
+		brand        sql.NullString  // nullable brand
+		servingSize  string          // composed serving size string
+		calories     float64         // calories_per_100g
+		protein      float64         // protein_g
+		carbs        float64         // carbs_g
+		fat          float64         // fat_g
+		fiber        sql.NullFloat64 // fiber_g (nullable)
+		sugar        sql.NullFloat64 // sugar_g (nullable)
+		sodium       sql.NullFloat64 // sodium_g (nullable)
+		qualityScore *int32          // data_quality_score (NULL for rows cached before validation)
+		qualityFlags []string        // data_quality_flags
+		categories   []string        // category_tags
+		nutriscore   string          // nutriscore_grade ("" when unknown)
+		novaGroup    int32           // nova_group (0 when unknown)
+		updatedAt    time.Time       // updated_at
+		country      string          // off_country ("" when unknown)
+		fromUpstream bool            // source = open_food_facts
+		merged       bool            // scanned barcode resolves to a merge survivor
 	)
 
 	err := pool.QueryRow(ctx, query, barcode).Scan(
-		&id,          // scan id
-		&dbBarcode,   // scan barcode
-		&name,        // scan name
-		&brand,       // scan brand (nullable)
-		&servingSize, // scan serving size string
-		&calories,    // scan calories
-		&protein,     // scan protein
-		&carbs,       // scan carbs
-		&fat,         // scan fat
-		&fiber,       // scan fiber (nullable)
-		&sugar,       // scan sugar (nullable)
-		&sodium,      // scan sodium (nullable)
-		&updatedAt,   // scan updated_at
+		&id,           // scan id
+		&dbBarcode,    // scan barcode
+		&name,         // scan name
+		&brand,        // scan brand (nullable)
+		&servingSize,  // scan serving size string
+		&calories,     // scan calories
+		&protein,      // scan protein
+		&carbs,        // scan carbs
+		&fat,          // scan fat
+		&fiber,        // scan fiber (nullable)
+		&sugar,        // scan sugar (nullable)
+		&sodium,       // scan sodium (nullable)
+		&qualityScore, // scan data quality score (nullable)
+		&qualityFlags, // scan data quality flags
+		&categories,   // scan category tags
+		&nutriscore,   // scan Nutri-Score grade
+		&novaGroup,    // scan NOVA group
+		&updatedAt,    // scan updated_at
+		&country,      // scan OFF country
+		&fromUpstream, // scan upstream flag
+		&merged,       // scan merged flag
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) { // no cached row exists
@@ -142,18 +169,46 @@ func getFoodItemByBarcode(ctx context.Context, pool *pgxpool.Pool, barcode strin
 			ProteinG:     protein,                  // per-100g protein
 			CarbsG:       carbs,                    // per-100g carbs
 			FatG:         fat,                      // per-100g fat
-			FiberG:       nullFloat64ToPtr(fiber),  // nullable fiber
-			SugarG:       nullFloat64ToPtr(sugar),  // nullable sugar
-			SodiumG:      nullFloat64ToPtr(sodium), // nullable sodium (g)
+			FiberG:       NullFloat64ToPtr(fiber),  // nullable fiber
+			SugarG:       NullFloat64ToPtr(sugar),  // nullable sugar
+			SodiumG:      NullFloat64ToPtr(sodium), // nullable sodium (g)
 		},
-		ImageUrl: "", // DB doesn't store image URL yet
+		ImageUrl:   "",             // DB doesn't store image URL yet
+		Categories: categories,     // OFF category tags
+		Nutriscore: nutriscore,     // Nutri-Score grade
+		NovaGroup:  int(novaGroup), // NOVA group
+		merged:     merged,         // served without OFF refreshes
+
+		country:      country,      // country the nutrients were fetched for
+		fromUpstream: fromUpstream, // refetched for another country
+	}
+	if qualityScore != nil { // rows cached before validation have no verdict
+		item.DataQuality = dataQualityFromColumns(int(*qualityScore), qualityFlags)
 	}
 
 	return item, updatedAt, true, nil // found cached item
 }
 
+// hasContribution reports whether the barcode's food_items row came from an approved user
+// contribution (still waiting for write-back, submitted, or given up on).
+func hasContribution(ctx context.Context, pool *pgxpool.Pool, barcode string) (bool, error) {
+	const query = `
+		SELECT EXISTS (
+			SELECT 1 FROM product_contributions
+			WHERE barcode = $1 AND status IN ('approved', 'submitted', 'failed')
+		)
+	`
+	var exists bool
+	if err := pool.QueryRow(ctx, query, barcode).Scan(&exists); err != nil {
+		return false, fmt.Errorf("query product_contributions: %w", err)
+	}
+	return exists, nil
+}
+
 // upsertFoodItem writes the upstream product into food_items for caching.
-func upsertFoodItem(ctx context.Context, pool *pgxpool.Pool, product *openfoodfacts.Product, barcode string, servingSizeG float64, servingSizeUnit string) error {
+// country is the OFF country database the product was fetched from (its formulation).
+// quality is the validation verdict; only cacheable products should reach this point.
+func upsertFoodItem(ctx context.Context, pool *pgxpool.Pool, product *openfoodfacts.Product, barcode string, country string, servingSizeG float64, servingSizeUnit string, quality DataQuality) error {
 	if product == nil { // guard: we cannot write a nil product
 		return fmt.Errorf("product is nil")
 	}
@@ -178,6 +233,20 @@ func upsertFoodItem(ctx context.Context, pool *pgxpool.Pool, product *openfoodfa
 		brand = &product.Brands // pointer to real brand string
 	}
 
+	// Health attributes for alternatives: NULL when unknown, never a nil tag array.
+	categories := product.CategoriesTags
+	if categories == nil {
+		categories = []string{}
+	}
+	var nutriscore *string // nullable grade
+	if grade := normalizeNutriscore(product.NutritionGrades); grade != "" {
+		nutriscore = &grade
+	}
+	var novaGroup *int // nullable NOVA group
+	if group := normalizeNovaGroup(product.Nutriments.NovaGroup); group != 0 {
+		novaGroup = &group
+	}
+
 	// Source id: prefer upstream ID, fallback to barcode if empty.
 	sourceID := product.Id
 	if sourceID == "" {
@@ -202,11 +271,20 @@ func upsertFoodItem(ctx context.Context, pool *pgxpool.Pool, product *openfoodfa
 			source,
 			source_id,
 			verified,
-			created_by
+			created_by,
+			data_quality_score,
+			data_quality_flags,
+			category_tags,
+			nutriscore_grade,
+			nova_group,
+			off_country
 		) VALUES (
 			$1, $2, $3, $4, $5,
 			$6, $7, $8, $9, $10, $11, $12,
-			'open_food_facts', $13, false, NULL
+			'open_food_facts', $13, false, NULL,
+			$14, $15,
+			$16, $17, $18,
+			$19
 		)
 		ON CONFLICT (barcode) DO UPDATE SET
 			name = EXCLUDED.name,
@@ -222,27 +300,43 @@ func upsertFoodItem(ctx context.Context, pool *pgxpool.Pool, product *openfoodfa
 			sodium_mg = EXCLUDED.sodium_mg,
 			source = EXCLUDED.source,
 			source_id = EXCLUDED.source_id,
+			data_quality_score = EXCLUDED.data_quality_score,
+			data_quality_flags = EXCLUDED.data_quality_flags,
+			category_tags = EXCLUDED.category_tags,
+			nutriscore_grade = EXCLUDED.nutriscore_grade,
+			nova_group = EXCLUDED.nova_group,
+			off_country = EXCLUDED.off_country,
 			updated_at = now()
-		WHERE food_items.source = 'open_food_facts'
+		WHERE food_items.source = 'open_food_facts' AND food_items.merged_into_id IS NULL
 	`
 
-	_, err := pool.Exec(
-		ctx,   // request-scoped context
-		query, // SQL upsert statement
-		product.ProductName,                 // name
-		brand,                               // brand (nullable)
-		barcode,                             // barcode (unique key)
-		servingSizeG,                        // serving size value (g)
-		servingSizeUnit,                     // serving size unit ("g")
-		product.Nutriments.Energy100G,       // calories per 100g
-		product.Nutriments.Proteins100G,     // protein per 100g
-		product.Nutriments.Carbohydrates100G, // carbs per 100g
-		product.Nutriments.Fat100G,          // fat per 100g
-		fiber,                               // fiber per 100g (nullable)
-		sugar,                               // sugar per 100g (nullable)
-		sodiumMg,                            // sodium in mg (nullable)
-		sourceID,                            // upstream source id
-	)
+	// Tag the write so the food_items trigger records it as an upstream refresh in food_item_revisions.
+	err := withChangeContext(ctx, pool, changeSourceUpstreamRefresh, changedByBarcodeService, func(tx pgx.Tx) error {
+		_, err := tx.Exec(
+			ctx,                                  // request-scoped context
+			query,                                // SQL upsert statement
+			baseProductName(product),             // world/English name (localized names live in food_item_localizations)
+			brand,                                // brand (nullable)
+			barcode,                              // barcode (unique key)
+			servingSizeG,                         // serving size value (g)
+			servingSizeUnit,                      // serving size unit ("g")
+			product.Nutriments.Energy100G,        // calories per 100g
+			product.Nutriments.Proteins100G,      // protein per 100g
+			product.Nutriments.Carbohydrates100G, // carbs per 100g
+			product.Nutriments.Fat100G,           // fat per 100g
+			fiber,                                // fiber per 100g (nullable)
+			sugar,                                // sugar per 100g (nullable)
+			sodiumMg,                             // sodium in mg (nullable)
+			sourceID,                             // upstream source id
+			quality.Score,                        // plausibility score (0-100)
+			quality.Flags,                        // data-quality flag codes
+			categories,                           // OFF category tags
+			nutriscore,                           // Nutri-Score grade (nullable)
+			novaGroup,                            // NOVA group (nullable)
+			country,                              // OFF country database
+		)
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("upsert food_items: %w", err) // wrap DB error for logging
@@ -250,3 +344,127 @@ func upsertFoodItem(ctx context.Context, pool *pgxpool.Pool, product *openfoodfa
 
 	return nil // success
 }
+
+// dataQualityFromColumns rebuilds the verdict stored on food_items.
+// The status isn't stored; it follows from the flags (invalid rows are never cached).
+func dataQualityFromColumns(score int, flags []string) *DataQuality {
+	quality := DataQuality{Score: score, Status: QualityOK, Flags: []string{}}
+	for _, flag := range flags {
+		quality.Flags = append(quality.Flags, flag)
+		switch flag {
+		case FlagEnergyConvertedFromKJ, FlagSodiumConvertedFromMg, FlagSodiumDerivedFromSalt:
+			if qualityRank(QualityCorrected) > qualityRank(quality.Status) {
+				quality.Status = QualityCorrected
+			}
+		default:
+			quality.Status = QualitySuspect
+		}
+	}
+	return &quality
+}
+
+// quarantineFoodItem parks an implausible upstream product for review instead of caching it.
+// One row per barcode; repeat sightings refresh the payload and bump seen_count.
+func quarantineFoodItem(ctx context.Context, pool *pgxpool.Pool, product *openfoodfacts.Product, barcode string, quality DataQuality) error {
+	if product == nil || barcode == "" { // nothing to park
+		return nil
+	}
+
+	payload, err := json.Marshal(product) // raw upstream product (after unit fixes) for reviewers
+	if err != nil {
+		return fmt.Errorf("encode quarantined product: %w", err)
+	}
+
+	const query = `
+		INSERT INTO food_item_quarantine (
+			barcode,
+			source,
+			source_id,
+			payload,
+			quality_score,
+			quality_flags
+		) VALUES ($1, 'open_food_facts', $2, $3, $4, $5)
+		ON CONFLICT (barcode) DO UPDATE SET
+			source_id = EXCLUDED.source_id,
+			payload = EXCLUDED.payload,
+			quality_score = EXCLUDED.quality_score,
+			quality_flags = EXCLUDED.quality_flags,
+			seen_count = food_item_quarantine.seen_count + 1,
+			updated_at = now()
+	`
+
+	if _, err := pool.Exec(ctx, query, barcode, product.Id, payload, quality.Score, quality.Flags); err != nil {
+		return fmt.Errorf("upsert food_item_quarantine: %w", err)
+	}
+	return nil
+}
+
+// getFoodItemLocalization loads the cached localized name/ingredients for a food item,
+// plus the language they are actually in (a fallback row for a language OFF doesn't have).
+// Example: (item_1, "fr") -> ("Pâte à tartiner", "sucre, huile de palme, ...", "fr", true)
+func getFoodItemLocalization(ctx context.Context, pool *pgxpool.Pool, foodItemID string, language string) (string, string, string, bool, error) {
+	const query = `
+		SELECT name, COALESCE(ingredients_text, ''), COALESCE(content_language, language)
+		FROM food_item_localizations
+		WHERE food_item_id = $1 AND language = $2
+		LIMIT 1
+	`
+
+	var name, ingredients, contentLanguage string
+	err := pool.QueryRow(ctx, query, foodItemID, language).Scan(&name, &ingredients, &contentLanguage)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) { // nothing cached for this language
+			return "", "", "", false, nil
+		}
+		return "", "", "", false, fmt.Errorf("query food_item_localizations: %w", err)
+	}
+
+	return name, ingredients, contentLanguage, true, nil
+}
+
+// upsertFoodItemLocalization caches a localized name for the food item with this barcode.
+// One row per (food_item_id, language); the base food_items row keeps the world/English name.
+// contentLanguage is the language the name is really in: it differs from language when OFF
+// had no translation and we cache the fallback we got (so that language isn't fetched again).
+func upsertFoodItemLocalization(ctx context.Context, pool *pgxpool.Pool, barcode string, language string, contentLanguage string, name string, ingredients string) error {
+	if barcode == "" || language == "" || name == "" { // nothing useful to cache
+		return nil
+	}
+
+	// Use NULL for ingredients when upstream had none.
+	var ingredientsText *string
+	if ingredients != "" {
+		ingredientsText = &ingredients
+	}
+
+	// NULL content_language = the row is in its own language (the common case).
+	var contentLanguageText *string
+	if contentLanguage != "" && contentLanguage != language {
+		contentLanguageText = &contentLanguage
+	}
+
+	// Resolve food_item_id from the barcode inside the same statement (row was just upserted).
+	const query = `
+		INSERT INTO food_item_localizations (
+			food_item_id,
+			language,
+			name,
+			ingredients_text,
+			content_language
+		)
+		SELECT id, $2, $3, $4, $5
+		FROM food_items
+		WHERE barcode = $1
+		ON CONFLICT (food_item_id, language) DO UPDATE SET
+			name = EXCLUDED.name,
+			ingredients_text = EXCLUDED.ingredients_text,
+			content_language = EXCLUDED.content_language,
+			updated_at = now()
+	`
+
+	if _, err := pool.Exec(ctx, query, barcode, language, name, ingredientsText, contentLanguageText); err != nil {
+		return fmt.Errorf("upsert food_item_localizations: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package barcode
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"healthmetrics-services/internal/units"
+)
+
+// servingAmountRegex finds the metric amount inside free-text serving sizes:
+// "30 g", "2 tbsp (32g)", "1 can (330 ml)". Unlike parseServingSize there is no 100 g default;
+// an unparseable serving simply gets no converted value.
+var servingAmountRegex = regexp.MustCompile(`([0-9]+(?:[.,][0-9]+)?)\s*(g|ml)\b`)
+
+// parseServingAmount returns the first gram/ml amount in raw; ok=false when there is none.
+func parseServingAmount(raw string) (float64, string, bool) {
+	matches := servingAmountRegex.FindStringSubmatch(strings.ToLower(raw))
+	if len(matches) != 3 {
+		return 0, "", false
+	}
+	value, err := strconv.ParseFloat(strings.Replace(matches[1], ",", ".", 1), 64)
+	if err != nil || value <= 0 {
+		return 0, "", false
+	}
+	return value, matches[2], true
+}
+
+// ServingAmount is the serving as an amount in g or ml ("2 tbsp (32g)" -> 32, "g");
+// ok=false when the serving text has no metric amount.
+func (item FoodItem) ServingAmount() (float64, string, bool) {
+	return parseServingAmount(item.ServingSize)
+}
+
+// applyUnits adds the display fields for system. Canonical fields (serving_size, per-100g
+// nutrients) are left as they are; nutrient amounts stay in g like on US labels.
+func applyUnits(item *FoodItem, system units.System) {
+	item.Units = string(system)
+	item.ServingSizeDisplay = nil
+	if value, unit, ok := parseServingAmount(item.ServingSize); ok {
+		display := units.Amount(value, unit, system)
+		item.ServingSizeDisplay = &display
+	}
+}
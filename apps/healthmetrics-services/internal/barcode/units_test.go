@@ -0,0 +1,35 @@
+package barcode
+
+import (
+	"testing"
+
+	"healthmetrics-services/internal/units"
+)
+
+func TestApplyUnits(t *testing.T) {
+	tests := []struct {
+		servingSize string
+		system      units.System
+		want        *units.Quantity
+	}{
+		{"30 g", units.Imperial, &units.Quantity{Value: 1.1, Unit: "oz"}},
+		{"2 tbsp (32g)", units.Metric, &units.Quantity{Value: 32, Unit: "g"}},
+		{"1 can (330 ml)", units.Imperial, &units.Quantity{Value: 11.2, Unit: "fl oz"}},
+		{"12,5 g", units.Metric, &units.Quantity{Value: 12.5, Unit: "g"}},
+		{"1 slice", units.Imperial, nil}, // no metric amount to convert
+		{"", units.Imperial, nil},
+	}
+	for _, tt := range tests {
+		item := FoodItem{ServingSize: tt.servingSize}
+		applyUnits(&item, tt.system)
+		if item.Units != string(tt.system) {
+			t.Errorf("%q: units = %q", tt.servingSize, item.Units)
+		}
+		switch {
+		case tt.want == nil && item.ServingSizeDisplay != nil:
+			t.Errorf("%q: expected no display value, got %+v", tt.servingSize, *item.ServingSizeDisplay)
+		case tt.want != nil && (item.ServingSizeDisplay == nil || *item.ServingSizeDisplay != *tt.want):
+			t.Errorf("%q: got %+v, want %+v", tt.servingSize, item.ServingSizeDisplay, *tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package contribution turns NOT_FOUND scans into product contributions: users submit what is on
+// the label (name, brand, nutrition panel, photos), an admin reviews it, and approved products are
+// cached in food_items right away and written back to OpenFoodFacts with our app account.
+//
+// Status flow (product_contributions.status):
+//
+//	pending --approve--> approved --OFF accepted--> submitted
+//	        \--reject--> rejected         \--gave up--> failed
+package contribution
+
+import (
+	"time"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// Statuses mirror the ContributionStatus enum in prisma/schema.prisma.
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusSubmitted = "submitted"
+	StatusFailed    = "failed"
+)
+
+// photoKinds are the label photos OpenFoodFacts knows about (imagefield front_<lc>, ...).
+var photoKinds = map[string]bool{
+	"front":       true,
+	"nutrition":   true,
+	"ingredients": true,
+}
+
+// defaultServingSizeG is used for food_items when the label had no gram serving size
+// (matches barcode.parseServingSize).
+const defaultServingSizeG = 100.0
+
+// Contribution is a product_contributions row as returned by the API.
+// Nutrients are per 100 g with sodium in grams, like barcode.FoodItem.
+type Contribution struct {
+	ID               string                    `json:"id"`
+	UserID           string                    `json:"user_id"`
+	Barcode          string                    `json:"barcode"`
+	Name             string                    `json:"name"`
+	Brand            string                    `json:"brand"`
+	Quantity         string                    `json:"quantity"`     // as printed, e.g. "500 g"
+	ServingSize      string                    `json:"serving_size"` // as printed, e.g. "30 g"
+	ServingSizeG     *float64                  `json:"serving_size_g"`
+	Ingredients      string                    `json:"ingredients"`
+	Language         string                    `json:"language"` // label language (OFF lc)
+	Nutrients        barcode.FoodItemNutrients `json:"nutrients_per_100g"`
+	DataQualityFlags []string                  `json:"data_quality_flags"` // same codes as barcode.DataQuality
+	Photos           []string                  `json:"photos"`             // photo kinds uploaded so far
+	Status           string                    `json:"status"`
+	ReviewNote       *string                   `json:"review_note"`
+	ReviewedAt       *time.Time                `json:"reviewed_at"`
+	FoodItemID       *string                   `json:"food_item_id"` // set once approved
+	OFFAttempts      int                       `json:"off_attempts"`
+	OFFLastError     *string                   `json:"off_last_error"`
+	OFFSubmittedAt   *time.Time                `json:"off_submitted_at"`
+	CreatedAt        time.Time                 `json:"created_at"`
+}
+
+// newContribution is a validated POST /v1/contributions body, ready to insert.
+type newContribution struct {
+	UserID           string
+	Barcode          string // normalized (EAN-13 form for UPC-A), like food_items.barcode
+	Name             string
+	Brand            string
+	Quantity         string
+	ServingSize      string
+	ServingSizeG     *float64
+	Ingredients      string
+	Language         string
+	Nutrients        barcode.FoodItemNutrients // after barcode.ValidateNutrients unit fixes
+	DataQualityFlags []string
+}
+
+// offProduct maps a contribution to the OpenFoodFacts write payload.
+func offProduct(item Contribution) OFFProduct {
+	var sodiumMg *float64
+	if item.Nutrients.SodiumG != nil {
+		value := *item.Nutrients.SodiumG * 1000
+		sodiumMg = &value
+	}
+	return OFFProduct{
+		Code:         item.Barcode,
+		Name:         item.Name,
+		Brand:        item.Brand,
+		Quantity:     item.Quantity,
+		ServingSize:  item.ServingSize,
+		Ingredients:  item.Ingredients,
+		Language:     item.Language,
+		Comment:      "HealthMetrics user contribution " + item.ID,
+		CaloriesKcal: item.Nutrients.CaloriesKcal,
+		ProteinG:     item.Nutrients.ProteinG,
+		CarbsG:       item.Nutrients.CarbsG,
+		FatG:         item.Nutrients.FatG,
+		FiberG:       item.Nutrients.FiberG,
+		SugarG:       item.Nutrients.SugarG,
+		SodiumMg:     sodiumMg,
+	}
+}
@@ -0,0 +1,416 @@
+package contribution
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"healthmetrics-services/internal/barcode"
+	"healthmetrics-services/internal/httpx"
+)
+
+// Request limits (keep values inside the product_contributions columns and OFF's field sizes).
+const (
+	maxNameLen        = 200
+	maxBrandLen       = 200
+	maxQuantityLen    = 100
+	maxIngredientsLen = 5000
+	maxNoteLen        = 1000
+	maxPhotoBytes     = 5 << 20 // 5 MB per photo; label photos from phones are well under this
+	defaultListLimit  = 50
+	maxListLimit      = 200
+)
+
+var (
+	languageRegex     = regexp.MustCompile(`^[a-z]{2}$`)                          // OFF language codes (lc)
+	servingSizeGRegex = regexp.MustCompile(`^\s*([0-9]+(?:[.,][0-9]+)?)\s*g\s*$`) // "30 g", "30g", "30,5 g"
+)
+
+// CreateRequest is the POST /v1/contributions body. Nutrition is per 100 g as printed on the label;
+// calories, protein, carbs and fat are required, the rest are optional.
+// Example:
+//
+//	{"barcode": "4006381333931", "name": "Oat Crunch", "brand": "Acme", "quantity": "500 g",
+//	 "serving_size": "40 g", "language": "en",
+//	 "nutrients_per_100g": {"calories_kcal": 412, "protein_g": 9.5, "carbs_g": 64, "fat_g": 12, "sodium_g": 0.2}}
+type CreateRequest struct {
+	Barcode     string         `json:"barcode"`
+	Name        string         `json:"name"`
+	Brand       string         `json:"brand"`
+	Quantity    string         `json:"quantity"`
+	ServingSize string         `json:"serving_size"`
+	Ingredients string         `json:"ingredients"`
+	Language    string         `json:"language"` // defaults to "en"
+	Nutrients   NutrientsInput `json:"nutrients_per_100g"`
+}
+
+// NutrientsInput uses pointers so a missing value is not mistaken for 0.
+type NutrientsInput struct {
+	CaloriesKcal *float64 `json:"calories_kcal"`
+	ProteinG     *float64 `json:"protein_g"`
+	CarbsG       *float64 `json:"carbs_g"`
+	FatG         *float64 `json:"fat_g"`
+	FiberG       *float64 `json:"fiber_g"`
+	SugarG       *float64 `json:"sugar_g"`
+	SodiumG      *float64 `json:"sodium_g"`
+}
+
+// ReviewRequest is the POST /v1/admin/contributions/:id/review body.
+type ReviewRequest struct {
+	Decision string `json:"decision"` // approve | reject
+	Note     string `json:"note"`     // shown to the contributor
+}
+
+// NewCreateHandler handles POST /v1/contributions (the "add this product" form after a NOT_FOUND scan).
+// Nutrition goes through the same plausibility checks as OpenFoodFacts data: impossible values are a
+// 422, obvious unit slips (kJ, sodium in mg) are fixed and flagged for the reviewer.
+func NewCreateHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+
+		userID := c.GetString("userID") // set by auth middleware after session validation
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+
+		var req CreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "Invalid JSON body")
+			return
+		}
+		if message := barcode.BarcodeError(req.Barcode); message != "" {
+			httpx.WriteError(c, 400, "INVALID_BARCODE", message)
+			return
+		}
+		input, message := buildContribution(userID, req)
+		if message != "" {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", message)
+			return
+		}
+
+		nutrients, quality := barcode.ValidateNutrients(input.Nutrients)
+		if !quality.Cacheable() {
+			c.JSON(422, gin.H{
+				"error": map[string]interface{}{
+					"code":    "INVALID_NUTRITION",
+					"message": "Nutrition values are not plausible; please check the label",
+				},
+				"data_quality": quality,
+			})
+			return
+		}
+		input.Nutrients = nutrients
+		input.DataQualityFlags = quality.Flags
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		created, conflict, err := createContributionFunc(c.Request.Context(), pool, input)
+		if err != nil {
+			log.Printf("contribution_write_error request_id=%s user_id=%s barcode=%s err=%v", requestID, userID, input.Barcode, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to save contribution")
+			return
+		}
+		switch conflict {
+		case conflictProduct:
+			httpx.WriteError(c, 409, "PRODUCT_EXISTS", "This product is already in the database")
+			return
+		case conflictContribution:
+			httpx.WriteError(c, 409, "CONTRIBUTION_EXISTS", "This product has already been contributed")
+			return
+		}
+
+		log.Printf("contribution_created request_id=%s user_id=%s contribution_id=%s barcode=%s quality=%s",
+			requestID, userID, created.ID, created.Barcode, quality.Status)
+		c.JSON(201, gin.H{"contribution": created, "data_quality": quality})
+	}
+}
+
+// buildContribution validates the body and returns the insert input, or a 400 message.
+func buildContribution(userID string, req CreateRequest) (newContribution, string) {
+	input := newContribution{
+		UserID:      userID,
+		Barcode:     barcode.NormalizeBarcode(req.Barcode),
+		Name:        strings.TrimSpace(req.Name),
+		Brand:       strings.TrimSpace(req.Brand),
+		Quantity:    strings.TrimSpace(req.Quantity),
+		ServingSize: strings.TrimSpace(req.ServingSize),
+		Ingredients: strings.TrimSpace(req.Ingredients),
+		Language:    strings.ToLower(strings.TrimSpace(req.Language)),
+	}
+	if input.Language == "" {
+		input.Language = "en"
+	}
+
+	switch {
+	case input.Name == "":
+		return input, "name is required"
+	case len(input.Name) > maxNameLen:
+		return input, fmt.Sprintf("name must be at most %d characters", maxNameLen)
+	case len(input.Brand) > maxBrandLen:
+		return input, fmt.Sprintf("brand must be at most %d characters", maxBrandLen)
+	case len(input.Quantity) > maxQuantityLen || len(input.ServingSize) > maxQuantityLen:
+		return input, fmt.Sprintf("quantity and serving_size must be at most %d characters", maxQuantityLen)
+	case len(input.Ingredients) > maxIngredientsLen:
+		return input, fmt.Sprintf("ingredients must be at most %d characters", maxIngredientsLen)
+	case !languageRegex.MatchString(input.Language):
+		return input, "language must be a 2-letter code"
+	}
+
+	n := req.Nutrients
+	if n.CaloriesKcal == nil || n.ProteinG == nil || n.CarbsG == nil || n.FatG == nil {
+		return input, "nutrients_per_100g needs calories_kcal, protein_g, carbs_g and fat_g"
+	}
+	input.Nutrients = barcode.FoodItemNutrients{
+		CaloriesKcal: *n.CaloriesKcal,
+		ProteinG:     *n.ProteinG,
+		CarbsG:       *n.CarbsG,
+		FatG:         *n.FatG,
+		FiberG:       n.FiberG,
+		SugarG:       n.SugarG,
+		SodiumG:      n.SodiumG,
+	}
+
+	// Gram serving sizes feed food_items.serving_size_g; anything else ("1 bar") stays text only.
+	if matches := servingSizeGRegex.FindStringSubmatch(strings.ToLower(input.ServingSize)); matches != nil {
+		if value, err := strconv.ParseFloat(strings.Replace(matches[1], ",", ".", 1), 64); err == nil && value > 0 {
+			input.ServingSizeG = &value
+		}
+	}
+	return input, ""
+}
+
+// NewListHandler serves GET /v1/contributions: the caller's own contributions with their status,
+// newest first.
+func NewListHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+		listContributionsResponse(c, userID, c.Query("status"), false)
+	}
+}
+
+// NewAdminListHandler serves GET /v1/admin/contributions?status=pending (the review queue, oldest first).
+// Mount behind auth.RequireAdmin.
+func NewAdminListHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		listContributionsResponse(c, "", c.DefaultQuery("status", StatusPending), true)
+	}
+}
+
+func listContributionsResponse(c *gin.Context, userID, status string, oldestFirst bool) {
+	switch status {
+	case "", StatusPending, StatusApproved, StatusRejected, StatusSubmitted, StatusFailed:
+	default:
+		httpx.WriteError(c, 400, "INVALID_REQUEST", "status must be pending, approved, rejected, submitted or failed")
+		return
+	}
+
+	limit := defaultListLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxListLimit {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	pool, ok := httpx.PoolFromContext(c)
+	if !ok {
+		return
+	}
+
+	items, err := listContributionsFunc(c.Request.Context(), pool, userID, status, oldestFirst, limit)
+	if err != nil {
+		requestID := c.GetHeader("X-Request-ID")
+		log.Printf("contribution_read_error request_id=%s user_id=%s err=%v", requestID, c.GetString("userID"), err)
+		httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load contributions")
+		return
+	}
+	c.JSON(200, gin.H{"contributions": items})
+}
+
+// NewUploadPhotoHandler handles POST /v1/contributions/:id/photos (multipart: kind + image).
+// JPEG/PNG only, detected from the bytes; one photo per kind (re-uploading replaces it).
+// Only the contributor can add photos, and only while the contribution is pending.
+func NewUploadPhotoHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+
+		userID := c.GetString("userID")
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+
+		// Cap the whole body; the small slack covers the multipart framing and the kind field.
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPhotoBytes+64<<10)
+
+		if err := c.Request.ParseMultipartForm(maxPhotoBytes); err != nil {
+			if isBodyTooLarge(err) {
+				httpx.WriteError(c, 413, "IMAGE_TOO_LARGE", fmt.Sprintf("Photos must be at most %d MB", maxPhotoBytes>>20))
+				return
+			}
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "Expected a multipart/form-data body")
+			return
+		}
+		kind := c.Request.FormValue("kind")
+		if !photoKinds[kind] {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "kind must be front, nutrition or ingredients")
+			return
+		}
+		data, ok := readPhoto(c)
+		if !ok {
+			return
+		}
+		contentType := http.DetectContentType(data)
+		if contentType != "image/jpeg" && contentType != "image/png" {
+			httpx.WriteError(c, 415, "UNSUPPORTED_MEDIA_TYPE", "Photos must be JPEG or PNG")
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		contributionID := c.Param("id")
+		ownerID, status, found, err := photoTargetFunc(c.Request.Context(), pool, contributionID)
+		if err != nil {
+			log.Printf("contribution_read_error request_id=%s user_id=%s contribution_id=%s err=%v", requestID, userID, contributionID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load contribution")
+			return
+		}
+		if !found || ownerID != userID {
+			httpx.WriteError(c, 404, "NOT_FOUND", "Contribution not found")
+			return
+		}
+		if status != StatusPending {
+			httpx.WriteError(c, 409, "ALREADY_REVIEWED", "Photos can only be added while the contribution is pending")
+			return
+		}
+
+		if err := upsertPhotoFunc(c.Request.Context(), pool, contributionID, kind, contentType, data); err != nil {
+			log.Printf("contribution_write_error request_id=%s user_id=%s contribution_id=%s kind=%s err=%v", requestID, userID, contributionID, kind, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to save photo")
+			return
+		}
+		c.JSON(201, gin.H{"contribution_id": contributionID, "kind": kind, "content_type": contentType, "bytes": len(data)})
+	}
+}
+
+// readPhoto returns the "image" part, writing a 4xx when it is missing or too large.
+func readPhoto(c *gin.Context) ([]byte, bool) {
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		httpx.WriteError(c, 400, "INVALID_REQUEST", "Multipart field \"image\" is required")
+		return nil, false
+	}
+	defer file.Close()
+	if header.Size > maxPhotoBytes {
+		httpx.WriteError(c, 413, "IMAGE_TOO_LARGE", fmt.Sprintf("Photos must be at most %d MB", maxPhotoBytes>>20))
+		return nil, false
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil || len(data) == 0 {
+		httpx.WriteError(c, 400, "INVALID_REQUEST", "Unreadable image file")
+		return nil, false
+	}
+	return data, true
+}
+
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// NewAdminPhotoHandler serves GET /v1/admin/contributions/:id/photos/:kind (the raw image for reviewers).
+func NewAdminPhotoHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		contributionID, kind := c.Param("id"), c.Param("kind")
+		contentType, data, found, err := getPhotoFunc(c.Request.Context(), pool, contributionID, kind)
+		if err != nil {
+			requestID := c.GetHeader("X-Request-ID")
+			log.Printf("contribution_read_error request_id=%s contribution_id=%s kind=%s err=%v", requestID, contributionID, kind, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load photo")
+			return
+		}
+		if !found {
+			httpx.WriteError(c, 404, "NOT_FOUND", "Photo not found")
+			return
+		}
+		c.Data(200, contentType, data)
+	}
+}
+
+// NewReviewHandler handles POST /v1/admin/contributions/:id/review. Mount behind auth.RequireAdmin.
+// Approving makes the barcode resolve for everyone immediately (food_items row) and queues the
+// OpenFoodFacts write-back; rejecting just closes it with the note.
+func NewReviewHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		reviewerID := c.GetString("userID")
+
+		var req ReviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "Invalid JSON body")
+			return
+		}
+		if req.Decision != "approve" && req.Decision != "reject" {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "decision must be approve or reject")
+			return
+		}
+		note := strings.TrimSpace(req.Note)
+		if len(note) > maxNoteLen {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", fmt.Sprintf("note must be at most %d characters", maxNoteLen))
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		contributionID := c.Param("id")
+		reviewed, err := reviewContributionFunc(c.Request.Context(), pool, contributionID, reviewerID, req.Decision == "approve", note)
+		switch {
+		case errors.Is(err, errNotFound):
+			httpx.WriteError(c, 404, "NOT_FOUND", "Contribution not found")
+			return
+		case errors.Is(err, errNotPending):
+			httpx.WriteError(c, 409, "ALREADY_REVIEWED", "Contribution has already been reviewed")
+			return
+		case errors.Is(err, errProductExists):
+			httpx.WriteError(c, 409, "PRODUCT_EXISTS", "This barcode is already in the database; reject the contribution instead")
+			return
+		case err != nil:
+			log.Printf("contribution_review_error request_id=%s reviewer_id=%s contribution_id=%s err=%v", requestID, reviewerID, contributionID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to review contribution")
+			return
+		}
+
+		log.Printf("contribution_reviewed request_id=%s reviewer_id=%s contribution_id=%s barcode=%s status=%s",
+			requestID, reviewerID, reviewed.ID, reviewed.Barcode, reviewed.Status)
+		c.JSON(200, gin.H{"contribution": reviewed})
+	}
+}
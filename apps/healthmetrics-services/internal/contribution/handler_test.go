@@ -0,0 +1,295 @@
+package contribution
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+func newRouter(userID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		if userID != "" {
+			c.Set("userID", userID)
+		}
+		c.Next()
+	})
+	router.POST("/v1/contributions", NewCreateHandler())
+	router.GET("/v1/contributions", NewListHandler())
+	router.POST("/v1/contributions/:id/photos", NewUploadPhotoHandler())
+	router.GET("/v1/admin/contributions", NewAdminListHandler())
+	router.POST("/v1/admin/contributions/:id/review", NewReviewHandler())
+	return router
+}
+
+func postJSON(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal error: %v (%s)", err, rec.Body.String())
+	}
+	return body.Error.Code
+}
+
+func TestCreateHandler(t *testing.T) {
+	origCreate := createContributionFunc
+	defer func() { createContributionFunc = origCreate }()
+
+	var got newContribution
+	createContributionFunc = func(_ context.Context, _ *pgxpool.Pool, input newContribution) (Contribution, string, error) {
+		got = input
+		switch input.Barcode {
+		case "5449000000996":
+			return Contribution{}, conflictProduct, nil
+		case "4006381333931":
+			return Contribution{}, conflictContribution, nil
+		}
+		return Contribution{ID: "contrib_1", Barcode: input.Barcode, Name: input.Name, Status: StatusPending}, conflictNone, nil
+	}
+
+	router := newRouter("user_1")
+	// UPC-A barcode, sodium typed in mg (450 "g"), serving size in grams.
+	rec := postJSON(router, "/v1/contributions", `{
+		"barcode": "072745068393", "name": " Creamy Peanut Butter ", "brand": "Acme", "serving_size": "32 g",
+		"nutrients_per_100g": {"calories_kcal": 588, "protein_g": 25, "carbs_g": 20, "fat_g": 50, "sodium_g": 450}
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got.UserID != "user_1" || got.Barcode != "0072745068393" || got.Name != "Creamy Peanut Butter" || got.Language != "en" {
+		t.Fatalf("unexpected input: %+v", got)
+	}
+	if got.ServingSizeG == nil || *got.ServingSizeG != 32 {
+		t.Fatalf("expected serving_size_g 32, got %v", got.ServingSizeG)
+	}
+	if got.Nutrients.SodiumG == nil || *got.Nutrients.SodiumG != 0.45 || len(got.DataQualityFlags) != 1 || got.DataQualityFlags[0] != barcode.FlagSodiumConvertedFromMg {
+		t.Fatalf("expected sodium fixed from mg and flagged, got %v %v", got.Nutrients.SodiumG, got.DataQualityFlags)
+	}
+	var body struct {
+		Contribution Contribution        `json:"contribution"`
+		DataQuality  barcode.DataQuality `json:"data_quality"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.Contribution.ID != "contrib_1" || body.DataQuality.Status != barcode.QualityCorrected {
+		t.Fatalf("unexpected response: %+v", body)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+		err  string
+	}{
+		{"bad checksum", `{"barcode":"4006381333932","name":"x","nutrients_per_100g":{"calories_kcal":1,"protein_g":0,"carbs_g":0,"fat_g":0}}`, 400, "INVALID_BARCODE"},
+		{"missing name", `{"barcode":"96385074","nutrients_per_100g":{"calories_kcal":1,"protein_g":0,"carbs_g":0,"fat_g":0}}`, 400, "INVALID_REQUEST"},
+		{"missing fat", `{"barcode":"96385074","name":"x","nutrients_per_100g":{"calories_kcal":1,"protein_g":0,"carbs_g":0}}`, 400, "INVALID_REQUEST"},
+		{"bad language", `{"barcode":"96385074","name":"x","language":"english","nutrients_per_100g":{"calories_kcal":1,"protein_g":0,"carbs_g":0,"fat_g":0}}`, 400, "INVALID_REQUEST"},
+		{"impossible macros", `{"barcode":"96385074","name":"x","nutrients_per_100g":{"calories_kcal":400,"protein_g":60,"carbs_g":60,"fat_g":10}}`, 422, "INVALID_NUTRITION"},
+		{"product exists", `{"barcode":"5449000000996","name":"x","nutrients_per_100g":{"calories_kcal":42,"protein_g":0,"carbs_g":10.6,"fat_g":0}}`, 409, "PRODUCT_EXISTS"},
+		{"already contributed", `{"barcode":"4006381333931","name":"x","nutrients_per_100g":{"calories_kcal":42,"protein_g":0,"carbs_g":10.6,"fat_g":0}}`, 409, "CONTRIBUTION_EXISTS"},
+	}
+	for _, tt := range tests {
+		rec := postJSON(router, "/v1/contributions", tt.body)
+		if rec.Code != tt.code || errorCode(t, rec) != tt.err {
+			t.Errorf("%s: expected %d %s, got %d %s", tt.name, tt.code, tt.err, rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec := postJSON(newRouter(""), "/v1/contributions", `{}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a user, got %d", rec.Code)
+	}
+}
+
+func TestListHandlers(t *testing.T) {
+	origList := listContributionsFunc
+	defer func() { listContributionsFunc = origList }()
+
+	type call struct {
+		userID, status string
+		oldestFirst    bool
+		limit          int
+	}
+	var calls []call
+	listContributionsFunc = func(_ context.Context, _ *pgxpool.Pool, userID, status string, oldestFirst bool, limit int) ([]Contribution, error) {
+		calls = append(calls, call{userID, status, oldestFirst, limit})
+		return []Contribution{{ID: "contrib_1", Status: StatusPending}}, nil
+	}
+
+	router := newRouter("user_1")
+	for _, path := range []string{"/v1/contributions", "/v1/admin/contributions", "/v1/admin/contributions?status=failed&limit=5"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+	}
+	want := []call{
+		{"user_1", "", false, defaultListLimit},     // own contributions, any status, newest first
+		{"", StatusPending, true, defaultListLimit}, // review queue
+		{"", StatusFailed, true, 5},
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("call %d = %+v, want %+v", i, calls[i], want[i])
+		}
+	}
+
+	for _, query := range []string{"?status=done", "?limit=0"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/contributions"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func photoRequest(t *testing.T, path, kind string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("kind", kind)
+	part, err := form.CreateFormFile("image", "label.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestUploadPhotoHandler(t *testing.T) {
+	origTarget, origUpsert := photoTargetFunc, upsertPhotoFunc
+	defer func() { photoTargetFunc, upsertPhotoFunc = origTarget, origUpsert }()
+
+	photoTargetFunc = func(_ context.Context, _ *pgxpool.Pool, id string) (string, string, bool, error) {
+		switch id {
+		case "contrib_1":
+			return "user_1", StatusPending, true, nil
+		case "contrib_reviewed":
+			return "user_1", StatusApproved, true, nil
+		}
+		return "", "", false, nil
+	}
+	var stored []string
+	upsertPhotoFunc = func(_ context.Context, _ *pgxpool.Pool, id, kind, contentType string, data []byte) error {
+		stored = append(stored, id+"/"+kind+"/"+contentType)
+		return nil
+	}
+
+	jpeg := append([]byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00}, make([]byte, 64)...)
+	router := newRouter("user_1")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, photoRequest(t, "/v1/contributions/contrib_1/photos", "nutrition", jpeg))
+	if rec.Code != http.StatusCreated || len(stored) != 1 || stored[0] != "contrib_1/nutrition/image/jpeg" {
+		t.Fatalf("expected stored nutrition photo, got %d %s (%v)", rec.Code, rec.Body.String(), stored)
+	}
+
+	tests := []struct {
+		name   string
+		router *gin.Engine
+		path   string
+		kind   string
+		data   []byte
+		code   int
+	}{
+		{"bad kind", router, "/v1/contributions/contrib_1/photos", "selfie", jpeg, 400},
+		{"not an image", router, "/v1/contributions/contrib_1/photos", "front", []byte("GIF89a not allowed"), 415},
+		{"too large", router, "/v1/contributions/contrib_1/photos", "front", append(jpeg, make([]byte, maxPhotoBytes)...), 413},
+		{"someone else's", newRouter("user_2"), "/v1/contributions/contrib_1/photos", "front", jpeg, 404},
+		{"already reviewed", router, "/v1/contributions/contrib_reviewed/photos", "front", jpeg, 409},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.router.ServeHTTP(rec, photoRequest(t, tt.path, tt.kind, tt.data))
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.code, rec.Code, rec.Body.String())
+		}
+	}
+	if len(stored) != 1 {
+		t.Fatalf("rejected uploads must not be stored: %v", stored)
+	}
+}
+
+func TestReviewHandler(t *testing.T) {
+	origReview := reviewContributionFunc
+	defer func() { reviewContributionFunc = origReview }()
+
+	var gotApprove bool
+	var gotReviewer, gotNote string
+	reviewContributionFunc = func(_ context.Context, _ *pgxpool.Pool, id, reviewerID string, approve bool, note string) (Contribution, error) {
+		gotApprove, gotReviewer, gotNote = approve, reviewerID, note
+		switch id {
+		case "contrib_missing":
+			return Contribution{}, errNotFound
+		case "contrib_done":
+			return Contribution{}, errNotPending
+		case "contrib_dupe":
+			return Contribution{}, errProductExists
+		}
+		status := StatusRejected
+		if approve {
+			status = StatusApproved
+		}
+		foodItemID := "food_1"
+		return Contribution{ID: id, Status: status, FoodItemID: &foodItemID}, nil
+	}
+
+	router := newRouter("admin_1")
+	rec := postJSON(router, "/v1/admin/contributions/contrib_1/review", `{"decision":"approve","note":" looks right "}`)
+	if rec.Code != http.StatusOK || !gotApprove || gotReviewer != "admin_1" || gotNote != "looks right" {
+		t.Fatalf("expected approval by admin_1, got %d approve=%t reviewer=%s note=%q", rec.Code, gotApprove, gotReviewer, gotNote)
+	}
+	var body struct {
+		Contribution Contribution `json:"contribution"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Contribution.Status != StatusApproved {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+
+	tests := []struct {
+		path, body string
+		code       int
+		err        string
+	}{
+		{"/v1/admin/contributions/contrib_1/review", `{"decision":"maybe"}`, 400, "INVALID_REQUEST"},
+		{"/v1/admin/contributions/contrib_missing/review", `{"decision":"reject"}`, 404, "NOT_FOUND"},
+		{"/v1/admin/contributions/contrib_done/review", `{"decision":"approve"}`, 409, "ALREADY_REVIEWED"},
+		{"/v1/admin/contributions/contrib_dupe/review", `{"decision":"approve"}`, 409, "PRODUCT_EXISTS"},
+	}
+	for _, tt := range tests {
+		rec := postJSON(router, tt.path, tt.body)
+		if rec.Code != tt.code || errorCode(t, rec) != tt.err {
+			t.Errorf("%s %s: expected %d %s, got %d %s", tt.path, tt.body, tt.code, tt.err, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package contribution
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"healthmetrics-services/internal/retry"
+)
+
+// DefaultOFFWriteURL is the live OpenFoodFacts server (writes go to world, not a country subdomain).
+const DefaultOFFWriteURL = "https://world.openfoodfacts.org"
+
+// OFFWriter pushes products and photos to the OpenFoodFacts write API with our app account.
+// The openfoodfacts-go client is read-only, so we post the same forms the OFF apps do:
+//   - /cgi/product_jqm2.pl          product fields + nutrition (per 100 g)
+//   - /cgi/product_image_upload.pl  one photo per call (front / nutrition / ingredients)
+type OFFWriter struct {
+	BaseURL    string       // DefaultOFFWriteURL in production; tests point this at httptest
+	UserID     string       // OFF app account (OPENFOODFACTS_WRITE_USER)
+	Password   string       // OFF app password (OPENFOODFACTS_WRITE_PASSWORD)
+	UserAgent  string       // OFF asks apps to identify themselves
+	HTTPClient *http.Client // timeout lives here
+	Sandbox    bool         // true -> openfoodfacts.net, which sits behind basic auth off:off
+}
+
+// OFFProduct is what we send for one contribution.
+type OFFProduct struct {
+	Code        string
+	Name        string
+	Brand       string
+	Quantity    string // as printed, e.g. "500 g"
+	ServingSize string // as printed, e.g. "30 g"
+	Ingredients string
+	Language    string // main language of the label (lc)
+	Comment     string // edit comment shown in the OFF product history
+
+	// Per 100 g; nil = not on the label (the field is left out, not sent as 0).
+	CaloriesKcal float64
+	ProteinG     float64
+	CarbsG       float64
+	FatG         float64
+	FiberG       *float64
+	SugarG       *float64
+	SodiumMg     *float64
+}
+
+// offWriteResponse covers both endpoints:
+// product_jqm2.pl answers {"status": 1, "status_verbose": "fields saved"};
+// product_image_upload.pl answers {"status": "status ok", ...} or {"status": "status not ok", "error": "..."}.
+type offWriteResponse struct {
+	Status        json.RawMessage `json:"status"`
+	StatusVerbose string          `json:"status_verbose"`
+	Error         string          `json:"error"`
+}
+
+// SaveProduct creates or updates the product on OFF.
+func (w *OFFWriter) SaveProduct(ctx context.Context, product OFFProduct) error {
+	form := url.Values{}
+	w.setCredentials(form)
+	form.Set("code", product.Code)
+	form.Set("lc", product.Language)
+	form.Set("product_name", product.Name)
+	setIfNotEmpty(form, "brands", product.Brand)
+	setIfNotEmpty(form, "quantity", product.Quantity)
+	setIfNotEmpty(form, "serving_size", product.ServingSize)
+	setIfNotEmpty(form, "ingredients_text_"+product.Language, product.Ingredients)
+	setIfNotEmpty(form, "comment", product.Comment)
+
+	// Label values are per 100 g; units are explicit so OFF doesn't guess.
+	form.Set("nutrition_data_per", "100g")
+	setNutriment(form, "energy-kcal", product.CaloriesKcal, "kcal")
+	setNutriment(form, "proteins", product.ProteinG, "g")
+	setNutriment(form, "carbohydrates", product.CarbsG, "g")
+	setNutriment(form, "fat", product.FatG, "g")
+	if product.FiberG != nil {
+		setNutriment(form, "fiber", *product.FiberG, "g")
+	}
+	if product.SugarG != nil {
+		setNutriment(form, "sugars", *product.SugarG, "g")
+	}
+	if product.SodiumMg != nil {
+		setNutriment(form, "sodium", *product.SodiumMg, "mg")
+	}
+
+	body := strings.NewReader(form.Encode())
+	result, err := w.post(ctx, "/cgi/product_jqm2.pl", "application/x-www-form-urlencoded", body)
+	if err != nil {
+		return err
+	}
+	if string(result.Status) != "1" {
+		return fmt.Errorf("openfoodfacts rejected product %s: %s", product.Code, firstNonEmpty(result.Error, result.StatusVerbose, "unknown error"))
+	}
+	return nil
+}
+
+// UploadPhoto attaches one label photo. kind is front / nutrition / ingredients.
+// Re-sending a photo OFF already has is not an error (it answers "already been sent").
+func (w *OFFWriter) UploadPhoto(ctx context.Context, code, kind, language, contentType string, data []byte) error {
+	imageField := kind + "_" + language // e.g. "nutrition_en"
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := url.Values{}
+	w.setCredentials(fields)
+	fields.Set("code", code)
+	fields.Set("imagefield", imageField)
+	for key, values := range fields {
+		if err := form.WriteField(key, values[0]); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("imgupload_"+imageField, imageField+photoExtension(contentType))
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(data); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	result, err := w.post(ctx, "/cgi/product_image_upload.pl", form.FormDataContentType(), &body)
+	if err != nil {
+		return err
+	}
+	var status string
+	_ = json.Unmarshal(result.Status, &status)
+	if status == "status ok" || strings.Contains(result.Error, "already been sent") {
+		return nil
+	}
+	return fmt.Errorf("openfoodfacts rejected %s photo for %s: %s", imageField, code, firstNonEmpty(result.Error, status, "unknown error"))
+}
+
+// post sends one write request and decodes the JSON answer.
+// Non-2xx responses come back as *retry.HTTPError so the submitter can tell 5xx/429 from the rest.
+func (w *OFFWriter) post(ctx context.Context, path, contentType string, body io.Reader) (offWriteResponse, error) {
+	base := w.BaseURL
+	if base == "" {
+		base = DefaultOFFWriteURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(base, "/")+path, body)
+	if err != nil {
+		return offWriteResponse{}, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.UserAgent != "" {
+		req.Header.Set("User-Agent", w.UserAgent)
+	}
+	if w.Sandbox {
+		req.SetBasicAuth("off", "off") // the sandbox sits behind basic auth
+	}
+
+	httpClient := w.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return offWriteResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return offWriteResponse{}, retry.NewHTTPError("openfoodfacts", resp)
+	}
+
+	var result offWriteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return offWriteResponse{}, fmt.Errorf("decode openfoodfacts response: %w", err)
+	}
+	return result, nil
+}
+
+func (w *OFFWriter) setCredentials(form url.Values) {
+	form.Set("user_id", w.UserID)
+	form.Set("password", w.Password)
+}
+
+func setNutriment(form url.Values, name string, value float64, unit string) {
+	form.Set("nutriment_"+name, strconv.FormatFloat(value, 'f', -1, 64))
+	form.Set("nutriment_"+name+"_unit", unit)
+}
+
+func setIfNotEmpty(form url.Values, key, value string) {
+	if value != "" {
+		form.Set(key, value)
+	}
+}
+
+func photoExtension(contentType string) string {
+	if contentType == "image/png" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package contribution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"healthmetrics-services/internal/retry"
+)
+
+// fakeOFF is a local stand-in for the OpenFoodFacts write API.
+// It keeps what it was sent and answers the way OFF does.
+type fakeOFF struct {
+	mu         sync.Mutex
+	products   map[string]map[string]string // code -> form fields
+	photos     map[string][]byte            // code/imagefield -> bytes
+	authOK     bool                         // last request carried sandbox basic auth
+	failNext   int                          // answer failStatus (503 when 0) to this many requests
+	failStatus int
+	reject     string // product_jqm2.pl answers status 0 with this message
+}
+
+func newFakeOFF(t *testing.T) (*fakeOFF, *httptest.Server) {
+	t.Helper()
+	off := &fakeOFF{products: map[string]map[string]string{}, photos: map[string][]byte{}}
+	server := httptest.NewServer(http.HandlerFunc(off.serve))
+	t.Cleanup(server.Close)
+	return off, server
+}
+
+func (f *fakeOFF) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	user, pass, ok := r.BasicAuth()
+	f.authOK = ok && user == "off" && pass == "off"
+
+	if f.failNext > 0 {
+		f.failNext--
+		status := f.failStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/cgi/product_jqm2.pl":
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("user_id") != "healthmetrics" || r.PostForm.Get("password") != "secret" {
+			json.NewEncoder(w).Encode(map[string]any{"status": 0, "status_verbose": "Incorrect user name or password"})
+			return
+		}
+		if f.reject != "" {
+			json.NewEncoder(w).Encode(map[string]any{"status": 0, "status_verbose": f.reject})
+			return
+		}
+		fields := map[string]string{}
+		for key := range r.PostForm {
+			fields[key] = r.PostForm.Get(key)
+		}
+		f.products[r.PostForm.Get("code")] = fields
+		json.NewEncoder(w).Encode(map[string]any{"status": 1, "status_verbose": "fields saved"})
+
+	case "/cgi/product_image_upload.pl":
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		code, field := r.FormValue("code"), r.FormValue("imagefield")
+		file, _, err := r.FormFile("imgupload_" + field)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]any{"status": "status not ok", "error": "no image"})
+			return
+		}
+		data, _ := io.ReadAll(file)
+		key := code + "/" + field
+		if _, seen := f.photos[key]; seen {
+			json.NewEncoder(w).Encode(map[string]any{"status": "status not ok", "error": "This picture has already been sent."})
+			return
+		}
+		f.photos[key] = data
+		json.NewEncoder(w).Encode(map[string]any{"status": "status ok", "imagefield": field})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func testWriter(server *httptest.Server) *OFFWriter {
+	return &OFFWriter{BaseURL: server.URL, UserID: "healthmetrics", Password: "secret", UserAgent: "healthmetrics-test", HTTPClient: server.Client()}
+}
+
+func TestOFFWriter_SaveProduct(t *testing.T) {
+	off, server := newFakeOFF(t)
+	writer := testWriter(server)
+
+	fiber := 7.5
+	sodium := 200.0
+	err := writer.SaveProduct(context.Background(), OFFProduct{
+		Code: "4006381333931", Name: "Oat Crunch", Brand: "Acme", Quantity: "500 g", ServingSize: "40 g",
+		Ingredients: "oats, sugar", Language: "en",
+		CaloriesKcal: 412, ProteinG: 9.5, CarbsG: 64, FatG: 12, FiberG: &fiber, SodiumMg: &sodium,
+	})
+	if err != nil {
+		t.Fatalf("SaveProduct: %v", err)
+	}
+
+	fields := off.products["4006381333931"]
+	want := map[string]string{
+		"product_name":               "Oat Crunch",
+		"brands":                     "Acme",
+		"lc":                         "en",
+		"ingredients_text_en":        "oats, sugar",
+		"nutrition_data_per":         "100g",
+		"nutriment_energy-kcal":      "412",
+		"nutriment_energy-kcal_unit": "kcal",
+		"nutriment_proteins":         "9.5",
+		"nutriment_fiber":            "7.5",
+		"nutriment_sodium":           "200",
+		"nutriment_sodium_unit":      "mg",
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("%s = %q, want %q", key, fields[key], value)
+		}
+	}
+	// Unknown values are left out, not sent as 0.
+	if _, ok := fields["nutriment_sugars"]; ok {
+		t.Errorf("sugars should not be sent when unknown")
+	}
+	if off.authOK {
+		t.Errorf("live writes must not send sandbox basic auth")
+	}
+}
+
+func TestOFFWriter_Errors(t *testing.T) {
+	off, server := newFakeOFF(t)
+
+	// Wrong credentials: OFF answers 200 with status 0.
+	bad := testWriter(server)
+	bad.Password = "wrong"
+	err := bad.SaveProduct(context.Background(), OFFProduct{Code: "4006381333931", Name: "x", Language: "en"})
+	if err == nil || !strings.Contains(err.Error(), "Incorrect user name or password") {
+		t.Fatalf("expected the OFF status message, got %v", err)
+	}
+
+	// 5xx comes back typed, so the retry classifier sees it as transient.
+	off.failNext = 1
+	err = testWriter(server).SaveProduct(context.Background(), OFFProduct{Code: "4006381333931", Name: "x", Language: "en"})
+	var httpErr *retry.HTTPError
+	if !errors.As(err, &httpErr) || !retry.IsTransient(err) {
+		t.Fatalf("expected a transient HTTP error, got %v", err)
+	}
+}
+
+func TestOFFWriter_UploadPhoto(t *testing.T) {
+	off, server := newFakeOFF(t)
+	writer := testWriter(server)
+	writer.Sandbox = true
+
+	photo := []byte("\xff\xd8\xff\xe0 fake jpeg")
+	if err := writer.UploadPhoto(context.Background(), "4006381333931", "nutrition", "fr", "image/jpeg", photo); err != nil {
+		t.Fatalf("UploadPhoto: %v", err)
+	}
+	if string(off.photos["4006381333931/nutrition_fr"]) != string(photo) {
+		t.Fatalf("photo not stored under nutrition_fr: %v", off.photos)
+	}
+	if !off.authOK {
+		t.Fatalf("sandbox writes must send basic auth off:off")
+	}
+
+	// Re-sending the same photo (retry after a later failure) is not an error.
+	if err := writer.UploadPhoto(context.Background(), "4006381333931", "nutrition", "fr", "image/jpeg", photo); err != nil {
+		t.Fatalf("expected duplicate upload to succeed, got %v", err)
+	}
+}
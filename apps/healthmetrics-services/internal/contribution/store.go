@@ -0,0 +1,509 @@
+package contribution
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// Swappable DB helpers so tests can run without Postgres.
+var (
+	createContributionFunc = createContribution
+	listContributionsFunc  = listContributions
+	photoTargetFunc        = photoTarget
+	upsertPhotoFunc        = upsertPhoto
+	getPhotoFunc           = getPhoto
+	reviewContributionFunc = reviewContribution
+	claimSubmissionsFunc   = claimDueSubmissions
+	releaseSubmissionsFunc = releaseSubmissions
+	markPhotoUploadedFunc  = markPhotoUploaded
+	markSubmittedFunc      = markSubmitted
+	markSubmitFailedFunc   = markSubmitFailed
+)
+
+// Change sources recorded on food_item_revisions.change_source for contribution writes.
+const (
+	changeSourceContribution  = "user_contribution"          // admin approved a contribution -> new food_items row
+	changeSourceOFFSubmission = "open_food_facts_submission" // row handed over to OpenFoodFacts refreshes
+	changedBySubmitter        = "contribution-submitter"
+)
+
+// Store errors the handlers map to status codes.
+var (
+	errNotFound      = errors.New("contribution not found")
+	errNotPending    = errors.New("contribution already reviewed")
+	errProductExists = errors.New("product already exists")
+)
+
+// Conflicts reported by createContribution (nothing is written).
+const (
+	conflictNone         = ""
+	conflictProduct      = "product"      // food_items already has the barcode
+	conflictContribution = "contribution" // someone already contributed it (pending/approved/submitted)
+)
+
+// contributionColumns is the Contribution shape, shared by every query that returns one.
+// Table alias must be c.
+const contributionColumns = `
+	c.id,
+	c.user_id,
+	c.barcode,
+	c.name,
+	COALESCE(c.brand, ''),
+	COALESCE(c.quantity, ''),
+	COALESCE(c.serving_size, ''),
+	c.serving_size_g::float8,
+	COALESCE(c.ingredients, ''),
+	c.language,
+	c.calories_per_100g::float8,
+	c.protein_g::float8,
+	c.carbs_g::float8,
+	c.fat_g::float8,
+	c.fiber_g::float8,
+	c.sugar_g::float8,
+	(c.sodium_mg / 1000.0)::float8,
+	COALESCE(c.data_quality_flags, ARRAY[]::text[]),
+	ARRAY(SELECT p.kind FROM contribution_photos p WHERE p.contribution_id = c.id ORDER BY p.kind),
+	c.status::text,
+	c.review_note,
+	c.reviewed_at,
+	c.food_item_id,
+	c.off_attempts,
+	c.off_last_error,
+	c.off_submitted_at,
+	c.created_at
+`
+
+func scanContribution(row pgx.Row) (Contribution, error) {
+	var item Contribution
+	err := row.Scan(
+		&item.ID,
+		&item.UserID,
+		&item.Barcode,
+		&item.Name,
+		&item.Brand,
+		&item.Quantity,
+		&item.ServingSize,
+		&item.ServingSizeG,
+		&item.Ingredients,
+		&item.Language,
+		&item.Nutrients.CaloriesKcal,
+		&item.Nutrients.ProteinG,
+		&item.Nutrients.CarbsG,
+		&item.Nutrients.FatG,
+		&item.Nutrients.FiberG,
+		&item.Nutrients.SugarG,
+		&item.Nutrients.SodiumG,
+		&item.DataQualityFlags,
+		&item.Photos,
+		&item.Status,
+		&item.ReviewNote,
+		&item.ReviewedAt,
+		&item.FoodItemID,
+		&item.OFFAttempts,
+		&item.OFFLastError,
+		&item.OFFSubmittedAt,
+		&item.CreatedAt,
+	)
+	return item, err
+}
+
+// createContribution queues a contribution unless the barcode is already known.
+// The existence checks run in the same statement as the insert, so a double-tap can't queue twice.
+func createContribution(ctx context.Context, pool *pgxpool.Pool, input newContribution) (Contribution, string, error) {
+	const query = `
+		INSERT INTO product_contributions AS c (
+			id, user_id, barcode, name, brand, quantity, serving_size, serving_size_g, ingredients, language,
+			calories_per_100g, protein_g, carbs_g, fat_g, fiber_g, sugar_g, sodium_mg, data_quality_flags,
+			created_at, updated_at
+		)
+		SELECT gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, $7, $8, $9,
+			$10, $11, $12, $13, $14, $15, $16, $17,
+			now(), now()
+		WHERE NOT EXISTS (SELECT 1 FROM food_items WHERE barcode = $2)
+		  AND NOT EXISTS (
+			SELECT 1 FROM product_contributions
+			WHERE barcode = $2 AND status IN ('pending', 'approved', 'submitted')
+		  )
+		RETURNING ` + contributionColumns
+
+	var sodiumMg *float64
+	if input.Nutrients.SodiumG != nil {
+		value := *input.Nutrients.SodiumG * 1000 // stored as mg like food_items
+		sodiumMg = &value
+	}
+
+	item, err := scanContribution(pool.QueryRow(ctx, query,
+		input.UserID,
+		input.Barcode,
+		input.Name,
+		nullIfEmpty(input.Brand),
+		nullIfEmpty(input.Quantity),
+		nullIfEmpty(input.ServingSize),
+		input.ServingSizeG,
+		nullIfEmpty(input.Ingredients),
+		input.Language,
+		input.Nutrients.CaloriesKcal,
+		input.Nutrients.ProteinG,
+		input.Nutrients.CarbsG,
+		input.Nutrients.FatG,
+		input.Nutrients.FiberG,
+		input.Nutrients.SugarG,
+		sodiumMg,
+		input.DataQualityFlags,
+	))
+	if err == nil {
+		return item, conflictNone, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Contribution{}, conflictNone, err
+	}
+
+	// Nothing inserted: report which check stopped it.
+	var productExists bool
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM food_items WHERE barcode = $1)`, input.Barcode).Scan(&productExists); err != nil {
+		return Contribution{}, conflictNone, err
+	}
+	if productExists {
+		return Contribution{}, conflictProduct, nil
+	}
+	return Contribution{}, conflictContribution, nil
+}
+
+// listContributions returns contributions, newest first (oldest first for the review queue).
+// userID "" = everyone (admin); status "" = any status.
+func listContributions(ctx context.Context, pool *pgxpool.Pool, userID, status string, oldestFirst bool, limit int) ([]Contribution, error) {
+	order := "DESC"
+	if oldestFirst {
+		order = "ASC"
+	}
+	query := `
+		SELECT ` + contributionColumns + `
+		FROM product_contributions c
+		WHERE ($1 = '' OR c.user_id = $1)
+		  AND ($2 = '' OR c.status::text = $2)
+		ORDER BY c.created_at ` + order + `
+		LIMIT $3
+	`
+
+	rows, err := pool.Query(ctx, query, userID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Contribution{} // never nil, so JSON is [] not null
+	for rows.Next() {
+		item, err := scanContribution(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// photoTarget loads the owner + status a photo upload is checked against.
+func photoTarget(ctx context.Context, pool *pgxpool.Pool, contributionID string) (string, string, bool, error) {
+	var userID, status string
+	err := pool.QueryRow(ctx, `SELECT user_id, status::text FROM product_contributions WHERE id = $1`, contributionID).Scan(&userID, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	return userID, status, true, nil
+}
+
+// upsertPhoto stores one photo per kind; a retake replaces the previous one.
+func upsertPhoto(ctx context.Context, pool *pgxpool.Pool, contributionID, kind, contentType string, data []byte) error {
+	const query = `
+		INSERT INTO contribution_photos (id, contribution_id, kind, content_type, data, created_at)
+		VALUES (gen_random_uuid()::text, $1, $2, $3, $4, now())
+		ON CONFLICT (contribution_id, kind) DO UPDATE SET
+			content_type = EXCLUDED.content_type,
+			data = EXCLUDED.data,
+			off_uploaded_at = NULL,
+			created_at = now()
+	`
+	_, err := pool.Exec(ctx, query, contributionID, kind, contentType, data)
+	return err
+}
+
+// getPhoto loads one stored photo for reviewers.
+func getPhoto(ctx context.Context, pool *pgxpool.Pool, contributionID, kind string) (string, []byte, bool, error) {
+	var contentType string
+	var data []byte
+	err := pool.QueryRow(ctx, `
+		SELECT content_type, data FROM contribution_photos WHERE contribution_id = $1 AND kind = $2
+	`, contributionID, kind).Scan(&contentType, &data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, false, nil
+	}
+	if err != nil {
+		return "", nil, false, err
+	}
+	return contentType, data, true, nil
+}
+
+// reviewContribution records an admin decision on a pending contribution.
+// Approving also creates the food_items row (source 'user', created_by = contributor), so the
+// barcode resolves from our cache right away, and queues the OpenFoodFacts write-back.
+// The food_items insert is tagged so food_item_revisions shows who approved it.
+func reviewContribution(ctx context.Context, pool *pgxpool.Pool, contributionID, reviewerID string, approve bool, note string) (Contribution, error) {
+	var reviewed Contribution
+	err := barcode.WithChangeContext(ctx, pool, changeSourceContribution, reviewerID, func(tx pgx.Tx) error {
+		current, err := scanContribution(tx.QueryRow(ctx, `
+			SELECT `+contributionColumns+`
+			FROM product_contributions c
+			WHERE c.id = $1
+			FOR UPDATE
+		`, contributionID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if current.Status != StatusPending {
+			return errNotPending
+		}
+
+		status := StatusRejected
+		var foodItemID *string
+		if approve {
+			status = StatusApproved
+			id, err := insertContributedFoodItem(ctx, tx, current)
+			if err != nil {
+				return err
+			}
+			foodItemID = &id
+		}
+
+		reviewed, err = scanContribution(tx.QueryRow(ctx, `
+			UPDATE product_contributions AS c SET
+				status = $2::"ContributionStatus",
+				review_note = $3,
+				reviewed_by = $4,
+				reviewed_at = now(),
+				food_item_id = $5,
+				off_next_attempt_at = CASE WHEN $2 = 'approved' THEN now() END,
+				updated_at = now()
+			WHERE c.id = $1
+			RETURNING `+contributionColumns,
+			contributionID, status, nullIfEmpty(note), reviewerID, foodItemID,
+		))
+		return err
+	})
+	return reviewed, err
+}
+
+// insertContributedFoodItem writes the approved product to food_items.
+// The barcode appearing in the meantime (someone scanned it after OFF got it elsewhere)
+// is errProductExists: the reviewer should reject instead of overwriting real OFF data.
+func insertContributedFoodItem(ctx context.Context, tx pgx.Tx, item Contribution) (string, error) {
+	// Score the stored values again (the column only keeps flags).
+	_, quality := barcode.ValidateNutrients(item.Nutrients)
+
+	servingSizeG := defaultServingSizeG
+	if item.ServingSizeG != nil {
+		servingSizeG = *item.ServingSizeG
+	}
+
+	var sodiumMg *float64
+	if item.Nutrients.SodiumG != nil {
+		value := *item.Nutrients.SodiumG * 1000
+		sodiumMg = &value
+	}
+
+	const query = `
+		INSERT INTO food_items (
+			id, name, brand, barcode, serving_size_g, serving_size_unit,
+			calories_per_100g, protein_g, carbs_g, fat_g, fiber_g, sugar_g, sodium_mg,
+			source, source_id, verified, created_by, data_quality_score, data_quality_flags,
+			created_at, updated_at
+		) VALUES (
+			gen_random_uuid()::text, $1, $2, $3, $4, 'g',
+			$5, $6, $7, $8, $9, $10, $11,
+			'user', $12, false, $13, $14, $15,
+			now(), now()
+		)
+		ON CONFLICT (barcode) DO NOTHING
+		RETURNING id
+	`
+
+	var id string
+	err := tx.QueryRow(ctx, query,
+		item.Name,
+		nullIfEmpty(item.Brand),
+		item.Barcode,
+		servingSizeG,
+		item.Nutrients.CaloriesKcal,
+		item.Nutrients.ProteinG,
+		item.Nutrients.CarbsG,
+		item.Nutrients.FatG,
+		item.Nutrients.FiberG,
+		item.Nutrients.SugarG,
+		sodiumMg,
+		item.ID, // source_id: the contribution this row came from
+		item.UserID,
+		quality.Score,
+		quality.Flags,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errProductExists
+	}
+	return id, err
+}
+
+// submission is an approved contribution due for OpenFoodFacts write-back.
+type submission struct {
+	Contribution
+	Photos []submissionPhoto
+}
+
+type submissionPhoto struct {
+	ID          string
+	Kind        string
+	ContentType string
+	Data        []byte
+	Uploaded    bool // already on OFF (a previous attempt failed after the photo went up)
+}
+
+// claimDueSubmissions claims approved contributions whose next attempt is due, oldest first.
+// Claiming pushes off_next_attempt_at a lease ahead (SKIP LOCKED), so another replica running
+// the submitter skips them; the outcome (markSubmitted / markSubmitFailed) replaces the lease,
+// and a crashed replica's claims come due again once it runs out.
+func claimDueSubmissions(ctx context.Context, pool *pgxpool.Pool, limit int, lease time.Duration) ([]submission, error) {
+	rows, err := pool.Query(ctx, `
+		WITH due AS (
+			SELECT id
+			FROM product_contributions
+			WHERE status = 'approved'
+			  AND (off_next_attempt_at IS NULL OR off_next_attempt_at <= now())
+			ORDER BY off_next_attempt_at NULLS FIRST, created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE product_contributions c
+		SET off_next_attempt_at = now() + make_interval(secs => $2)
+		FROM due
+		WHERE c.id = due.id
+		RETURNING `+contributionColumns+`
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	var due []submission
+	index := map[string]int{}
+	var ids []string
+	for rows.Next() {
+		item, err := scanContribution(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[item.ID] = len(due)
+		ids = append(ids, item.ID)
+		due = append(due, submission{Contribution: item})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+
+	photoRows, err := pool.Query(ctx, `
+		SELECT id, contribution_id, kind, content_type, data, off_uploaded_at IS NOT NULL
+		FROM contribution_photos
+		WHERE contribution_id = ANY($1)
+		ORDER BY kind
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer photoRows.Close()
+	for photoRows.Next() {
+		var photo submissionPhoto
+		var contributionID string
+		if err := photoRows.Scan(&photo.ID, &contributionID, &photo.Kind, &photo.ContentType, &photo.Data, &photo.Uploaded); err != nil {
+			return nil, err
+		}
+		i := index[contributionID]
+		due[i].Photos = append(due[i].Photos, photo)
+	}
+	return due, photoRows.Err()
+}
+
+// releaseSubmissions hands claimed contributions back (due now) when the submitter stops
+// before getting to them.
+func releaseSubmissions(ctx context.Context, pool *pgxpool.Pool, contributionIDs []string) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE product_contributions SET off_next_attempt_at = now()
+		WHERE id = ANY($1) AND status = 'approved'
+	`, contributionIDs)
+	return err
+}
+
+func markPhotoUploaded(ctx context.Context, pool *pgxpool.Pool, photoID string) error {
+	_, err := pool.Exec(ctx, `UPDATE contribution_photos SET off_uploaded_at = now() WHERE id = $1`, photoID)
+	return err
+}
+
+// markSubmitted closes a contribution after OFF accepted it and hands the food_items row over to
+// the normal OpenFoodFacts refresh (source open_food_facts, source_id = barcode). From then on
+// OFF's moderated data wins over what the user typed.
+func markSubmitted(ctx context.Context, pool *pgxpool.Pool, item Contribution) error {
+	return barcode.WithChangeContext(ctx, pool, changeSourceOFFSubmission, changedBySubmitter, func(tx pgx.Tx) error {
+		if item.FoodItemID != nil {
+			if _, err := tx.Exec(ctx, `
+				UPDATE food_items
+				SET source = 'open_food_facts', source_id = $2, updated_at = now()
+				WHERE id = $1 AND source = 'user'
+			`, *item.FoodItemID, item.Barcode); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE product_contributions SET
+				status = 'submitted',
+				off_attempts = off_attempts + 1,
+				off_last_error = NULL,
+				off_next_attempt_at = NULL,
+				off_submitted_at = now(),
+				updated_at = now()
+			WHERE id = $1
+		`, item.ID)
+		return err
+	})
+}
+
+// markSubmitFailed records a failed attempt. nextAttempt nil = give up (status failed);
+// the food_items row stays (source 'user'), so the barcode keeps resolving for our users.
+func markSubmitFailed(ctx context.Context, pool *pgxpool.Pool, contributionID, message string, nextAttempt *time.Time) error {
+	_, err := pool.Exec(ctx, `
+		UPDATE product_contributions SET
+			status = CASE WHEN $3::timestamp IS NULL THEN 'failed'::"ContributionStatus" ELSE status END,
+			off_attempts = off_attempts + 1,
+			off_last_error = $2,
+			off_next_attempt_at = $3,
+			updated_at = now()
+		WHERE id = $1
+	`, contributionID, message, nextAttempt)
+	return err
+}
+
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
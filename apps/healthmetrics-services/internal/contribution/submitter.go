@@ -0,0 +1,191 @@
+package contribution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/retry"
+)
+
+// Write-back defaults: with a 15 min base the 8 attempts span roughly a day and a half
+// (15m, 30m, 1h, 2h, 4h, 8h, 16h) before a contribution is marked failed.
+const (
+	DefaultMaxAttempts = 8
+	DefaultBaseDelay   = 15 * time.Minute
+	maxRetryDelay      = 24 * time.Hour
+	submitBatchSize    = 20
+	submitClaimLease   = 30 * time.Minute // covers a batch; other replicas skip claimed rows until then
+	maxErrorLen        = 500              // off_last_error is for operators, not a full response dump
+)
+
+// Submitter pushes approved contributions to OpenFoodFacts: product fields first, then any photos
+// not uploaded yet. Transient failures back off exponentially; after MaxAttempts, or right away
+// when OFF refuses the request (4xx), the contribution is failed (the food_items row stays, so
+// the barcode keeps resolving for our users). Every replica may run one: batches are claimed.
+type Submitter struct {
+	Pool        *pgxpool.Pool
+	Writer      *OFFWriter
+	MaxAttempts int           // DefaultMaxAttempts when 0
+	BaseDelay   time.Duration // DefaultBaseDelay when 0
+}
+
+// SubmitResult counts what one run did.
+type SubmitResult struct {
+	Submitted int // accepted by OFF
+	Retrying  int // failed, next attempt scheduled
+	Failed    int // failed for the last time
+}
+
+// Run processes every due contribution (in batches) and returns the totals.
+// The error is the last store error, if any; OFF errors are recorded per contribution instead.
+func (s Submitter) Run(ctx context.Context) (SubmitResult, error) {
+	var result SubmitResult
+	var lastErr error
+
+	for {
+		due, err := claimSubmissionsFunc(ctx, s.Pool, submitBatchSize, submitClaimLease)
+		if err != nil {
+			return result, err
+		}
+
+		progressed := false
+		for i, item := range due {
+			if ctx.Err() != nil {
+				s.release(ctx, due[i:])
+				return result, ctx.Err()
+			}
+			outcome, err := s.submit(ctx, item)
+			if err != nil {
+				log.Printf("contribution_submit_store_error contribution_id=%s err=%v", item.ID, err)
+				lastErr = err
+				continue
+			}
+			progressed = true
+			switch outcome {
+			case StatusSubmitted:
+				result.Submitted++
+			case StatusFailed:
+				result.Failed++
+			default:
+				result.Retrying++
+			}
+		}
+
+		// A short batch is the last one; a batch where nothing could be recorded would loop forever.
+		if len(due) < submitBatchSize || !progressed {
+			return result, lastErr
+		}
+	}
+}
+
+// submit sends one contribution and records the outcome.
+// Returns submitted / approved (retry scheduled) / failed.
+func (s Submitter) submit(ctx context.Context, item submission) (string, error) {
+	sendErr := s.send(ctx, item)
+	if sendErr == nil {
+		if err := markSubmittedFunc(ctx, s.Pool, item.Contribution); err != nil {
+			return "", err
+		}
+		log.Printf("contribution_submitted contribution_id=%s barcode=%s attempt=%d", item.ID, item.Barcode, item.OFFAttempts+1)
+		return StatusSubmitted, nil
+	}
+	if errors.Is(sendErr, context.Canceled) || errors.Is(sendErr, context.DeadlineExceeded) {
+		s.release(ctx, []submission{item})
+		return "", sendErr // shutting down: not the contribution's fault, don't count an attempt
+	}
+
+	attempt := item.OFFAttempts + 1
+	message := sendErr.Error()
+	if len(message) > maxErrorLen {
+		message = message[:maxErrorLen]
+	}
+
+	var nextAttempt *time.Time
+	outcome := StatusFailed
+	if attempt < s.maxAttempts() && retryable(sendErr) {
+		next := time.Now().UTC().Add(s.retryDelay(attempt))
+		nextAttempt = &next
+		outcome = StatusApproved
+	}
+	if err := markSubmitFailedFunc(ctx, s.Pool, item.ID, message, nextAttempt); err != nil {
+		return "", err
+	}
+	log.Printf("contribution_submit_error contribution_id=%s barcode=%s attempt=%d outcome=%s err=%v",
+		item.ID, item.Barcode, attempt, outcome, sendErr)
+	return outcome, nil
+}
+
+// retryable reports whether a failed send may succeed later. OFF refusing the request (4xx other
+// than 408/425/429) won't change on retry; errors without a status (OFF's own "status 0"
+// rejections, network errors, our store) keep the backoff.
+func retryable(err error) bool {
+	var httpErr *retry.HTTPError
+	if errors.As(err, &httpErr) {
+		return retry.IsTransient(err)
+	}
+	return true
+}
+
+// release hands claimed contributions back so the next run (on any replica) picks them up
+// instead of waiting out the claim. Own context: ctx is usually cancelled by now.
+func (s Submitter) release(ctx context.Context, items []submission) {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := releaseSubmissionsFunc(releaseCtx, s.Pool, ids); err != nil {
+		log.Printf("contribution_submit_release_error contribution_ids=%v err=%v", ids, err)
+	}
+}
+
+// send writes the product, then photos. Each uploaded photo is recorded right away, so a retry
+// after a later failure doesn't upload it again.
+func (s Submitter) send(ctx context.Context, item submission) error {
+	if s.Writer == nil {
+		return errors.New("openfoodfacts writer not configured")
+	}
+	if err := s.Writer.SaveProduct(ctx, offProduct(item.Contribution)); err != nil {
+		return fmt.Errorf("save product: %w", err)
+	}
+	for _, photo := range item.Photos {
+		if photo.Uploaded {
+			continue
+		}
+		if err := s.Writer.UploadPhoto(ctx, item.Barcode, photo.Kind, item.Language, photo.ContentType, photo.Data); err != nil {
+			return fmt.Errorf("upload %s photo: %w", photo.Kind, err)
+		}
+		if err := markPhotoUploadedFunc(ctx, s.Pool, photo.ID); err != nil {
+			return fmt.Errorf("record %s photo upload: %w", photo.Kind, err)
+		}
+	}
+	return nil
+}
+
+func (s Submitter) maxAttempts() int {
+	if s.MaxAttempts > 0 {
+		return s.MaxAttempts
+	}
+	return DefaultMaxAttempts
+}
+
+// retryDelay doubles per attempt: base, 2*base, 4*base, ... capped at a day.
+func (s Submitter) retryDelay(attempt int) time.Duration {
+	delay := s.BaseDelay
+	if delay <= 0 {
+		delay = DefaultBaseDelay
+	}
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,188 @@
+package contribution
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// stubSubmissionStore swaps the submitter's DB helpers and records what they were told.
+type stubSubmissionStore struct {
+	due       []submission
+	submitted []string
+	failed    map[string]*time.Time // contribution id -> next attempt (nil = gave up)
+	messages  map[string]string
+	uploaded  []string
+	released  []string
+}
+
+func installSubmissionStore(t *testing.T, due []submission) *stubSubmissionStore {
+	t.Helper()
+	origClaim, origRelease, origPhoto, origSubmitted, origFailed := claimSubmissionsFunc, releaseSubmissionsFunc, markPhotoUploadedFunc, markSubmittedFunc, markSubmitFailedFunc
+	t.Cleanup(func() {
+		claimSubmissionsFunc, releaseSubmissionsFunc, markPhotoUploadedFunc, markSubmittedFunc, markSubmitFailedFunc = origClaim, origRelease, origPhoto, origSubmitted, origFailed
+	})
+
+	store := &stubSubmissionStore{due: due, failed: map[string]*time.Time{}, messages: map[string]string{}}
+	claimSubmissionsFunc = func(_ context.Context, _ *pgxpool.Pool, limit int, lease time.Duration) ([]submission, error) {
+		batch := store.due
+		store.due = nil // claimed: a second claim (another replica) gets nothing
+		return batch, nil
+	}
+	releaseSubmissionsFunc = func(_ context.Context, _ *pgxpool.Pool, ids []string) error {
+		store.released = append(store.released, ids...)
+		return nil
+	}
+	markPhotoUploadedFunc = func(_ context.Context, _ *pgxpool.Pool, photoID string) error {
+		store.uploaded = append(store.uploaded, photoID)
+		return nil
+	}
+	markSubmittedFunc = func(_ context.Context, _ *pgxpool.Pool, item Contribution) error {
+		store.submitted = append(store.submitted, item.ID)
+		return nil
+	}
+	markSubmitFailedFunc = func(_ context.Context, _ *pgxpool.Pool, id, message string, next *time.Time) error {
+		store.failed[id] = next
+		store.messages[id] = message
+		return nil
+	}
+	return store
+}
+
+func approvedSubmission(id, code string, attempts int) submission {
+	sodium := 0.2
+	return submission{Contribution: Contribution{
+		ID: id, Barcode: code, Name: "Oat Crunch", Brand: "Acme", Language: "en", Status: StatusApproved, OFFAttempts: attempts,
+		Nutrients: barcode.FoodItemNutrients{CaloriesKcal: 412, ProteinG: 9.5, CarbsG: 64, FatG: 12, SodiumG: &sodium},
+	}}
+}
+
+func TestSubmitterRun(t *testing.T) {
+	off, server := newFakeOFF(t)
+
+	withPhotos := approvedSubmission("contrib_1", "4006381333931", 0)
+	withPhotos.Photos = []submissionPhoto{
+		{ID: "photo_front", Kind: "front", ContentType: "image/jpeg", Data: []byte("front"), Uploaded: true}, // sent by an earlier attempt
+		{ID: "photo_nutrition", Kind: "nutrition", ContentType: "image/png", Data: []byte("nutrition")},
+	}
+	store := installSubmissionStore(t, []submission{withPhotos})
+
+	result, err := Submitter{Writer: testWriter(server)}.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result != (SubmitResult{Submitted: 1}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(store.submitted) != 1 || store.submitted[0] != "contrib_1" {
+		t.Fatalf("expected contrib_1 to be marked submitted, got %v", store.submitted)
+	}
+
+	product := off.products["4006381333931"]
+	if product["product_name"] != "Oat Crunch" || product["nutriment_sodium"] != "200" || product["nutriment_sodium_unit"] != "mg" {
+		t.Fatalf("unexpected product sent to OFF: %v", product)
+	}
+	if product["comment"] != "HealthMetrics user contribution contrib_1" {
+		t.Fatalf("expected an edit comment, got %q", product["comment"])
+	}
+	// Only the photo not uploaded yet is sent (and recorded).
+	if _, ok := off.photos["4006381333931/front_en"]; ok {
+		t.Fatalf("front photo was already uploaded and must not be re-sent")
+	}
+	if _, ok := off.photos["4006381333931/nutrition_en"]; !ok || len(store.uploaded) != 1 || store.uploaded[0] != "photo_nutrition" {
+		t.Fatalf("expected the nutrition photo to be uploaded and recorded, got %v / %v", off.photos, store.uploaded)
+	}
+}
+
+func TestSubmitterRun_RetriesThenGivesUp(t *testing.T) {
+	off, server := newFakeOFF(t)
+	off.failNext = 2 // both products get a 503
+
+	store := installSubmissionStore(t, []submission{
+		approvedSubmission("contrib_retry", "4006381333931", 1),
+		approvedSubmission("contrib_last", "96385074", 2),
+	})
+
+	start := time.Now()
+	result, err := Submitter{Writer: testWriter(server), MaxAttempts: 3, BaseDelay: time.Minute}.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result != (SubmitResult{Retrying: 1, Failed: 1}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// Second attempt -> next try after 2x base delay.
+	next := store.failed["contrib_retry"]
+	if next == nil || next.Sub(start) < 2*time.Minute || next.Sub(start) > 2*time.Minute+5*time.Second {
+		t.Fatalf("expected a retry ~2m out, got %v", next)
+	}
+	// Third attempt of 3 -> gave up.
+	if next, ok := store.failed["contrib_last"]; !ok || next != nil {
+		t.Fatalf("expected contrib_last to give up, got %v (recorded %t)", next, ok)
+	}
+	if store.messages["contrib_last"] == "" || len(store.submitted) != 0 {
+		t.Fatalf("expected the error to be recorded and nothing submitted: %v / %v", store.messages, store.submitted)
+	}
+}
+
+func TestSubmitterRun_Rejected(t *testing.T) {
+	off, server := newFakeOFF(t)
+	off.reject = "Invalid code"
+
+	store := installSubmissionStore(t, []submission{approvedSubmission("contrib_1", "4006381333931", 0)})
+	result, err := Submitter{Writer: testWriter(server)}.Run(context.Background())
+	if err != nil || result.Retrying != 1 {
+		t.Fatalf("expected a scheduled retry, got %+v, %v", result, err)
+	}
+	if msg := store.messages["contrib_1"]; !strings.Contains(msg, "save product") || !strings.Contains(msg, "Invalid code") {
+		t.Fatalf("expected OFF's message in off_last_error, got %q", msg)
+	}
+}
+
+func TestSubmitterRun_ClientErrorFailsRightAway(t *testing.T) {
+	off, server := newFakeOFF(t)
+	off.failNext, off.failStatus = 1, http.StatusBadRequest
+
+	store := installSubmissionStore(t, []submission{approvedSubmission("contrib_1", "4006381333931", 0)})
+	result, err := Submitter{Writer: testWriter(server)}.Run(context.Background())
+	if err != nil || result != (SubmitResult{Failed: 1}) {
+		t.Fatalf("expected the first 400 to fail the contribution, got %+v, %v", result, err)
+	}
+	if next, ok := store.failed["contrib_1"]; !ok || next != nil {
+		t.Fatalf("expected no retry to be scheduled, got %v (recorded %t)", next, ok)
+	}
+}
+
+func TestSubmitterRun_ShutdownReleasesClaims(t *testing.T) {
+	_, server := newFakeOFF(t)
+	store := installSubmissionStore(t, []submission{
+		approvedSubmission("contrib_1", "4006381333931", 0),
+		approvedSubmission("contrib_2", "96385074", 0),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := (Submitter{Writer: testWriter(server)}).Run(ctx); err == nil {
+		t.Fatalf("expected the cancelled run to stop")
+	}
+	if len(store.released) != 2 || len(store.failed) != 0 || len(store.submitted) != 0 {
+		t.Fatalf("expected both claims to be handed back untouched, got released=%v failed=%v", store.released, store.failed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	s := Submitter{BaseDelay: 15 * time.Minute}
+	tests := map[int]time.Duration{1: 15 * time.Minute, 2: 30 * time.Minute, 4: 2 * time.Hour, 20: 24 * time.Hour}
+	for attempt, want := range tests {
+		if got := s.retryDelay(attempt); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WithAdvisoryLock runs fn while holding the Postgres advisory lock named name, so a
+// background job runs on one replica at a time. ran=false (and no error) means another
+// session holds the lock and fn was skipped.
+// The lock is session-level: it lives on one pooled connection for the whole run and is
+// released when fn returns, or by Postgres if this process dies mid-run.
+// Example: WithAdvisoryLock(ctx, pool, "recall_ingest", ingester.Run)
+func WithAdvisoryLock(ctx context.Context, pool *pgxpool.Pool, name string, fn func(context.Context) error) (bool, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquire connection for lock %s: %w", name, err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, name).Scan(&locked); err != nil {
+		return false, fmt.Errorf("try advisory lock %s: %w", name, err)
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		// Own context: a cancelled run must still unlock before the connection goes back to
+		// the pool. If the unlock fails, close the connection so Postgres drops the lock.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock(hashtext($1))`, name); err != nil {
+			conn.Conn().Close(unlockCtx)
+		}
+	}()
+
+	return true, fn(ctx)
+}
@@ -0,0 +1,170 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// Blocking: only rows sharing a name token of at least minBlockTokenLen runes are compared.
+// Tokens shared by more than maxBlockSize rows ("chicken", "organic") don't narrow anything and
+// would make the run quadratic; pairs in them are still found through their rarer tokens.
+const (
+	minBlockTokenLen = 3
+	maxBlockSize     = 500
+)
+
+// foodItem is the slice of a food_items row the detector compares.
+type foodItem struct {
+	ID        string
+	Name      string
+	Brand     string
+	Barcode   string // "" when the row has none (USDA, some user entries)
+	Source    string
+	Verified  bool
+	CreatedAt time.Time
+	Nutrients barcode.FoodItemNutrients // per 100 g, sodium in g
+
+	tokens   []string // nameTokens(Name, Brand)
+	brandKey string   // brandKey(Brand)
+}
+
+// proposal is one pair to store in food_item_merge_candidates (AID < BID).
+type proposal struct {
+	AID                 string
+	BID                 string
+	SuggestedSurvivorID string
+	pairScore
+}
+
+// Detector proposes merge candidates from every unmerged food_items row.
+// It never merges anything itself; an admin reviews the candidates.
+type Detector struct {
+	Pool *pgxpool.Pool
+}
+
+// DetectResult summarizes one run (logged by the scheduler).
+type DetectResult struct {
+	Items         int // rows compared
+	Candidates    int // open candidates after the run
+	NewCandidates int // candidates first proposed by this run
+	Removed       int // open candidates that no longer qualify (rows edited or merged since)
+	SkippedBlocks int // name tokens too common to block on
+}
+
+// Run loads the rows, scores candidate pairs and replaces the open candidate set.
+// Dismissed pairs stay dismissed even when they still qualify.
+func (d Detector) Run(ctx context.Context) (DetectResult, error) {
+	var result DetectResult
+
+	items, err := loadFoodItemsFunc(ctx, d.Pool)
+	if err != nil {
+		return result, fmt.Errorf("load food items: %w", err)
+	}
+	result.Items = len(items)
+
+	proposals, skipped := findCandidates(items)
+	result.SkippedBlocks = skipped
+
+	inserted, removed, err := saveCandidatesFunc(ctx, d.Pool, proposals)
+	if err != nil {
+		return result, fmt.Errorf("save merge candidates: %w", err)
+	}
+	result.Candidates = len(proposals)
+	result.NewCandidates = inserted
+	result.Removed = removed
+	return result, nil
+}
+
+// findCandidates blocks rows on name tokens and scores each pair once.
+// Returns proposals (best first) and how many blocks were too large to use.
+func findCandidates(items []foodItem) ([]proposal, int) {
+	blocks := map[string][]int{}
+	for i := range items {
+		items[i].tokens = nameTokens(items[i].Name, items[i].Brand)
+		items[i].brandKey = brandKey(items[i].Brand)
+		for _, token := range items[i].tokens {
+			if utf8.RuneCountInString(token) >= minBlockTokenLen {
+				blocks[token] = append(blocks[token], i)
+			}
+		}
+	}
+
+	// Sorted keys keep runs deterministic (same input -> same proposals).
+	keys := make([]string, 0, len(blocks))
+	for key := range blocks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	skipped := 0
+	seen := map[[2]int]bool{}
+	var proposals []proposal
+	for _, key := range keys {
+		members := blocks[key]
+		if len(members) > maxBlockSize {
+			skipped++
+			continue
+		}
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				pair := [2]int{members[x], members[y]}
+				if seen[pair] {
+					continue
+				}
+				seen[pair] = true
+
+				a, b := items[pair[0]], items[pair[1]]
+				score, ok := scorePair(a, b)
+				if !ok {
+					continue
+				}
+				if b.ID < a.ID {
+					a, b = b, a
+				}
+				proposals = append(proposals, proposal{AID: a.ID, BID: b.ID, SuggestedSurvivorID: suggestSurvivor(a, b), pairScore: score})
+			}
+		}
+	}
+
+	sort.Slice(proposals, func(i, j int) bool {
+		if proposals[i].Score != proposals[j].Score {
+			return proposals[i].Score > proposals[j].Score
+		}
+		if proposals[i].AID != proposals[j].AID {
+			return proposals[i].AID < proposals[j].AID
+		}
+		return proposals[i].BID < proposals[j].BID
+	})
+	return proposals, skipped
+}
+
+// suggestSurvivor picks the row to keep by default: verified first, then the one with a barcode
+// (scans resolve through it), then upstream data over user entries, then the older row.
+func suggestSurvivor(a, b foodItem) string {
+	switch {
+	case a.Verified != b.Verified:
+		return pick(a.Verified, a, b)
+	case (a.Barcode != "") != (b.Barcode != ""):
+		return pick(a.Barcode != "", a, b)
+	case (a.Source == "user") != (b.Source == "user"):
+		return pick(b.Source == "user", a, b)
+	case !a.CreatedAt.Equal(b.CreatedAt):
+		return pick(a.CreatedAt.Before(b.CreatedAt), a, b)
+	default:
+		return pick(a.ID < b.ID, a, b)
+	}
+}
+
+func pick(first bool, a, b foodItem) string {
+	if first {
+		return a.ID
+	}
+	return b.ID
+}
@@ -0,0 +1,113 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+func peanutButter(id, name, brand, code, source string, kcal float64) foodItem {
+	return foodItem{
+		ID: id, Name: name, Brand: brand, Barcode: code, Source: source,
+		CreatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Nutrients: barcode.FoodItemNutrients{CaloriesKcal: kcal, ProteinG: 25, CarbsG: 20, FatG: 50},
+	}
+}
+
+func TestFindCandidates(t *testing.T) {
+	items := []foodItem{
+		peanutButter("food_user", "Creamy peanut butter", "acme", "", "user", 590),
+		peanutButter("food_off", "Acme Creamy Peanut Butter 500g", "Acme", "072745068393", "open_food_facts", 588),
+		peanutButter("food_globex", "Creamy Peanut Butter", "Globex", "", "usda", 588),                        // other brand
+		peanutButter("food_crunchy", "Crunchy Peanut Butter", "Acme", "072745068394", "open_food_facts", 588), // other product
+	}
+
+	proposals, skipped := findCandidates(items)
+	if skipped != 0 {
+		t.Fatalf("unexpected skipped blocks: %d", skipped)
+	}
+	if len(proposals) != 1 {
+		t.Fatalf("expected exactly one proposal, got %+v", proposals)
+	}
+	got := proposals[0]
+	if got.AID != "food_off" || got.BID != "food_user" {
+		t.Fatalf("pair must be stored ordered, got %s / %s", got.AID, got.BID)
+	}
+	if got.SuggestedSurvivorID != "food_off" {
+		t.Fatalf("the row with a barcode should survive, got %s", got.SuggestedSurvivorID)
+	}
+}
+
+func TestFindCandidates_SkipsHugeBlocks(t *testing.T) {
+	// Every row shares "chicken"; only the "tikka" pair shares a usable block.
+	var items []foodItem
+	for i := 0; i <= maxBlockSize; i++ {
+		items = append(items, peanutButter(fmt.Sprintf("food_%04d", i), fmt.Sprintf("Chicken variety%04d", i), "", "", "usda", 200))
+	}
+	items = append(items,
+		peanutButter("food_tikka_a", "Chicken Tikka", "", "", "usda", 150),
+		peanutButter("food_tikka_b", "Chicken Tikka", "Acme", "", "user", 152),
+	)
+
+	proposals, skipped := findCandidates(items)
+	if skipped != 1 {
+		t.Fatalf("expected the chicken block to be skipped, got %d", skipped)
+	}
+	if len(proposals) != 1 || proposals[0].AID != "food_tikka_a" {
+		t.Fatalf("expected only the tikka pair, got %+v", proposals)
+	}
+}
+
+func TestSuggestSurvivor(t *testing.T) {
+	older := peanutButter("food_b", "x", "", "", "user", 100)
+	newer := peanutButter("food_a", "x", "", "", "user", 100)
+	newer.CreatedAt = older.CreatedAt.Add(time.Hour)
+	if got := suggestSurvivor(newer, older); got != "food_b" {
+		t.Errorf("older row should survive, got %s", got)
+	}
+
+	verified := newer
+	verified.Verified = true
+	if got := suggestSurvivor(older, verified); got != "food_a" {
+		t.Errorf("verified row should survive, got %s", got)
+	}
+
+	upstream := newer
+	upstream.Source = "usda"
+	if got := suggestSurvivor(older, upstream); got != "food_a" {
+		t.Errorf("upstream row should beat a user entry, got %s", got)
+	}
+}
+
+func TestDetectorRun(t *testing.T) {
+	origLoad, origSave := loadFoodItemsFunc, saveCandidatesFunc
+	defer func() { loadFoodItemsFunc, saveCandidatesFunc = origLoad, origSave }()
+
+	loadFoodItemsFunc = func(_ context.Context, _ *pgxpool.Pool) ([]foodItem, error) {
+		return []foodItem{
+			peanutButter("food_1", "Creamy Peanut Butter", "Acme", "072745068393", "open_food_facts", 588),
+			peanutButter("food_2", "Peanut Butter Creamy", "Acme Foods", "", "user", 590),
+		}, nil
+	}
+	var saved []proposal
+	saveCandidatesFunc = func(_ context.Context, _ *pgxpool.Pool, proposals []proposal) (int, int, error) {
+		saved = proposals
+		return 1, 2, nil
+	}
+
+	result, err := Detector{}.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result != (DetectResult{Items: 2, Candidates: 1, NewCandidates: 1, Removed: 2}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(saved) != 1 || saved[0].Score < minScore {
+		t.Fatalf("unexpected proposals saved: %+v", saved)
+	}
+}
@@ -0,0 +1,146 @@
+package dedupe
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"healthmetrics-services/internal/httpx"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// MergeRequest is the POST /v1/admin/food-items/merge body.
+// Example:
+//
+//	{"survivor_id": "3f1c...", "duplicate_id": "9a7e..."}
+type MergeRequest struct {
+	SurvivorID  string `json:"survivor_id"`  // row that stays
+	DuplicateID string `json:"duplicate_id"` // row whose references move to the survivor
+}
+
+// NewListCandidatesHandler serves GET /v1/admin/food-items/merge-candidates?status=open&limit=50
+// (best score first). Mount behind auth.RequireAdmin.
+func NewListCandidatesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", StatusOpen)
+		switch status {
+		case StatusOpen, StatusMerged, StatusDismissed:
+		default:
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "status must be open, merged or dismissed")
+			return
+		}
+
+		limit := defaultListLimit
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxListLimit {
+				httpx.WriteError(c, 400, "INVALID_REQUEST", fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+				return
+			}
+			limit = parsed
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		items, err := listCandidatesFunc(c.Request.Context(), pool, status, limit)
+		if err != nil {
+			log.Printf("merge_candidate_read_error request_id=%s admin_id=%s err=%v", c.GetHeader("X-Request-ID"), c.GetString("userID"), err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load merge candidates")
+			return
+		}
+		c.JSON(200, gin.H{"candidates": items})
+	}
+}
+
+// NewDismissCandidateHandler handles POST /v1/admin/food-items/merge-candidates/:id/dismiss
+// (the pair is two different products). Mount behind auth.RequireAdmin.
+func NewDismissCandidateHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		adminID := c.GetString("userID")
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		candidateID := c.Param("id")
+		item, err := dismissCandidateFunc(c.Request.Context(), pool, candidateID, adminID)
+		switch {
+		case errors.Is(err, errNotFound):
+			httpx.WriteError(c, 404, "NOT_FOUND", "Merge candidate not found")
+			return
+		case errors.Is(err, errAlreadyResolved):
+			httpx.WriteError(c, 409, "ALREADY_RESOLVED", "Merge candidate has already been "+item.Status)
+			return
+		case err != nil:
+			log.Printf("merge_candidate_write_error request_id=%s admin_id=%s candidate_id=%s err=%v", requestID, adminID, candidateID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to dismiss merge candidate")
+			return
+		}
+
+		log.Printf("merge_candidate_dismissed request_id=%s admin_id=%s candidate_id=%s", requestID, adminID, candidateID)
+		c.JSON(200, gin.H{"candidate": item})
+	}
+}
+
+// NewMergeHandler handles POST /v1/admin/food-items/merge. Mount behind auth.RequireAdmin.
+// Any two unmerged rows can be merged, proposed by the detector or not; the response lists every
+// diary entry, meal plan and scan that moved (also kept in food_item_merges).
+func NewMergeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		adminID := c.GetString("userID")
+
+		var req MergeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "Invalid JSON body")
+			return
+		}
+		req.SurvivorID = strings.TrimSpace(req.SurvivorID)
+		req.DuplicateID = strings.TrimSpace(req.DuplicateID)
+		if req.SurvivorID == "" || req.DuplicateID == "" {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "survivor_id and duplicate_id are required")
+			return
+		}
+		if req.SurvivorID == req.DuplicateID {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "survivor_id and duplicate_id must be different food items")
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		merge, err := mergeFoodItemsFunc(c.Request.Context(), pool, req.SurvivorID, req.DuplicateID, adminID)
+		switch {
+		case errors.Is(err, errNotFound):
+			httpx.WriteError(c, 404, "NOT_FOUND", "Food item not found")
+			return
+		case errors.Is(err, errAlreadyMerged):
+			httpx.WriteError(c, 409, "ALREADY_MERGED", "One of the food items has already been merged into another")
+			return
+		case err != nil:
+			log.Printf("food_item_merge_error request_id=%s admin_id=%s survivor_id=%s duplicate_id=%s err=%v",
+				requestID, adminID, req.SurvivorID, req.DuplicateID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to merge food items")
+			return
+		}
+
+		log.Printf("food_item_merged request_id=%s admin_id=%s merge_id=%s survivor_id=%s duplicate_id=%s diary_entries=%d meal_plans=%d barcode_scans=%d",
+			requestID, adminID, merge.ID, merge.SurvivorID, merge.DuplicateID, len(merge.DiaryEntryIDs), len(merge.MealPlanIDs), len(merge.BarcodeScanIDs))
+		c.JSON(200, gin.H{"merge": merge})
+	}
+}
@@ -0,0 +1,155 @@
+package dedupe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		c.Set("userID", "admin_1")
+		c.Next()
+	})
+	router.GET("/v1/admin/food-items/merge-candidates", NewListCandidatesHandler())
+	router.POST("/v1/admin/food-items/merge-candidates/:id/dismiss", NewDismissCandidateHandler())
+	router.POST("/v1/admin/food-items/merge", NewMergeHandler())
+	return router
+}
+
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal error: %v (%s)", err, rec.Body.String())
+	}
+	return body.Error.Code
+}
+
+func TestListCandidatesHandler(t *testing.T) {
+	origList := listCandidatesFunc
+	defer func() { listCandidatesFunc = origList }()
+
+	var gotStatus string
+	var gotLimit int
+	listCandidatesFunc = func(_ context.Context, _ *pgxpool.Pool, status string, limit int) ([]Candidate, error) {
+		gotStatus, gotLimit = status, limit
+		return []Candidate{{ID: "cand_1", SuggestedSurvivorID: "food_1", Score: 0.97, Status: StatusOpen}}, nil
+	}
+
+	router := newRouter()
+	rec := serve(router, http.MethodGet, "/v1/admin/food-items/merge-candidates", "")
+	if rec.Code != http.StatusOK || gotStatus != StatusOpen || gotLimit != defaultListLimit {
+		t.Fatalf("expected open candidates with the default limit, got %d %q %d: %s", rec.Code, gotStatus, gotLimit, rec.Body.String())
+	}
+	var body struct {
+		Candidates []Candidate `json:"candidates"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.Candidates) != 1 || body.Candidates[0].ID != "cand_1" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+
+	if rec := serve(router, http.MethodGet, "/v1/admin/food-items/merge-candidates?status=pending", ""); rec.Code != 400 {
+		t.Fatalf("expected 400 for an unknown status, got %d", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/v1/admin/food-items/merge-candidates?limit=500", ""); rec.Code != 400 {
+		t.Fatalf("expected 400 for a limit over the cap, got %d", rec.Code)
+	}
+}
+
+func TestDismissCandidateHandler(t *testing.T) {
+	origDismiss := dismissCandidateFunc
+	defer func() { dismissCandidateFunc = origDismiss }()
+
+	dismissCandidateFunc = func(_ context.Context, _ *pgxpool.Pool, candidateID, adminID string) (Candidate, error) {
+		switch candidateID {
+		case "missing":
+			return Candidate{}, errNotFound
+		case "merged":
+			return Candidate{ID: candidateID, Status: StatusMerged}, errAlreadyResolved
+		}
+		return Candidate{ID: candidateID, Status: StatusDismissed}, nil
+	}
+
+	router := newRouter()
+	if rec := serve(router, http.MethodPost, "/v1/admin/food-items/merge-candidates/cand_1/dismiss", ""); rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(router, http.MethodPost, "/v1/admin/food-items/merge-candidates/missing/dismiss", ""); rec.Code != 404 {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	rec := serve(router, http.MethodPost, "/v1/admin/food-items/merge-candidates/merged/dismiss", "")
+	if rec.Code != 409 || errorCode(t, rec) != "ALREADY_RESOLVED" {
+		t.Fatalf("expected 409 ALREADY_RESOLVED, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMergeHandler(t *testing.T) {
+	origMerge := mergeFoodItemsFunc
+	defer func() { mergeFoodItemsFunc = origMerge }()
+
+	var gotAdmin string
+	mergeFoodItemsFunc = func(_ context.Context, _ *pgxpool.Pool, survivorID, duplicateID, adminID string) (Merge, error) {
+		gotAdmin = adminID
+		switch duplicateID {
+		case "food_missing":
+			return Merge{}, errNotFound
+		case "food_merged":
+			return Merge{}, errAlreadyMerged
+		}
+		return Merge{
+			ID: "merge_1", SurvivorID: survivorID, DuplicateID: duplicateID, MergedBy: adminID,
+			DiaryEntryIDs: []string{"entry_1", "entry_2"}, MealPlanIDs: []string{}, BarcodeScanIDs: []string{"scan_1"},
+		}, nil
+	}
+
+	router := newRouter()
+	rec := serve(router, http.MethodPost, "/v1/admin/food-items/merge", `{"survivor_id": "food_1", "duplicate_id": "food_2"}`)
+	if rec.Code != 200 || gotAdmin != "admin_1" {
+		t.Fatalf("expected 200 merged by admin_1, got %d (%s): %s", rec.Code, gotAdmin, rec.Body.String())
+	}
+	var body struct {
+		Merge Merge `json:"merge"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.Merge.DiaryEntryIDs) != 2 || body.Merge.MealPlanIDs == nil {
+		t.Fatalf("expected the moved ids in the response, got %s", rec.Body.String())
+	}
+
+	tests := []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"survivor_id": "food_1"}`, 400, "INVALID_REQUEST"},
+		{`{"survivor_id": "food_1", "duplicate_id": "food_1"}`, 400, "INVALID_REQUEST"},
+		{`{"survivor_id": "food_1", "duplicate_id": "food_missing"}`, 404, "NOT_FOUND"},
+		{`{"survivor_id": "food_1", "duplicate_id": "food_merged"}`, 409, "ALREADY_MERGED"},
+	}
+	for _, tt := range tests {
+		rec := serve(router, http.MethodPost, "/v1/admin/food-items/merge", tt.body)
+		if rec.Code != tt.status || errorCode(t, rec) != tt.code {
+			t.Errorf("%s: expected %d %s, got %d: %s", tt.body, tt.status, tt.code, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package dedupe finds food_items rows that describe the same product (OpenFoodFacts, USDA and
+// user entries overlap) and merges them: references move to the surviving row, the duplicate
+// stays behind as a tombstone (merged_into_id) so history and old ids keep resolving.
+package dedupe
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// Thresholds for proposing a pair. Names must be close, brands must not contradict each other,
+// and per-100g nutrients must agree within a label-rounding margin.
+const (
+	minNameSimilarity   = 0.75
+	maxNutrientDistance = 0.15 // mean relative difference across compared nutrients
+	maxCalorieDiff      = 0.1  // calories alone; the mean can hide a "light" variant
+	minScore            = 0.8
+
+	// Score weights (sum to 1).
+	nameWeight     = 0.5
+	brandWeight    = 0.2
+	nutrientWeight = 0.3
+)
+
+var (
+	// "500g", "1.5", "12", "330ml", "2x" - pack sizes differ between otherwise identical listings.
+	sizeTokenRegex = regexp.MustCompile(`^[0-9]+(?:[.,][0-9]+)?(?:g|kg|mg|ml|cl|l|oz|lb|lbs|ct|pk|x)?$`)
+
+	// Words that carry no product identity in names.
+	nameStopwords = map[string]bool{
+		"a": true, "an": true, "and": true, "the": true, "of": true, "with": true, "in": true,
+		"g": true, "kg": true, "mg": true, "ml": true, "cl": true, "l": true, "oz": true, "fl": true,
+		"lb": true, "lbs": true, "pack": true, "pk": true, "ct": true, "count": true, "x": true,
+	}
+
+	// Company suffixes: "Acme Foods Inc." and "Acme" are the same brand.
+	brandSuffixes = map[string]bool{
+		"inc": true, "llc": true, "ltd": true, "co": true, "corp": true, "company": true,
+		"foods": true, "food": true, "gmbh": true, "sa": true, "ag": true, "plc": true,
+	}
+
+	// Accents seen in OFF names; folding them lets "Crème" match "Creme".
+	accentFolder = strings.NewReplacer(
+		"à", "a", "á", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+		"ç", "c",
+		"è", "e", "é", "e", "ê", "e", "ë", "e",
+		"ì", "i", "í", "i", "î", "i", "ï", "i",
+		"ñ", "n",
+		"ò", "o", "ó", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+		"ù", "u", "ú", "u", "û", "u", "ü", "u",
+		"ß", "ss",
+	)
+)
+
+// tokenize lowercases, folds accents and splits on anything that isn't a letter or digit.
+func tokenize(value string) []string {
+	value = accentFolder.Replace(strings.ToLower(value))
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameTokens is the normalized, sorted, de-duplicated name: no sizes, stopwords or brand words
+// ("Acme Creamy Peanut Butter 500g" with brand Acme -> [butter creamy peanut]).
+// Brand words are kept when they are the whole name.
+func nameTokens(name, brand string) []string {
+	brandWords := map[string]bool{}
+	for _, word := range tokenize(brand) {
+		brandWords[word] = true
+	}
+
+	seen := map[string]bool{}
+	var tokens, withBrand []string
+	for _, word := range tokenize(name) {
+		if nameStopwords[word] || sizeTokenRegex.MatchString(word) || seen[word] {
+			continue
+		}
+		seen[word] = true
+		withBrand = append(withBrand, word)
+		if !brandWords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	if len(tokens) == 0 {
+		tokens = withBrand
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// brandKey normalizes a brand for comparison ("Acme Foods, Inc." -> "acme"). "" = unknown.
+func brandKey(brand string) string {
+	var words []string
+	for _, word := range tokenize(brand) {
+		if !brandSuffixes[word] {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return strings.Join(tokenize(brand), "") // the brand is only a suffix word; keep it
+	}
+	return strings.Join(words, "")
+}
+
+// nameSimilarity compares normalized names: the better of token overlap (handles reordering)
+// and character trigram overlap (handles plurals and typos). 0..1.
+func nameSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	return math.Max(dice(toSet(a), toSet(b)), dice(trigrams(strings.Join(a, " ")), trigrams(strings.Join(b, " "))))
+}
+
+// brandSimilarity: same brand 1, one brand a prefix of the other 0.8 ("acme" / "acmeorganic"),
+// unknown on either side 0.5, different brands 0 (never duplicates).
+func brandSimilarity(a, b string) float64 {
+	switch {
+	case a == "" || b == "":
+		return 0.5
+	case a == b:
+		return 1
+	case strings.HasPrefix(a, b) || strings.HasPrefix(b, a):
+		return 0.8
+	default:
+		return 0
+	}
+}
+
+// nutrientDistance is the mean relative difference of per-100g values. Calories and macros are
+// always compared; fiber, sugar and sodium only when both rows have them. The floors keep
+// near-zero values (0.1 g vs 0.3 g fat) from looking wildly different.
+func nutrientDistance(a, b barcode.FoodItemNutrients) float64 {
+	diffs := []float64{
+		relativeDiff(a.CaloriesKcal, b.CaloriesKcal, 20),
+		relativeDiff(a.ProteinG, b.ProteinG, 1),
+		relativeDiff(a.CarbsG, b.CarbsG, 1),
+		relativeDiff(a.FatG, b.FatG, 1),
+	}
+	optional := []struct {
+		a, b  *float64
+		floor float64
+	}{
+		{a.FiberG, b.FiberG, 1},
+		{a.SugarG, b.SugarG, 1},
+		{a.SodiumG, b.SodiumG, 0.05},
+	}
+	for _, value := range optional {
+		if value.a != nil && value.b != nil {
+			diffs = append(diffs, relativeDiff(*value.a, *value.b, value.floor))
+		}
+	}
+
+	total := 0.0
+	for _, diff := range diffs {
+		total += diff
+	}
+	return total / float64(len(diffs))
+}
+
+func relativeDiff(a, b, floor float64) float64 {
+	scale := math.Max(floor, math.Max(math.Abs(a), math.Abs(b)))
+	return math.Min(1, math.Abs(a-b)/scale)
+}
+
+// pairScore holds the signals behind one proposed pair.
+type pairScore struct {
+	Score            float64
+	NameSimilarity   float64
+	BrandSimilarity  float64
+	NutrientDistance float64
+}
+
+// scorePair compares two rows; ok=false when they should not be proposed.
+func scorePair(a, b foodItem) (pairScore, bool) {
+	brandSim := brandSimilarity(a.brandKey, b.brandKey)
+	if brandSim == 0 {
+		return pairScore{}, false
+	}
+	nameSim := nameSimilarity(a.tokens, b.tokens)
+	if nameSim < minNameSimilarity {
+		return pairScore{}, false
+	}
+	distance := nutrientDistance(a.Nutrients, b.Nutrients)
+	if distance > maxNutrientDistance || relativeDiff(a.Nutrients.CaloriesKcal, b.Nutrients.CaloriesKcal, 20) > maxCalorieDiff {
+		return pairScore{}, false
+	}
+
+	score := nameWeight*nameSim + brandWeight*brandSim + nutrientWeight*(1-distance/maxNutrientDistance)
+	if score < minScore {
+		return pairScore{}, false
+	}
+	return pairScore{
+		Score:            round4(score),
+		NameSimilarity:   round4(nameSim),
+		BrandSimilarity:  round4(brandSim),
+		NutrientDistance: round4(distance),
+	}, true
+}
+
+// round4 matches the DECIMAL(5,4) score columns.
+func round4(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// trigrams returns the padded character trigrams of value.
+func trigrams(value string) map[string]bool {
+	runes := []rune(" " + value + " ")
+	set := map[string]bool{}
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// dice is the Sørensen–Dice coefficient of two sets.
+func dice(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for key := range a {
+		if b[key] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
@@ -0,0 +1,99 @@
+package dedupe
+
+import (
+	"reflect"
+	"testing"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+func TestNameTokens(t *testing.T) {
+	tests := []struct {
+		name, brand string
+		want        []string
+	}{
+		{"Acme Creamy Peanut Butter 500g", "Acme", []string{"butter", "creamy", "peanut"}},
+		{"Peanut Butter, Creamy (16 oz)", "", []string{"butter", "creamy", "peanut"}},
+		{"Crème Fraîche", "", []string{"creme", "fraiche"}},
+		{"Acme", "Acme Foods", []string{"acme"}}, // brand-only name keeps the brand word
+		{"2 x 330 ml Cola", "", []string{"cola"}},
+	}
+	for _, tt := range tests {
+		if got := nameTokens(tt.name, tt.brand); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("nameTokens(%q, %q) = %v, want %v", tt.name, tt.brand, got, tt.want)
+		}
+	}
+}
+
+func TestBrandSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Acme Foods, Inc.", "ACME", 1},
+		{"Acme", "Acme Organic", 0.8},
+		{"Acme", "", 0.5},
+		{"Acme", "Globex", 0},
+	}
+	for _, tt := range tests {
+		if got := brandSimilarity(brandKey(tt.a), brandKey(tt.b)); got != tt.want {
+			t.Errorf("brandSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	same := nameSimilarity(nameTokens("Creamy Peanut Butter", ""), nameTokens("Peanut Butter Creamy", ""))
+	if same != 1 {
+		t.Errorf("reordered names should match exactly, got %v", same)
+	}
+	plural := nameSimilarity(nameTokens("Oat Cookie", ""), nameTokens("Oat Cookies", ""))
+	if plural < minNameSimilarity {
+		t.Errorf("plural should clear the threshold, got %v", plural)
+	}
+	different := nameSimilarity(nameTokens("Peanut Butter", ""), nameTokens("Almond Milk", ""))
+	if different > 0.3 {
+		t.Errorf("unrelated names should not match, got %v", different)
+	}
+}
+
+func TestNutrientDistance(t *testing.T) {
+	sodium := 0.4
+	a := barcode.FoodItemNutrients{CaloriesKcal: 588, ProteinG: 25, CarbsG: 20, FatG: 50, SodiumG: &sodium}
+	b := barcode.FoodItemNutrients{CaloriesKcal: 590, ProteinG: 25, CarbsG: 20, FatG: 50} // sodium unknown: not compared
+	if got := nutrientDistance(a, b); got > 0.01 {
+		t.Errorf("label rounding should be close to 0, got %v", got)
+	}
+
+	// Near-zero values use the floor: 0.1 g vs 0.3 g fat is not a 67% difference.
+	lean := barcode.FoodItemNutrients{CaloriesKcal: 42, ProteinG: 3.4, CarbsG: 5, FatG: 0.1}
+	lean2 := barcode.FoodItemNutrients{CaloriesKcal: 42, ProteinG: 3.4, CarbsG: 5, FatG: 0.3}
+	if got := nutrientDistance(lean, lean2); got > 0.06 {
+		t.Errorf("expected a small distance for trace fat, got %v", got)
+	}
+
+	light := barcode.FoodItemNutrients{CaloriesKcal: 350, ProteinG: 25, CarbsG: 30, FatG: 20}
+	if got := nutrientDistance(a, light); got <= maxNutrientDistance {
+		t.Errorf("a light version should be too far to merge, got %v", got)
+	}
+}
+
+func TestScorePair(t *testing.T) {
+	item := func(name, brand string, kcal float64) foodItem {
+		return foodItem{
+			Name: name, Brand: brand, Nutrients: barcode.FoodItemNutrients{CaloriesKcal: kcal, ProteinG: 25, CarbsG: 20, FatG: 50},
+			tokens: nameTokens(name, brand), brandKey: brandKey(brand),
+		}
+	}
+
+	score, ok := scorePair(item("Acme Creamy Peanut Butter 500g", "Acme", 588), item("Creamy Peanut Butter", "Acme Foods", 590))
+	if !ok || score.Score < 0.95 || score.NameSimilarity != 1 || score.BrandSimilarity != 1 {
+		t.Fatalf("expected a strong candidate, got %+v (ok=%t)", score, ok)
+	}
+	if _, ok := scorePair(item("Creamy Peanut Butter", "Acme", 588), item("Creamy Peanut Butter", "Globex", 588)); ok {
+		t.Errorf("different brands must not be proposed")
+	}
+	if _, ok := scorePair(item("Creamy Peanut Butter", "Acme", 588), item("Creamy Peanut Butter", "Acme", 480)); ok {
+		t.Errorf("different calories must not be proposed")
+	}
+}
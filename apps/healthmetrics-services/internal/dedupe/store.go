@@ -0,0 +1,457 @@
+package dedupe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// Swappable DB helpers so tests can run without Postgres.
+var (
+	loadFoodItemsFunc    = loadFoodItems
+	saveCandidatesFunc   = saveCandidates
+	listCandidatesFunc   = listCandidates
+	dismissCandidateFunc = dismissCandidate
+	mergeFoodItemsFunc   = mergeFoodItems
+)
+
+// changeSourceMerge tags the food_items update that marks a duplicate merged (food_item_revisions).
+const changeSourceMerge = "admin_merge"
+
+// Candidate statuses (food_item_merge_candidates.status).
+const (
+	StatusOpen      = "open"
+	StatusMerged    = "merged"
+	StatusDismissed = "dismissed"
+)
+
+// Store errors the handlers map to status codes.
+var (
+	errNotFound        = errors.New("not found")
+	errAlreadyResolved = errors.New("merge candidate already resolved")
+	errAlreadyMerged   = errors.New("food item already merged")
+)
+
+// FoodItemSummary is one side of a candidate, with what an admin needs to pick the survivor.
+type FoodItemSummary struct {
+	ID           string                    `json:"id"`
+	Name         string                    `json:"name"`
+	Brand        string                    `json:"brand"`
+	Barcode      string                    `json:"barcode"`
+	Source       string                    `json:"source"`
+	Verified     bool                      `json:"verified"`
+	Nutrients    barcode.FoodItemNutrients `json:"nutrients_per_100g"` // sodium in g
+	DiaryEntries int                       `json:"diary_entries"`      // entries that would move if this row is the duplicate
+}
+
+// Candidate is a proposed duplicate pair (GET /v1/admin/food-items/merge-candidates).
+type Candidate struct {
+	ID                  string          `json:"id"`
+	FoodItemA           FoodItemSummary `json:"food_item_a"`
+	FoodItemB           FoodItemSummary `json:"food_item_b"`
+	SuggestedSurvivorID string          `json:"suggested_survivor_id"`
+	Score               float64         `json:"score"`
+	NameSimilarity      float64         `json:"name_similarity"`
+	BrandSimilarity     float64         `json:"brand_similarity"`
+	NutrientDistance    float64         `json:"nutrient_distance"`
+	Status              string          `json:"status"`
+	ResolvedAt          *time.Time      `json:"resolved_at"`
+	CreatedAt           time.Time       `json:"created_at"`
+}
+
+// Merge is one food_item_merges row: which rows were re-pointed from the duplicate to the survivor.
+type Merge struct {
+	ID             string    `json:"id"`
+	SurvivorID     string    `json:"survivor_id"`
+	DuplicateID    string    `json:"duplicate_id"`
+	MergedBy       string    `json:"merged_by"`
+	DiaryEntryIDs  []string  `json:"diary_entry_ids"`
+	MealPlanIDs    []string  `json:"meal_plan_ids"`
+	BarcodeScanIDs []string  `json:"barcode_scan_ids"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// loadFoodItems reads every unmerged row the detector should compare.
+// Rows flagged nutrition_missing are skipped: all-zero nutrients would "match" each other.
+func loadFoodItems(ctx context.Context, pool *pgxpool.Pool) ([]foodItem, error) {
+	const query = `
+		SELECT
+			id,
+			name,
+			COALESCE(brand, ''),
+			COALESCE(barcode, ''),
+			source::text,
+			verified,
+			created_at,
+			calories_per_100g::float8,
+			protein_g::float8,
+			carbs_g::float8,
+			fat_g::float8,
+			fiber_g::float8,
+			sugar_g::float8,
+			(sodium_mg / 1000.0)::float8
+		FROM food_items
+		WHERE merged_into_id IS NULL
+			AND NOT ('nutrition_missing' = ANY(COALESCE(data_quality_flags, ARRAY[]::text[])))
+	`
+
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []foodItem
+	for rows.Next() {
+		var (
+			item                 foodItem
+			fiber, sugar, sodium sql.NullFloat64
+		)
+		if err := rows.Scan(
+			&item.ID, &item.Name, &item.Brand, &item.Barcode, &item.Source, &item.Verified, &item.CreatedAt,
+			&item.Nutrients.CaloriesKcal, &item.Nutrients.ProteinG, &item.Nutrients.CarbsG, &item.Nutrients.FatG,
+			&fiber, &sugar, &sodium,
+		); err != nil {
+			return nil, err
+		}
+		item.Nutrients.FiberG = barcode.NullFloat64ToPtr(fiber)
+		item.Nutrients.SugarG = barcode.NullFloat64ToPtr(sugar)
+		item.Nutrients.SodiumG = barcode.NullFloat64ToPtr(sodium)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// saveCandidates upserts this run's proposals and deletes open candidates it no longer proposes,
+// in one transaction so the review queue never shows a half-written run.
+// Resolved (merged/dismissed) pairs are left alone. Returns (inserted, removed).
+func saveCandidates(ctx context.Context, pool *pgxpool.Pool, proposals []proposal) (int, int, error) {
+	var (
+		aIDs, bIDs, survivorIDs                 = []string{}, []string{}, []string{}
+		scores, nameSims, brandSims, nutrientDs = []float64{}, []float64{}, []float64{}, []float64{}
+	)
+	for _, p := range proposals {
+		aIDs = append(aIDs, p.AID)
+		bIDs = append(bIDs, p.BID)
+		survivorIDs = append(survivorIDs, p.SuggestedSurvivorID)
+		scores = append(scores, p.Score)
+		nameSims = append(nameSims, p.NameSimilarity)
+		brandSims = append(brandSims, p.BrandSimilarity)
+		nutrientDs = append(nutrientDs, p.NutrientDistance)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	rows, err := tx.Query(ctx, `
+		INSERT INTO food_item_merge_candidates (
+			id, food_item_a_id, food_item_b_id, suggested_survivor_id,
+			score, name_similarity, brand_similarity, nutrient_distance,
+			status, created_at, updated_at
+		)
+		SELECT gen_random_uuid()::text, p.a, p.b, p.survivor, p.score, p.name_sim, p.brand_sim, p.nutrient_distance,
+			'open', now(), now()
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::float8[], $5::float8[], $6::float8[], $7::float8[])
+			AS p(a, b, survivor, score, name_sim, brand_sim, nutrient_distance)
+		ON CONFLICT (food_item_a_id, food_item_b_id) DO UPDATE SET
+			suggested_survivor_id = EXCLUDED.suggested_survivor_id,
+			score = EXCLUDED.score,
+			name_similarity = EXCLUDED.name_similarity,
+			brand_similarity = EXCLUDED.brand_similarity,
+			nutrient_distance = EXCLUDED.nutrient_distance,
+			updated_at = now()
+		WHERE food_item_merge_candidates.status = 'open'
+		RETURNING (xmax = 0) AS inserted
+	`, aIDs, bIDs, survivorIDs, scores, nameSims, brandSims, nutrientDs)
+	if err != nil {
+		return 0, 0, err
+	}
+	inserted := 0
+	for rows.Next() {
+		var isNew bool
+		if err := rows.Scan(&isNew); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		if isNew {
+			inserted++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM food_item_merge_candidates m
+		WHERE m.status = 'open'
+			AND NOT EXISTS (
+				SELECT 1 FROM unnest($1::text[], $2::text[]) AS p(a, b)
+				WHERE p.a = m.food_item_a_id AND p.b = m.food_item_b_id
+			)
+	`, aIDs, bIDs)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+	return inserted, int(tag.RowsAffected()), nil
+}
+
+// summaryColumns is one FoodItemSummary for the food_items alias given.
+func summaryColumns(alias string) string {
+	return fmt.Sprintf(`
+		%[1]s.id,
+		%[1]s.name,
+		COALESCE(%[1]s.brand, ''),
+		COALESCE(%[1]s.barcode, ''),
+		%[1]s.source::text,
+		%[1]s.verified,
+		%[1]s.calories_per_100g::float8,
+		%[1]s.protein_g::float8,
+		%[1]s.carbs_g::float8,
+		%[1]s.fat_g::float8,
+		%[1]s.fiber_g::float8,
+		%[1]s.sugar_g::float8,
+		(%[1]s.sodium_mg / 1000.0)::float8,
+		(SELECT count(*) FROM diary_entries d WHERE d.food_item_id = %[1]s.id)`, alias)
+}
+
+// candidateQuery selects Candidates; callers append WHERE/ORDER/LIMIT.
+var candidateQuery = `
+	SELECT
+		m.id,
+		m.suggested_survivor_id,
+		m.score::float8,
+		m.name_similarity::float8,
+		m.brand_similarity::float8,
+		m.nutrient_distance::float8,
+		m.status::text,
+		m.resolved_at,
+		m.created_at,` + summaryColumns("a") + `,` + summaryColumns("b") + `
+	FROM food_item_merge_candidates m
+	JOIN food_items a ON a.id = m.food_item_a_id
+	JOIN food_items b ON b.id = m.food_item_b_id
+`
+
+func scanCandidate(row pgx.Row) (Candidate, error) {
+	var (
+		item       Candidate
+		aOpt, bOpt [3]sql.NullFloat64 // fiber, sugar, sodium
+	)
+	err := row.Scan(
+		&item.ID, &item.SuggestedSurvivorID, &item.Score, &item.NameSimilarity, &item.BrandSimilarity,
+		&item.NutrientDistance, &item.Status, &item.ResolvedAt, &item.CreatedAt,
+		&item.FoodItemA.ID, &item.FoodItemA.Name, &item.FoodItemA.Brand, &item.FoodItemA.Barcode,
+		&item.FoodItemA.Source, &item.FoodItemA.Verified,
+		&item.FoodItemA.Nutrients.CaloriesKcal, &item.FoodItemA.Nutrients.ProteinG,
+		&item.FoodItemA.Nutrients.CarbsG, &item.FoodItemA.Nutrients.FatG,
+		&aOpt[0], &aOpt[1], &aOpt[2], &item.FoodItemA.DiaryEntries,
+		&item.FoodItemB.ID, &item.FoodItemB.Name, &item.FoodItemB.Brand, &item.FoodItemB.Barcode,
+		&item.FoodItemB.Source, &item.FoodItemB.Verified,
+		&item.FoodItemB.Nutrients.CaloriesKcal, &item.FoodItemB.Nutrients.ProteinG,
+		&item.FoodItemB.Nutrients.CarbsG, &item.FoodItemB.Nutrients.FatG,
+		&bOpt[0], &bOpt[1], &bOpt[2], &item.FoodItemB.DiaryEntries,
+	)
+	if err != nil {
+		return Candidate{}, err
+	}
+	item.FoodItemA.Nutrients.FiberG, item.FoodItemA.Nutrients.SugarG, item.FoodItemA.Nutrients.SodiumG =
+		barcode.NullFloat64ToPtr(aOpt[0]), barcode.NullFloat64ToPtr(aOpt[1]), barcode.NullFloat64ToPtr(aOpt[2])
+	item.FoodItemB.Nutrients.FiberG, item.FoodItemB.Nutrients.SugarG, item.FoodItemB.Nutrients.SodiumG =
+		barcode.NullFloat64ToPtr(bOpt[0]), barcode.NullFloat64ToPtr(bOpt[1]), barcode.NullFloat64ToPtr(bOpt[2])
+	return item, nil
+}
+
+// listCandidates returns candidates with the given status, best score first.
+func listCandidates(ctx context.Context, pool *pgxpool.Pool, status string, limit int) ([]Candidate, error) {
+	rows, err := pool.Query(ctx, candidateQuery+`
+		WHERE m.status::text = $1
+		ORDER BY m.score DESC, m.created_at ASC
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Candidate{} // never nil, so JSON is [] not null
+	for rows.Next() {
+		item, err := scanCandidate(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// dismissCandidate marks an open candidate as not a duplicate; later runs won't reopen it.
+func dismissCandidate(ctx context.Context, pool *pgxpool.Pool, candidateID, adminID string) (Candidate, error) {
+	tag, err := pool.Exec(ctx, `
+		UPDATE food_item_merge_candidates SET
+			status = 'dismissed',
+			resolved_by = $2,
+			resolved_at = now(),
+			updated_at = now()
+		WHERE id = $1 AND status = 'open'
+	`, candidateID, adminID)
+	if err != nil {
+		return Candidate{}, err
+	}
+
+	item, err := scanCandidate(pool.QueryRow(ctx, candidateQuery+` WHERE m.id = $1`, candidateID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Candidate{}, errNotFound
+	}
+	if err != nil {
+		return Candidate{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return item, errAlreadyResolved
+	}
+	return item, nil
+}
+
+// mergeFoodItems merges duplicateID into survivorID in one transaction:
+//   - diary_entries, meal_plans and barcode_scans pointing at the duplicate move to the survivor;
+//     diary entries remember the item they were logged against (original_food_item_id), so past
+//     nutrient totals still come from the duplicate's revisions
+//   - rows merged into the duplicate earlier are re-pointed too (no merge chains)
+//   - the duplicate is kept with merged_into_id set (recorded in food_item_revisions), so its
+//     barcode and id keep resolving to the survivor
+//   - the moved row ids go to food_item_merges, and candidates for the pair are closed
+func mergeFoodItems(ctx context.Context, pool *pgxpool.Pool, survivorID, duplicateID, adminID string) (Merge, error) {
+	merge := Merge{SurvivorID: survivorID, DuplicateID: duplicateID, MergedBy: adminID}
+	err := barcode.WithChangeContext(ctx, pool, changeSourceMerge, adminID, func(tx pgx.Tx) error {
+		// Lock both rows so concurrent merges of either one serialize.
+		rows, err := tx.Query(ctx, `
+			SELECT id, merged_into_id IS NOT NULL
+			FROM food_items
+			WHERE id IN ($1, $2)
+			ORDER BY id
+			FOR UPDATE
+		`, survivorID, duplicateID)
+		if err != nil {
+			return err
+		}
+		found := 0
+		alreadyMerged := false
+		for rows.Next() {
+			var id string
+			var merged bool
+			if err := rows.Scan(&id, &merged); err != nil {
+				rows.Close()
+				return err
+			}
+			found++
+			alreadyMerged = alreadyMerged || merged
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if found != 2 {
+			return errNotFound
+		}
+		if alreadyMerged {
+			return errAlreadyMerged
+		}
+
+		if merge.DiaryEntryIDs, err = collectIDs(ctx, tx, `
+			UPDATE diary_entries SET
+				original_food_item_id = COALESCE(original_food_item_id, food_item_id),
+				food_item_id = $1,
+				updated_at = now()
+			WHERE food_item_id = $2
+			RETURNING id
+		`, survivorID, duplicateID); err != nil {
+			return fmt.Errorf("move diary_entries: %w", err)
+		}
+		if merge.MealPlanIDs, err = collectIDs(ctx, tx, `
+			UPDATE meal_plans SET food_item_id = $1, updated_at = now()
+			WHERE food_item_id = $2
+			RETURNING id
+		`, survivorID, duplicateID); err != nil {
+			return fmt.Errorf("move meal_plans: %w", err)
+		}
+		if merge.BarcodeScanIDs, err = collectIDs(ctx, tx, `
+			UPDATE barcode_scans SET food_item_id = $1
+			WHERE food_item_id = $2
+			RETURNING id
+		`, survivorID, duplicateID); err != nil {
+			return fmt.Errorf("move barcode_scans: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, `
+			UPDATE food_items SET merged_into_id = $1, updated_at = now()
+			WHERE merged_into_id = $2
+		`, survivorID, duplicateID); err != nil {
+			return fmt.Errorf("re-point earlier merges: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE food_items SET merged_into_id = $1, merged_at = now(), updated_at = now()
+			WHERE id = $2
+		`, survivorID, duplicateID); err != nil {
+			return fmt.Errorf("mark duplicate merged: %w", err)
+		}
+
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO food_item_merges (
+				id, survivor_id, duplicate_id, merged_by, diary_entry_ids, meal_plan_ids, barcode_scan_ids, created_at
+			) VALUES (gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, now())
+			RETURNING id, created_at
+		`, survivorID, duplicateID, adminID, merge.DiaryEntryIDs, merge.MealPlanIDs, merge.BarcodeScanIDs,
+		).Scan(&merge.ID, &merge.CreatedAt); err != nil {
+			return fmt.Errorf("insert food_item_merges: %w", err)
+		}
+
+		// The pair's own candidate (if any) is merged; other open candidates of the duplicate are moot.
+		if _, err := tx.Exec(ctx, `
+			UPDATE food_item_merge_candidates SET
+				status = 'merged', resolved_by = $3, resolved_at = now(), updated_at = now()
+			WHERE food_item_a_id = LEAST($1, $2) AND food_item_b_id = GREATEST($1, $2)
+		`, survivorID, duplicateID, adminID); err != nil {
+			return fmt.Errorf("close merge candidate: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM food_item_merge_candidates
+			WHERE status = 'open' AND (food_item_a_id = $1 OR food_item_b_id = $1)
+		`, duplicateID); err != nil {
+			return fmt.Errorf("drop merge candidates: %w", err)
+		}
+		return nil
+	})
+	return merge, err
+}
+
+// collectIDs runs an UPDATE ... RETURNING id and returns the ids ([] when nothing matched).
+func collectIDs(ctx context.Context, tx pgx.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,372 @@
+// Package diary writes and summarizes food diary entries (diary_entries) for the authenticated user.
+package diary
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+	"healthmetrics-services/internal/httpx"
+	"healthmetrics-services/internal/units"
+)
+
+// Limits that keep values inside the diary_entries DECIMAL columns (and out of typo territory).
+const (
+	maxQuantityG         = 5000.0 // quantity_g DECIMAL(10,2); 5 kg in one entry is already a typo
+	maxServings          = 100.0  // servings DECIMAL(5,2) allows up to 999.99
+	maxIdempotencyKeyLen = 255
+	maxNotesLen          = 1000
+	fallbackServingSizeG = 100.0 // food_items without a usable serving size (matches barcode.parseServingSize)
+)
+
+// mealTypes mirrors the MealType enum in prisma/schema.prisma.
+var mealTypes = map[string]bool{
+	"breakfast": true,
+	"lunch":     true,
+	"dinner":    true,
+	"snack":     true,
+	"other":     true,
+}
+
+// ProductResolver looks up a barcode (cache -> OpenFoodFacts); barcode.Resolver in production.
+// It writes the error response itself, like barcode.Resolver.Resolve.
+type ProductResolver interface {
+	Resolve(c *gin.Context, pool *pgxpool.Pool, code string) (barcode.FoodItem, bool)
+}
+
+// CreateEntryRequest is the POST /v1/diary/entries body.
+// Give exactly one of Barcode / FoodItemID and exactly one of QuantityG / Servings.
+// Example: {"barcode": "072745068393", "meal_type": "breakfast", "date": "2026-10-18", "servings": 1.5}
+type CreateEntryRequest struct {
+	Barcode    string   `json:"barcode"`
+	FoodItemID string   `json:"food_item_id"`
+	MealType   string   `json:"meal_type"`
+	Date       string   `json:"date"`       // user's local day, YYYY-MM-DD
+	QuantityG  *float64 `json:"quantity_g"` // grams eaten
+	Servings   *float64 `json:"servings"`   // or servings eaten (x food_items.serving_size_g)
+	Notes      string   `json:"notes"`
+}
+
+// FoodSummary is the food item an entry points at.
+type FoodSummary struct {
+	ID              string                    `json:"id"`
+	Name            string                    `json:"name"`
+	Brand           string                    `json:"brand"`
+	Barcode         string                    `json:"barcode,omitempty"`
+	ServingSizeG    float64                   `json:"serving_size_g"`
+	ServingSizeUnit string                    `json:"serving_size_unit,omitempty"`
+	Per100g         barcode.FoodItemNutrients `json:"nutrients_per_100g"`
+
+	ServingSizeDisplay units.Quantity `json:"serving_size_display"` // serving in the request's unit system
+}
+
+// Entry is the created (or replayed) diary entry with nutrients for the eaten quantity.
+type Entry struct {
+	ID        string                    `json:"id"`
+	UserID    string                    `json:"user_id"`
+	Date      string                    `json:"date"`
+	MealType  string                    `json:"meal_type"`
+	QuantityG float64                   `json:"quantity_g"`
+	Servings  float64                   `json:"servings"`
+	Notes     *string                   `json:"notes"`
+	CreatedAt time.Time                 `json:"created_at"`
+	FoodItem  FoodSummary               `json:"food_item"`
+	Nutrients barcode.FoodItemNutrients `json:"nutrients"` // for quantity_g
+
+	// quantity_g in the request's unit system (oz / fl oz for imperial); nutrients stay in g.
+	Units           string         `json:"units"`
+	QuantityDisplay units.Quantity `json:"quantity_display"`
+}
+
+// NewCreateEntryHandler handles POST /v1/diary/entries.
+// Flow: validate -> resolve the food (barcode lookup or food_item_id) -> serving math ->
+// one transaction writing diary_entries + barcode_scans.
+// Clients must send an Idempotency-Key header: retrying with the same key returns the first
+// entry (200, Idempotent-Replayed: true) instead of logging the food twice.
+func NewCreateEntryHandler(resolver ProductResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+
+		userID := c.GetString("userID") // set by auth middleware after session validation
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+
+		idempotencyKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if idempotencyKey == "" || len(idempotencyKey) > maxIdempotencyKeyLen {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", fmt.Sprintf("Idempotency-Key header is required (max %d characters)", maxIdempotencyKeyLen))
+			return
+		}
+
+		var req CreateEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "Invalid JSON body")
+			return
+		}
+		if message := validateRequest(&req); message != "" {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", message)
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		// A retry is answered from the stored entry before anything is resolved: no OpenFoodFacts
+		// call, and the check can't be thrown off by a food item that changed since.
+		fingerprint := requestFingerprint(req)
+		existing, found, err := getEntryByKeyFunc(c.Request.Context(), pool, userID, idempotencyKey)
+		if err != nil {
+			log.Printf("diary_entry_error request_id=%s user_id=%s err=%v", requestID, userID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to create diary entry")
+			return
+		}
+		if found {
+			replayEntry(c, pool, userID, existing, fingerprint)
+			return
+		}
+
+		food, scannedBarcode, ok := resolveFood(c, pool, resolver, req)
+		if !ok {
+			return
+		}
+
+		quantityG, servings, message := servingMath(req, food.ServingSizeG)
+		if message != "" {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", message)
+			return
+		}
+
+		var notes *string
+		if req.Notes != "" {
+			notes = &req.Notes
+		}
+
+		stored, created, err := createEntryFunc(c.Request.Context(), pool, newEntry{
+			UserID:         userID,
+			FoodItemID:     food.ID,
+			Date:           req.Date,
+			MealType:       req.MealType,
+			QuantityG:      quantityG,
+			Servings:       servings,
+			Notes:          notes,
+			IdempotencyKey: idempotencyKey,
+			Fingerprint:    fingerprint,
+			Barcode:        scannedBarcode,
+		})
+		if err != nil {
+			log.Printf("diary_entry_error request_id=%s user_id=%s err=%v", requestID, userID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to create diary entry")
+			return
+		}
+
+		if !created {
+			// A concurrent request with the same key inserted first.
+			replayEntry(c, pool, userID, stored, fingerprint)
+			return
+		}
+
+		c.JSON(201, buildEntry(userID, stored, food, units.Resolve(c, pool)))
+	}
+}
+
+// replayEntry answers a request whose Idempotency-Key already created an entry: the same entry
+// (200, Idempotent-Replayed: true) when the request matches, 409 when the key was reused for a
+// different entry (a client bug, not a retry; don't pretend it succeeded).
+func replayEntry(c *gin.Context, pool *pgxpool.Pool, userID string, stored storedEntry, fingerprint string) {
+	// Entries from before fingerprints were stored can't be compared; they replay.
+	if stored.Fingerprint != nil && *stored.Fingerprint != fingerprint {
+		httpx.WriteError(c, 409, "IDEMPOTENCY_KEY_REUSED", "Idempotency-Key was already used for a different entry")
+		return
+	}
+
+	food, found, err := getFoodByIDFunc(c.Request.Context(), pool, stored.FoodItemID)
+	if err != nil || !found {
+		log.Printf("diary_entry_error request_id=%s user_id=%s entry_id=%s found=%t err=%v", c.GetHeader("X-Request-ID"), userID, stored.ID, found, err)
+		httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load food item")
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(200, buildEntry(userID, stored, food, units.Resolve(c, pool)))
+}
+
+// requestFingerprint identifies what a create request asked for, from the request fields
+// themselves (after validateRequest trims them): the food as given (barcode or id), day, meal,
+// amount as given (grams or servings) and notes.
+func requestFingerprint(req CreateEntryRequest) string {
+	amount := func(value *float64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+	fields := []string{req.Barcode, req.FoodItemID, req.Date, req.MealType, amount(req.QuantityG), amount(req.Servings), req.Notes}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateRequest checks the body shape and returns a message for the 400 (empty when valid).
+// Serving math happens once the food is known.
+func validateRequest(req *CreateEntryRequest) string {
+	req.Barcode = strings.TrimSpace(req.Barcode)
+	req.FoodItemID = strings.TrimSpace(req.FoodItemID)
+	req.MealType = strings.ToLower(strings.TrimSpace(req.MealType))
+	req.Notes = strings.TrimSpace(req.Notes)
+
+	if (req.Barcode == "") == (req.FoodItemID == "") {
+		return "Provide exactly one of barcode or food_item_id"
+	}
+	if !mealTypes[req.MealType] {
+		return "meal_type must be one of breakfast, lunch, dinner, snack, other"
+	}
+	if _, err := time.Parse(dateLayout, req.Date); err != nil {
+		return "date must be YYYY-MM-DD"
+	}
+	if (req.QuantityG == nil) == (req.Servings == nil) {
+		return "Provide exactly one of quantity_g or servings"
+	}
+	if req.QuantityG != nil && (*req.QuantityG <= 0 || *req.QuantityG > maxQuantityG) {
+		return fmt.Sprintf("quantity_g must be between 0 and %.0f", maxQuantityG)
+	}
+	if req.Servings != nil && (*req.Servings <= 0 || *req.Servings > maxServings) {
+		return fmt.Sprintf("servings must be between 0 and %.0f", maxServings)
+	}
+	if len(req.Notes) > maxNotesLen {
+		return fmt.Sprintf("notes must be at most %d characters", maxNotesLen)
+	}
+	return ""
+}
+
+// resolveFood finds the food_items row for the request and writes the error response when it can't.
+// Barcodes go through the shared barcode lookup first (cache -> OpenFoodFacts -> cache write),
+// so a first-time scan is cached before we point a diary entry at it.
+// scannedBarcode is the normalized barcode for barcode_scans ("" for food_item_id requests).
+func resolveFood(c *gin.Context, pool *pgxpool.Pool, resolver ProductResolver, req CreateEntryRequest) (barcode.FoodRow, string, bool) {
+	ctx := c.Request.Context()
+
+	if req.FoodItemID != "" {
+		food, found, err := getFoodByIDFunc(ctx, pool, req.FoodItemID)
+		if err != nil {
+			log.Printf("diary_entry_error request_id=%s err=%v", c.GetHeader("X-Request-ID"), err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load food item")
+			return barcode.FoodRow{}, "", false
+		}
+		if !found {
+			httpx.WriteError(c, 404, "NOT_FOUND", "Food item not found")
+			return barcode.FoodRow{}, "", false
+		}
+		return food, "", true
+	}
+
+	item, ok := resolver.Resolve(c, pool, req.Barcode)
+	if !ok {
+		return barcode.FoodRow{}, "", false
+	}
+
+	code := barcode.NormalizeBarcode(req.Barcode)
+	food, found, err := getFoodByBarcodeFunc(ctx, pool, code)
+	if err != nil {
+		log.Printf("diary_entry_error request_id=%s barcode=%s err=%v", c.GetHeader("X-Request-ID"), code, err)
+		httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load food item")
+		return barcode.FoodRow{}, "", false
+	}
+	if !found {
+		// The lookup succeeded but nothing was cached: invalid data is quarantined, not stored.
+		if item.DataQuality != nil && !item.DataQuality.Cacheable() {
+			httpx.WriteError(c, 422, "INVALID_PRODUCT_DATA", "Product nutrition data failed validation and can't be logged")
+			return barcode.FoodRow{}, "", false
+		}
+		httpx.WriteError(c, 503, "PRODUCT_NOT_CACHED", "Product could not be saved; try again")
+		return barcode.FoodRow{}, "", false
+	}
+	return food, code, true
+}
+
+// servingMath turns the requested amount into (quantity_g, servings) for diary_entries.
+// Examples with serving_size_g=40:
+//   - servings=1.5    -> 60 g, 1.5 servings
+//   - quantity_g=100  -> 100 g, 2.5 servings
+func servingMath(req CreateEntryRequest, servingSizeG float64) (float64, float64, string) {
+	if servingSizeG <= 0 {
+		servingSizeG = fallbackServingSizeG
+	}
+
+	if req.Servings != nil {
+		servings := roundTo(*req.Servings, 2)
+		quantityG := roundTo(servings*servingSizeG, 2)
+		if quantityG > maxQuantityG {
+			return 0, 0, fmt.Sprintf("servings is too large for this food (max %.0f g)", maxQuantityG)
+		}
+		return quantityG, servings, ""
+	}
+
+	quantityG := roundTo(*req.QuantityG, 2)
+	servings := roundTo(quantityG/servingSizeG, 2)
+	if servings > maxServings {
+		return 0, 0, fmt.Sprintf("quantity_g is too large for this food's serving size (max %.0f servings)", maxServings)
+	}
+	return quantityG, servings, ""
+}
+
+// buildEntry shapes the response; nutrients use the stored quantity (same math as the TS diary).
+func buildEntry(userID string, stored storedEntry, food barcode.FoodRow, system units.System) Entry {
+	factor := stored.QuantityG / 100
+
+	return Entry{
+		ID:        stored.ID,
+		UserID:    userID,
+		Date:      stored.Date,
+		MealType:  stored.MealType,
+		QuantityG: stored.QuantityG,
+		Servings:  stored.Servings,
+		Notes:     stored.Notes,
+		CreatedAt: stored.CreatedAt,
+		FoodItem: FoodSummary{
+			ID:              food.ID,
+			Name:            food.Name,
+			Brand:           food.Brand,
+			Barcode:         food.Barcode,
+			ServingSizeG:    food.ServingSizeG,
+			ServingSizeUnit: food.ServingSizeUnit,
+			Per100g:         food.Per100g,
+
+			ServingSizeDisplay: units.Amount(food.ServingSizeG, food.ServingSizeUnit, system),
+		},
+		Nutrients: barcode.FoodItemNutrients{
+			CaloriesKcal: math.Round(food.Per100g.CaloriesKcal * factor),
+			ProteinG:     roundTo(food.Per100g.ProteinG*factor, 1),
+			CarbsG:       roundTo(food.Per100g.CarbsG*factor, 1),
+			FatG:         roundTo(food.Per100g.FatG*factor, 1),
+			FiberG:       scaleOptional(food.Per100g.FiberG, factor, 1),
+			SugarG:       scaleOptional(food.Per100g.SugarG, factor, 1),
+			SodiumG:      scaleOptional(food.Per100g.SodiumG, factor, 3),
+		},
+		Units:           string(system),
+		QuantityDisplay: units.Amount(stored.QuantityG, food.ServingSizeUnit, system),
+	}
+}
+
+func scaleOptional(value *float64, factor float64, places int) *float64 {
+	if value == nil { // unknown stays unknown
+		return nil
+	}
+	scaled := roundTo(*value*factor, places)
+	return &scaled
+}
+
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
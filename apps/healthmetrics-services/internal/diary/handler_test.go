@@ -0,0 +1,326 @@
+package diary
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+	"healthmetrics-services/internal/httpx"
+	"healthmetrics-services/internal/units"
+)
+
+func ptr(value float64) *float64 {
+	return &value
+}
+
+var oats = barcode.FoodRow{
+	ID:           "food_oats",
+	Name:         "Rolled Oats",
+	Barcode:      "0072745068393",
+	ServingSizeG: 40,
+	Per100g:      barcode.FoodItemNutrients{CaloriesKcal: 379, ProteinG: 13, CarbsG: 68, FatG: 6.5, SodiumG: ptr(0.006)},
+}
+
+// fakeResolver stands in for barcode.Resolver (no OpenFoodFacts, no cache).
+type fakeResolver struct {
+	item  barcode.FoodItem
+	calls int
+}
+
+func (f *fakeResolver) Resolve(c *gin.Context, _ *pgxpool.Pool, code string) (barcode.FoodItem, bool) {
+	f.calls++
+	if code == "00000000" {
+		httpx.WriteError(c, 404, "NOT_FOUND", "Product not found")
+		return barcode.FoodItem{}, false
+	}
+	return f.item, true
+}
+
+// fakeDiary is an in-memory diary_entries + barcode_scans keyed like the unique index.
+type fakeDiary struct {
+	entries map[string]storedEntry // user_id + idempotency_key -> entry
+	scans   []newEntry
+}
+
+func setupStubs(t *testing.T, foods ...barcode.FoodRow) *fakeDiary {
+	origByID, origByBarcode, origCreate, origByKey := getFoodByIDFunc, getFoodByBarcodeFunc, createEntryFunc, getEntryByKeyFunc
+	t.Cleanup(func() {
+		getFoodByIDFunc, getFoodByBarcodeFunc, createEntryFunc, getEntryByKeyFunc = origByID, origByBarcode, origCreate, origByKey
+	})
+
+	getFoodByIDFunc = func(_ context.Context, _ *pgxpool.Pool, id string) (barcode.FoodRow, bool, error) {
+		for _, food := range foods {
+			if food.ID == id {
+				return food, true, nil
+			}
+		}
+		return barcode.FoodRow{}, false, nil
+	}
+	getFoodByBarcodeFunc = func(_ context.Context, _ *pgxpool.Pool, code string) (barcode.FoodRow, bool, error) {
+		for _, food := range foods {
+			if food.Barcode == code {
+				return food, true, nil
+			}
+		}
+		return barcode.FoodRow{}, false, nil
+	}
+
+	diary := &fakeDiary{entries: map[string]storedEntry{}}
+	createEntryFunc = func(_ context.Context, _ *pgxpool.Pool, entry newEntry) (storedEntry, bool, error) {
+		key := entry.UserID + "/" + entry.IdempotencyKey
+		if existing, ok := diary.entries[key]; ok {
+			return existing, false, nil
+		}
+		stored := storedEntry{
+			ID:         "entry_" + entry.IdempotencyKey,
+			FoodItemID: entry.FoodItemID,
+			Date:       entry.Date,
+			MealType:   entry.MealType,
+			QuantityG:  entry.QuantityG,
+			Servings:   entry.Servings,
+			Notes:      entry.Notes,
+			CreatedAt:  time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC),
+
+			Fingerprint: &entry.Fingerprint,
+		}
+		diary.entries[key] = stored
+		if entry.Barcode != "" {
+			diary.scans = append(diary.scans, entry)
+		}
+		return stored, true, nil
+	}
+	getEntryByKeyFunc = func(_ context.Context, _ *pgxpool.Pool, userID, key string) (storedEntry, bool, error) {
+		existing, ok := diary.entries[userID+"/"+key]
+		return existing, ok, nil
+	}
+	return diary
+}
+
+func postEntry(t *testing.T, resolver ProductResolver, userID string, key string, body any) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		if userID != "" {
+			c.Set("userID", userID)
+		}
+		c.Next()
+	})
+	router.POST("/v1/diary/entries", NewCreateEntryHandler(resolver))
+
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/v1/diary/entries", bytes.NewReader(payload))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	req.Header.Set("X-User-Units", "metric") // keeps units.Resolve off the zero-value pool
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCreateEntry_BarcodeServings(t *testing.T) {
+	diary := setupStubs(t, oats)
+	resolver := &fakeResolver{item: barcode.FoodItem{Barcode: oats.Barcode}}
+
+	rec := postEntry(t, resolver, "user_1", "key_1", CreateEntryRequest{
+		Barcode:  "072745068393", // UPC-A; stored scan uses the EAN-13 form
+		MealType: "Breakfast",
+		Date:     "2026-10-18",
+		Servings: ptr(1.5),
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	// 1.5 servings x 40 g = 60 g -> 379 * 0.6 = 227.4 kcal
+	if entry.QuantityG != 60 || entry.Servings != 1.5 || entry.MealType != "breakfast" {
+		t.Fatalf("unexpected serving math: %+v", entry)
+	}
+	if entry.Nutrients.CaloriesKcal != 227 || entry.Nutrients.ProteinG != 7.8 || *entry.Nutrients.SodiumG != 0.004 {
+		t.Fatalf("unexpected nutrients: %+v", entry.Nutrients)
+	}
+	if len(diary.scans) != 1 || diary.scans[0].Barcode != "0072745068393" {
+		t.Fatalf("expected one scan with normalized barcode, got %+v", diary.scans)
+	}
+	if resolver.calls != 1 {
+		t.Fatalf("expected barcode lookup, got %d calls", resolver.calls)
+	}
+}
+
+func TestCreateEntry_IdempotentReplay(t *testing.T) {
+	diary := setupStubs(t, oats)
+	resolver := &fakeResolver{item: barcode.FoodItem{Barcode: oats.Barcode}}
+	body := CreateEntryRequest{Barcode: oats.Barcode, MealType: "snack", Date: "2026-10-18", QuantityG: ptr(100)}
+
+	first := postEntry(t, resolver, "user_1", "key_1", body)
+	second := postEntry(t, resolver, "user_1", "key_1", body)
+
+	if first.Code != http.StatusCreated || second.Code != http.StatusOK {
+		t.Fatalf("expected 201 then 200, got %d then %d", first.Code, second.Code)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected Idempotent-Replayed header on replay")
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Fatalf("expected replay to return the same entry:\n%s\n%s", first.Body.String(), second.Body.String())
+	}
+	if len(diary.entries) != 1 || len(diary.scans) != 1 {
+		t.Fatalf("expected one entry and one scan, got %d entries, %d scans", len(diary.entries), len(diary.scans))
+	}
+
+	// The replay is answered from the stored entry, without resolving the barcode again.
+	if resolver.calls != 1 {
+		t.Fatalf("expected one barcode lookup, got %d", resolver.calls)
+	}
+
+	// Same key, different request: refuse instead of silently returning the old entry.
+	changed := map[string]CreateEntryRequest{
+		"amount":   {Barcode: oats.Barcode, MealType: "snack", Date: "2026-10-18", QuantityG: ptr(50)},
+		"servings": {Barcode: oats.Barcode, MealType: "snack", Date: "2026-10-18", Servings: ptr(2.5)}, // also 100 g
+		"meal":     {Barcode: oats.Barcode, MealType: "lunch", Date: "2026-10-18", QuantityG: ptr(100)},
+		"barcode":  {Barcode: "96385074", MealType: "snack", Date: "2026-10-18", QuantityG: ptr(100)},
+	}
+	for name, changedBody := range changed {
+		if rec := postEntry(t, resolver, "user_1", "key_1", changedBody); rec.Code != http.StatusConflict {
+			t.Fatalf("%s: expected 409 for reused key, got %d", name, rec.Code)
+		}
+	}
+	if resolver.calls != 1 {
+		t.Fatalf("expected reused keys to be refused before any lookup, got %d lookups", resolver.calls)
+	}
+}
+
+func TestCreateEntry_ReplayAfterServingSizeChange(t *testing.T) {
+	foods := []barcode.FoodRow{oats}
+	setupStubs(t, foods...) // the stubs read this slice, so edits below show up
+	resolver := &fakeResolver{item: barcode.FoodItem{Barcode: oats.Barcode}}
+	body := CreateEntryRequest{Barcode: oats.Barcode, MealType: "snack", Date: "2026-10-18", Servings: ptr(1)}
+
+	if rec := postEntry(t, resolver, "user_1", "key_1", body); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// An upstream refresh changes the serving size: 1 serving is no longer 40 g, but the retry
+	// is the same request and still replays.
+	foods[0].ServingSizeG = 30
+	rec := postEntry(t, resolver, "user_1", "key_1", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the retry to replay, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var entry Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if entry.QuantityG != 40 {
+		t.Fatalf("expected the stored 40 g, got %v", entry.QuantityG)
+	}
+}
+
+func TestCreateEntry_FoodItemIDQuantity(t *testing.T) {
+	diary := setupStubs(t, oats)
+	resolver := &fakeResolver{}
+
+	rec := postEntry(t, resolver, "user_1", "key_2", CreateEntryRequest{
+		FoodItemID: oats.ID,
+		MealType:   "lunch",
+		Date:       "2026-10-18",
+		QuantityG:  ptr(100),
+		Notes:      "with berries",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if entry.Servings != 2.5 || entry.Notes == nil || *entry.Notes != "with berries" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if resolver.calls != 0 || len(diary.scans) != 0 {
+		t.Fatalf("expected no barcode lookup or scan for food_item_id, got %d calls, %d scans", resolver.calls, len(diary.scans))
+	}
+}
+
+func TestCreateEntry_ProductNotCached(t *testing.T) {
+	setupStubs(t) // food_items is empty
+	invalid := &barcode.DataQuality{Score: 40, Status: barcode.QualityInvalid}
+	resolver := &fakeResolver{item: barcode.FoodItem{DataQuality: invalid}}
+
+	rec := postEntry(t, resolver, "user_1", "key_3", CreateEntryRequest{
+		Barcode: oats.Barcode, MealType: "dinner", Date: "2026-10-18", Servings: ptr(1),
+	})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for quarantined product, got %d", rec.Code)
+	}
+
+	rec = postEntry(t, resolver, "user_1", "key_4", CreateEntryRequest{
+		Barcode: "00000000", MealType: "dinner", Date: "2026-10-18", Servings: ptr(1),
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected resolver 404 to pass through, got %d", rec.Code)
+	}
+}
+
+func TestCreateEntry_InvalidRequests(t *testing.T) {
+	setupStubs(t, oats)
+	resolver := &fakeResolver{item: barcode.FoodItem{Barcode: oats.Barcode}}
+	valid := CreateEntryRequest{Barcode: oats.Barcode, MealType: "lunch", Date: "2026-10-18", Servings: ptr(1)}
+
+	if rec := postEntry(t, resolver, "", "key", valid); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without user, got %d", rec.Code)
+	}
+	if rec := postEntry(t, resolver, "user_1", "", valid); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without Idempotency-Key, got %d", rec.Code)
+	}
+
+	bodies := []CreateEntryRequest{
+		{MealType: "lunch", Date: "2026-10-18", Servings: ptr(1)},                                             // no food
+		{Barcode: oats.Barcode, FoodItemID: oats.ID, MealType: "lunch", Date: "2026-10-18", Servings: ptr(1)}, // both
+		{Barcode: oats.Barcode, MealType: "brunch", Date: "2026-10-18", Servings: ptr(1)},
+		{Barcode: oats.Barcode, MealType: "lunch", Date: "18/10/2026", Servings: ptr(1)},
+		{Barcode: oats.Barcode, MealType: "lunch", Date: "2026-10-18"},                                       // no amount
+		{Barcode: oats.Barcode, MealType: "lunch", Date: "2026-10-18", Servings: ptr(1), QuantityG: ptr(40)}, // both amounts
+		{Barcode: oats.Barcode, MealType: "lunch", Date: "2026-10-18", QuantityG: ptr(-5)},
+		{Barcode: oats.Barcode, MealType: "lunch", Date: "2026-10-18", Servings: ptr(200)},
+		{FoodItemID: oats.ID, MealType: "lunch", Date: "2026-10-18", QuantityG: ptr(4500)}, // 112.5 servings
+	}
+	for i, body := range bodies {
+		if rec := postEntry(t, resolver, "user_1", "key", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("case %d: expected 400, got %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestBuildEntry_Imperial(t *testing.T) {
+	stored := storedEntry{ID: "entry_1", Date: "2026-10-18", MealType: "breakfast", QuantityG: 60, Servings: 1.5}
+
+	entry := buildEntry("user_1", stored, oats, units.Imperial)
+	// 60 g = 2.116 oz, 40 g serving = 1.411 oz; nutrients stay in g
+	if entry.Units != "imperial" || entry.QuantityDisplay != (units.Quantity{Value: 2.1, Unit: "oz"}) {
+		t.Fatalf("unexpected quantity display: %s %+v", entry.Units, entry.QuantityDisplay)
+	}
+	if entry.FoodItem.ServingSizeDisplay != (units.Quantity{Value: 1.4, Unit: "oz"}) || entry.Nutrients.ProteinG != 7.8 {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	milk := oats
+	milk.ServingSizeG, milk.ServingSizeUnit = 250, "ml"
+	entry = buildEntry("user_1", storedEntry{QuantityG: 250}, milk, units.Imperial)
+	if entry.QuantityDisplay != (units.Quantity{Value: 8.5, Unit: "fl oz"}) {
+		t.Fatalf("expected fl oz for liquids, got %+v", entry.QuantityDisplay)
+	}
+}
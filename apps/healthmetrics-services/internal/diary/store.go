@@ -0,0 +1,268 @@
+package diary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// newEntry is one diary_entries row to write (plus the barcode_scans row when Barcode is set).
+type newEntry struct {
+	UserID         string
+	FoodItemID     string
+	Date           string // YYYY-MM-DD
+	MealType       string
+	QuantityG      float64
+	Servings       float64
+	Notes          *string
+	IdempotencyKey string
+	Fingerprint    string // requestFingerprint of the request that created it
+	Barcode        string // normalized barcode; "" = not a scan, no barcode_scans row
+}
+
+// storedEntry is a diary_entries row as written (or as found for a replayed key).
+type storedEntry struct {
+	ID         string
+	FoodItemID string
+	Date       string
+	MealType   string
+	QuantityG  float64
+	Servings   float64
+	Notes      *string
+	CreatedAt  time.Time
+
+	Fingerprint *string // requestFingerprint (NULL for entries created before it was stored)
+}
+
+// userTargets is the users row slice the summary needs (nil goal = not set, use defaults).
+type userTargets struct {
+	CaloriesKcal *int32
+	ProteinG     *int32
+	CarbsG       *int32
+	FatG         *int32
+	Timezone     string
+}
+
+// entryNutrients is one diary entry with the food's per-100g values as of when it was logged.
+type entryNutrients struct {
+	Date      string // YYYY-MM-DD
+	MealType  string
+	QuantityG float64
+	Per100g   barcode.FoodItemNutrients
+}
+
+// Allow tests to swap DB helpers without changing production logic.
+var (
+	getFoodByIDFunc      = barcode.GetFoodRowByID      // default: real DB fetch
+	getFoodByBarcodeFunc = barcode.GetFoodRowByBarcode // default: real DB fetch
+	createEntryFunc      = createEntry                 // default: real DB write (transaction)
+	getEntryByKeyFunc    = getEntryByKey               // default: real DB fetch
+
+	getUserTargetsFunc     = getUserTargets     // default: real DB fetch
+	listEntryNutrientsFunc = listEntryNutrients // default: real DB fetch
+)
+
+const entryColumns = `
+	id,
+	food_item_id,
+	to_char(date, 'YYYY-MM-DD'),
+	meal_type::text,
+	quantity_g::float8,
+	servings::float8,
+	notes,
+	created_at,
+	idempotency_fingerprint
+`
+
+func scanEntry(row pgx.Row) (storedEntry, error) {
+	var entry storedEntry
+	err := row.Scan(
+		&entry.ID,
+		&entry.FoodItemID,
+		&entry.Date,
+		&entry.MealType,
+		&entry.QuantityG,
+		&entry.Servings,
+		&entry.Notes,
+		&entry.CreatedAt,
+		&entry.Fingerprint,
+	)
+	return entry, err
+}
+
+// createEntry writes the diary entry and its barcode scan in one transaction.
+// Idempotent per (user_id, idempotency_key): a replayed key writes nothing and returns the
+// entry from the first call with created=false. Concurrent calls with the same key block on
+// the unique index, so only one of them inserts.
+func createEntry(ctx context.Context, pool *pgxpool.Pool, entry newEntry) (storedEntry, bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return storedEntry{}, false, fmt.Errorf("begin diary transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after Commit
+
+	// id/updated_at have Prisma-side defaults only, so set them here.
+	insertQuery := `
+		INSERT INTO diary_entries (
+			id,
+			user_id,
+			date,
+			food_item_id,
+			meal_type,
+			quantity_g,
+			servings,
+			notes,
+			idempotency_key,
+			idempotency_fingerprint,
+			updated_at
+		) VALUES (
+			gen_random_uuid()::text, $1, $2::date, $3, $4::"MealType", $5, $6, $7, $8, $9, now()
+		)
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING
+		RETURNING ` + entryColumns
+
+	stored, err := scanEntry(tx.QueryRow(
+		ctx,
+		insertQuery,
+		entry.UserID,
+		entry.Date,
+		entry.FoodItemID,
+		entry.MealType,
+		entry.QuantityG,
+		entry.Servings,
+		entry.Notes,
+		entry.IdempotencyKey,
+		entry.Fingerprint,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Key already used: return the original entry (and don't record a second scan).
+		selectQuery := `SELECT ` + entryColumns + ` FROM diary_entries WHERE user_id = $1 AND idempotency_key = $2`
+		existing, err := scanEntry(tx.QueryRow(ctx, selectQuery, entry.UserID, entry.IdempotencyKey))
+		if err != nil {
+			return storedEntry{}, false, fmt.Errorf("query diary_entries: %w", err)
+		}
+		return existing, false, nil
+	}
+	if err != nil {
+		return storedEntry{}, false, fmt.Errorf("insert diary_entries: %w", err)
+	}
+
+	if entry.Barcode != "" {
+		const scanQuery = `
+			INSERT INTO barcode_scans (id, user_id, barcode, food_item_id, scanned_at)
+			VALUES (gen_random_uuid()::text, $1, $2, $3, now())
+		`
+		if _, err := tx.Exec(ctx, scanQuery, entry.UserID, entry.Barcode, entry.FoodItemID); err != nil {
+			return storedEntry{}, false, fmt.Errorf("insert barcode_scans: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storedEntry{}, false, fmt.Errorf("commit diary transaction: %w", err)
+	}
+	return stored, true, nil
+}
+
+// getEntryByKey loads the entry a user created with this Idempotency-Key (found=false when unused).
+func getEntryByKey(ctx context.Context, pool *pgxpool.Pool, userID, idempotencyKey string) (storedEntry, bool, error) {
+	query := `SELECT ` + entryColumns + ` FROM diary_entries WHERE user_id = $1 AND idempotency_key = $2`
+	entry, err := scanEntry(pool.QueryRow(ctx, query, userID, idempotencyKey))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storedEntry{}, false, nil
+	}
+	if err != nil {
+		return storedEntry{}, false, fmt.Errorf("query diary_entries: %w", err)
+	}
+	return entry, true, nil
+}
+
+// getUserTargets loads the user's daily goals and timezone (zero value when the profile is missing).
+func getUserTargets(ctx context.Context, pool *pgxpool.Pool, userID string) (userTargets, error) {
+	const query = `
+		SELECT daily_calorie_goal, daily_protein_goal_g, daily_carb_goal_g, daily_fat_goal_g, timezone
+		FROM users
+		WHERE id = $1
+	`
+	var targets userTargets
+	err := pool.QueryRow(ctx, query, userID).Scan(
+		&targets.CaloriesKcal,
+		&targets.ProteinG,
+		&targets.CarbsG,
+		&targets.FatG,
+		&targets.Timezone,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return userTargets{}, nil
+		}
+		return userTargets{}, fmt.Errorf("query users: %w", err)
+	}
+	return targets, nil
+}
+
+// listEntryNutrients loads the user's diary entries in [from, to] with per-100g nutrients.
+// Nutrients are the food item as it was when the entry was logged (barcode.NutrientHistory),
+// loaded for all entries at once. Entries re-pointed by a food item merge read the item they
+// were logged against (original_food_item_id).
+func listEntryNutrients(ctx context.Context, pool *pgxpool.Pool, userID string, from string, to string) ([]entryNutrients, error) {
+	const query = `
+		SELECT
+			to_char(date, 'YYYY-MM-DD'),
+			meal_type::text,
+			quantity_g::float8,
+			COALESCE(original_food_item_id, food_item_id),
+			created_at
+		FROM diary_entries
+		WHERE user_id = $1 AND date BETWEEN $2::date AND $3::date
+		ORDER BY date ASC, created_at ASC
+	`
+
+	rows, err := pool.Query(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query diary_entries: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		entries     []entryNutrients
+		foodItemIDs []string
+		loggedAt    []time.Time
+	)
+	for rows.Next() {
+		var (
+			entry      entryNutrients
+			foodItemID string
+			createdAt  time.Time
+		)
+		if err := rows.Scan(&entry.Date, &entry.MealType, &entry.QuantityG, &foodItemID, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan diary_entries: %w", err)
+		}
+		entries = append(entries, entry)
+		foodItemIDs = append(foodItemIDs, foodItemID)
+		loggedAt = append(loggedAt, createdAt)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("iterate diary_entries: %w", rows.Err())
+	}
+
+	history, err := barcode.LoadNutrientHistory(ctx, pool, foodItemIDs)
+	if err != nil {
+		return nil, err
+	}
+	resolved := entries[:0]
+	for i, entry := range entries {
+		per100g, found := history.NutrientsAsOf(foodItemIDs[i], loggedAt[i])
+		if !found { // food item deleted since (diary rows cascade, so only mid-query)
+			continue
+		}
+		entry.Per100g = per100g
+		resolved = append(resolved, entry)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,488 @@
+package diary
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"healthmetrics-services/internal/barcode"
+	"healthmetrics-services/internal/httpx"
+)
+
+// Nutrient keys (same names as the FoodItemNutrients JSON fields).
+const (
+	nutrientCalories = "calories_kcal"
+	nutrientProtein  = "protein_g"
+	nutrientCarbs    = "carbs_g"
+	nutrientFat      = "fat_g"
+	nutrientFiber    = "fiber_g"
+	nutrientSugar    = "sugar_g"
+	nutrientSodium   = "sodium_g"
+)
+
+// Target kinds: which direction counts as a problem.
+const (
+	targetGoal    = "goal"    // aim for it: too little and too much are both flagged
+	targetMinimum = "minimum" // at least this much (protein, fiber)
+	targetLimit   = "limit"   // at most this much (sugar, sodium)
+)
+
+// Where a target value came from.
+const (
+	targetSourceUser    = "user"    // users.daily_*_goal
+	targetSourceDefault = "default" // daily value (scaled to the user's calorie goal when set)
+)
+
+// Gap finding kinds.
+const (
+	gapShortfall = "shortfall"
+	gapExcess    = "excess"
+)
+
+// Summary range + gap thresholds.
+const (
+	defaultSummaryDays = 7  // no range given -> the last week (ending today in the user's timezone)
+	maxSummaryDays     = 92 // about a quarter; keeps the query and response bounded
+	minGapDays         = 3  // logged days needed before anything counts as "consistent"
+	gapDayShare        = 0.6
+	defaultCaloriesDV  = 2000.0 // FDA daily values assume a 2,000 kcal diet
+)
+
+// nutrientRule describes how one nutrient is judged.
+// Low/High are shares of the target: a goal of 2000 kcal with Low=0.9, High=1.1 flags days
+// under 1800 or over 2200. 0 means "no bound on that side".
+type nutrientRule struct {
+	Nutrient           string
+	Unit               string
+	DefaultValue       float64 // FDA daily value for a 2,000 kcal diet
+	Kind               string
+	Low                float64
+	High               float64
+	ScalesWithCalories bool // default follows the user's calorie goal (e.g. fiber 14 g per 1,000 kcal)
+}
+
+// nutrientRules lists the nutrients food_items tracks, in response order.
+// Sugar uses the added-sugars daily value; food_items only has total sugars, so it is a conservative limit.
+var nutrientRules = []nutrientRule{
+	{Nutrient: nutrientCalories, Unit: "kcal", DefaultValue: defaultCaloriesDV, Kind: targetGoal, Low: 0.9, High: 1.1},
+	{Nutrient: nutrientProtein, Unit: "g", DefaultValue: 50, Kind: targetMinimum, Low: 0.8, ScalesWithCalories: true},
+	{Nutrient: nutrientCarbs, Unit: "g", DefaultValue: 275, Kind: targetGoal, Low: 0.8, High: 1.2, ScalesWithCalories: true},
+	{Nutrient: nutrientFat, Unit: "g", DefaultValue: 78, Kind: targetGoal, Low: 0.8, High: 1.2, ScalesWithCalories: true},
+	{Nutrient: nutrientFiber, Unit: "g", DefaultValue: 28, Kind: targetMinimum, Low: 0.8, ScalesWithCalories: true},
+	{Nutrient: nutrientSugar, Unit: "g", DefaultValue: 50, Kind: targetLimit, High: 1.0, ScalesWithCalories: true},
+	{Nutrient: nutrientSodium, Unit: "g", DefaultValue: 2.3, Kind: targetLimit, High: 1.0},
+}
+
+// Target is the daily target used for one nutrient.
+type Target struct {
+	Nutrient string  `json:"nutrient"`
+	Value    float64 `json:"value"`
+	Unit     string  `json:"unit"`
+	Kind     string  `json:"kind"`   // goal / minimum / limit
+	Source   string  `json:"source"` // user / default
+}
+
+// DaySummary is one calendar day of the diary.
+type DaySummary struct {
+	Date            string                               `json:"date"`
+	EntryCount      int                                  `json:"entry_count"` // 0 = nothing logged (excluded from gaps)
+	Totals          barcode.FoodItemNutrients            `json:"totals"`
+	ByMeal          map[string]barcode.FoodItemNutrients `json:"by_meal"`
+	PercentOfTarget map[string]float64                   `json:"percent_of_target,omitempty"`
+	// Micronutrients some entries had no data for: the total is a lower bound.
+	IncompleteNutrients []string `json:"incomplete_nutrients,omitempty"`
+}
+
+// WeekSummary aggregates the range's days by ISO week (Monday start; edge weeks may be partial).
+type WeekSummary struct {
+	WeekStart    string                     `json:"week_start"`
+	DaysLogged   int                        `json:"days_logged"`
+	Totals       barcode.FoodItemNutrients  `json:"totals"`
+	DailyAverage *barcode.FoodItemNutrients `json:"daily_average"` // per logged day; nil when nothing was logged
+}
+
+// Gap is a consistent shortfall or excess over the range.
+// Example: fiber below 80% of target on 5 of 6 logged days.
+type Gap struct {
+	Nutrient        string  `json:"nutrient"`
+	Kind            string  `json:"kind"` // shortfall / excess
+	Target          float64 `json:"target"`
+	DailyAverage    float64 `json:"daily_average"`
+	PercentOfTarget float64 `json:"percent_of_target"`
+	DaysFlagged     int     `json:"days_flagged"`
+	DaysConsidered  int     `json:"days_considered"`
+	Message         string  `json:"message"`
+}
+
+// Summary is the GET /v1/diary/summary response.
+type Summary struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Targets []Target      `json:"targets"`
+	Days    []DaySummary  `json:"days"`
+	Weeks   []WeekSummary `json:"weeks"`
+	Gaps    []Gap         `json:"gaps"`
+}
+
+// NewSummaryHandler handles GET /v1/diary/summary?from=YYYY-MM-DD&to=YYYY-MM-DD.
+// Totals calories, macros and the micronutrients food_items tracks (fiber, sugar, sodium)
+// by day and meal type, compares them with the user's targets (or daily values), and lists
+// consistent gaps over the range. Without from/to it covers the last 7 days.
+func NewSummaryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+
+		userID := c.GetString("userID") // set by auth middleware after session validation
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		profile, err := getUserTargetsFunc(c.Request.Context(), pool, userID)
+		if err != nil {
+			log.Printf("diary_summary_error request_id=%s user_id=%s err=%v", requestID, userID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load user targets")
+			return
+		}
+
+		from, to, message := summaryRange(c.Query("from"), c.Query("to"), todayIn(profile.Timezone, time.Now()))
+		if message != "" {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", message)
+			return
+		}
+
+		entries, err := listEntryNutrientsFunc(c.Request.Context(), pool, userID, from.Format(dateLayout), to.Format(dateLayout))
+		if err != nil {
+			log.Printf("diary_summary_error request_id=%s user_id=%s err=%v", requestID, userID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load diary entries")
+			return
+		}
+
+		c.JSON(200, buildSummary(from, to, resolveTargets(profile), entries))
+	}
+}
+
+const dateLayout = "2006-01-02"
+
+// todayIn is the current date in the user's timezone (UTC when unknown).
+func todayIn(timezone string, now time.Time) time.Time {
+	location, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		location = time.UTC
+	}
+	year, month, day := now.In(location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// summaryRange parses from/to (dates only, as UTC midnights) and returns a 400 message when invalid.
+// Missing values: to = today, from = to - 6 days.
+func summaryRange(rawFrom, rawTo string, today time.Time) (time.Time, time.Time, string) {
+	to := today
+	if rawTo != "" {
+		parsed, err := time.Parse(dateLayout, rawTo)
+		if err != nil {
+			return time.Time{}, time.Time{}, "to must be YYYY-MM-DD"
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -(defaultSummaryDays - 1))
+	if rawFrom != "" {
+		parsed, err := time.Parse(dateLayout, rawFrom)
+		if err != nil {
+			return time.Time{}, time.Time{}, "from must be YYYY-MM-DD"
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, "from must not be after to"
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxSummaryDays {
+		return time.Time{}, time.Time{}, fmt.Sprintf("Range is limited to %d days", maxSummaryDays)
+	}
+	return from, to, ""
+}
+
+// resolveTargets picks each nutrient's target: the user's goal when set, else the daily value.
+// Defaults that scale with calories follow the user's calorie goal
+// (a 1,600 kcal goal -> fiber 22.4 g instead of 28 g).
+func resolveTargets(profile userTargets) []Target {
+	userGoals := map[string]*int32{
+		nutrientCalories: profile.CaloriesKcal,
+		nutrientProtein:  profile.ProteinG,
+		nutrientCarbs:    profile.CarbsG,
+		nutrientFat:      profile.FatG,
+	}
+
+	scale := 1.0
+	if goal := profile.CaloriesKcal; goal != nil && *goal > 0 {
+		scale = float64(*goal) / defaultCaloriesDV
+	}
+
+	targets := make([]Target, 0, len(nutrientRules))
+	for _, rule := range nutrientRules {
+		target := Target{Nutrient: rule.Nutrient, Unit: rule.Unit, Kind: rule.Kind, Source: targetSourceDefault}
+		if goal := userGoals[rule.Nutrient]; goal != nil && *goal > 0 {
+			target.Value = float64(*goal)
+			target.Source = targetSourceUser
+		} else if rule.ScalesWithCalories {
+			target.Value = roundTo(rule.DefaultValue*scale, 1)
+		} else {
+			target.Value = rule.DefaultValue
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// buildSummary totals entries per day/meal/week and runs the gap analysis.
+func buildSummary(from, to time.Time, targets []Target, entries []entryNutrients) Summary {
+	summary := Summary{
+		From:    from.Format(dateLayout),
+		To:      to.Format(dateLayout),
+		Targets: targets,
+		Days:    []DaySummary{},
+		Weeks:   []WeekSummary{},
+		Gaps:    []Gap{},
+	}
+
+	// One row per calendar day (unlogged days included so charts have no holes).
+	dayIndex := map[string]int{}
+	incomplete := map[string]map[string]bool{} // date -> micronutrient -> some entry had no value
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		dayIndex[date] = len(summary.Days)
+		incomplete[date] = map[string]bool{}
+		summary.Days = append(summary.Days, DaySummary{Date: date, ByMeal: map[string]barcode.FoodItemNutrients{}})
+	}
+
+	for _, entry := range entries {
+		i, ok := dayIndex[entry.Date]
+		if !ok { // outside the range (shouldn't happen; the query filters by date)
+			continue
+		}
+		day := &summary.Days[i]
+		factor := entry.QuantityG / 100
+
+		day.EntryCount++
+		addScaled(&day.Totals, entry.Per100g, factor)
+		meal := day.ByMeal[entry.MealType]
+		addScaled(&meal, entry.Per100g, factor)
+		day.ByMeal[entry.MealType] = meal
+
+		for _, nutrient := range []string{nutrientFiber, nutrientSugar, nutrientSodium} {
+			if nutrientValue(entry.Per100g, nutrient) == nil {
+				incomplete[entry.Date][nutrient] = true
+			}
+		}
+	}
+
+	for i := range summary.Days {
+		day := &summary.Days[i]
+		day.Totals = roundNutrients(day.Totals)
+		for meal, totals := range day.ByMeal {
+			day.ByMeal[meal] = roundNutrients(totals)
+		}
+		if day.EntryCount == 0 {
+			continue
+		}
+		for _, rule := range nutrientRules { // rule order keeps the list stable
+			if incomplete[day.Date][rule.Nutrient] {
+				day.IncompleteNutrients = append(day.IncompleteNutrients, rule.Nutrient)
+			}
+		}
+		day.PercentOfTarget = map[string]float64{}
+		for _, target := range targets {
+			if value := nutrientValue(day.Totals, target.Nutrient); value != nil && target.Value > 0 {
+				day.PercentOfTarget[target.Nutrient] = roundTo(*value/target.Value*100, 1)
+			}
+		}
+	}
+
+	summary.Weeks = buildWeeks(summary.Days)
+	summary.Gaps = findGaps(summary.Days, targets)
+	return summary
+}
+
+// buildWeeks groups days by ISO week (weeks start on Monday).
+func buildWeeks(days []DaySummary) []WeekSummary {
+	weeks := []WeekSummary{}
+	for _, day := range days {
+		date, _ := time.Parse(dateLayout, day.Date)
+		offset := (int(date.Weekday()) + 6) % 7 // Monday=0 ... Sunday=6
+		weekStart := date.AddDate(0, 0, -offset).Format(dateLayout)
+
+		if len(weeks) == 0 || weeks[len(weeks)-1].WeekStart != weekStart {
+			weeks = append(weeks, WeekSummary{WeekStart: weekStart})
+		}
+		week := &weeks[len(weeks)-1]
+		if day.EntryCount == 0 {
+			continue
+		}
+		week.DaysLogged++
+		addScaled(&week.Totals, day.Totals, 1)
+	}
+
+	for i := range weeks {
+		week := &weeks[i]
+		week.Totals = roundNutrients(week.Totals)
+		if week.DaysLogged == 0 {
+			continue
+		}
+		average := barcode.FoodItemNutrients{}
+		addScaled(&average, week.Totals, 1/float64(week.DaysLogged))
+		average = roundNutrients(average)
+		week.DailyAverage = &average
+	}
+	return weeks
+}
+
+// findGaps reports nutrients that miss their target on most logged days.
+// A day counts as flagged when it falls outside the rule's band; a gap is reported when at
+// least 60% of the considered days (min 3) are flagged the same way. Days where a
+// micronutrient total is incomplete can't prove a shortfall, so they're skipped for shortfalls.
+func findGaps(days []DaySummary, targets []Target) []Gap {
+	rules := map[string]nutrientRule{}
+	for _, rule := range nutrientRules {
+		rules[rule.Nutrient] = rule
+	}
+
+	gaps := []Gap{}
+	for _, target := range targets {
+		rule := rules[target.Nutrient]
+		if target.Value <= 0 {
+			continue
+		}
+
+		for _, kind := range []string{gapShortfall, gapExcess} {
+			bound := rule.Low
+			if kind == gapExcess {
+				bound = rule.High
+			}
+			if bound == 0 { // the rule doesn't care about this direction
+				continue
+			}
+
+			considered, flagged := 0, 0
+			sum := 0.0
+			for _, day := range days {
+				if day.EntryCount == 0 {
+					continue
+				}
+				value := nutrientValue(day.Totals, target.Nutrient)
+				if value == nil {
+					continue
+				}
+				if kind == gapShortfall && slices.Contains(day.IncompleteNutrients, target.Nutrient) {
+					continue
+				}
+				considered++
+				sum += *value
+				ratio := *value / target.Value
+				if (kind == gapShortfall && ratio < bound) || (kind == gapExcess && ratio > bound) {
+					flagged++
+				}
+			}
+
+			if considered < minGapDays || float64(flagged) < gapDayShare*float64(considered) {
+				continue
+			}
+			average := sum / float64(considered)
+			gaps = append(gaps, Gap{
+				Nutrient:        target.Nutrient,
+				Kind:            kind,
+				Target:          target.Value,
+				DailyAverage:    roundTo(average, 1),
+				PercentOfTarget: roundTo(average/target.Value*100, 1),
+				DaysFlagged:     flagged,
+				DaysConsidered:  considered,
+				Message:         gapMessage(target, kind, bound, flagged, considered, average),
+			})
+		}
+	}
+	return gaps
+}
+
+// gapMessage renders a gap for display.
+// Example: "fiber_g was below 80% of the daily value (28 g) on 5 of 6 days (average 17.2 g)"
+func gapMessage(target Target, kind string, bound float64, flagged, considered int, average float64) string {
+	direction := "below"
+	if kind == gapExcess {
+		direction = "above"
+	}
+	reference := "your target"
+	if target.Source == targetSourceDefault {
+		reference = "the daily value"
+	}
+	limit := fmt.Sprintf("%.0f%% of %s", bound*100, reference)
+	if bound == 1 {
+		limit = reference
+	}
+	return fmt.Sprintf("%s was %s %s (%g %s) on %d of %d days (average %g %s)",
+		target.Nutrient, direction, limit, target.Value, target.Unit, flagged, considered, roundTo(average, 1), target.Unit)
+}
+
+// nutrientValue reads one nutrient by key (nil when unknown).
+func nutrientValue(n barcode.FoodItemNutrients, nutrient string) *float64 {
+	switch nutrient {
+	case nutrientCalories:
+		return &n.CaloriesKcal
+	case nutrientProtein:
+		return &n.ProteinG
+	case nutrientCarbs:
+		return &n.CarbsG
+	case nutrientFat:
+		return &n.FatG
+	case nutrientFiber:
+		return n.FiberG
+	case nutrientSugar:
+		return n.SugarG
+	case nutrientSodium:
+		return n.SodiumG
+	}
+	return nil
+}
+
+// addScaled adds value*factor into total. Unknown micronutrients don't make a known total unknown.
+func addScaled(total *barcode.FoodItemNutrients, value barcode.FoodItemNutrients, factor float64) {
+	total.CaloriesKcal += value.CaloriesKcal * factor
+	total.ProteinG += value.ProteinG * factor
+	total.CarbsG += value.CarbsG * factor
+	total.FatG += value.FatG * factor
+	total.FiberG = addOptional(total.FiberG, value.FiberG, factor)
+	total.SugarG = addOptional(total.SugarG, value.SugarG, factor)
+	total.SodiumG = addOptional(total.SodiumG, value.SodiumG, factor)
+}
+
+func addOptional(total *float64, value *float64, factor float64) *float64 {
+	if value == nil {
+		return total
+	}
+	sum := *value * factor
+	if total != nil {
+		sum += *total
+	}
+	return &sum
+}
+
+// roundNutrients rounds totals for the response (sodium keeps mg precision).
+func roundNutrients(n barcode.FoodItemNutrients) barcode.FoodItemNutrients {
+	return barcode.FoodItemNutrients{
+		CaloriesKcal: roundTo(n.CaloriesKcal, 1),
+		ProteinG:     roundTo(n.ProteinG, 1),
+		CarbsG:       roundTo(n.CarbsG, 1),
+		FatG:         roundTo(n.FatG, 1),
+		FiberG:       scaleOptional(n.FiberG, 1, 1),
+		SugarG:       scaleOptional(n.SugarG, 1, 1),
+		SodiumG:      scaleOptional(n.SodiumG, 1, 3),
+	}
+}
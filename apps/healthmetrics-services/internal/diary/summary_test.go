@@ -0,0 +1,192 @@
+package diary
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+func int32Ptr(value int32) *int32 {
+	return &value
+}
+
+// Per-100g foods for the summary tests.
+var (
+	bread = barcode.FoodItemNutrients{CaloriesKcal: 250, ProteinG: 9, CarbsG: 49, FatG: 3, FiberG: ptr(2), SugarG: ptr(5), SodiumG: ptr(0.5)}
+	candy = barcode.FoodItemNutrients{CaloriesKcal: 400, ProteinG: 0, CarbsG: 100, FatG: 0, SugarG: ptr(90)} // no fiber/sodium data
+)
+
+func TestSummaryRange(t *testing.T) {
+	today := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	from, to, message := summaryRange("", "", today)
+	if message != "" || from.Format(dateLayout) != "2026-10-12" || to.Format(dateLayout) != "2026-10-18" {
+		t.Fatalf("expected last 7 days, got %s..%s (%q)", from.Format(dateLayout), to.Format(dateLayout), message)
+	}
+
+	for _, tc := range [][2]string{
+		{"2026-10-19", "2026-10-18"}, // from after to
+		{"2026-01-01", "2026-10-18"}, // too long
+		{"10/01/2026", ""},
+		{"", "yesterday"},
+	} {
+		if _, _, message := summaryRange(tc[0], tc[1], today); message == "" {
+			t.Fatalf("expected %v to be rejected", tc)
+		}
+	}
+}
+
+func TestTodayIn(t *testing.T) {
+	now := time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC) // still Oct 17 in Los Angeles
+	if got := todayIn("America/Los_Angeles", now).Format(dateLayout); got != "2026-10-17" {
+		t.Fatalf("expected 2026-10-17, got %s", got)
+	}
+	if got := todayIn("Not/AZone", now).Format(dateLayout); got != "2026-10-18" {
+		t.Fatalf("expected UTC fallback, got %s", got)
+	}
+}
+
+func TestResolveTargets(t *testing.T) {
+	targets := resolveTargets(userTargets{CaloriesKcal: int32Ptr(1600), ProteinG: int32Ptr(120)})
+
+	byNutrient := map[string]Target{}
+	for _, target := range targets {
+		byNutrient[target.Nutrient] = target
+	}
+	if target := byNutrient[nutrientCalories]; target.Value != 1600 || target.Source != targetSourceUser {
+		t.Fatalf("unexpected calorie target: %+v", target)
+	}
+	if target := byNutrient[nutrientProtein]; target.Value != 120 || target.Source != targetSourceUser {
+		t.Fatalf("unexpected protein target: %+v", target)
+	}
+	// Defaults follow the 1600 kcal goal (x0.8); sodium doesn't scale.
+	if target := byNutrient[nutrientFiber]; target.Value != 22.4 || target.Source != targetSourceDefault {
+		t.Fatalf("unexpected fiber target: %+v", target)
+	}
+	if target := byNutrient[nutrientSodium]; target.Value != 2.3 {
+		t.Fatalf("unexpected sodium target: %+v", target)
+	}
+}
+
+func TestBuildSummary_TotalsAndGaps(t *testing.T) {
+	from := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC) // Monday
+	to := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)   // next Monday
+	targets := resolveTargets(userTargets{})
+
+	// Oct 12-15: 400 g bread at breakfast (1000 kcal, 8 g fiber) -> low calories + low fiber every day.
+	// Oct 13 also has candy (no fiber data) at snack; that day can't prove a fiber shortfall.
+	var entries []entryNutrients
+	for _, date := range []string{"2026-10-12", "2026-10-13", "2026-10-14", "2026-10-15"} {
+		entries = append(entries, entryNutrients{Date: date, MealType: "breakfast", QuantityG: 400, Per100g: bread})
+	}
+	entries = append(entries, entryNutrients{Date: "2026-10-13", MealType: "snack", QuantityG: 50, Per100g: candy})
+
+	summary := buildSummary(from, to, targets, entries)
+
+	if len(summary.Days) != 8 || summary.Days[6].EntryCount != 0 {
+		t.Fatalf("expected 8 days with unlogged ones included, got %d", len(summary.Days))
+	}
+	day := summary.Days[1] // Oct 13
+	if day.Totals.CaloriesKcal != 1200 || *day.Totals.SugarG != 65 || *day.Totals.FiberG != 8 {
+		t.Fatalf("unexpected Oct 13 totals: %+v", day.Totals)
+	}
+	if snack := day.ByMeal["snack"]; snack.CaloriesKcal != 200 || snack.FiberG != nil {
+		t.Fatalf("unexpected snack totals: %+v", snack)
+	}
+	if len(day.IncompleteNutrients) != 2 || day.IncompleteNutrients[0] != nutrientFiber {
+		t.Fatalf("expected fiber+sodium incomplete, got %v", day.IncompleteNutrients)
+	}
+	if day.PercentOfTarget[nutrientCalories] != 60 {
+		t.Fatalf("expected 60%% of calories, got %v", day.PercentOfTarget[nutrientCalories])
+	}
+
+	if len(summary.Weeks) != 2 || summary.Weeks[0].WeekStart != "2026-10-12" || summary.Weeks[0].DaysLogged != 4 {
+		t.Fatalf("unexpected weeks: %+v", summary.Weeks)
+	}
+	if summary.Weeks[0].DailyAverage.CaloriesKcal != 1050 || summary.Weeks[1].DailyAverage != nil {
+		t.Fatalf("unexpected weekly averages: %+v / %+v", summary.Weeks[0].DailyAverage, summary.Weeks[1].DailyAverage)
+	}
+
+	gaps := map[string]Gap{}
+	for _, gap := range summary.Gaps {
+		gaps[gap.Nutrient+"/"+gap.Kind] = gap
+	}
+	if gap, ok := gaps["calories_kcal/shortfall"]; !ok || gap.DaysFlagged != 4 || gap.DaysConsidered != 4 {
+		t.Fatalf("expected calorie shortfall on 4 of 4 days, got %+v", gap)
+	}
+	if gap, ok := gaps["fiber_g/shortfall"]; !ok || gap.DaysConsidered != 3 || gap.DailyAverage != 8 {
+		t.Fatalf("expected fiber shortfall over 3 complete days, got %+v", gap)
+	}
+	if _, ok := gaps["sugar_g/excess"]; ok {
+		t.Fatalf("sugar was over the limit on one day only; not a consistent gap")
+	}
+	if _, ok := gaps["protein_g/excess"]; ok {
+		t.Fatalf("protein is a minimum; excess must not be reported")
+	}
+}
+
+func TestBuildSummary_TooFewDaysForGaps(t *testing.T) {
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	entries := []entryNutrients{{Date: "2026-10-18", MealType: "lunch", QuantityG: 100, Per100g: bread}}
+
+	summary := buildSummary(day, day, resolveTargets(userTargets{}), entries)
+	if len(summary.Gaps) != 0 {
+		t.Fatalf("expected no gaps from a single day, got %+v", summary.Gaps)
+	}
+}
+
+func TestSummaryHandler(t *testing.T) {
+	origTargets, origEntries := getUserTargetsFunc, listEntryNutrientsFunc
+	t.Cleanup(func() {
+		getUserTargetsFunc, listEntryNutrientsFunc = origTargets, origEntries
+	})
+
+	getUserTargetsFunc = func(context.Context, *pgxpool.Pool, string) (userTargets, error) {
+		return userTargets{CaloriesKcal: int32Ptr(2200), Timezone: "UTC"}, nil
+	}
+	var gotFrom, gotTo string
+	listEntryNutrientsFunc = func(_ context.Context, _ *pgxpool.Pool, _ string, from string, to string) ([]entryNutrients, error) {
+		gotFrom, gotTo = from, to
+		return []entryNutrients{{Date: "2026-10-02", MealType: "dinner", QuantityG: 200, Per100g: bread}}, nil
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		c.Set("userID", "user_1")
+		c.Next()
+	})
+	router.GET("/v1/diary/summary", NewSummaryHandler())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/diary/summary?from=2026-10-01&to=2026-10-03", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if gotFrom != "2026-10-01" || gotTo != "2026-10-03" {
+		t.Fatalf("unexpected query range %s..%s", gotFrom, gotTo)
+	}
+
+	var summary Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(summary.Days) != 3 || summary.Days[1].Totals.CaloriesKcal != 500 || summary.Targets[0].Value != 2200 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/diary/summary?from=2026-10-05&to=2026-10-01", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for reversed range, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,33 @@
+// Package httpx holds the Gin helpers every JSON API package shares: the error envelope and
+// the DB pool that main.go puts on the request context.
+package httpx
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WriteError writes the API error envelope.
+// Example: {"error": {"code": "NOT_FOUND", "message": "Product not found"}}
+func WriteError(c *gin.Context, status int, code string, message string) {
+	c.JSON(status, gin.H{"error": map[string]interface{}{ // wrap error in a predictable envelope
+		"code":    code,    // short error code for programmatic checks
+		"message": message, // human-readable message for debugging/UI
+	}})
+}
+
+// PoolFromContext pulls the DB pool out of Gin context (set in main.go) and writes a 500 if missing.
+func PoolFromContext(c *gin.Context) (*pgxpool.Pool, bool) {
+	poolValue, ok := c.Get("db")
+	if !ok {
+		WriteError(c, 500, "INTERNAL_ERROR", "Database not configured")
+		return nil, false
+	}
+
+	pool, ok := poolValue.(*pgxpool.Pool)
+	if !ok || pool == nil {
+		WriteError(c, 500, "INTERNAL_ERROR", "Invalid database handle")
+		return nil, false
+	}
+	return pool, true
+}
@@ -0,0 +1,45 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPoolFromContext_MissingPoolWritesEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	if pool, ok := PoolFromContext(c); ok || pool != nil {
+		t.Fatalf("expected no pool, got %v", pool)
+	}
+	if rec.Code != 500 {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.Error.Code != "INTERNAL_ERROR" || body.Error.Message != "Database not configured" {
+		t.Fatalf("unexpected envelope %s", rec.Body.String())
+	}
+}
+
+func TestPoolFromContext_WrongType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Set("db", "not a pool")
+
+	if _, ok := PoolFromContext(c); ok || rec.Code != 500 {
+		t.Fatalf("expected a 500 for a bad handle, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package label
+
+import (
+	"fmt"
+
+	"healthmetrics-services/internal/barcode"
+	"healthmetrics-services/internal/units"
+)
+
+// EU reference intakes for an average adult (Regulation (EU) 1169/2011, Annex XIII Part B).
+const (
+	riEnergyKJ   = 8400.0
+	riEnergyKcal = 2000.0
+	riFatG       = 70.0
+	riCarbG      = 260.0
+	riSugarsG    = 90.0
+	riProteinG   = 50.0
+	riSaltG      = 6.0
+)
+
+// saltPerSodium converts sodium to the declared salt (Annex I: salt = sodium x 2.5).
+const saltPerSodium = 2.5
+
+// buildEU lays out the EU nutrition declaration: per 100 g (or 100 ml) always, per portion and
+// %RI when the portion is known. Rounding follows the Commission guidance on tolerances (2012).
+// Saturates are mandatory but not tracked in food_items; fibre is voluntary and only shown when known.
+func buildEU(item barcode.FoodItem, portion serving) Panel {
+	basis := "g"
+	if portion.Known && portion.Unit == "ml" {
+		basis = "ml"
+	}
+
+	panel := Panel{
+		Style:   StyleEU,
+		Title:   "Nutrition declaration",
+		Product: productName(item),
+		Columns: []string{fmt.Sprintf("Per 100 %s", basis)},
+		Missing: []string{"Saturates"},
+	}
+	if portion.Known {
+		panel.Serving = fmt.Sprintf("%s %s", formatNumber(portion.Amount, 1), portion.Unit)
+		panel.Columns = append(panel.Columns, fmt.Sprintf("Per portion (%s)", panel.Serving), "%RI*")
+		panel.Footnotes = []string{"* Reference intake of an average adult (8400 kJ / 2000 kcal)"}
+	}
+
+	per100 := item.Nutrients
+	factor := portion.Amount / 100
+
+	// columns builds one row's values: per 100, per portion, %RI of the portion (ri 0 = none).
+	columns := func(perHundred float64, format func(float64) string, ri float64) []string {
+		values := []string{format(perHundred)}
+		if portion.Known {
+			values = append(values, format(perHundred*factor), "")
+			if ri > 0 {
+				values[2] = percent(perHundred*factor, ri)
+			}
+		}
+		return values
+	}
+	missing := func() []string {
+		values := []string{missingValue}
+		if portion.Known {
+			values = append(values, missingValue, "")
+		}
+		return values
+	}
+
+	kcal := per100.CaloriesKcal
+	kj := kcal * units.KilojoulesPerKcal
+	panel.Rows = append(panel.Rows,
+		Row{Name: "Energy", Values: columns(kj, euEnergy("kJ"), riEnergyKJ), Bold: true},
+		Row{Name: "", Values: columns(kcal, euEnergy("kcal"), riEnergyKcal), Bold: true},
+		Row{Name: "Fat", Values: columns(per100.FatG, euGrams, riFatG), Bold: true},
+		Row{Name: "of which saturates", Values: missing(), Indent: 1},
+		Row{Name: "Carbohydrate", Values: columns(per100.CarbsG, euGrams, riCarbG), Bold: true},
+	)
+
+	if per100.SugarG != nil {
+		panel.Rows = append(panel.Rows, Row{Name: "of which sugars", Values: columns(*per100.SugarG, euGrams, riSugarsG), Indent: 1})
+	} else {
+		panel.Rows = append(panel.Rows, Row{Name: "of which sugars", Values: missing(), Indent: 1})
+		panel.Missing = append(panel.Missing, "Sugars")
+	}
+	if per100.FiberG != nil {
+		panel.Rows = append(panel.Rows, Row{Name: "Fibre", Values: columns(*per100.FiberG, euGrams, 0), Bold: true})
+	}
+	panel.Rows = append(panel.Rows, Row{Name: "Protein", Values: columns(per100.ProteinG, euGrams, riProteinG), Bold: true})
+
+	if per100.SodiumG != nil {
+		panel.Rows = append(panel.Rows, Row{Name: "Salt", Values: columns(*per100.SodiumG*saltPerSodium, euSalt, riSaltG), Bold: true})
+	} else {
+		panel.Rows = append(panel.Rows, Row{Name: "Salt", Values: missing(), Bold: true})
+		panel.Missing = append(panel.Missing, "Salt")
+	}
+	return panel
+}
+
+// euEnergy: nearest 1 kJ / kcal.
+func euEnergy(unit string) func(float64) string {
+	return func(value float64) string {
+		return fmt.Sprintf("%s %s", formatNumber(roundTo(value, 1), 0), unit)
+	}
+}
+
+// euGrams (fat, carbohydrate, sugars, fibre, protein): 10 g and up -> nearest 1 g,
+// below -> nearest 0.1 g, 0.5 g or less -> "<0.5 g".
+func euGrams(grams float64) string {
+	switch {
+	case grams <= 0:
+		return "0 g"
+	case grams <= 0.5:
+		return "<0.5 g"
+	case grams < 10:
+		return formatNumber(roundTo(grams, 0.1), 1) + " g"
+	default:
+		return formatNumber(roundTo(grams, 1), 0) + " g"
+	}
+}
+
+// euSalt: 1 g and up -> nearest 0.1 g, below -> nearest 0.01 g, 0.0125 g or less -> "<0.01 g".
+func euSalt(grams float64) string {
+	switch {
+	case grams <= 0:
+		return "0 g"
+	case grams <= 0.0125:
+		return "<0.01 g"
+	case grams < 1:
+		return formatNumber(roundTo(grams, 0.01), 2) + " g"
+	default:
+		return formatNumber(roundTo(grams, 0.1), 1) + " g"
+	}
+}
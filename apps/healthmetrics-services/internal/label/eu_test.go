@@ -0,0 +1,67 @@
+package label
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildEU(t *testing.T) {
+	panel := buildEU(peanutButter, resolveServing(peanutButter, 0))
+
+	if !reflect.DeepEqual(panel.Columns, []string{"Per 100 g", "Per portion (32 g)", "%RI*"}) {
+		t.Fatalf("unexpected columns: %v", panel.Columns)
+	}
+	want := [][]string{
+		{"2460 kJ", "787 kJ", "9%"},
+		{"588 kcal", "188 kcal", "9%"},
+		{"50 g", "16 g", "23%"},          // fat
+		{missingValue, missingValue, ""}, // saturates
+		{"20 g", "6.4 g", "2%"},          // carbohydrate
+		{"9 g", "2.9 g", "3%"},           // sugars
+		{"6 g", "1.9 g", ""},             // fibre
+		{"25 g", "8 g", "16%"},           // protein
+		{"1.1 g", "0.34 g", "6%"},        // salt = 0.43 g sodium x 2.5
+	}
+	if len(panel.Rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), panel.Rows)
+	}
+	for i, row := range panel.Rows {
+		if !reflect.DeepEqual(row.Values, want[i]) {
+			t.Errorf("%q: got %v, want %v", row.Name, row.Values, want[i])
+		}
+	}
+	if !reflect.DeepEqual(panel.Missing, []string{"Saturates"}) {
+		t.Errorf("unexpected missing list: %v", panel.Missing)
+	}
+}
+
+func TestBuildEU_PerHundredOnly(t *testing.T) {
+	item := peanutButter
+	item.ServingSize = ""
+	item.Nutrients.FiberG = nil // voluntary in the EU: the row is left out
+
+	panel := buildEU(item, resolveServing(item, 0))
+	if len(panel.Columns) != 1 || panel.Footnotes != nil {
+		t.Fatalf("expected only the per-100 g column, got %v / %v", panel.Columns, panel.Footnotes)
+	}
+	for _, row := range panel.Rows {
+		if row.Name == "Fibre" || len(row.Values) != 1 {
+			t.Fatalf("unexpected row %+v", row)
+		}
+	}
+}
+
+func TestEURounding(t *testing.T) {
+	grams := map[float64]string{0: "0 g", 0.5: "<0.5 g", 0.54: "0.5 g", 9.96: "10 g", 12.5: "13 g"}
+	for value, want := range grams {
+		if got := euGrams(value); got != want {
+			t.Errorf("euGrams(%v) = %q, want %q", value, got, want)
+		}
+	}
+	salt := map[float64]string{0.01: "<0.01 g", 0.125: "0.13 g", 1.075: "1.1 g", 2.44: "2.4 g"}
+	for value, want := range salt {
+		if got := euSalt(value); got != want {
+			t.Errorf("euSalt(%v) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package label
+
+import (
+	"fmt"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// FDA Daily Values for adults and children 4+ (21 CFR 101.9(c), 2016 rule).
+const (
+	dvTotalFatG  = 78.0
+	dvSodiumMg   = 2300.0
+	dvTotalCarbG = 275.0
+	dvFiberG     = 28.0
+)
+
+// fdaUntracked are mandatory Nutrition Facts lines food_items has no column for.
+var fdaUntracked = []string{"Saturated Fat", "Trans Fat", "Cholesterol", "Added Sugars", "Vitamin D", "Calcium", "Iron", "Potassium"}
+
+// buildFDA lays out a Nutrition Facts panel for one serving (100 g when the serving is unknown).
+// Amounts use the 21 CFR 101.9 rounding rules; %DV is computed from the declared amount.
+func buildFDA(item barcode.FoodItem, portion serving) Panel {
+	panel := Panel{
+		Style:   StyleFDA,
+		Title:   "Nutrition Facts",
+		Product: productName(item),
+		Columns: []string{"", "% Daily Value*"},
+		Footnotes: []string{
+			"* The % Daily Value (DV) tells you how much a nutrient in a serving of food contributes to a daily diet. 2,000 calories a day is used for general nutrition advice.",
+		},
+		Missing: append([]string{}, fdaUntracked...),
+	}
+
+	if !portion.Known {
+		portion = serving{Amount: 100, Unit: "g"}
+		panel.Footnotes = append(panel.Footnotes, "Serving size unknown; values are per 100 g.")
+	}
+	panel.Serving = fdaServingText(portion)
+
+	factor := portion.Amount / 100
+	per100 := item.Nutrients
+
+	panel.Calories = formatNumber(fdaCalories(per100.CaloriesKcal*factor), 0)
+
+	fatText, fat := fdaFat(per100.FatG * factor)
+	carbText, carbs := fdaGrams(per100.CarbsG * factor)
+	proteinText, _ := fdaGrams(per100.ProteinG * factor)
+
+	panel.Rows = append(panel.Rows,
+		Row{Name: "Total Fat", Values: []string{fatText, percent(fat, dvTotalFatG)}, Bold: true},
+		Row{Name: "Saturated Fat", Values: []string{missingValue, ""}, Indent: 1},
+		Row{Name: "Trans Fat", Values: []string{missingValue, ""}, Indent: 1},
+		Row{Name: "Cholesterol", Values: []string{missingValue, ""}, Bold: true},
+		fdaSodiumRow(per100.SodiumG, factor, &panel),
+		Row{Name: "Total Carbohydrate", Values: []string{carbText, percent(carbs, dvTotalCarbG)}, Bold: true},
+		fdaOptionalRow("Dietary Fiber", per100.FiberG, factor, dvFiberG, &panel),
+		fdaOptionalRow("Total Sugars", per100.SugarG, factor, 0, &panel),
+		Row{Name: "Includes Added Sugars", Values: []string{missingValue, ""}, Indent: 2},
+		Row{Name: "Protein", Values: []string{proteinText, ""}, Bold: true},
+	)
+	return panel
+}
+
+// fdaServingText is "2 tbsp (32g)" when upstream gave a household measure, else "32g".
+func fdaServingText(portion serving) string {
+	metric := formatNumber(portion.Amount, 1) + "g"
+	if portion.Unit == "ml" {
+		metric = formatNumber(portion.Amount, 1) + "mL"
+	}
+	if portion.Text == "" {
+		return metric
+	}
+	return portion.Text
+}
+
+func fdaSodiumRow(perHundredG *float64, factor float64, panel *Panel) Row {
+	if perHundredG == nil {
+		panel.Missing = append(panel.Missing, "Sodium")
+		return Row{Name: "Sodium", Values: []string{missingValue, ""}, Bold: true}
+	}
+	sodium := fdaSodiumMg(*perHundredG * 1000 * factor)
+	return Row{Name: "Sodium", Values: []string{formatNumber(sodium, 0) + "mg", percent(sodium, dvSodiumMg)}, Bold: true}
+}
+
+// fdaOptionalRow is a nullable sub-nutrient of carbohydrate (dv 0 = no %DV for it).
+func fdaOptionalRow(name string, perHundredG *float64, factor, dv float64, panel *Panel) Row {
+	row := Row{Name: name, Indent: 1}
+	if perHundredG == nil {
+		panel.Missing = append(panel.Missing, name)
+		row.Values = []string{missingValue, ""}
+		return row
+	}
+	text, declared := fdaGrams(*perHundredG * factor)
+	row.Values = []string{text, ""}
+	if dv > 0 {
+		row.Values[1] = percent(declared, dv)
+	}
+	return row
+}
+
+// fdaCalories: <5 kcal -> 0, up to 50 -> nearest 5, above -> nearest 10.
+func fdaCalories(kcal float64) float64 {
+	switch {
+	case kcal < 5:
+		return 0
+	case kcal <= 50:
+		return roundTo(kcal, 5)
+	default:
+		return roundTo(kcal, 10)
+	}
+}
+
+// fdaFat (total/saturated/trans): <0.5 g -> 0, below 5 g -> nearest 0.5 g, above -> nearest 1 g.
+// Returns the label text and the declared amount (for %DV).
+func fdaFat(grams float64) (string, float64) {
+	var declared float64
+	switch {
+	case grams < 0.5:
+		declared = 0
+	case grams < 5:
+		declared = roundTo(grams, 0.5)
+	default:
+		declared = roundTo(grams, 1)
+	}
+	return formatNumber(declared, 1) + "g", declared
+}
+
+// fdaGrams (carbohydrate, fiber, sugars, protein): <0.5 g -> 0, below 1 g -> "<1g", above -> nearest 1 g.
+// "<1g" keeps the actual amount for %DV.
+func fdaGrams(grams float64) (string, float64) {
+	switch {
+	case grams < 0.5:
+		return "0g", 0
+	case grams < 1:
+		return "<1g", grams
+	default:
+		declared := roundTo(grams, 1)
+		return fmt.Sprintf("%sg", formatNumber(declared, 0)), declared
+	}
+}
+
+// fdaSodiumMg: <5 mg -> 0, 5-140 mg -> nearest 5 mg, above -> nearest 10 mg.
+func fdaSodiumMg(mg float64) float64 {
+	switch {
+	case mg < 5:
+		return 0
+	case mg <= 140:
+		return roundTo(mg, 5)
+	default:
+		return roundTo(mg, 10)
+	}
+}
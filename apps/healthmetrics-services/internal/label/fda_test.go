@@ -0,0 +1,101 @@
+package label
+
+import (
+	"reflect"
+	"testing"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+func ptr(value float64) *float64 {
+	return &value
+}
+
+// peanutButter is per 100 g, with an upstream household serving.
+var peanutButter = barcode.FoodItem{
+	ID:          "food_pb",
+	Barcode:     "0072745068393",
+	Name:        "Creamy Peanut Butter",
+	Brand:       "Acme",
+	ServingSize: "2 tbsp (32 g)",
+	Nutrients: barcode.FoodItemNutrients{
+		CaloriesKcal: 588, ProteinG: 25, CarbsG: 20, FatG: 50,
+		FiberG: ptr(6), SugarG: ptr(9), SodiumG: ptr(0.43),
+	},
+}
+
+func TestBuildFDA(t *testing.T) {
+	panel := buildFDA(peanutButter, resolveServing(peanutButter, 0))
+
+	if panel.Serving != "2 tbsp (32 g)" || panel.Calories != "190" || panel.Product != "Creamy Peanut Butter (Acme)" {
+		t.Fatalf("unexpected header: %+v", panel)
+	}
+	// 32 g: fat 16 g, sodium 137.6 mg -> 140, carbs 6.4 -> 6, fiber 1.92 -> 2, sugars 2.88 -> 3, protein 8.
+	want := map[string][]string{
+		"Total Fat":             {"16g", "21%"},
+		"Saturated Fat":         {missingValue, ""},
+		"Sodium":                {"140mg", "6%"},
+		"Total Carbohydrate":    {"6g", "2%"},
+		"Dietary Fiber":         {"2g", "7%"},
+		"Total Sugars":          {"3g", ""},
+		"Includes Added Sugars": {missingValue, ""},
+		"Protein":               {"8g", ""},
+	}
+	for _, row := range panel.Rows {
+		if expected, ok := want[row.Name]; ok && !reflect.DeepEqual(row.Values, expected) {
+			t.Errorf("%s: got %v, want %v", row.Name, row.Values, expected)
+		}
+	}
+	if len(panel.Missing) != len(fdaUntracked) {
+		t.Errorf("only untracked nutrients should be missing, got %v", panel.Missing)
+	}
+}
+
+func TestBuildFDA_UnknownServing(t *testing.T) {
+	item := peanutButter
+	item.ServingSize = "1 slice"
+	item.Nutrients.SodiumG = nil
+
+	panel := buildFDA(item, resolveServing(item, 0))
+	if panel.Serving != "100g" || panel.Calories != "590" {
+		t.Fatalf("expected a per-100 g label, got %q / %q", panel.Serving, panel.Calories)
+	}
+	if len(panel.Footnotes) != 2 || panel.Missing[len(panel.Missing)-1] != "Sodium" {
+		t.Fatalf("expected the per-100 g note and sodium listed as missing, got %v / %v", panel.Footnotes, panel.Missing)
+	}
+
+	// An explicit serving wins over the item's.
+	if panel := buildFDA(item, resolveServing(item, 50)); panel.Serving != "50g" || panel.Calories != "290" {
+		t.Fatalf("expected the 50 g override, got %q / %q", panel.Serving, panel.Calories)
+	}
+}
+
+func TestFDARounding(t *testing.T) {
+	calories := map[float64]float64{4.9: 0, 5: 5, 47.5: 50, 52: 50, 188.16: 190}
+	for kcal, want := range calories {
+		if got := fdaCalories(kcal); got != want {
+			t.Errorf("fdaCalories(%v) = %v, want %v", kcal, got, want)
+		}
+	}
+
+	fats := map[float64]string{0.49: "0g", 0.5: "0.5g", 2.3: "2.5g", 4.74: "4.5g", 5.5: "6g"}
+	for grams, want := range fats {
+		if got, _ := fdaFat(grams); got != want {
+			t.Errorf("fdaFat(%v) = %q, want %q", grams, got, want)
+		}
+	}
+
+	grams := map[float64]string{0.4: "0g", 0.7: "<1g", 1.5: "2g", 12.49: "12g"}
+	for value, want := range grams {
+		if got, _ := fdaGrams(value); got != want {
+			t.Errorf("fdaGrams(%v) = %q, want %q", value, got, want)
+		}
+	}
+
+	sodium := map[float64]float64{4: 0, 12: 10, 137.6: 140, 141: 140, 146: 150}
+	for mg, want := range sodium {
+		if got := fdaSodiumMg(mg); got != want {
+			t.Errorf("fdaSodiumMg(%v) = %v, want %v", mg, got, want)
+		}
+	}
+}
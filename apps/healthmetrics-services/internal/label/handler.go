@@ -0,0 +1,144 @@
+package label
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+	"healthmetrics-services/internal/httpx"
+)
+
+// Output formats (?format=).
+const (
+	formatSVG  = "svg"
+	formatHTML = "html"
+	formatJSON = "json"
+)
+
+// maxServingG caps ?serving_g= (same ceiling as a diary entry).
+const maxServingG = 5000.0
+
+// ProductResolver is the shared barcode lookup (barcode.Resolver in prod, a fake in tests).
+type ProductResolver interface {
+	Resolve(c *gin.Context, pool *pgxpool.Pool, code string) (barcode.FoodItem, bool)
+}
+
+// options are the query parameters shared by both label endpoints.
+type options struct {
+	Style    string
+	Format   string
+	ServingG float64 // 0 = use the item's serving size
+}
+
+// NewBarcodeLabelHandler serves GET /v1/barcodes/:code/label?style=fda|eu&format=svg|html|json&serving_g=.
+// The item comes from the same cache -> OpenFoodFacts lookup as GET /v1/barcodes/:code.
+func NewBarcodeLabelHandler(resolver ProductResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, ok := parseOptions(c)
+		if !ok {
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		item, ok := resolver.Resolve(c, pool, c.Param("code"))
+		if !ok {
+			return
+		}
+		writeLabel(c, item, opts, "nutrition-"+item.Barcode)
+	}
+}
+
+// NewFoodItemLabelHandler serves GET /v1/food-items/:id/label for items without a barcode
+// (USDA and user entries). Merged duplicates render their survivor.
+func NewFoodItemLabelHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, ok := parseOptions(c)
+		if !ok {
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		foodItemID := c.Param("id")
+		item, err := getFoodItemFunc(c.Request.Context(), pool, foodItemID)
+		if errors.Is(err, errNotFound) {
+			httpx.WriteError(c, 404, "NOT_FOUND", "Food item not found")
+			return
+		}
+		if err != nil {
+			log.Printf("label_read_error request_id=%s food_item_id=%s err=%v", c.GetHeader("X-Request-ID"), foodItemID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load food item")
+			return
+		}
+		writeLabel(c, item, opts, "nutrition-"+item.ID)
+	}
+}
+
+// parseOptions validates the query; defaults are FDA style as SVG at the item's serving size.
+func parseOptions(c *gin.Context) (options, bool) {
+	opts := options{
+		Style:  strings.ToLower(c.DefaultQuery("style", StyleFDA)),
+		Format: strings.ToLower(c.DefaultQuery("format", formatSVG)),
+	}
+	if opts.Style != StyleFDA && opts.Style != StyleEU {
+		httpx.WriteError(c, 400, "INVALID_REQUEST", "style must be fda or eu")
+		return options{}, false
+	}
+	if opts.Format != formatSVG && opts.Format != formatHTML && opts.Format != formatJSON {
+		httpx.WriteError(c, 400, "INVALID_REQUEST", "format must be svg, html or json")
+		return options{}, false
+	}
+	if raw := c.Query("serving_g"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 || parsed > maxServingG {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", fmt.Sprintf("serving_g must be between 0 and %.0f", maxServingG))
+			return options{}, false
+		}
+		opts.ServingG = parsed
+	}
+	return opts, true
+}
+
+// buildPanel picks the regulation layout.
+func buildPanel(item barcode.FoodItem, opts options) Panel {
+	portion := resolveServing(item, opts.ServingG)
+	if opts.Style == StyleEU {
+		return buildEU(item, portion)
+	}
+	return buildFDA(item, portion)
+}
+
+func writeLabel(c *gin.Context, item barcode.FoodItem, opts options, filename string) {
+	panel := buildPanel(item, opts)
+	if opts.Format == formatJSON {
+		c.JSON(200, gin.H{"label": panel})
+		return
+	}
+
+	render, contentType := renderSVG, "image/svg+xml; charset=utf-8"
+	if opts.Format == formatHTML {
+		render, contentType = renderHTML, "text/html; charset=utf-8"
+	}
+	body, err := render(panel)
+	if err != nil {
+		log.Printf("label_render_error request_id=%s item=%s style=%s err=%v", c.GetHeader("X-Request-ID"), item.ID, opts.Style, err)
+		httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to render label")
+		return
+	}
+	// inline: embeddable in <img>/<iframe>; the filename is used when the app shares/saves it.
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s-%s.%s"`, filename, opts.Style, opts.Format))
+	c.Data(200, contentType, body)
+}
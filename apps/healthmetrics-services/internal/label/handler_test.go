@@ -0,0 +1,124 @@
+package label
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// fakeResolver stands in for barcode.Resolver (no OpenFoodFacts, no cache).
+type fakeResolver struct {
+	item barcode.FoodItem
+}
+
+func (f fakeResolver) Resolve(c *gin.Context, _ *pgxpool.Pool, code string) (barcode.FoodItem, bool) {
+	if code != f.item.Barcode {
+		c.JSON(404, gin.H{"error": gin.H{"code": "NOT_FOUND", "message": "Product not found"}})
+		return barcode.FoodItem{}, false
+	}
+	return f.item, true
+}
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		c.Next()
+	})
+	router.GET("/v1/barcodes/:code/label", NewBarcodeLabelHandler(fakeResolver{item: peanutButter}))
+	router.GET("/v1/food-items/:id/label", NewFoodItemLabelHandler())
+	return router
+}
+
+func get(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestBarcodeLabelHandler_SVG(t *testing.T) {
+	rec := get(newRouter(), "/v1/barcodes/0072745068393/label")
+	if rec.Code != 200 || !strings.HasPrefix(rec.Header().Get("Content-Type"), "image/svg+xml") {
+		t.Fatalf("expected an SVG, got %d %s: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"<svg", "Nutrition Facts", "Serving size", ">190<", "Total Fat", "16g", "21%", "Not available in our data"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("SVG is missing %q", want)
+		}
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `inline; filename="nutrition-0072745068393-fda.svg"` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+}
+
+func TestBarcodeLabelHandler_EUFormats(t *testing.T) {
+	router := newRouter()
+
+	rec := get(router, "/v1/barcodes/0072745068393/label?style=eu&format=html")
+	if rec.Code != 200 || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected HTML, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Nutrition declaration") || !strings.Contains(body, "Per portion (32 g)") {
+		t.Fatalf("unexpected HTML: %s", body)
+	}
+
+	rec = get(router, "/v1/barcodes/0072745068393/label?style=eu&format=json&serving_g=50")
+	var body struct {
+		Label Panel `json:"label"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Label.Serving != "50 g" {
+		t.Fatalf("expected a 50 g portion, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBarcodeLabelHandler_Escapes(t *testing.T) {
+	item := peanutButter
+	item.Name = `Nuts & <Bolts>`
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("db", &pgxpool.Pool{}); c.Next() })
+	router.GET("/v1/barcodes/:code/label", NewBarcodeLabelHandler(fakeResolver{item: item}))
+
+	body := get(router, "/v1/barcodes/0072745068393/label").Body.String()
+	if strings.Contains(body, "<Bolts>") || !strings.Contains(body, "Nuts &amp; &lt;Bolts&gt;") {
+		t.Fatalf("product name must be escaped: %s", body)
+	}
+}
+
+func TestLabelHandlers_Errors(t *testing.T) {
+	origGet := getFoodItemFunc
+	defer func() { getFoodItemFunc = origGet }()
+	getFoodItemFunc = func(_ context.Context, _ *pgxpool.Pool, id string) (barcode.FoodItem, error) {
+		if id == "food_pb" {
+			return peanutButter, nil
+		}
+		return barcode.FoodItem{}, errNotFound
+	}
+
+	router := newRouter()
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/v1/barcodes/0072745068393/label?style=uk", 400},
+		{"/v1/barcodes/0072745068393/label?format=pdf", 400},
+		{"/v1/barcodes/0072745068393/label?serving_g=0", 400},
+		{"/v1/barcodes/0000000000000/label", 404},
+		{"/v1/food-items/food_missing/label", 404},
+		{"/v1/food-items/food_pb/label?style=eu", 200},
+	}
+	for _, tt := range tests {
+		if rec := get(router, tt.path); rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.path, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+}
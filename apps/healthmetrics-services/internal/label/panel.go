@@ -0,0 +1,100 @@
+// Package label renders regulatory nutrition panels for a food item: US FDA "Nutrition Facts"
+// (per serving, %DV) and the EU nutrition declaration (per 100 g / per portion, %RI).
+// Panels are built from barcode.FoodItem (per-100g values) and rendered as SVG, HTML or JSON.
+package label
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// Label styles (?style=).
+const (
+	StyleFDA = "fda"
+	StyleEU  = "eu"
+)
+
+// missingValue marks a nutrient the regulation wants but food_items doesn't track (or the
+// product didn't report). We never print 0 for unknown data.
+const missingValue = "—"
+
+// Panel is a rendered-agnostic nutrition label: the handler turns it into SVG/HTML/JSON.
+type Panel struct {
+	Style     string   `json:"style"`
+	Title     string   `json:"title"`             // "Nutrition Facts" / "Nutrition declaration"
+	Product   string   `json:"product"`           // name (and brand)
+	Serving   string   `json:"serving,omitempty"` // FDA "Serving size" value / EU portion ("30 g")
+	Calories  string   `json:"calories,omitempty"`
+	Columns   []string `json:"columns"` // headers for Row.Values
+	Rows      []Row    `json:"rows"`
+	Footnotes []string `json:"footnotes,omitempty"`
+	Missing   []string `json:"missing,omitempty"` // mandatory nutrients we have no data for
+}
+
+// Row is one nutrient line. Values line up with Panel.Columns ("" = nothing in that column).
+type Row struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+	Indent int      `json:"indent,omitempty"` // 1 = "of which" / sub-nutrient, 2 = nested under that
+	Bold   bool     `json:"bold,omitempty"`
+}
+
+// serving is the portion the label is computed for.
+type serving struct {
+	Amount float64 // g or ml
+	Unit   string  // "g" or "ml"
+	Text   string  // household measure from upstream ("2 tbsp (32 g)"), "" when there is none
+	Known  bool    // false = no usable serving; FDA falls back to 100 g, EU drops the portion column
+}
+
+// resolveServing picks the serving: an explicit ?serving_g= override wins, then the amount in
+// the item's serving text.
+func resolveServing(item barcode.FoodItem, overrideG float64) serving {
+	if overrideG > 0 {
+		return serving{Amount: overrideG, Unit: "g", Known: true}
+	}
+	amount, unit, ok := item.ServingAmount()
+	if !ok {
+		return serving{}
+	}
+
+	// Keep upstream household measures ("1 cup (240 ml)"); cached rows only have "30.00g".
+	text := strings.TrimSpace(item.ServingSize)
+	if _, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.ToLower(text), unit)), 64); err == nil {
+		text = ""
+	}
+	return serving{Amount: amount, Unit: unit, Text: text, Known: true}
+}
+
+// productName is "Name (Brand)" when the brand isn't already part of the name.
+func productName(item barcode.FoodItem) string {
+	name := strings.TrimSpace(item.Name)
+	brand := strings.TrimSpace(item.Brand)
+	if brand == "" || strings.Contains(strings.ToLower(name), strings.ToLower(brand)) {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, brand)
+}
+
+// roundTo rounds value to the nearest multiple of step, halves away from zero.
+func roundTo(value, step float64) float64 {
+	return math.Round(value/step) * step
+}
+
+// formatNumber prints without trailing zeros ("2.50" -> "2.5", "3.0" -> "3").
+func formatNumber(value float64, decimals int) string {
+	text := strconv.FormatFloat(value, 'f', decimals, 64)
+	if strings.Contains(text, ".") {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	return text
+}
+
+// percent is a whole-number percentage of reference ("12%").
+func percent(value, reference float64) string {
+	return fmt.Sprintf("%d%%", int(math.Round(value/reference*100)))
+}
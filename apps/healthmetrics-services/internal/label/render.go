@@ -0,0 +1,255 @@
+package label
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+)
+
+// SVG geometry. Text widths aren't measured, so long product names and notes are wrapped by
+// character count (approximate for a sans-serif font at these sizes).
+const (
+	svgWidth       = 320
+	svgMargin      = 10
+	rowHeight      = 17
+	noteLineHeight = 11
+	noteWrapChars  = 62
+	titleWrapChars = 40
+)
+
+// svgText is one positioned text element; Bold/Value are rendered as tspans so "Total Fat 8g"
+// reads as one line with only the name in bold.
+type svgText struct {
+	X, Y   int
+	Size   int
+	Anchor string // start / end
+	Bold   bool
+	Text   string
+	Value  string // appended after Text in regular weight
+}
+
+// svgRule is a horizontal bar across the panel (thickness per the FDA format: 1, 4 or 8 px).
+type svgRule struct {
+	Y, Thickness int
+}
+
+type svgLayout struct {
+	Width, Height int
+	Label         string // aria-label
+	Texts         []svgText
+	Rules         []svgRule
+}
+
+var svgTemplate = template.Must(template.New("svg").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" role="img" aria-label="{{.Label}}" font-family="Helvetica, Arial, sans-serif">
+<rect x="0.5" y="0.5" width="{{.Width}}" height="{{.Height}}" fill="#fff" stroke="#000"/>
+{{- range .Rules}}
+<rect x="10" y="{{.Y}}" width="300" height="{{.Thickness}}" fill="#000"/>
+{{- end}}
+{{- range .Texts}}
+<text x="{{.X}}" y="{{.Y}}" font-size="{{.Size}}" text-anchor="{{.Anchor}}"{{if .Bold}} font-weight="bold"{{end}}>{{.Text}}{{if .Value}}<tspan font-weight="normal"> {{.Value}}</tspan>{{end}}</text>
+{{- end}}
+</svg>
+`))
+
+var htmlTemplate = template.Must(template.New("html").Funcs(template.FuncMap{
+	"join": func(values []string) string { return strings.Join(values, ", ") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} – {{.Product}}</title>
+<style>
+.nutrition-label{font-family:Helvetica,Arial,sans-serif;border:1px solid #000;padding:4px 8px;max-width:320px}
+.nutrition-label h1{font-size:1.6em;margin:0}
+.nutrition-label table{width:100%;border-collapse:collapse}
+.nutrition-label th,.nutrition-label td{border-top:1px solid #000;padding:2px 0;text-align:right}
+.nutrition-label th:first-child,.nutrition-label td:first-child{text-align:left}
+.nutrition-label .indent-1{padding-left:1em}
+.nutrition-label .indent-2{padding-left:2em}
+.nutrition-label .bold{font-weight:bold}
+.nutrition-label .calories{font-size:1.6em;font-weight:bold}
+.nutrition-label p{font-size:.75em;margin:4px 0}
+</style>
+</head>
+<body>
+<section class="nutrition-label nutrition-label-{{.Style}}">
+<h1>{{.Title}}</h1>
+<p>{{.Product}}</p>
+{{- if and .Serving (eq .Style "fda")}}
+<p class="bold">Serving size {{.Serving}}</p>
+<p class="bold">Amount per serving</p>
+<p class="calories">Calories {{.Calories}}</p>
+{{- end}}
+<table>
+<thead><tr><th></th>{{range .Columns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{- range .Rows}}
+<tr><td class="indent-{{.Indent}}{{if .Bold}} bold{{end}}">{{.Name}}</td>{{range .Values}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+{{- range .Footnotes}}
+<p>{{.}}</p>
+{{- end}}
+{{- if .Missing}}
+<p>Not available in our data: {{join .Missing}}.</p>
+{{- end}}
+</section>
+</body>
+</html>
+`))
+
+// renderSVG draws the panel in the layout of its style.
+func renderSVG(panel Panel) ([]byte, error) {
+	var layout svgLayout
+	if panel.Style == StyleEU {
+		layout = layoutEU(panel)
+	} else {
+		layout = layoutFDA(panel)
+	}
+	var buf bytes.Buffer
+	if err := svgTemplate.Execute(&buf, layout); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderHTML is a self-contained page (a semantic table) for sharing or a web view.
+func renderHTML(panel Panel) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, panel); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// layoutFDA follows the Nutrition Facts format: title, serving size, heavy bar, calories,
+// medium bar, "% Daily Value*", nutrient lines with hairlines, heavy bar, footnote.
+func layoutFDA(panel Panel) svgLayout {
+	right := svgWidth - svgMargin
+	layout := svgLayout{Width: svgWidth, Label: panel.Title + " for " + panel.Product}
+	y := 36
+
+	layout.Texts = append(layout.Texts, svgText{X: svgMargin, Y: y, Size: 30, Anchor: "start", Bold: true, Text: panel.Title})
+	y += 4
+	layout.Rules = append(layout.Rules, svgRule{Y: y, Thickness: 1})
+	y = addWrapped(&layout, panel.Product, titleWrapChars, y+14, 11, 13, false)
+	layout.Texts = append(layout.Texts,
+		svgText{X: svgMargin, Y: y + 4, Size: 13, Anchor: "start", Bold: true, Text: "Serving size"},
+		svgText{X: right, Y: y + 4, Size: 13, Anchor: "end", Bold: true, Text: panel.Serving},
+	)
+	y += 10
+	layout.Rules = append(layout.Rules, svgRule{Y: y, Thickness: 8})
+	y += 8
+
+	layout.Texts = append(layout.Texts,
+		svgText{X: svgMargin, Y: y + 12, Size: 10, Anchor: "start", Bold: true, Text: "Amount per serving"},
+		svgText{X: svgMargin, Y: y + 38, Size: 26, Anchor: "start", Bold: true, Text: "Calories"},
+		svgText{X: right, Y: y + 38, Size: 30, Anchor: "end", Bold: true, Text: panel.Calories},
+	)
+	y += 44
+	layout.Rules = append(layout.Rules, svgRule{Y: y, Thickness: 4})
+	y += 4
+	layout.Texts = append(layout.Texts, svgText{X: right, Y: y + 12, Size: 10, Anchor: "end", Bold: true, Text: panel.Columns[1]})
+	y += 16
+
+	for _, row := range panel.Rows {
+		layout.Rules = append(layout.Rules, svgRule{Y: y, Thickness: 1})
+		layout.Texts = append(layout.Texts, svgText{
+			X: svgMargin + 12*row.Indent, Y: y + 13, Size: 12, Anchor: "start", Bold: row.Bold || row.Indent == 0,
+			Text: row.Name, Value: row.Values[0],
+		})
+		if row.Values[1] != "" {
+			layout.Texts = append(layout.Texts, svgText{X: right, Y: y + 13, Size: 12, Anchor: "end", Bold: true, Text: row.Values[1]})
+		}
+		y += rowHeight
+	}
+	layout.Rules = append(layout.Rules, svgRule{Y: y, Thickness: 8})
+	y += 10
+
+	layout.Height = addNotes(&layout, panel, y) + 6
+	return layout
+}
+
+// layoutEU is the tabular declaration: a header row, then one line per nutrient with the
+// per-100 / per-portion / %RI columns right-aligned.
+func layoutEU(panel Panel) svgLayout {
+	layout := svgLayout{Width: svgWidth, Label: panel.Title + " for " + panel.Product}
+	columnX := []int{svgWidth - svgMargin}
+	if len(panel.Columns) == 3 {
+		columnX = []int{150, 250, svgWidth - svgMargin}
+	}
+
+	y := addWrapped(&layout, panel.Title, titleWrapChars, 24, 16, 18, true)
+	y = addWrapped(&layout, panel.Product, titleWrapChars, y, 11, 13, false)
+
+	layout.Rules = append(layout.Rules, svgRule{Y: y - 6, Thickness: 2})
+	for i, column := range panel.Columns {
+		// "Per portion (30 g)" is the widest header; split it over two lines.
+		header, second, _ := strings.Cut(column, " (")
+		layout.Texts = append(layout.Texts, svgText{X: columnX[i], Y: y + 8, Size: 10, Anchor: "end", Bold: true, Text: header})
+		if second != "" {
+			layout.Texts = append(layout.Texts, svgText{X: columnX[i], Y: y + 20, Size: 10, Anchor: "end", Bold: true, Text: "(" + second})
+		}
+	}
+	y += 26
+
+	for _, row := range panel.Rows {
+		if row.Name != "" { // the kcal line continues the Energy row
+			layout.Rules = append(layout.Rules, svgRule{Y: y, Thickness: 1})
+		}
+		layout.Texts = append(layout.Texts, svgText{X: svgMargin + 12*row.Indent, Y: y + 13, Size: 11, Anchor: "start", Bold: row.Bold, Text: row.Name})
+		for i, value := range row.Values {
+			if value != "" {
+				layout.Texts = append(layout.Texts, svgText{X: columnX[i], Y: y + 13, Size: 11, Anchor: "end", Text: value})
+			}
+		}
+		y += rowHeight
+	}
+	layout.Rules = append(layout.Rules, svgRule{Y: y, Thickness: 2})
+	y += 6
+
+	layout.Height = addNotes(&layout, panel, y) + 6
+	return layout
+}
+
+// addNotes writes footnotes and the missing-data note; returns the y after the last line.
+func addNotes(layout *svgLayout, panel Panel, y int) int {
+	notes := append([]string{}, panel.Footnotes...)
+	if len(panel.Missing) > 0 {
+		notes = append(notes, "Not available in our data: "+strings.Join(panel.Missing, ", ")+".")
+	}
+	for _, note := range notes {
+		y = addWrapped(layout, note, noteWrapChars, y+noteLineHeight, 8, noteLineHeight, false) - noteLineHeight + 2
+	}
+	return y
+}
+
+// addWrapped writes text as left-aligned lines starting at baseline y; returns the next baseline.
+func addWrapped(layout *svgLayout, text string, width, y, size, lineHeight int, bold bool) int {
+	for _, line := range wrap(text, width) {
+		layout.Texts = append(layout.Texts, svgText{X: svgMargin, Y: y, Size: size, Anchor: "start", Bold: bold, Text: line})
+		y += lineHeight
+	}
+	return y
+}
+
+// wrap splits text on spaces into lines of at most width characters (long words stay whole).
+func wrap(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,77 @@
+package label
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// Allow tests to swap DB helpers without changing production logic.
+var getFoodItemFunc = getFoodItem // default: real DB fetch
+
+var errNotFound = errors.New("food item not found")
+
+// getFoodItem loads a food_items row by id as a barcode.FoodItem (per-100g values, sodium in g).
+// Merged duplicates resolve to their survivor, like the barcode lookup.
+func getFoodItem(ctx context.Context, pool *pgxpool.Pool, foodItemID string) (barcode.FoodItem, error) {
+	const query = `
+		SELECT
+			id,
+			COALESCE(barcode, ''),
+			name,
+			COALESCE(brand, ''),
+			serving_size_g::text || COALESCE(serving_size_unit, 'g'),
+			calories_per_100g::float8,
+			protein_g::float8,
+			carbs_g::float8,
+			fat_g::float8,
+			fiber_g::float8,
+			sugar_g::float8,
+			(sodium_mg / 1000.0)::float8
+		FROM food_items
+		WHERE id = (SELECT COALESCE(merged_into_id, id) FROM food_items WHERE id = $1)
+	`
+
+	var (
+		item                 barcode.FoodItem
+		fiber, sugar, sodium sql.NullFloat64
+	)
+	err := pool.QueryRow(ctx, query, foodItemID).Scan(
+		&item.ID,
+		&item.Barcode,
+		&item.Name,
+		&item.Brand,
+		&item.ServingSize,
+		&item.Nutrients.CaloriesKcal,
+		&item.Nutrients.ProteinG,
+		&item.Nutrients.CarbsG,
+		&item.Nutrients.FatG,
+		&fiber,
+		&sugar,
+		&sodium,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return barcode.FoodItem{}, errNotFound
+	}
+	if err != nil {
+		return barcode.FoodItem{}, fmt.Errorf("query food_items: %w", err)
+	}
+
+	item.Nutrients.FiberG = nullableFloat(fiber)
+	item.Nutrients.SugarG = nullableFloat(sugar)
+	item.Nutrients.SodiumG = nullableFloat(sodium)
+	return item, nil
+}
+
+func nullableFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
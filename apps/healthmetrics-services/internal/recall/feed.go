@@ -0,0 +1,293 @@
+// Package recall ingests product recall feeds, matches recalls against cached food_items,
+// and alerts users who scanned or logged an affected product.
+package recall
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// maxFeedBytes caps a feed download/file (a year of national recalls is well under this).
+const maxFeedBytes = 20 << 20
+
+// Recall is one feed entry, normalized.
+// Barcodes and/or Brand + ProductPattern say which products are affected.
+type Recall struct {
+	ExternalID     string    `json:"id"`              // the feed's own ID (unique per source)
+	Title          string    `json:"title"`           // "Acme Peanut Butter recalled"
+	Reason         string    `json:"reason"`          // "Possible Salmonella contamination"
+	Brand          string    `json:"brand"`           // matched case-insensitively against food_items.brand
+	ProductPattern string    `json:"product_pattern"` // name pattern, "*" = any text ("*peanut butter*")
+	Barcodes       []string  `json:"barcodes"`        // EAN/UPC codes (normalized like food_items.barcode)
+	URL            string    `json:"url"`             // link to the official notice
+	PublishedAt    time.Time `json:"published_at"`
+}
+
+// Source is where recalls come from. Name is stored with each recall so two feeds
+// can use the same external IDs without clashing.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Recall, error)
+}
+
+// FileSource reads a local .json or .csv feed (handy for manual imports and tests).
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Name() string {
+	return "file:" + filepath.Base(s.Path)
+}
+
+func (s FileSource) Fetch(ctx context.Context) ([]Recall, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(s.Path)) {
+	case ".json":
+		return ParseJSON(io.LimitReader(file, maxFeedBytes))
+	case ".csv":
+		return ParseCSV(io.LimitReader(file, maxFeedBytes))
+	default:
+		return nil, fmt.Errorf("recall feed %s: unsupported file type (want .json or .csv)", s.Path)
+	}
+}
+
+// HTTPSource downloads a JSON or CSV feed (format from Content-Type, JSON by default).
+// Feed is the short name stored with its recalls. It is not derived from URL, so moving the
+// feed (or rotating a token in its query string) doesn't duplicate every recall.
+type HTTPSource struct {
+	Feed   string // e.g. "fda"; "" = the single default HTTP feed
+	URL    string
+	Client *http.Client
+}
+
+func (s HTTPSource) Name() string {
+	if s.Feed == "" {
+		return "http"
+	}
+	return "http:" + s.Feed
+}
+
+func (s HTTPSource) Fetch(ctx context.Context) ([]Recall, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, text/csv")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("recall feed %s: status %d", s.URL, resp.StatusCode)
+	}
+
+	body := io.LimitReader(resp.Body, maxFeedBytes)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		return ParseCSV(body)
+	}
+	return ParseJSON(body)
+}
+
+// InvalidEntriesError lists feed entries that were skipped (bad barcode, missing date, ...).
+// Parsers return it together with the valid recalls, so one bad row never blocks a whole feed.
+type InvalidEntriesError struct {
+	Errs []error
+}
+
+func (e *InvalidEntriesError) Error() string {
+	return fmt.Sprintf("%d invalid recall entries (first: %v)", len(e.Errs), e.Errs[0])
+}
+
+func (e *InvalidEntriesError) Unwrap() []error {
+	return e.Errs
+}
+
+// partial returns recalls plus an *InvalidEntriesError when some entries were skipped.
+func partial(recalls []Recall, invalid []error) ([]Recall, error) {
+	if len(invalid) > 0 {
+		return recalls, &InvalidEntriesError{Errs: invalid}
+	}
+	return recalls, nil
+}
+
+// feedEntry is the wire format (published_at may be a date or an RFC 3339 timestamp).
+type feedEntry struct {
+	ID             string   `json:"id"`
+	Title          string   `json:"title"`
+	Reason         string   `json:"reason"`
+	Brand          string   `json:"brand"`
+	ProductPattern string   `json:"product_pattern"`
+	Barcodes       []string `json:"barcodes"`
+	URL            string   `json:"url"`
+	PublishedAt    string   `json:"published_at"`
+}
+
+// ParseJSON reads either a bare array of recalls or {"recalls": [...]}.
+// Invalid entries are skipped and reported via *InvalidEntriesError (other errors mean no recalls).
+func ParseJSON(r io.Reader) ([]Recall, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []feedEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		var wrapped struct {
+			Recalls []feedEntry `json:"recalls"`
+		}
+		if wrappedErr := json.Unmarshal(data, &wrapped); wrappedErr != nil {
+			return nil, fmt.Errorf("invalid recall JSON: %w", err)
+		}
+		entries = wrapped.Recalls
+	}
+
+	recalls := make([]Recall, 0, len(entries))
+	var invalid []error
+	for i, entry := range entries {
+		recall, err := entry.normalize()
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("recall %d (%s): %w", i, entry.ID, err))
+			continue
+		}
+		recalls = append(recalls, recall)
+	}
+	return partial(recalls, invalid)
+}
+
+// ParseCSV reads a feed with a header row. Columns (any order, extra columns ignored):
+// id, title, reason, brand, product_pattern, barcodes (";"-separated), url, published_at.
+// Like ParseJSON, invalid rows are skipped and reported via *InvalidEntriesError.
+func ParseCSV(r io.Reader) ([]Recall, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // tolerate ragged rows; missing cells read as ""
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid recall CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["id"]; !ok {
+		return nil, errors.New("invalid recall CSV: missing id column")
+	}
+
+	var recalls []Recall
+	var invalid []error
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return partial(recalls, invalid)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid recall CSV line %d: %w", line, err)
+		}
+
+		cell := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		entry := feedEntry{
+			ID:             cell("id"),
+			Title:          cell("title"),
+			Reason:         cell("reason"),
+			Brand:          cell("brand"),
+			ProductPattern: cell("product_pattern"),
+			URL:            cell("url"),
+			PublishedAt:    cell("published_at"),
+		}
+		entry.Barcodes = strings.FieldsFunc(cell("barcodes"), func(r rune) bool { return r == ';' || r == ',' })
+
+		recall, err := entry.normalize()
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("recall CSV line %d (%s): %w", line, entry.ID, err))
+			continue
+		}
+		recalls = append(recalls, recall)
+	}
+}
+
+var nonDigits = regexp.MustCompile(`\D`)
+
+// normalize validates one entry and puts barcodes in food_items form.
+func (e feedEntry) normalize() (Recall, error) {
+	recall := Recall{
+		ExternalID:     strings.TrimSpace(e.ID),
+		Title:          strings.TrimSpace(e.Title),
+		Reason:         strings.TrimSpace(e.Reason),
+		Brand:          strings.TrimSpace(e.Brand),
+		ProductPattern: strings.TrimSpace(e.ProductPattern),
+		URL:            strings.TrimSpace(e.URL),
+	}
+	if recall.ExternalID == "" {
+		return Recall{}, errors.New("id is required")
+	}
+	if recall.Title == "" {
+		return Recall{}, errors.New("title is required")
+	}
+
+	for _, raw := range e.Barcodes {
+		// Feeds print codes with spaces/dashes ("0 12345 67890 5"); keep the digits.
+		code := nonDigits.ReplaceAllString(raw, "")
+		if len(code) < 8 || len(code) > 14 {
+			return Recall{}, fmt.Errorf("invalid barcode %q", raw)
+		}
+		recall.Barcodes = append(recall.Barcodes, barcode.NormalizeBarcode(code))
+	}
+	// A brand alone (or a name alone) would flag far too many products.
+	if len(recall.Barcodes) == 0 && (recall.Brand == "" || recall.ProductPattern == "") {
+		return Recall{}, errors.New("barcodes or brand + product_pattern are required")
+	}
+
+	publishedAt, err := parseFeedTime(e.PublishedAt)
+	if err != nil {
+		return Recall{}, err
+	}
+	recall.PublishedAt = publishedAt
+	return recall, nil
+}
+
+func parseFeedTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("published_at is required")
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid published_at %q (want YYYY-MM-DD or RFC 3339)", value)
+}
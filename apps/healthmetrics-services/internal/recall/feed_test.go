@@ -0,0 +1,150 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSource_JSON(t *testing.T) {
+	source := FileSource{Path: filepath.Join("testdata", "recalls.json")}
+	if source.Name() != "file:recalls.json" {
+		t.Fatalf("unexpected source name %q", source.Name())
+	}
+
+	recalls, err := source.Fetch(context.Background())
+	var invalid *InvalidEntriesError
+	if !errors.As(err, &invalid) || len(invalid.Errs) != 1 {
+		t.Fatalf("expected 1 invalid entry (brand without pattern), got %v", err)
+	}
+	if len(recalls) != 2 {
+		t.Fatalf("expected 2 valid recalls, got %d", len(recalls))
+	}
+
+	peanut := recalls[0]
+	if peanut.ExternalID != "FDA-2026-1042" || peanut.Brand != "Acme" || peanut.ProductPattern != "*peanut butter*" {
+		t.Fatalf("unexpected recall: %+v", peanut)
+	}
+	// Spaced UPC-A digits are cleaned and normalized to the food_items (EAN-13) form.
+	if !reflect.DeepEqual(peanut.Barcodes, []string{"0072745068393"}) {
+		t.Fatalf("unexpected barcodes: %v", peanut.Barcodes)
+	}
+	if !peanut.PublishedAt.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected published_at: %v", peanut.PublishedAt)
+	}
+	if !recalls[1].PublishedAt.Equal(time.Date(2026, 10, 5, 14, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected RFC 3339 timestamp, got %v", recalls[1].PublishedAt)
+	}
+}
+
+func TestFileSource_CSV(t *testing.T) {
+	recalls, err := FileSource{Path: filepath.Join("testdata", "recalls.csv")}.Fetch(context.Background())
+	var invalid *InvalidEntriesError
+	if !errors.As(err, &invalid) || len(invalid.Errs) != 1 || !strings.Contains(invalid.Errs[0].Error(), "RASFF-2026.7733") {
+		t.Fatalf("expected the bad barcode row to be skipped, got %v", err)
+	}
+	if len(recalls) != 2 {
+		t.Fatalf("expected 2 valid recalls, got %d", len(recalls))
+	}
+	if recalls[0].Brand != "Nutty" || recalls[0].ProductPattern != "hazelnut spread" || len(recalls[0].Barcodes) != 0 {
+		t.Fatalf("unexpected brand/name recall: %+v", recalls[0])
+	}
+	if !reflect.DeepEqual(recalls[1].Barcodes, []string{"5449000000996", "96385074"}) {
+		t.Fatalf("unexpected barcodes: %v", recalls[1].Barcodes)
+	}
+}
+
+func TestFileSource_UnsupportedType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recalls.xml")
+	if err := os.WriteFile(path, []byte("<recalls/>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (FileSource{Path: path}).Fetch(context.Background()); err == nil {
+		t.Fatal("expected an error for .xml feeds")
+	}
+}
+
+func TestHTTPSource_Name(t *testing.T) {
+	// The stored source must not change when the URL does.
+	for _, url := range []string{"https://a.example/recalls.json", "https://b.example/feed?token=x"} {
+		if got := (HTTPSource{URL: url}).Name(); got != "http" {
+			t.Fatalf("unexpected default source name %q", got)
+		}
+	}
+	if got := (HTTPSource{Feed: "fda", URL: "https://a.example/recalls.json"}).Name(); got != "http:fda" {
+		t.Fatalf("unexpected source name %q", got)
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	csvFeed, err := os.ReadFile(filepath.Join("testdata", "recalls.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonFeed, err := os.ReadFile(filepath.Join("testdata", "recalls.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Local stand-in for a recall agency feed.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/recalls.csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Write(csvFeed)
+		case "/recalls":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(jsonFeed)
+		default:
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	recalls, err := HTTPSource{URL: server.URL + "/recalls.csv", Client: server.Client()}.Fetch(context.Background())
+	var invalid *InvalidEntriesError
+	if !errors.As(err, &invalid) || len(recalls) != 2 || recalls[0].ExternalID != "RASFF-2026.7731" {
+		t.Fatalf("expected CSV feed by content type, got %d recalls, err %v", len(recalls), err)
+	}
+
+	recalls, err = HTTPSource{URL: server.URL + "/recalls", Client: server.Client()}.Fetch(context.Background())
+	if !errors.As(err, &invalid) || len(recalls) != 2 || recalls[0].ExternalID != "FDA-2026-1042" {
+		t.Fatalf("expected JSON feed, got %d recalls, err %v", len(recalls), err)
+	}
+
+	if _, err := (HTTPSource{URL: server.URL + "/broken", Client: server.Client()}).Fetch(context.Background()); err == nil || errors.As(err, &invalid) {
+		t.Fatalf("expected a hard error for a 503 feed, got %v", err)
+	}
+}
+
+func TestParseJSON_BareArrayAndErrors(t *testing.T) {
+	recalls, err := ParseJSON(strings.NewReader(`[{"id":"1","title":"Chips","barcodes":["96385074"],"published_at":"2026-10-10"}]`))
+	if err != nil || len(recalls) != 1 {
+		t.Fatalf("expected 1 recall from a bare array, got %d, %v", len(recalls), err)
+	}
+
+	if _, err := ParseJSON(strings.NewReader(`not json`)); err == nil {
+		t.Fatal("expected an error for invalid JSON")
+	}
+
+	tests := map[string]string{
+		"missing id":    `[{"title":"x","barcodes":["96385074"],"published_at":"2026-10-10"}]`,
+		"missing title": `[{"id":"1","barcodes":["96385074"],"published_at":"2026-10-10"}]`,
+		"bad date":      `[{"id":"1","title":"x","barcodes":["96385074"],"published_at":"10/10/2026"}]`,
+		"no target":     `[{"id":"1","title":"x","product_pattern":"*chips*","published_at":"2026-10-10"}]`,
+	}
+	for name, feed := range tests {
+		recalls, err := ParseJSON(strings.NewReader(feed))
+		var invalid *InvalidEntriesError
+		if !errors.As(err, &invalid) || len(recalls) != 0 {
+			t.Errorf("%s: expected entry to be skipped, got %d recalls, err %v", name, len(recalls), err)
+		}
+	}
+}
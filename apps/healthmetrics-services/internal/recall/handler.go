@@ -0,0 +1,93 @@
+package recall
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"healthmetrics-services/internal/httpx"
+)
+
+const (
+	defaultAlertsLimit = 50
+	maxAlertsLimit     = 200
+)
+
+// NewListAlertsHandler serves GET /v1/recalls/alerts?status=open|all&limit=50.
+// Default is open (unacknowledged) alerts, newest recall first.
+func NewListAlertsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID") // set by auth middleware after session validation
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+
+		includeAcknowledged := false
+		switch c.DefaultQuery("status", "open") {
+		case "open":
+		case "all":
+			includeAcknowledged = true
+		default:
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "status must be open or all")
+			return
+		}
+
+		limit := defaultAlertsLimit
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxAlertsLimit {
+				httpx.WriteError(c, 400, "INVALID_REQUEST", fmt.Sprintf("limit must be between 1 and %d", maxAlertsLimit))
+				return
+			}
+			limit = parsed
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		alerts, err := listAlertsFunc(c.Request.Context(), pool, userID, includeAcknowledged, limit)
+		if err != nil {
+			requestID := c.GetHeader("X-Request-ID")
+			log.Printf("recall_alerts_read_error request_id=%s user_id=%s err=%v", requestID, userID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load recall alerts")
+			return
+		}
+		c.JSON(200, gin.H{"alerts": alerts})
+	}
+}
+
+// NewAcknowledgeAlertHandler serves POST /v1/recalls/alerts/:id/acknowledge.
+// Acknowledging twice is fine (the first time is kept); someone else's alert is a 404.
+func NewAcknowledgeAlertHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		alertID := c.Param("id")
+		acknowledgedAt, found, err := acknowledgeAlertFunc(c.Request.Context(), pool, userID, alertID)
+		if err != nil {
+			requestID := c.GetHeader("X-Request-ID")
+			log.Printf("recall_alert_ack_error request_id=%s user_id=%s alert_id=%s err=%v", requestID, userID, alertID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to acknowledge alert")
+			return
+		}
+		if !found {
+			httpx.WriteError(c, 404, "NOT_FOUND", "Recall alert not found")
+			return
+		}
+		c.JSON(200, gin.H{"id": alertID, "acknowledged_at": acknowledgedAt})
+	}
+}
@@ -0,0 +1,124 @@
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func newRouter(userID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		if userID != "" {
+			c.Set("userID", userID)
+		}
+		c.Next()
+	})
+	router.GET("/v1/recalls/alerts", NewListAlertsHandler())
+	router.POST("/v1/recalls/alerts/:id/acknowledge", NewAcknowledgeAlertHandler())
+	return router
+}
+
+func TestListAlertsHandler(t *testing.T) {
+	origList := listAlertsFunc
+	defer func() { listAlertsFunc = origList }()
+
+	var gotAll bool
+	var gotLimit int
+	listAlertsFunc = func(_ context.Context, _ *pgxpool.Pool, userID string, includeAcknowledged bool, limit int) ([]Alert, error) {
+		if userID != "user_1" {
+			t.Fatalf("unexpected user %q", userID)
+		}
+		gotAll, gotLimit = includeAcknowledged, limit
+		return []Alert{{
+			ID:        "alert_1",
+			Recall:    AlertRecall{ID: "recall_1", Title: "Acme Creamy Peanut Butter recalled", PublishedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+			Products:  []AlertProduct{{ID: "food_1", Name: "Creamy Peanut Butter", Brand: "Acme", Barcode: "0072745068393"}},
+			MatchedBy: matchedByBarcode,
+			Exposures: []string{"diary", "scan"},
+		}}, nil
+	}
+
+	router := newRouter("user_1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/recalls/alerts", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if gotAll || gotLimit != defaultAlertsLimit {
+		t.Fatalf("expected open alerts with default limit, got all=%t limit=%d", gotAll, gotLimit)
+	}
+	var body struct {
+		Alerts []Alert `json:"alerts"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(body.Alerts) != 1 || body.Alerts[0].Products[0].Name != "Creamy Peanut Butter" || body.Alerts[0].AcknowledgedAt != nil {
+		t.Fatalf("unexpected alerts: %+v", body.Alerts)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/recalls/alerts?status=all&limit=10", nil))
+	if rec.Code != http.StatusOK || !gotAll || gotLimit != 10 {
+		t.Fatalf("expected all alerts with limit 10, got %d all=%t limit=%d", rec.Code, gotAll, gotLimit)
+	}
+
+	for _, query := range []string{"?status=closed", "?limit=0", "?limit=201"} {
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/recalls/alerts"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	newRouter("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/recalls/alerts", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a user, got %d", rec.Code)
+	}
+}
+
+func TestAcknowledgeAlertHandler(t *testing.T) {
+	origAck := acknowledgeAlertFunc
+	defer func() { acknowledgeAlertFunc = origAck }()
+
+	ackAt := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	acknowledgeAlertFunc = func(_ context.Context, _ *pgxpool.Pool, userID string, alertID string) (time.Time, bool, error) {
+		// Only user_1's alert exists; anyone else gets found=false.
+		if userID == "user_1" && alertID == "alert_1" {
+			return ackAt, true, nil
+		}
+		return time.Time{}, false, nil
+	}
+
+	rec := httptest.NewRecorder()
+	newRouter("user_1").ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/recalls/alerts/alert_1/acknowledge", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		ID             string    `json:"id"`
+		AcknowledgedAt time.Time `json:"acknowledged_at"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if body.ID != "alert_1" || !body.AcknowledgedAt.Equal(ackAt) {
+		t.Fatalf("unexpected response: %+v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	newRouter("user_2").ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/recalls/alerts/alert_1/acknowledge", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's alert, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultLookback is how long before a recall's publication a scan or diary entry still counts.
+// Recalled lots are usually on shelves for weeks to months before the notice goes out.
+const DefaultLookback = 90 * 24 * time.Hour
+
+// Ingester pulls a feed, stores its recalls, and (re)matches each one against scan history.
+// Matching reruns for every recall still in the feed, so a user who scans a recalled
+// product after the notice went out is alerted on the next run.
+type Ingester struct {
+	Pool     *pgxpool.Pool
+	Source   Source
+	Lookback time.Duration // 0 = DefaultLookback
+}
+
+// IngestResult summarizes one run (logged by the scheduler).
+type IngestResult struct {
+	Recalls   int // valid recalls in the feed
+	Invalid   int // feed entries skipped as invalid
+	Alerts    int // alerts created or refreshed
+	NewAlerts int // alerts created by this run
+}
+
+// Run fetches the feed once. Invalid entries and per-recall DB errors are logged and skipped;
+// the returned error is non-nil when the feed could not be read or any recall failed.
+func (in Ingester) Run(ctx context.Context) (IngestResult, error) {
+	var result IngestResult
+	source := in.Source.Name()
+
+	recalls, err := in.Source.Fetch(ctx)
+	var invalid *InvalidEntriesError
+	if errors.As(err, &invalid) {
+		result.Invalid = len(invalid.Errs)
+		for _, entryErr := range invalid.Errs {
+			log.Printf("recall_feed_invalid_entry source=%s err=%v", source, entryErr)
+		}
+	} else if err != nil {
+		return result, fmt.Errorf("fetch recall feed %s: %w", source, err)
+	}
+	result.Recalls = len(recalls)
+
+	lookback := in.Lookback
+	if lookback <= 0 {
+		lookback = DefaultLookback
+	}
+
+	var failed []error
+	for _, recall := range recalls {
+		recallID, err := upsertRecallFunc(ctx, in.Pool, source, recall)
+		if err != nil {
+			log.Printf("recall_store_error source=%s recall=%s err=%v", source, recall.ExternalID, err)
+			failed = append(failed, err)
+			continue
+		}
+
+		matched, err := matchAlertsFunc(ctx, in.Pool, recallID, recall, recall.PublishedAt.Add(-lookback))
+		if err != nil {
+			log.Printf("recall_match_error source=%s recall=%s err=%v", source, recall.ExternalID, err)
+			failed = append(failed, err)
+			continue
+		}
+		result.Alerts += matched.Alerts
+		result.NewAlerts += matched.NewAlerts
+		if matched.NewAlerts > 0 {
+			log.Printf("recall_alerts_created source=%s recall=%s new=%d", source, recall.ExternalID, matched.NewAlerts)
+		}
+	}
+
+	if len(failed) > 0 {
+		return result, fmt.Errorf("%d of %d recalls failed: %w", len(failed), len(recalls), errors.Join(failed...))
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestLikePattern(t *testing.T) {
+	tests := map[string]string{
+		"*peanut butter*": "%peanut butter%",
+		"peanut butter":   "%peanut butter%", // no wildcard = contains
+		"Creamy*":         "Creamy%",
+		"100% juice*":     `100\% juice%`, // literal % and _ are escaped
+		"snack_bar":       `%snack\_bar%`,
+	}
+	for pattern, want := range tests {
+		if got := likePattern(pattern); got != want {
+			t.Errorf("likePattern(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func stubStore(t *testing.T) {
+	t.Helper()
+	origUpsert, origMatch := upsertRecallFunc, matchAlertsFunc
+	t.Cleanup(func() { upsertRecallFunc, matchAlertsFunc = origUpsert, origMatch })
+}
+
+func TestIngesterRun(t *testing.T) {
+	stubStore(t)
+
+	type matchCall struct {
+		recallID     string
+		externalID   string
+		exposedSince time.Time
+	}
+	var sources []string
+	var calls []matchCall
+	upsertRecallFunc = func(_ context.Context, _ *pgxpool.Pool, source string, recall Recall) (string, error) {
+		sources = append(sources, source)
+		return "row-" + recall.ExternalID, nil
+	}
+	matchAlertsFunc = func(_ context.Context, _ *pgxpool.Pool, recallID string, recall Recall, exposedSince time.Time) (matchResult, error) {
+		calls = append(calls, matchCall{recallID, recall.ExternalID, exposedSince})
+		if recall.ExternalID == "FDA-2026-1042" {
+			return matchResult{Alerts: 3, NewAlerts: 2}, nil
+		}
+		return matchResult{Alerts: 1}, nil
+	}
+
+	ingester := Ingester{Source: FileSource{Path: filepath.Join("testdata", "recalls.json")}, Lookback: 30 * 24 * time.Hour}
+	result, err := ingester.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := IngestResult{Recalls: 2, Invalid: 1, Alerts: 4, NewAlerts: 2}
+	if result != want {
+		t.Fatalf("result = %+v, want %+v", result, want)
+	}
+
+	if len(calls) != 2 || calls[0].recallID != "row-FDA-2026-1042" || sources[0] != "file:recalls.json" {
+		t.Fatalf("unexpected calls: %+v (sources %v)", calls, sources)
+	}
+	// Exposure window starts Lookback before publication.
+	if !calls[0].exposedSince.Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected exposure window start: %v", calls[0].exposedSince)
+	}
+}
+
+func TestIngesterRun_PartialFailure(t *testing.T) {
+	stubStore(t)
+
+	upsertRecallFunc = func(_ context.Context, _ *pgxpool.Pool, _ string, recall Recall) (string, error) {
+		if recall.ExternalID == "FDA-2026-1042" {
+			return "", errors.New("connection reset")
+		}
+		return "row", nil
+	}
+	matched := 0
+	matchAlertsFunc = func(context.Context, *pgxpool.Pool, string, Recall, time.Time) (matchResult, error) {
+		matched++
+		return matchResult{Alerts: 1, NewAlerts: 1}, nil
+	}
+
+	result, err := Ingester{Source: FileSource{Path: filepath.Join("testdata", "recalls.json")}}.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected an error when a recall fails to store")
+	}
+	// The other recall is still matched.
+	if matched != 1 || result.NewAlerts != 1 {
+		t.Fatalf("expected the remaining recall to be processed, got %+v (matched %d)", result, matched)
+	}
+}
+
+func TestIngesterRun_FeedError(t *testing.T) {
+	stubStore(t)
+	upsertRecallFunc = func(context.Context, *pgxpool.Pool, string, Recall) (string, error) {
+		t.Fatal("nothing should be stored when the feed is unreadable")
+		return "", nil
+	}
+
+	_, err := Ingester{Source: FileSource{Path: filepath.Join("testdata", "missing.json")}}.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected a fetch error")
+	}
+}
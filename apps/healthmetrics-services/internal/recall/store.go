@@ -0,0 +1,279 @@
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Swappable DB helpers so tests can run without Postgres.
+var (
+	upsertRecallFunc     = upsertRecall
+	matchAlertsFunc      = matchAlerts
+	listAlertsFunc       = listAlerts
+	acknowledgeAlertFunc = acknowledgeAlert
+)
+
+// Match kinds stored in recall_alerts.matched_by.
+const (
+	matchedByBarcode   = "barcode"    // exact barcode from the notice (strong)
+	matchedByBrandName = "brand_name" // brand + product name pattern (weaker, shown to the user)
+)
+
+// matchResult counts what one recall produced.
+type matchResult struct {
+	Alerts    int // alerts created or refreshed
+	NewAlerts int // alerts that did not exist before
+}
+
+// upsertRecall stores a feed entry (keyed by source + external ID) and returns its row ID.
+// Feeds republish corrected notices, so later versions overwrite earlier ones.
+func upsertRecall(ctx context.Context, pool *pgxpool.Pool, source string, recall Recall) (string, error) {
+	const query = `
+		INSERT INTO product_recalls (
+			id, source, external_id, title, reason, brand, product_pattern, barcodes, url, published_at, created_at, updated_at
+		)
+		VALUES (gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, $7, $8, $9, now(), now())
+		ON CONFLICT (source, external_id) DO UPDATE SET
+			title = EXCLUDED.title,
+			reason = EXCLUDED.reason,
+			brand = EXCLUDED.brand,
+			product_pattern = EXCLUDED.product_pattern,
+			barcodes = EXCLUDED.barcodes,
+			url = EXCLUDED.url,
+			published_at = EXCLUDED.published_at,
+			updated_at = now()
+		RETURNING id
+	`
+
+	barcodes := recall.Barcodes
+	if barcodes == nil {
+		barcodes = []string{} // column is NOT NULL-ish (default []), never write NULL
+	}
+
+	var id string
+	err := pool.QueryRow(ctx, query,
+		source,
+		recall.ExternalID,
+		recall.Title,
+		nullIfEmpty(recall.Reason),
+		nullIfEmpty(recall.Brand),
+		nullIfEmpty(recall.ProductPattern),
+		barcodes,
+		nullIfEmpty(recall.URL),
+		recall.PublishedAt,
+	).Scan(&id)
+	return id, err
+}
+
+// matchAlerts finds food_items affected by a recall, then users who scanned (barcode_scans)
+// or logged (diary_entries) one of them since exposedSince, and upserts one alert per user.
+// Scans of barcodes we never resolved (food_item_id NULL) still match on the barcode itself.
+// Diary entries re-pointed by a food item merge match on the item they were logged against.
+// An acknowledged alert reopens when the user is exposed again after acknowledging it.
+func matchAlerts(ctx context.Context, pool *pgxpool.Pool, recallID string, recall Recall, exposedSince time.Time) (matchResult, error) {
+	const query = `
+		WITH matched AS (
+			SELECT id, 'barcode' AS matched_by
+			FROM food_items
+			WHERE barcode = ANY($2::text[])
+			UNION
+			SELECT id, 'brand_name'
+			FROM food_items
+			WHERE $3 <> '' AND $4 <> '' AND lower(brand) = lower($3) AND name ILIKE $4
+		),
+		exposures AS (
+			SELECT s.user_id, s.food_item_id, 'scan' AS kind, s.scanned_at AS exposed_at,
+			       COALESCE(m.matched_by, 'barcode') AS matched_by
+			FROM barcode_scans s
+			LEFT JOIN matched m ON m.id = s.food_item_id
+			WHERE (m.id IS NOT NULL OR s.barcode = ANY($2::text[]))
+			  AND s.scanned_at >= $5
+			UNION ALL
+			SELECT d.user_id, m.id, 'diary', d.date::timestamp, m.matched_by
+			FROM diary_entries d
+			JOIN matched m ON m.id = COALESCE(d.original_food_item_id, d.food_item_id)
+			WHERE d.date >= $5::date
+		)
+		INSERT INTO recall_alerts (
+			id, user_id, recall_id, food_item_ids, matched_by, exposures, last_exposure_at, created_at
+		)
+		SELECT
+			gen_random_uuid()::text,
+			user_id,
+			$1,
+			COALESCE(array_agg(DISTINCT food_item_id) FILTER (WHERE food_item_id IS NOT NULL), ARRAY[]::text[]),
+			CASE WHEN bool_or(matched_by = 'barcode') THEN 'barcode' ELSE 'brand_name' END,
+			array_agg(DISTINCT kind),
+			max(exposed_at),
+			now()
+		FROM exposures
+		GROUP BY user_id
+		ON CONFLICT (user_id, recall_id) DO UPDATE SET
+			food_item_ids = EXCLUDED.food_item_ids,
+			matched_by = EXCLUDED.matched_by,
+			exposures = EXCLUDED.exposures,
+			last_exposure_at = GREATEST(recall_alerts.last_exposure_at, EXCLUDED.last_exposure_at),
+			acknowledged_at = CASE
+				WHEN EXCLUDED.last_exposure_at > recall_alerts.acknowledged_at THEN NULL
+				ELSE recall_alerts.acknowledged_at
+			END
+		RETURNING (xmax = 0) AS inserted
+	`
+
+	barcodes := recall.Barcodes
+	if barcodes == nil {
+		barcodes = []string{}
+	}
+	pattern := ""
+	if recall.Brand != "" && recall.ProductPattern != "" {
+		pattern = likePattern(recall.ProductPattern)
+	}
+
+	rows, err := pool.Query(ctx, query, recallID, barcodes, recall.Brand, pattern, exposedSince)
+	if err != nil {
+		return matchResult{}, err
+	}
+	defer rows.Close()
+
+	var result matchResult
+	for rows.Next() {
+		var inserted bool // xmax = 0 only for freshly inserted rows
+		if err := rows.Scan(&inserted); err != nil {
+			return matchResult{}, err
+		}
+		result.Alerts++
+		if inserted {
+			result.NewAlerts++
+		}
+	}
+	return result, rows.Err()
+}
+
+// likePattern turns a feed pattern into ILIKE syntax: "*" = any text, everything else literal.
+// A pattern without "*" matches anywhere in the name ("peanut butter" = "*peanut butter*").
+func likePattern(pattern string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(pattern)
+	if !strings.Contains(escaped, "*") {
+		return "%" + escaped + "%"
+	}
+	return strings.ReplaceAll(escaped, "*", "%")
+}
+
+// AlertProduct is a matched food item, as shown in an alert.
+type AlertProduct struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Brand   string `json:"brand,omitempty"`
+	Barcode string `json:"barcode,omitempty"`
+}
+
+// AlertRecall is the recall notice behind an alert.
+type AlertRecall struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Reason      string    `json:"reason,omitempty"`
+	Brand       string    `json:"brand,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Alert is one recall_alerts row with its recall and product details.
+type Alert struct {
+	ID             string         `json:"id"`
+	Recall         AlertRecall    `json:"recall"`
+	Products       []AlertProduct `json:"products"`
+	MatchedBy      string         `json:"matched_by"` // "barcode" or "brand_name"
+	Exposures      []string       `json:"exposures"`  // "scan", "diary"
+	LastExposureAt time.Time      `json:"last_exposure_at"`
+	AcknowledgedAt *time.Time     `json:"acknowledged_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// listAlerts returns a user's alerts, newest recall first.
+func listAlerts(ctx context.Context, pool *pgxpool.Pool, userID string, includeAcknowledged bool, limit int) ([]Alert, error) {
+	const query = `
+		SELECT
+			a.id, r.id, r.title, COALESCE(r.reason, ''), COALESCE(r.brand, ''), COALESCE(r.url, ''), r.published_at,
+			(
+				SELECT COALESCE(json_agg(json_build_object(
+					'id', f.id, 'name', f.name, 'brand', COALESCE(f.brand, ''), 'barcode', COALESCE(f.barcode, '')
+				) ORDER BY f.name), '[]'::json)
+				FROM food_items f
+				WHERE f.id = ANY(a.food_item_ids)
+			),
+			a.matched_by, a.exposures, a.last_exposure_at, a.acknowledged_at, a.created_at
+		FROM recall_alerts a
+		JOIN product_recalls r ON r.id = a.recall_id
+		WHERE a.user_id = $1 AND ($2 OR a.acknowledged_at IS NULL)
+		ORDER BY r.published_at DESC, a.created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := pool.Query(ctx, query, userID, includeAcknowledged, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		var alert Alert
+		var products []byte
+		if err := rows.Scan(
+			&alert.ID,
+			&alert.Recall.ID,
+			&alert.Recall.Title,
+			&alert.Recall.Reason,
+			&alert.Recall.Brand,
+			&alert.Recall.URL,
+			&alert.Recall.PublishedAt,
+			&products,
+			&alert.MatchedBy,
+			&alert.Exposures,
+			&alert.LastExposureAt,
+			&alert.AcknowledgedAt,
+			&alert.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(products, &alert.Products); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// acknowledgeAlert marks one of the user's alerts as seen (idempotent: keeps the first time).
+// found=false when the alert does not exist or belongs to someone else.
+func acknowledgeAlert(ctx context.Context, pool *pgxpool.Pool, userID string, alertID string) (time.Time, bool, error) {
+	const query = `
+		UPDATE recall_alerts
+		SET acknowledged_at = COALESCE(acknowledged_at, now())
+		WHERE id = $1 AND user_id = $2
+		RETURNING acknowledged_at
+	`
+
+	var acknowledgedAt time.Time
+	err := pool.QueryRow(ctx, query, alertID, userID).Scan(&acknowledgedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return acknowledgedAt, true, nil
+}
+
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,353 @@
+package recipe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+	"healthmetrics-services/internal/httpx"
+	"healthmetrics-services/internal/units"
+)
+
+// maxIngredients caps request size (a long cookbook recipe has ~30 lines).
+const maxIngredients = 100
+
+// Ingredient statuses in the breakdown.
+const (
+	statusResolved        = "resolved"         // matched a food item and converted to grams
+	statusUnmatched       = "unmatched"        // no food item matched confidently (see suggestion)
+	statusUnknownQuantity = "unknown_quantity" // matched, but the amount can't be turned into grams
+	statusNotFound        = "not_found"        // barcode / food_item_id isn't in food_items
+)
+
+// IngredientInput is one recipe line, identified by Barcode, FoodItemID, or free Text.
+// Examples:
+//   - {"text": "2 cups rolled oats"}
+//   - {"barcode": "0072745068393", "quantity": 40, "unit": "g"}
+//   - {"barcode": "0072745068393", "text": "2 tbsp"} -> amount read from text
+//   - {"food_item_id": "...", "quantity": 2}         -> 2 servings of the item
+//
+// For barcode/food_item_id lines, a missing quantity means one serving.
+type IngredientInput struct {
+	Text       string  `json:"text"`
+	Barcode    string  `json:"barcode"`
+	FoodItemID string  `json:"food_item_id"`
+	Quantity   float64 `json:"quantity"`
+	Unit       string  `json:"unit"`
+}
+
+// AnalyzeRequest is the POST /v1/recipes/analyze body.
+// RecipeID is the cookbook recipe id; when set, the result is stored in recipe_cache.
+type AnalyzeRequest struct {
+	RecipeID    string            `json:"recipe_id"`
+	RecipeSlug  string            `json:"recipe_slug"`
+	Servings    int               `json:"servings"` // default 1
+	Ingredients []IngredientInput `json:"ingredients"`
+}
+
+// Suggestion is the closest food item for a line we couldn't match confidently.
+type Suggestion struct {
+	FoodItemID string  `json:"food_item_id"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// IngredientResult is one line of the breakdown (also stored in recipe_cache.ingredients_breakdown).
+type IngredientResult struct {
+	Index      int                        `json:"index"`  // position in the request
+	Input      string                     `json:"input"`  // original text, or "barcode:<code>" / "food_item:<id>"
+	Status     string                     `json:"status"` // resolved / unmatched / unknown_quantity / not_found
+	FoodItemID string                     `json:"food_item_id,omitempty"`
+	Name       string                     `json:"name,omitempty"`       // matched food item name
+	Quantity   float64                    `json:"quantity,omitempty"`   // parsed/given amount
+	Unit       string                     `json:"unit,omitempty"`       // canonical unit ("" = count/servings)
+	Grams      float64                    `json:"grams,omitempty"`      // amount in grams
+	Conversion string                     `json:"conversion,omitempty"` // mass / volume_density / volume_water / serving_size
+	Confidence float64                    `json:"confidence"`           // 1 for barcode/id, name-match score for text
+	Nutrients  *barcode.FoodItemNutrients `json:"nutrients,omitempty"`  // contribution of this line
+	Suggestion *Suggestion                `json:"suggestion,omitempty"` // best candidate when unmatched
+
+	WeightDisplay *units.Quantity `json:"weight_display,omitempty"` // grams in the request's unit system (response only)
+}
+
+// Analysis is the computed recipe nutrition.
+type Analysis struct {
+	RecipeID        string                    `json:"recipe_id,omitempty"`
+	RecipeSlug      string                    `json:"recipe_slug,omitempty"`
+	Servings        int                       `json:"servings"`
+	TotalWeightG    float64                   `json:"total_weight_g"`
+	Totals          barcode.FoodItemNutrients `json:"totals"`      // whole recipe (resolved lines only)
+	PerServing      barcode.FoodItemNutrients `json:"per_serving"` // totals / servings
+	Ingredients     []IngredientResult        `json:"ingredients"`
+	UnresolvedCount int                       `json:"unresolved_count"`
+	Cached          bool                      `json:"cached"` // stored in recipe_cache
+
+	// total_weight_g in the request's unit system; nutrients stay in g/kcal.
+	Units              string          `json:"units,omitempty"`
+	TotalWeightDisplay *units.Quantity `json:"total_weight_display,omitempty"`
+}
+
+// NewAnalyzeHandler serves POST /v1/recipes/analyze.
+func NewAnalyzeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req AnalyzeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", "Invalid JSON body")
+			return
+		}
+		if message := validateRequest(&req); message != "" {
+			httpx.WriteError(c, 400, "INVALID_REQUEST", message)
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		requestID := c.GetHeader("X-Request-ID")
+		analysis, err := analyzeRecipe(c.Request.Context(), pool, req)
+		if err != nil {
+			log.Printf("recipe_analyze_error request_id=%s recipe_id=%s err=%v", requestID, req.RecipeID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to analyze recipe")
+			return
+		}
+
+		// Best-effort cache write: the caller still gets the numbers if it fails.
+		if req.RecipeID != "" {
+			if err := upsertRecipeCacheFunc(c.Request.Context(), pool, analysis); err != nil {
+				log.Printf("recipe_cache_write_error request_id=%s recipe_id=%s err=%v", requestID, req.RecipeID, err)
+			} else {
+				analysis.Cached = true
+			}
+		}
+
+		// After the cache write: recipe_cache keeps metric only, display units are per request.
+		applyUnits(&analysis, units.Resolve(c, pool))
+
+		c.JSON(200, analysis)
+	}
+}
+
+// validateRequest applies defaults and returns a message for invalid input ("" = ok).
+func validateRequest(req *AnalyzeRequest) string {
+	if len(req.Ingredients) == 0 {
+		return "At least one ingredient is required"
+	}
+	if len(req.Ingredients) > maxIngredients {
+		return fmt.Sprintf("At most %d ingredients are allowed", maxIngredients)
+	}
+	if req.Servings == 0 {
+		req.Servings = 1
+	}
+	if req.Servings < 0 {
+		return "servings must be positive"
+	}
+	if req.RecipeID != "" && req.RecipeSlug == "" {
+		req.RecipeSlug = req.RecipeID // recipe_slug is required by recipe_cache
+	}
+
+	for i, ingredient := range req.Ingredients {
+		identifiers := 0
+		for _, value := range []string{ingredient.Barcode, ingredient.FoodItemID} {
+			if strings.TrimSpace(value) != "" {
+				identifiers++
+			}
+		}
+		if identifiers > 1 {
+			return fmt.Sprintf("ingredients[%d]: give either barcode or food_item_id, not both", i)
+		}
+		if identifiers == 0 && strings.TrimSpace(ingredient.Text) == "" {
+			return fmt.Sprintf("ingredients[%d]: barcode, food_item_id or text is required", i)
+		}
+		if ingredient.Quantity < 0 {
+			return fmt.Sprintf("ingredients[%d]: quantity must not be negative", i)
+		}
+	}
+	return ""
+}
+
+// analyzeRecipe resolves every line and totals nutrients for the recipe and per serving.
+func analyzeRecipe(ctx context.Context, pool *pgxpool.Pool, req AnalyzeRequest) (Analysis, error) {
+	analysis := Analysis{
+		RecipeID:    req.RecipeID,
+		RecipeSlug:  req.RecipeSlug,
+		Servings:    req.Servings,
+		Ingredients: make([]IngredientResult, 0, len(req.Ingredients)),
+	}
+
+	for i, ingredient := range req.Ingredients {
+		result, err := resolveIngredient(ctx, pool, ingredient)
+		if err != nil {
+			return Analysis{}, fmt.Errorf("ingredient %d: %w", i, err)
+		}
+		result.Index = i
+
+		if result.Status == statusResolved {
+			analysis.TotalWeightG += result.Grams
+			addNutrients(&analysis.Totals, *result.Nutrients)
+		} else {
+			analysis.UnresolvedCount++
+		}
+		analysis.Ingredients = append(analysis.Ingredients, result)
+	}
+
+	analysis.TotalWeightG = roundTo(analysis.TotalWeightG, 1)
+	analysis.PerServing = scaleNutrients(analysis.Totals, 1/float64(analysis.Servings))
+	analysis.Totals = scaleNutrients(analysis.Totals, 1) // round totals the same way
+	return analysis, nil
+}
+
+// resolveIngredient finds the food item for one line and computes its contribution.
+func resolveIngredient(ctx context.Context, pool *pgxpool.Pool, input IngredientInput) (IngredientResult, error) {
+	var (
+		result IngredientResult
+		food   barcode.FoodRow
+		found  bool
+		err    error
+	)
+
+	quantity, unit, unitKnown := input.Quantity, "", true
+	if input.Unit != "" {
+		unit, unitKnown = lookupUnit(strings.ToLower(strings.TrimSpace(input.Unit)))
+	}
+
+	switch {
+	case input.Barcode != "":
+		result.Input = "barcode:" + input.Barcode
+		result.Confidence = 1
+		food, found, err = getFoodByBarcodeFunc(ctx, pool, barcode.NormalizeBarcode(strings.TrimSpace(input.Barcode)))
+	case input.FoodItemID != "":
+		result.Input = "food_item:" + input.FoodItemID
+		result.Confidence = 1
+		food, found, err = getFoodByIDFunc(ctx, pool, strings.TrimSpace(input.FoodItemID))
+	default:
+		result.Input = input.Text
+		parsed := parseIngredientLine(input.Text)
+		if input.Quantity == 0 { // structured quantity wins over the text
+			quantity, unit = parsed.Quantity, parsed.Unit
+		}
+
+		var candidates []barcode.FoodRow
+		candidates, err = searchFoodsByNameFunc(ctx, pool, nameTokens(parsed.Name))
+		if err != nil {
+			return IngredientResult{}, err
+		}
+
+		best, confidence, ok := bestMatch(parsed.Name, candidates)
+		result.Confidence = confidence
+		if !ok || confidence < minMatchConfidence {
+			result.Status = statusUnmatched
+			result.Quantity, result.Unit = quantity, unit
+			if ok {
+				result.Suggestion = &Suggestion{FoodItemID: best.ID, Name: best.Name, Confidence: confidence}
+			}
+			return result, nil
+		}
+		food, found = best, true
+	}
+	if err != nil {
+		return IngredientResult{}, err
+	}
+	if !found {
+		result.Status = statusNotFound
+		return result, nil
+	}
+
+	isLookup := input.Barcode != "" || input.FoodItemID != ""
+	// Barcode/id lines may carry the amount as text ({"barcode": "...", "text": "2 cups"}).
+	if isLookup && input.Quantity == 0 && input.Text != "" {
+		parsed := parseIngredientLine(input.Text)
+		quantity, unit = parsed.Quantity, parsed.Unit
+	}
+	// Barcode/id lines with no amount mean one serving of the item.
+	if isLookup && quantity == 0 {
+		quantity, unit = 1, ""
+	}
+
+	result.FoodItemID = food.ID
+	result.Name = food.Name
+	result.Quantity = quantity
+	result.Unit = unit
+
+	grams, conversion, ok := toGrams(quantity, unit, food.Name, food.ServingSizeG)
+	if !unitKnown || !ok {
+		result.Status = statusUnknownQuantity
+		return result, nil
+	}
+
+	nutrients := scaleNutrients(food.Per100g, grams/100)
+	result.Status = statusResolved
+	result.Grams = roundTo(grams, 1)
+	result.Conversion = conversion
+	result.Nutrients = &nutrients
+	return result, nil
+}
+
+// addNutrients adds src into dst. Optional nutrients stay nil until some line reports them.
+func addNutrients(dst *barcode.FoodItemNutrients, src barcode.FoodItemNutrients) {
+	dst.CaloriesKcal += src.CaloriesKcal
+	dst.ProteinG += src.ProteinG
+	dst.CarbsG += src.CarbsG
+	dst.FatG += src.FatG
+	dst.FiberG = addOptional(dst.FiberG, src.FiberG)
+	dst.SugarG = addOptional(dst.SugarG, src.SugarG)
+	dst.SodiumG = addOptional(dst.SodiumG, src.SodiumG)
+}
+
+func addOptional(total, value *float64) *float64 {
+	if value == nil {
+		return total
+	}
+	sum := *value
+	if total != nil {
+		sum += *total
+	}
+	return &sum
+}
+
+// scaleNutrients multiplies every nutrient by factor and rounds for display.
+// Example: per-100g values with factor 0.4 -> values for 40 g.
+func scaleNutrients(n barcode.FoodItemNutrients, factor float64) barcode.FoodItemNutrients {
+	return barcode.FoodItemNutrients{
+		CaloriesKcal: roundTo(n.CaloriesKcal*factor, 2),
+		ProteinG:     roundTo(n.ProteinG*factor, 2),
+		CarbsG:       roundTo(n.CarbsG*factor, 2),
+		FatG:         roundTo(n.FatG*factor, 2),
+		FiberG:       scaleOptional(n.FiberG, factor, 2),
+		SugarG:       scaleOptional(n.SugarG, factor, 2),
+		SodiumG:      scaleOptional(n.SodiumG, factor, 4), // grams; keep mg precision
+	}
+}
+
+func scaleOptional(value *float64, factor float64, places int) *float64 {
+	if value == nil {
+		return nil
+	}
+	scaled := roundTo(*value*factor, places)
+	return &scaled
+}
+
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
+
+// applyUnits adds the unit-system view of the recipe and ingredient weights.
+func applyUnits(analysis *Analysis, system units.System) {
+	total := units.Mass(analysis.TotalWeightG, system)
+	analysis.Units = string(system)
+	analysis.TotalWeightDisplay = &total
+	for i := range analysis.Ingredients {
+		if analysis.Ingredients[i].Grams > 0 {
+			weight := units.Mass(analysis.Ingredients[i].Grams, system)
+			analysis.Ingredients[i].WeightDisplay = &weight
+		}
+	}
+}
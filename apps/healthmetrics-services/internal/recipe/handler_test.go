@@ -0,0 +1,154 @@
+package recipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+func ptr(value float64) *float64 {
+	return &value
+}
+
+// testFoods is a tiny food_items table for the stubs.
+var testFoods = []barcode.FoodRow{
+	{ID: "oats", Name: "Rolled Oats", ServingSizeG: 40, Per100g: barcode.FoodItemNutrients{CaloriesKcal: 379, ProteinG: 13, CarbsG: 68, FatG: 6.5, FiberG: ptr(10)}},
+	{ID: "milk", Name: "Whole Milk", ServingSizeG: 240, Per100g: barcode.FoodItemNutrients{CaloriesKcal: 61, ProteinG: 3.2, CarbsG: 4.8, FatG: 3.3, SodiumG: ptr(0.043)}},
+	{ID: "bar", Name: "Peanut Protein Bar", ServingSizeG: 50, Per100g: barcode.FoodItemNutrients{CaloriesKcal: 400, ProteinG: 40, CarbsG: 30, FatG: 14}},
+}
+
+// setupStoreStubs swaps the recipe DB helpers and returns the captured cache write + cleanup.
+func setupStoreStubs(t *testing.T) *Analysis {
+	origByID, origByBarcode, origSearch, origUpsert := getFoodByIDFunc, getFoodByBarcodeFunc, searchFoodsByNameFunc, upsertRecipeCacheFunc
+	t.Cleanup(func() {
+		getFoodByIDFunc, getFoodByBarcodeFunc, searchFoodsByNameFunc, upsertRecipeCacheFunc = origByID, origByBarcode, origSearch, origUpsert
+	})
+
+	getFoodByIDFunc = func(_ context.Context, _ *pgxpool.Pool, id string) (barcode.FoodRow, bool, error) {
+		for _, food := range testFoods {
+			if food.ID == id {
+				return food, true, nil
+			}
+		}
+		return barcode.FoodRow{}, false, nil
+	}
+	getFoodByBarcodeFunc = func(_ context.Context, _ *pgxpool.Pool, code string) (barcode.FoodRow, bool, error) {
+		if code == "0012345678905" { // UPC-A input normalized to EAN-13
+			return testFoods[2], true, nil
+		}
+		return barcode.FoodRow{}, false, nil
+	}
+	searchFoodsByNameFunc = func(context.Context, *pgxpool.Pool, []string) ([]barcode.FoodRow, error) {
+		return testFoods, nil // let matchConfidence pick
+	}
+
+	stored := &Analysis{}
+	upsertRecipeCacheFunc = func(_ context.Context, _ *pgxpool.Pool, analysis Analysis) error {
+		*stored = analysis
+		return nil
+	}
+	return stored
+}
+
+func postAnalyze(t *testing.T, body any) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		c.Next()
+	})
+	router.POST("/v1/recipes/analyze", NewAnalyzeHandler())
+
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/v1/recipes/analyze", bytes.NewReader(payload))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAnalyzeHandler_TotalsAndCache(t *testing.T) {
+	stored := setupStoreStubs(t)
+
+	rec := postAnalyze(t, AnalyzeRequest{
+		RecipeID: "recipe_1",
+		Servings: 2,
+		Ingredients: []IngredientInput{
+			{Text: "1 cup rolled oats"},                     // 236.59 ml * 0.34 = 80.44 g
+			{FoodItemID: "milk", Quantity: 200, Unit: "ml"}, // 200 ml * 1.03 = 206 g
+			{Barcode: "012345678905"},                       // one 50 g serving
+			{Text: "2 tbsp unicorn dust"},                   // nothing close
+		},
+	})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var analysis Analysis
+	if err := json.Unmarshal(rec.Body.Bytes(), &analysis); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if analysis.UnresolvedCount != 1 || analysis.Ingredients[3].Status != statusUnmatched {
+		t.Fatalf("expected the last line unmatched, got %+v", analysis.Ingredients[3])
+	}
+	if analysis.Ingredients[0].FoodItemID != "oats" || analysis.Ingredients[0].Grams != 80.4 {
+		t.Fatalf("unexpected oats line: %+v", analysis.Ingredients[0])
+	}
+	if analysis.TotalWeightG != 336.4 {
+		t.Fatalf("expected 336.4 g total, got %v", analysis.TotalWeightG)
+	}
+	// 80.44 g oats (304.87) + 206 g milk (125.66) + 50 g bar (200) = 630.53 kcal
+	if analysis.Totals.CaloriesKcal != 630.53 || analysis.PerServing.CaloriesKcal != 315.27 {
+		t.Fatalf("unexpected calories: total=%v per_serving=%v", analysis.Totals.CaloriesKcal, analysis.PerServing.CaloriesKcal)
+	}
+	if analysis.Totals.FiberG == nil || *analysis.Totals.FiberG != 8.04 {
+		t.Fatalf("expected fiber from oats only, got %v", analysis.Totals.FiberG)
+	}
+	if !analysis.Cached || stored.RecipeID != "recipe_1" || stored.RecipeSlug != "recipe_1" {
+		t.Fatalf("expected result stored in recipe_cache, got cached=%t stored=%+v", analysis.Cached, stored.RecipeID)
+	}
+}
+
+func TestAnalyzeHandler_UnmatchedSuggestion(t *testing.T) {
+	setupStoreStubs(t)
+
+	rec := postAnalyze(t, AnalyzeRequest{Ingredients: []IngredientInput{{Text: "1 cup oat milk"}}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var analysis Analysis
+	if err := json.Unmarshal(rec.Body.Bytes(), &analysis); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	line := analysis.Ingredients[0]
+	if line.Status != statusUnmatched || line.Suggestion == nil || line.Confidence >= minMatchConfidence {
+		t.Fatalf("expected unmatched line with suggestion, got %+v", line)
+	}
+	if analysis.Cached {
+		t.Fatalf("expected no cache write without recipe_id")
+	}
+}
+
+func TestAnalyzeHandler_InvalidRequest(t *testing.T) {
+	setupStoreStubs(t)
+
+	for _, body := range []AnalyzeRequest{
+		{},
+		{Ingredients: []IngredientInput{{}}},
+		{Ingredients: []IngredientInput{{Barcode: "12345678", FoodItemID: "oats"}}},
+		{Servings: -1, Ingredients: []IngredientInput{{Text: "1 egg"}}},
+	} {
+		if rec := postAnalyze(t, body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %+v, got %d", body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package recipe
+
+import (
+	"strings"
+	"unicode"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// minMatchConfidence is the score a name match needs to count as resolved.
+// Below it the line comes back unresolved with the best candidate as a suggestion.
+const minMatchConfidence = 0.6
+
+// stopWords are dropped from ingredient names before matching ("fresh chopped parsley" -> "parsley").
+var stopWords = map[string]bool{
+	"of": true, "a": true, "an": true, "the": true, "and": true, "to": true, "taste": true,
+	"fresh": true, "chopped": true, "diced": true, "sliced": true, "minced": true, "grated": true,
+	"large": true, "medium": true, "small": true, "about": true, "for": true, "optional": true,
+}
+
+// nameTokens normalizes a name into comparable tokens.
+// Example: "Rolled Oats (Organic)" -> ["rolled", "oat", "organic"]
+func nameTokens(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if stopWords[field] {
+			continue
+		}
+		tokens = append(tokens, singular(field))
+	}
+	return tokens
+}
+
+// singular strips simple English plurals so "eggs" matches "egg" and "tomatoes" matches "tomato".
+func singular(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "oes"):
+		return strings.TrimSuffix(word, "es")
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return strings.TrimSuffix(word, "s")
+	default:
+		return word
+	}
+}
+
+// matchConfidence scores how well a food_items name matches an ingredient name (0..1).
+// Recall (how many ingredient words the candidate has) matters most; precision
+// (how few extra words it has) breaks ties between "Oats" and "Oat Milk Chocolate Bar".
+// Example: "rolled oats" vs "Rolled Oats" -> 1.0, vs "Oat Milk" -> 0.5
+func matchConfidence(query, candidate []string) float64 {
+	if len(query) == 0 || len(candidate) == 0 {
+		return 0
+	}
+
+	candidateSet := make(map[string]bool, len(candidate))
+	for _, token := range candidate {
+		candidateSet[token] = true
+	}
+
+	matched := 0
+	for _, token := range query {
+		if candidateSet[token] {
+			matched++
+		}
+	}
+
+	recall := float64(matched) / float64(len(query))
+	precision := float64(matched) / float64(len(candidate))
+	return roundTo(0.7*recall+0.3*precision, 2)
+}
+
+// bestMatch picks the highest-confidence candidate (earlier candidates win ties; the
+// store returns verified rows first).
+func bestMatch(name string, candidates []barcode.FoodRow) (barcode.FoodRow, float64, bool) {
+	query := nameTokens(name)
+
+	var (
+		best      barcode.FoodRow
+		bestScore float64
+		found     bool
+	)
+	for _, candidate := range candidates {
+		score := matchConfidence(query, nameTokens(candidate.Name))
+		if score > bestScore {
+			best, bestScore, found = candidate, score, true
+		}
+	}
+	return best, bestScore, found
+}
@@ -0,0 +1,178 @@
+package recipe
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParsedLine is a free-text ingredient line split into quantity, unit, and food name.
+// Example: "1 1/2 cups rolled oats, toasted" -> {Quantity: 1.5, Unit: "cup", Name: "rolled oats"}
+type ParsedLine struct {
+	Quantity float64 // 0 when the line has no leading amount ("salt to taste")
+	Unit     string  // canonical unit ("g", "cup", ...) or "" for counts ("3 eggs")
+	Name     string  // what to look up in food_items
+}
+
+var (
+	// "200g", "1.5kg", "12oz": amount glued to the unit.
+	attachedUnitRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([a-z]+)$`)
+	// "1/2", "3/4"
+	fractionRegex = regexp.MustCompile(`^([0-9]+)/([0-9]+)$`)
+	// "(optional)", "(about 200 g)": notes we drop before parsing.
+	parentheticalRegex = regexp.MustCompile(`\([^)]*\)`)
+)
+
+// unicodeFractions maps vulgar fraction characters to values ("½ cup milk").
+var unicodeFractions = map[rune]float64{
+	'½': 0.5, '⅓': 1.0 / 3, '⅔': 2.0 / 3, '¼': 0.25, '¾': 0.75, '⅛': 0.125, '⅜': 0.375, '⅝': 0.625, '⅞': 0.875,
+}
+
+// wordQuantities covers amounts written as words ("a cup of milk", "two eggs").
+var wordQuantities = map[string]float64{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "twelve": 12, "dozen": 12, "half": 0.5,
+}
+
+// unitAliases maps spellings to canonical units (see units.go for conversions).
+var unitAliases = map[string]string{
+	"g": "g", "gram": "g", "grams": "g", "gr": "g",
+	"kg": "kg", "kilogram": "kg", "kilograms": "kg",
+	"mg": "mg", "milligram": "mg", "milligrams": "mg",
+	"oz": "oz", "ounce": "oz", "ounces": "oz",
+	"lb": "lb", "lbs": "lb", "pound": "lb", "pounds": "lb",
+	"ml": "ml", "milliliter": "ml", "milliliters": "ml", "millilitre": "ml", "millilitres": "ml",
+	"l": "l", "liter": "l", "liters": "l", "litre": "l", "litres": "l",
+	"cup": "cup", "cups": "cup", "c": "cup",
+	"tbsp": "tbsp", "tbs": "tbsp", "tablespoon": "tbsp", "tablespoons": "tbsp",
+	"tsp": "tsp", "teaspoon": "tsp", "teaspoons": "tsp",
+	"floz": "floz", "pint": "pint", "pints": "pint", "quart": "quart", "quarts": "quart",
+	"pinch": "pinch", "pinches": "pinch", "dash": "pinch",
+	// Count-like units resolve to one serving of the food each.
+	"piece": "", "pieces": "", "whole": "", "clove": "", "cloves": "", "slice": "", "slices": "",
+	"large": "", "medium": "", "small": "",
+}
+
+// parseIngredientLine splits a free-text ingredient line.
+// Steps: drop notes ("(optional)", ", chopped") -> read the amount -> read the unit -> rest is the name.
+// Examples:
+//   - "2 cups rolled oats"       -> 2, cup, "rolled oats"
+//   - "200g chicken breast"      -> 200, g, "chicken breast"
+//   - "½ cup milk"               -> 0.5, cup, "milk"
+//   - "3 large eggs"             -> 3, "", "eggs"
+//   - "salt to taste"            -> 0, "", "salt to taste"
+func parseIngredientLine(line string) ParsedLine {
+	text := strings.ToLower(strings.TrimSpace(line))
+	text = parentheticalRegex.ReplaceAllString(text, " ")
+	if comma := strings.Index(text, ","); comma > 0 { // "onion, diced" -> "onion"
+		text = text[:comma]
+	}
+	text = expandUnicodeFractions(text)
+
+	tokens := strings.Fields(text)
+	quantity, unit, hasUnit, used := parseQuantity(tokens)
+	tokens = tokens[used:]
+
+	if quantity > 0 && !hasUnit && len(tokens) > 0 {
+		if canonical, ok := lookupUnit(tokens[0]); ok {
+			unit = canonical
+			tokens = tokens[1:]
+		} else if len(tokens) > 1 && tokens[0] == "fl" && strings.TrimSuffix(tokens[1], ".") == "oz" { // "1 fl oz"
+			unit = "floz"
+			tokens = tokens[2:]
+		}
+	}
+
+	if len(tokens) > 0 && tokens[0] == "of" { // "2 cups of flour"
+		tokens = tokens[1:]
+	}
+
+	return ParsedLine{Quantity: quantity, Unit: unit, Name: strings.Join(tokens, " ")}
+}
+
+// parseQuantity reads the leading amount and returns it with the number of tokens consumed.
+// Handles "2", "1.5", "1/2", "1 1/2", "a", "two", and "200g" (amount glued to a unit,
+// returned with hasUnit=true so the caller doesn't look for a separate unit token).
+func parseQuantity(tokens []string) (quantity float64, unit string, hasUnit bool, used int) {
+	if len(tokens) == 0 {
+		return 0, "", false, 0
+	}
+
+	first := tokens[0]
+	if matches := attachedUnitRegex.FindStringSubmatch(first); matches != nil {
+		if canonical, ok := lookupUnit(matches[2]); ok {
+			value, _ := strconv.ParseFloat(matches[1], 64)
+			return value, canonical, true, 1
+		}
+	}
+
+	value, ok := parseNumber(first)
+	if !ok {
+		if word, found := wordQuantities[first]; found {
+			return word, "", false, 1
+		}
+		return 0, "", false, 0
+	}
+
+	// Mixed number: "1 1/2"
+	if len(tokens) > 1 && fractionRegex.MatchString(tokens[1]) {
+		if fraction, ok := parseNumber(tokens[1]); ok {
+			return value + fraction, "", false, 2
+		}
+	}
+	return value, "", false, 1
+}
+
+// parseNumber parses "2", "1.5", "1/2", or "1-2" (ranges use the midpoint).
+func parseNumber(token string) (float64, bool) {
+	if matches := fractionRegex.FindStringSubmatch(token); matches != nil {
+		numerator, _ := strconv.ParseFloat(matches[1], 64)
+		denominator, _ := strconv.ParseFloat(matches[2], 64)
+		if denominator == 0 {
+			return 0, false
+		}
+		return numerator / denominator, true
+	}
+	if low, high, found := strings.Cut(token, "-"); found {
+		lowValue, errLow := strconv.ParseFloat(low, 64)
+		highValue, errHigh := strconv.ParseFloat(high, 64)
+		if errLow == nil && errHigh == nil {
+			return (lowValue + highValue) / 2, true
+		}
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(token, 64)
+	if err != nil || value < 0 {
+		return 0, false
+	}
+	return value, true
+}
+
+// expandUnicodeFractions turns "1½ cups" / "½ cup" into "1.5 cups" / "0.5 cup" for parseQuantity.
+func expandUnicodeFractions(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		value, ok := unicodeFractions[r]
+		if !ok {
+			builder.WriteRune(r)
+			continue
+		}
+		builder.WriteString(" " + strconv.FormatFloat(value, 'f', -1, 64) + " ")
+	}
+	// "1 0.5 cup" -> "1.5 cup": merge a whole number followed by a decimal fraction.
+	tokens := strings.Fields(builder.String())
+	if len(tokens) > 1 {
+		whole, errWhole := strconv.Atoi(tokens[0])
+		fraction, errFraction := strconv.ParseFloat(tokens[1], 64)
+		if errWhole == nil && errFraction == nil && fraction < 1 {
+			tokens = append([]string{strconv.FormatFloat(float64(whole)+fraction, 'f', -1, 64)}, tokens[2:]...)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// lookupUnit maps a token ("Tbsp.", "cups") to a canonical unit.
+func lookupUnit(token string) (string, bool) {
+	canonical, ok := unitAliases[strings.TrimSuffix(token, ".")]
+	return canonical, ok
+}
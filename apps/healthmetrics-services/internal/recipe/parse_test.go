@@ -0,0 +1,72 @@
+package recipe
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseIngredientLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want ParsedLine
+	}{
+		{"2 cups rolled oats", ParsedLine{Quantity: 2, Unit: "cup", Name: "rolled oats"}},
+		{"200g chicken breast, diced", ParsedLine{Quantity: 200, Unit: "g", Name: "chicken breast"}},
+		{"1 1/2 Tbsp. olive oil", ParsedLine{Quantity: 1.5, Unit: "tbsp", Name: "olive oil"}},
+		{"½ cup milk", ParsedLine{Quantity: 0.5, Unit: "cup", Name: "milk"}},
+		{"1½ cups of flour (sifted)", ParsedLine{Quantity: 1.5, Unit: "cup", Name: "flour"}},
+		{"3 large eggs", ParsedLine{Quantity: 3, Unit: "", Name: "eggs"}},
+		{"a pinch of salt", ParsedLine{Quantity: 1, Unit: "pinch", Name: "salt"}},
+		{"2-3 tsp honey", ParsedLine{Quantity: 2.5, Unit: "tsp", Name: "honey"}},
+		{"4 fl oz cream", ParsedLine{Quantity: 4, Unit: "floz", Name: "cream"}},
+		{"salt to taste", ParsedLine{Quantity: 0, Unit: "", Name: "salt to taste"}},
+	}
+
+	for _, tc := range cases {
+		got := parseIngredientLine(tc.line)
+		if math.Abs(got.Quantity-tc.want.Quantity) > 1e-9 || got.Unit != tc.want.Unit || got.Name != tc.want.Name {
+			t.Fatalf("%q: expected %+v, got %+v", tc.line, tc.want, got)
+		}
+	}
+}
+
+func TestToGrams(t *testing.T) {
+	cases := []struct {
+		quantity   float64
+		unit       string
+		name       string
+		serving    float64
+		grams      float64
+		conversion string
+	}{
+		{2, "cup", "rolled oats", 40, 160.88, conversionDensity},
+		{8, "oz", "chicken", 100, 226.796185, conversionMass},
+		{3, "", "egg", 50, 150, conversionServing},
+		{100, "ml", "broth", 240, 100, conversionWaterAssume},
+		{1, "tbsp", "boiled potatoes", 100, 14.786765, conversionWaterAssume}, // "oil" must not match "boiled"
+	}
+
+	for _, tc := range cases {
+		grams, conversion, ok := toGrams(tc.quantity, tc.unit, tc.name, tc.serving)
+		if !ok || math.Abs(grams-tc.grams) > 0.001 || conversion != tc.conversion {
+			t.Fatalf("%v %s %s: expected %v g (%s), got %v g (%s, ok=%t)", tc.quantity, tc.unit, tc.name, tc.grams, tc.conversion, grams, conversion, ok)
+		}
+	}
+
+	if _, _, ok := toGrams(0, "", "salt", 100); ok {
+		t.Fatalf("expected no conversion without an amount")
+	}
+}
+
+func TestMatchConfidence(t *testing.T) {
+	query := nameTokens("rolled oats")
+	if got := matchConfidence(query, nameTokens("Rolled Oats")); got != 1 {
+		t.Fatalf("expected exact match 1.0, got %v", got)
+	}
+	if got := matchConfidence(query, nameTokens("Oat Milk")); got >= minMatchConfidence {
+		t.Fatalf("expected weak match below threshold, got %v", got)
+	}
+	if got := matchConfidence(nameTokens("eggs"), nameTokens("Egg")); got != 1 {
+		t.Fatalf("expected plural to match singular, got %v", got)
+	}
+}
@@ -0,0 +1,137 @@
+package recipe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/barcode"
+)
+
+// maxNameCandidates caps how many food_items rows we score per free-text line.
+const maxNameCandidates = 50
+
+// Allow tests to swap DB helpers without changing production logic.
+var (
+	getFoodByIDFunc       = barcode.GetFoodRowByID      // default: real DB fetch
+	getFoodByBarcodeFunc  = barcode.GetFoodRowByBarcode // default: real DB fetch
+	searchFoodsByNameFunc = searchFoodsByName           // default: real DB fetch
+	upsertRecipeCacheFunc = upsertRecipeCache           // default: real DB write
+)
+
+// searchFoodsByName returns food_items whose name contains any of the tokens.
+// Scoring happens in Go (matchConfidence); verified rows come first so they win ties.
+func searchFoodsByName(ctx context.Context, pool *pgxpool.Pool, tokens []string) ([]barcode.FoodRow, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	patterns := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		// Escape LIKE wildcards; tokens are letters/digits already, but keep it safe.
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(token)
+		patterns = append(patterns, "%"+escaped+"%")
+	}
+
+	query := `SELECT ` + barcode.FoodRowColumns + `
+		FROM food_items
+		WHERE name ILIKE ANY($1)
+		ORDER BY verified DESC, length(name) ASC
+		LIMIT $2`
+
+	rows, err := pool.Query(ctx, query, patterns, maxNameCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("query food_items: %w", err)
+	}
+	defer rows.Close()
+
+	var foods []barcode.FoodRow
+	for rows.Next() {
+		food, err := barcode.ScanFoodRow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan food_items: %w", err)
+		}
+		foods = append(foods, food)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("iterate food_items: %w", rows.Err())
+	}
+	return foods, nil
+}
+
+// upsertRecipeCache stores the per-serving nutrition for a cookbook recipe.
+// recipe_cache holds per-serving macros (+ total calories); the full breakdown goes to ingredients_breakdown.
+func upsertRecipeCache(ctx context.Context, pool *pgxpool.Pool, analysis Analysis) error {
+	breakdown, err := json.Marshal(analysis.Ingredients)
+	if err != nil {
+		return fmt.Errorf("encode ingredients_breakdown: %w", err)
+	}
+
+	// recipe_cache stores sodium in mg (like food_items).
+	var sodiumMg *float64
+	if analysis.PerServing.SodiumG != nil {
+		value := *analysis.PerServing.SodiumG * 1000
+		sodiumMg = &value
+	}
+
+	// id/updated_at have Prisma-side defaults only, so set them here.
+	const query = `
+		INSERT INTO recipe_cache (
+			id,
+			recipe_id,
+			recipe_slug,
+			servings,
+			total_calories,
+			calories_per_serving,
+			protein_g,
+			carbs_g,
+			fat_g,
+			fiber_g,
+			sugar_g,
+			sodium_mg,
+			ingredients_breakdown,
+			last_synced_at,
+			updated_at
+		) VALUES (
+			gen_random_uuid()::text, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, now(), now()
+		)
+		ON CONFLICT (recipe_id) DO UPDATE SET
+			recipe_slug = EXCLUDED.recipe_slug,
+			servings = EXCLUDED.servings,
+			total_calories = EXCLUDED.total_calories,
+			calories_per_serving = EXCLUDED.calories_per_serving,
+			protein_g = EXCLUDED.protein_g,
+			carbs_g = EXCLUDED.carbs_g,
+			fat_g = EXCLUDED.fat_g,
+			fiber_g = EXCLUDED.fiber_g,
+			sugar_g = EXCLUDED.sugar_g,
+			sodium_mg = EXCLUDED.sodium_mg,
+			ingredients_breakdown = EXCLUDED.ingredients_breakdown,
+			last_synced_at = now(),
+			updated_at = now()
+	`
+
+	_, err = pool.Exec(
+		ctx,
+		query,
+		analysis.RecipeID,
+		analysis.RecipeSlug,
+		analysis.Servings,
+		analysis.Totals.CaloriesKcal,
+		analysis.PerServing.CaloriesKcal,
+		analysis.PerServing.ProteinG,
+		analysis.PerServing.CarbsG,
+		analysis.PerServing.FatG,
+		analysis.PerServing.FiberG,
+		analysis.PerServing.SugarG,
+		sodiumMg,
+		breakdown,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert recipe_cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package recipe
+
+import (
+	"strings"
+
+	"healthmetrics-services/internal/units"
+)
+
+// How an ingredient amount was turned into grams (reported per ingredient).
+const (
+	conversionMass        = "mass"           // g/kg/oz/lb: exact
+	conversionDensity     = "volume_density" // cups/tbsp/ml with a known density for the food
+	conversionWaterAssume = "volume_water"   // cups/tbsp/ml, density unknown: assumed 1 g/ml
+	conversionServing     = "serving_size"   // counts ("3 eggs"): food_items.serving_size_g each
+)
+
+// gramsPerUnit converts mass units to grams, using the same exact factors as the
+// barcode/diary display units (internal/units), so a recipe's "8 oz" matches a logged 8 oz.
+var gramsPerUnit = map[string]float64{
+	"g":  1,
+	"kg": 1000,
+	"mg": 0.001,
+	"oz": units.GramsPerOunce,
+	"lb": units.KilogramsPerPound * 1000, // 16 oz
+}
+
+// millilitersPerUnit converts volume units to ml (US customary, built from the exact fl oz).
+var millilitersPerUnit = map[string]float64{
+	"ml":    1,
+	"l":     1000,
+	"cup":   8 * units.MillilitersPerFluidOunce,
+	"tbsp":  units.MillilitersPerFluidOunce / 2,
+	"tsp":   units.MillilitersPerFluidOunce / 6,
+	"floz":  units.MillilitersPerFluidOunce,
+	"pint":  16 * units.MillilitersPerFluidOunce,
+	"quart": 32 * units.MillilitersPerFluidOunce,
+	"pinch": units.MillilitersPerFluidOunce / 96, // 1/16 tsp
+}
+
+// densities are g/ml for common dry/wet ingredients, matched by keyword in the food name.
+// Example: 1 cup rolled oats = 236.6 ml * 0.34 = ~80 g (a cup of water would be 236.6 g).
+// Order matters: more specific keywords first ("brown sugar" before "sugar").
+var densities = []struct {
+	keyword string
+	gPerMl  float64
+}{
+	{"peanut butter", 1.08},
+	{"brown sugar", 0.93},
+	{"powdered sugar", 0.5},
+	{"maple syrup", 1.32},
+	{"oat", 0.34},
+	{"flour", 0.53},
+	{"sugar", 0.85},
+	{"rice", 0.79},
+	{"oil", 0.92},
+	{"butter", 0.96},
+	{"honey", 1.42},
+	{"milk", 1.03},
+	{"yogurt", 1.03},
+	{"cream", 1.0},
+	{"cocoa", 0.42},
+	{"salt", 1.2},
+	{"almond", 0.6},
+	{"cheese", 0.47},
+	{"water", 1.0},
+}
+
+// toGrams converts an amount of a food to grams.
+// servingSizeG is food_items.serving_size_g, used for counts ("2 eggs").
+// Returns ok=false when there is no amount ("salt to taste") or the unit can't be converted.
+// Examples:
+//   - (2, "cup", "rolled oats", 40)  -> 160.9 g, volume_density
+//   - (200, "g", "chicken", 100)     -> 200 g, mass
+//   - (3, "", "egg", 50)             -> 150 g, serving_size
+func toGrams(quantity float64, unit, name string, servingSizeG float64) (float64, string, bool) {
+	if quantity <= 0 { // "salt to taste": guessing would skew the totals
+		return 0, "", false
+	}
+
+	if factor, ok := gramsPerUnit[unit]; ok {
+		return quantity * factor, conversionMass, true
+	}
+
+	if factor, ok := millilitersPerUnit[unit]; ok {
+		ml := quantity * factor
+		if density, found := densityFor(name); found {
+			return ml * density, conversionDensity, true
+		}
+		return ml, conversionWaterAssume, true
+	}
+
+	if unit == "" && servingSizeG > 0 { // counts: "3 eggs" -> 3 servings
+		return quantity * servingSizeG, conversionServing, true
+	}
+
+	return 0, "", false
+}
+
+// densityFor finds the density for a food name by keyword at a word start
+// ("rolled oats" matches "oat"; "boiled potatoes" does not match "oil").
+func densityFor(name string) (float64, bool) {
+	lower := " " + strings.ToLower(name)
+	for _, entry := range densities {
+		if strings.Contains(lower, " "+entry.keyword) {
+			return entry.gPerMl, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,235 @@
+// Package retry is the shared retry policy for outbound HTTP clients (OpenFoodFacts, WHOOP).
+// It retries only transient failures, backs off with full jitter, honors Retry-After,
+// stops when the caller's context is done, and caps the total time spent across attempts.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Policy describes how an operation is retried.
+// Example with MaxAttempts=3, BaseDelay=200ms, MaxDelay=2s, Budget=5s:
+// attempt 1 fails (503) -> wait rand(0..200ms)
+// attempt 2 fails (429, Retry-After: 1) -> wait 1s
+// attempt 3 fails -> return the last error
+// Any wait that would end past the 5s budget stops retrying early.
+type Policy struct {
+	MaxAttempts int           // total attempts including the first call
+	BaseDelay   time.Duration // backoff ceiling for the first retry
+	MaxDelay    time.Duration // cap for the exponential backoff ceiling
+	Budget      time.Duration // total time for all attempts + waits (0 = no budget)
+
+	// Classify reports whether err is worth retrying (nil -> IsTransient).
+	Classify func(error) bool
+
+	random func() float64                                   // swapped in tests (jitter source)
+	sleep  func(ctx context.Context, d time.Duration) error // swapped in tests
+}
+
+// withDefaults fills missing values so a zero Policy means "one attempt, no retries".
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts < 1 { // ensure we always try at least once
+		p.MaxAttempts = 1
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 200 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 2 * time.Second
+	}
+	if p.Classify == nil {
+		p.Classify = IsTransient
+	}
+	if p.random == nil {
+		p.random = rand.Float64
+	}
+	if p.sleep == nil {
+		p.sleep = sleepContext
+	}
+	return p
+}
+
+// Do runs fn until it succeeds, returns a non-transient error, or the policy gives up.
+// fn receives a context bounded by the budget so in-flight requests stop with it.
+// When the caller's context ends, Do returns ctx.Err() (wrapping the last upstream error).
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	p = p.withDefaults()
+
+	// The budget bounds the whole loop, including the request in flight.
+	attemptCtx := ctx
+	if p.Budget > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, p.Budget)
+		defer cancel()
+	}
+	deadline, hasDeadline := attemptCtx.Deadline()
+
+	var lastErr error // keep the last error so we can return it after retries
+	for attempt := 1; attempt <= p.MaxAttempts; attempt++ {
+		err := fn(attemptCtx)
+		if err == nil { // success path
+			return nil
+		}
+		lastErr = err
+
+		// The caller gave up (client disconnected, shutdown): don't keep trying.
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+		}
+		if !p.Classify(err) || attempt == p.MaxAttempts { // permanent error or no retries left
+			break
+		}
+
+		delay := p.delay(attempt, err)
+		// Don't start a wait we know will blow the budget; return the real error instead.
+		if hasDeadline && time.Until(deadline) < delay {
+			break
+		}
+		if err := p.sleep(attemptCtx, delay); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			}
+			break // budget ran out while waiting
+		}
+	}
+
+	return lastErr // return the final error if all retries fail
+}
+
+// delay picks the wait before the next attempt.
+// Server hints win (Retry-After); otherwise full jitter over the exponential ceiling:
+// attempt=1 -> rand(0..base), attempt=2 -> rand(0..base*2), ... capped at MaxDelay.
+func (p Policy) delay(attempt int, err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return httpErr.RetryAfter
+	}
+
+	ceiling := p.BaseDelay
+	for i := 1; i < attempt; i++ { // double per attempt
+		if ceiling >= p.MaxDelay/2 { // avoid overflow and cap cleanly
+			ceiling = p.MaxDelay
+			break
+		}
+		ceiling *= 2
+	}
+	if ceiling > p.MaxDelay { // final safety cap
+		ceiling = p.MaxDelay
+	}
+	return time.Duration(p.random() * float64(ceiling))
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// HTTPError is a non-2xx upstream response, kept typed so callers can classify it.
+type HTTPError struct {
+	Service    string        // "openfoodfacts", "whoop" (used in the message)
+	StatusCode int           // HTTP status code
+	Status     string        // full status line ("503 Service Unavailable")
+	RetryAfter time.Duration // parsed Retry-After (0 when absent/invalid)
+}
+
+// NewHTTPError builds an HTTPError from a response, parsing Retry-After.
+func NewHTTPError(service string, resp *http.Response) *HTTPError {
+	return &HTTPError{
+		Service:    service,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s api error: %s", e.Service, e.Status)
+}
+
+// ParseRetryAfter reads a Retry-After header (delta-seconds or HTTP-date).
+// Example: "2" -> 2s, "Wed, 21 Oct 2026 07:28:00 GMT" -> time until then.
+// Returns 0 for empty, invalid, or past values.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// IsTransient reports whether err is a failure that may succeed on retry.
+// Transient: timeouts, connection resets/refusals, truncated responses, 408/425/429/5xx (except 501).
+// Not transient: context cancellation, 4xx, decode errors, and anything unrecognized.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+			return true
+		case http.StatusNotImplemented:
+			return false
+		}
+		return httpErr.StatusCode >= 500
+	}
+
+	// Client timeouts are transient even though they wrap context.DeadlineExceeded.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) { // connection dropped mid-response
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) { // dial/read/write failures below HTTP
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	return false
+}
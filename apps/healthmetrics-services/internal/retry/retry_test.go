@@ -0,0 +1,167 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testPolicy records waits instead of sleeping and uses the full jitter ceiling.
+func testPolicy(attempts int, waits *[]time.Duration) Policy {
+	return Policy{
+		MaxAttempts: attempts,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    250 * time.Millisecond,
+		random:      func() float64 { return 1 }, // top of the jitter range
+		sleep: func(ctx context.Context, d time.Duration) error {
+			*waits = append(*waits, d)
+			return ctx.Err()
+		},
+	}
+}
+
+func unavailable() error {
+	return &HTTPError{Service: "test", StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+}
+
+func TestDo_BacksOffWithCap(t *testing.T) {
+	var waits []time.Duration
+	calls := 0
+	err := testPolicy(4, &waits).Do(context.Background(), func(context.Context) error {
+		calls++
+		return unavailable()
+	})
+
+	if err == nil || calls != 4 {
+		t.Fatalf("expected 4 failed attempts, got calls=%d err=%v", calls, err)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond}
+	if len(waits) != len(want) {
+		t.Fatalf("expected waits %v, got %v", want, waits)
+	}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Fatalf("expected waits %v, got %v", want, waits)
+		}
+	}
+}
+
+func TestDo_JitterStaysBelowCeiling(t *testing.T) {
+	var waits []time.Duration
+	policy := testPolicy(2, &waits)
+	policy.random = func() float64 { return 0.5 }
+
+	_ = policy.Do(context.Background(), func(context.Context) error { return unavailable() })
+	if len(waits) != 1 || waits[0] != 50*time.Millisecond {
+		t.Fatalf("expected one 50ms wait, got %v", waits)
+	}
+}
+
+func TestDo_HonorsRetryAfter(t *testing.T) {
+	var waits []time.Duration
+	calls := 0
+	err := testPolicy(2, &waits).Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls == 1 {
+			return &HTTPError{Service: "test", StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests", RetryAfter: 2 * time.Second}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if len(waits) != 1 || waits[0] != 2*time.Second {
+		t.Fatalf("expected Retry-After wait of 2s, got %v", waits)
+	}
+}
+
+func TestDo_PermanentErrorNotRetried(t *testing.T) {
+	var waits []time.Duration
+	calls := 0
+	err := testPolicy(3, &waits).Do(context.Background(), func(context.Context) error {
+		calls++
+		return &HTTPError{Service: "test", StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}
+	})
+
+	if err == nil || calls != 1 || len(waits) != 0 {
+		t.Fatalf("expected a single attempt, got calls=%d waits=%v err=%v", calls, waits, err)
+	}
+}
+
+func TestDo_BudgetStopsBeforeLongWait(t *testing.T) {
+	var waits []time.Duration
+	policy := testPolicy(3, &waits)
+	policy.Budget = time.Second
+	calls := 0
+
+	err := policy.Do(context.Background(), func(context.Context) error {
+		calls++
+		// Retry-After far beyond the budget: give up now instead of waiting.
+		return &HTTPError{Service: "test", StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", RetryAfter: time.Minute}
+	})
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected the upstream error, got %v", err)
+	}
+	if calls != 1 || len(waits) != 0 {
+		t.Fatalf("expected no wait past the budget, got calls=%d waits=%v", calls, waits)
+	}
+}
+
+func TestDo_CancelledContextStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	err := Policy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}.Do(ctx, func(context.Context) error {
+		calls++
+		cancel() // the caller goes away during the first attempt
+		return unavailable()
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	if got := ParseRetryAfter("5", now); got != 5*time.Second {
+		t.Fatalf("expected 5s, got %s", got)
+	}
+	if got := ParseRetryAfter("Sun, 18 Oct 2026 12:00:30 GMT", now); got != 30*time.Second {
+		t.Fatalf("expected 30s, got %s", got)
+	}
+	for _, value := range []string{"", "soon", "-1", "Sun, 18 Oct 2026 11:00:00 GMT"} {
+		if got := ParseRetryAfter(value, now); got != 0 {
+			t.Fatalf("expected 0 for %q, got %s", value, got)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"503", unavailable(), true},
+		{"429", &HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{"501", &HTTPError{StatusCode: http.StatusNotImplemented}, false},
+		{"404", &HTTPError{StatusCode: http.StatusNotFound}, false},
+		{"canceled", context.Canceled, false},
+		{"unknown", errors.New("boom"), false},
+	}
+	for _, tc := range cases {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Fatalf("%s: expected %t, got %t", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+// Package scanner decodes 1D retail barcodes (EAN-13, EAN-8, UPC-A, UPC-E, ITF-14)
+// from photos, using only the standard library.
+//
+// How it works (the same idea as a laser scanner, just in software):
+//  1. Convert the photo to grayscale (downscaled so big phone photos stay fast).
+//  2. Cast many scanlines across the image at several angles (handles rotation).
+//  3. For each line, find dark/light edges with sub-pixel precision (handles some blur).
+//  4. Turn the edges into bar/space widths and try every symbology on them.
+//  5. Every decoded value must pass its check digit; values are then voted across lines.
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/jpeg" // register JPEG decoding for image.Decode
+	_ "image/png"  // register PNG decoding for image.Decode
+	"io"
+	"math"
+	"sort"
+)
+
+// Symbology names returned in Result (snake_case to match the API).
+const (
+	SymbologyEAN13 = "ean_13"
+	SymbologyEAN8  = "ean_8"
+	SymbologyUPCA  = "upc_a"
+	SymbologyUPCE  = "upc_e"
+	SymbologyITF   = "itf"
+)
+
+const (
+	maxDimension  = 1600 // downscale photos so the longest side is at most this many pixels
+	maxPixels     = 40_000_000
+	sampleStep    = 0.5 // sample every half pixel along a scanline (narrow bars can be ~1.5px)
+	minContrast   = 30  // minimum dark/light difference (0..255) before a line is worth decoding
+	thresholdBlk  = 48  // samples per block for the local threshold (~24px)
+	angleStepDeg  = 10  // scan every 10 degrees (max misalignment 5 degrees, harmless for tall bars)
+	minLineLength = 60  // skip scanlines that only clip a corner of the image
+	confidentHits = 3   // stop early once one value was read this many times
+)
+
+var (
+	// ErrNotFound means no scanline produced a value with a valid check digit.
+	ErrNotFound = errors.New("no barcode found in image")
+	// ErrUnsupportedImage means the upload is not a JPEG/PNG we can decode.
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	// ErrImageTooLarge means the decoded image would be unreasonably big (decompression bomb guard).
+	ErrImageTooLarge = errors.New("image dimensions too large")
+)
+
+// Result is one decoded barcode.
+// Text is the value printed under the bars; Code is the form used for product lookups
+// (UPC-E is expanded to UPC-A, ITF-14 with a leading 0 becomes its EAN-13).
+type Result struct {
+	Symbology string `json:"symbology"`
+	Text      string `json:"text"`
+	Code      string `json:"code"`
+}
+
+// DecodeBytes decodes a JPEG/PNG payload and scans it for a barcode.
+func DecodeBytes(data []byte) (Result, error) {
+	// Check the header first so a tiny file claiming 100k x 100k pixels never gets allocated.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return Result{}, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, ErrUnsupportedImage
+	}
+	return Decode(img)
+}
+
+// DecodeReader is DecodeBytes for a stream (reads everything; callers should limit the size).
+func DecodeReader(r io.Reader) (Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+	return DecodeBytes(data)
+}
+
+// Decode scans an image and returns the most frequently read barcode.
+func Decode(img image.Image) (Result, error) {
+	g := toGray(img)
+	if g.w < 2 || g.h < 2 {
+		return Result{}, ErrNotFound
+	}
+
+	votes := map[Result]int{}
+	order := []Result{} // first-seen order keeps ties deterministic
+
+	for _, angle := range scanAngles() {
+		for _, line := range g.scanlines(angle) {
+			widths, firstDark, ok := binarize(line)
+			if !ok {
+				continue
+			}
+			// Reading the same line backwards covers barcodes that are upside down.
+			for _, reversed := range []bool{false, true} {
+				w, dark := widths, firstDark
+				if reversed {
+					w, dark = reverseRuns(widths, firstDark)
+				}
+				for _, res := range decodeRuns(w, dark) {
+					if votes[res] == 0 {
+						order = append(order, res)
+					}
+					votes[res]++
+				}
+			}
+		}
+
+		// A clean read usually shows up on many parallel lines at the first angle; no need to keep going.
+		if best, n := bestVote(votes, order); n >= confidentHits {
+			return best, nil
+		}
+	}
+
+	if best, n := bestVote(votes, order); n > 0 {
+		return best, nil
+	}
+	return Result{}, ErrNotFound
+}
+
+func bestVote(votes map[Result]int, order []Result) (Result, int) {
+	var best Result
+	bestCount := 0
+	for _, res := range order {
+		if votes[res] > bestCount {
+			best, bestCount = res, votes[res]
+		}
+	}
+	return best, bestCount
+}
+
+// scanAngles lists the scan directions, most likely first (upright and sideways photos).
+// 0..170 degrees is enough: 180..350 is the same line read backwards, which Decode also tries.
+func scanAngles() []float64 {
+	angles := []float64{0, 90, 45, 135}
+	for a := angleStepDeg; a < 180; a += angleStepDeg {
+		if a == 45 || a == 90 || a == 135 {
+			continue
+		}
+		angles = append(angles, float64(a))
+	}
+	return angles
+}
+
+// grayImage is a luminance buffer (0 = black, 255 = white).
+type grayImage struct {
+	w, h int
+	pix  []float32
+}
+
+// toGray converts to luminance and box-downscales by an integer factor so the longest
+// side is at most maxDimension. Fast paths cover what image/jpeg and image/png return.
+func toGray(img image.Image) grayImage {
+	b := img.Bounds()
+	factor := 1
+	for (b.Dx()+factor-1)/factor > maxDimension || (b.Dy()+factor-1)/factor > maxDimension {
+		factor++
+	}
+
+	w := (b.Dx() + factor - 1) / factor
+	h := (b.Dy() + factor - 1) / factor
+	sum := make([]float32, w*h)
+	count := make([]float32, w*h)
+
+	add := func(x, y int, lum float32) {
+		i := ((y-b.Min.Y)/factor)*w + (x-b.Min.X)/factor
+		sum[i] += lum
+		count[i]++
+	}
+
+	switch src := img.(type) {
+	case *image.YCbCr:
+		// JPEG: the Y plane already is luminance.
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				add(x, y, float32(src.Y[src.YOffset(x, y)]))
+			}
+		}
+	case *image.Gray:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				add(x, y, float32(src.Pix[src.PixOffset(x, y)]))
+			}
+		}
+	case *image.NRGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				i := src.PixOffset(x, y)
+				p := src.Pix[i : i+4 : i+4]
+				// Transparent pixels count as white paper.
+				lum := luma(float32(p[0]), float32(p[1]), float32(p[2]))
+				a := float32(p[3]) / 255
+				add(x, y, lum*a+255*(1-a))
+			}
+		}
+	case *image.RGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				i := src.PixOffset(x, y)
+				p := src.Pix[i : i+4 : i+4]
+				// RGBA is premultiplied: add the missing white behind transparent pixels.
+				add(x, y, luma(float32(p[0]), float32(p[1]), float32(p[2]))+255-float32(p[3]))
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, gg, bb, a := img.At(x, y).RGBA() // 16-bit premultiplied
+				lum := luma(float32(r), float32(gg), float32(bb)) / 257
+				add(x, y, lum+255-float32(a)/257)
+			}
+		}
+	}
+
+	for i := range sum {
+		if count[i] > 0 {
+			sum[i] /= count[i]
+		}
+	}
+	return grayImage{w: w, h: h, pix: sum}
+}
+
+func luma(r, g, b float32) float32 {
+	return 0.299*r + 0.587*g + 0.114*b
+}
+
+// at samples with bilinear interpolation (x, y must be inside the image).
+func (g grayImage) at(x, y float64) float32 {
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, g.w-1), min(y0+1, g.h-1)
+	fx, fy := float32(x-float64(x0)), float32(y-float64(y0))
+
+	top := g.pix[y0*g.w+x0]*(1-fx) + g.pix[y0*g.w+x1]*fx
+	bottom := g.pix[y1*g.w+x0]*(1-fx) + g.pix[y1*g.w+x1]*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// scanlines returns parallel lines at the given angle, sampled every sampleStep pixels.
+// Lines are ordered from the image center outwards because barcodes are usually centered.
+func (g grayImage) scanlines(angleDeg float64) [][]float32 {
+	rad := angleDeg * math.Pi / 180
+	dx, dy := math.Cos(rad), math.Sin(rad)
+	nx, ny := -dy, dx // normal: offsets move the line sideways
+
+	cx, cy := float64(g.w-1)/2, float64(g.h-1)/2
+	half := math.Hypot(float64(g.w), float64(g.h)) / 2
+	spacing := math.Max(4, half/24) // ~48 lines per angle across the diagonal
+
+	offsets := []float64{0}
+	for off := spacing; off < half; off += spacing {
+		offsets = append(offsets, off, -off)
+	}
+
+	lines := make([][]float32, 0, len(offsets))
+	for _, off := range offsets {
+		ox, oy := cx+off*nx, cy+off*ny // a point on this line
+
+		// Clip the parameter t so ox+t*dx, oy+t*dy stays inside the image.
+		tMin, tMax := -half, half
+		tMin, tMax = clipAxis(tMin, tMax, ox, dx, float64(g.w-1))
+		tMin, tMax = clipAxis(tMin, tMax, oy, dy, float64(g.h-1))
+		if tMax-tMin < minLineLength {
+			continue
+		}
+
+		n := int((tMax-tMin)/sampleStep) + 1
+		samples := make([]float32, n)
+		for i := range samples {
+			t := tMin + float64(i)*sampleStep
+			x := math.Min(math.Max(ox+t*dx, 0), float64(g.w-1))
+			y := math.Min(math.Max(oy+t*dy, 0), float64(g.h-1))
+			samples[i] = g.at(x, y)
+		}
+		lines = append(lines, samples)
+	}
+	return lines
+}
+
+// clipAxis narrows [tMin, tMax] so origin + t*dir stays within [0, limit] on one axis.
+func clipAxis(tMin, tMax, origin, dir, limit float64) (float64, float64) {
+	if math.Abs(dir) < 1e-9 {
+		if origin < 0 || origin > limit {
+			return 0, -1 // the line never enters the image
+		}
+		return tMin, tMax
+	}
+	t0, t1 := (0-origin)/dir, (limit-origin)/dir
+	if t0 > t1 {
+		t0, t1 = t1, t0
+	}
+	return math.Max(tMin, t0), math.Min(tMax, t1)
+}
+
+// binarize turns a scanline into alternating run widths (in samples).
+// Edges are placed where the signal crosses a local threshold, interpolated between samples:
+// blur softens edges symmetrically, so the crossing point stays close to the true edge.
+func binarize(line []float32) (widths []float64, firstDark bool, ok bool) {
+	if len(line) < 3 {
+		return nil, false, false
+	}
+
+	// Light smoothing ([1 2 1]) removes JPEG/sensor noise that would create fake edges.
+	smooth := make([]float32, len(line))
+	smooth[0], smooth[len(line)-1] = line[0], line[len(line)-1]
+	for i := 1; i < len(line)-1; i++ {
+		smooth[i] = (line[i-1] + 2*line[i] + line[i+1]) / 4
+	}
+
+	lo, hi := percentileRange(smooth)
+	if hi-lo < minContrast {
+		return nil, false, false // flat line (no barcode crosses it)
+	}
+	global := (lo + hi) / 2
+	thresholds := localThresholds(smooth, global)
+
+	dark := smooth[0] < thresholds[0]
+	firstDark = dark
+	last := 0.0
+	for i := 1; i < len(smooth); i++ {
+		isDark := smooth[i] < thresholds[i]
+		if isDark == dark {
+			continue
+		}
+		// Linear interpolation of where the signal crossed the threshold between i-1 and i.
+		a, b := smooth[i-1]-thresholds[i-1], smooth[i]-thresholds[i]
+		edge := float64(i - 1)
+		if a != b {
+			edge += float64(a / (a - b))
+		}
+		widths = append(widths, edge-last)
+		last = edge
+		dark = isDark
+	}
+	widths = append(widths, float64(len(smooth)-1)-last)
+	return widths, firstDark, len(widths) >= 20
+}
+
+// percentileRange returns the 5th and 95th percentile (robust against specular highlights).
+func percentileRange(values []float32) (float32, float32) {
+	sorted := append([]float32(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)*5/100], sorted[len(sorted)*95/100]
+}
+
+// localThresholds uses the min/max midpoint of nearby blocks, so uneven lighting
+// (a shadow across half the label) does not swallow bars. Low-contrast blocks fall back
+// to the global threshold; otherwise flat paper noise would turn into edges.
+func localThresholds(values []float32, global float32) []float32 {
+	blocks := (len(values) + thresholdBlk - 1) / thresholdBlk
+	blockMin := make([]float32, blocks)
+	blockMax := make([]float32, blocks)
+	for b := 0; b < blocks; b++ {
+		start, end := b*thresholdBlk, min((b+1)*thresholdBlk, len(values))
+		blockMin[b], blockMax[b] = values[start], values[start]
+		for _, v := range values[start:end] {
+			blockMin[b] = min(blockMin[b], v)
+			blockMax[b] = max(blockMax[b], v)
+		}
+	}
+
+	blockThreshold := make([]float32, blocks)
+	for b := range blockThreshold {
+		lo, hi := blockMin[b], blockMax[b]
+		for n := max(0, b-2); n <= min(blocks-1, b+2); n++ {
+			lo = min(lo, blockMin[n])
+			hi = max(hi, blockMax[n])
+		}
+		if hi-lo < minContrast {
+			blockThreshold[b] = global
+		} else {
+			blockThreshold[b] = (lo + hi) / 2
+		}
+	}
+
+	thresholds := make([]float32, len(values))
+	for i := range thresholds {
+		thresholds[i] = blockThreshold[i/thresholdBlk]
+	}
+	return thresholds
+}
+
+// reverseRuns flips a run list so the same decoders can read a barcode upside down.
+func reverseRuns(widths []float64, firstDark bool) ([]float64, bool) {
+	reversed := make([]float64, len(widths))
+	for i, w := range widths {
+		reversed[len(widths)-1-i] = w
+	}
+	// The last run becomes the first; its color depends on the parity of the run count.
+	lastDark := firstDark == (len(widths)%2 == 1)
+	return reversed, lastDark
+}
@@ -0,0 +1,490 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerate testdata/corpus")
+
+// The renderer below uses the module bit strings from the GS1 spec (1 = bar), independent of the
+// width tables in symbologies.go, so a typo in either side shows up as a failed decode.
+var (
+	eanL = []string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+	// EAN-13 left-half parity per leading digit (L = odd, G = even).
+	eanParity = []string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+	// UPC-E parity for number system 0 per check digit (E = even/G, O = odd/L); number system 1 is inverted.
+	upceSystem0 = []string{"EEEOOO", "EEOEOO", "EEOOEO", "EEOOOE", "EOEEOO", "EOOEEO", "EOOOEE", "EOEOEO", "EOEOOE", "EOOEOE"}
+	// ITF digit patterns (N = narrow, W = wide).
+	itfNW = []string{"NNWWN", "WNNNW", "NWNNW", "WWNNN", "NNWNW", "WNWNN", "NWWNN", "NNNWW", "WNNWN", "NWNWN"}
+)
+
+func eanR(d byte) string {
+	l := eanL[d-'0']
+	var b strings.Builder
+	for _, c := range l {
+		if c == '0' {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	return b.String()
+}
+
+func eanG(d byte) string {
+	r := eanR(d)
+	var b strings.Builder
+	for i := len(r) - 1; i >= 0; i-- {
+		b.WriteByte(r[i])
+	}
+	return b.String()
+}
+
+// modulesFor returns the bar pattern of a symbol as a string of module bits (widths for ITF are 1 or 3).
+func modulesFor(t *testing.T, symbology, text string) string {
+	t.Helper()
+	var b strings.Builder
+	switch symbology {
+	case SymbologyEAN13, SymbologyUPCA:
+		if symbology == SymbologyUPCA {
+			text = "0" + text
+		}
+		parity := eanParity[text[0]-'0']
+		b.WriteString("101")
+		for k := 0; k < 6; k++ {
+			if parity[k] == 'L' {
+				b.WriteString(eanL[text[1+k]-'0'])
+			} else {
+				b.WriteString(eanG(text[1+k]))
+			}
+		}
+		b.WriteString("01010")
+		for k := 7; k < 13; k++ {
+			b.WriteString(eanR(text[k]))
+		}
+		b.WriteString("101")
+	case SymbologyEAN8:
+		b.WriteString("101")
+		for k := 0; k < 4; k++ {
+			b.WriteString(eanL[text[k]-'0'])
+		}
+		b.WriteString("01010")
+		for k := 4; k < 8; k++ {
+			b.WriteString(eanR(text[k]))
+		}
+		b.WriteString("101")
+	case SymbologyUPCE:
+		parity := upceSystem0[text[7]-'0']
+		b.WriteString("101")
+		for k := 0; k < 6; k++ {
+			even := parity[k] == 'E'
+			if text[0] == '1' {
+				even = !even
+			}
+			if even {
+				b.WriteString(eanG(text[1+k]))
+			} else {
+				b.WriteString(eanL[text[1+k]-'0'])
+			}
+		}
+		b.WriteString("010101")
+	case SymbologyITF:
+		run := func(bar bool, wide bool) {
+			n := 1
+			if wide {
+				n = 3
+			}
+			for i := 0; i < n; i++ {
+				if bar {
+					b.WriteByte('1')
+				} else {
+					b.WriteByte('0')
+				}
+			}
+		}
+		b.WriteString("1010")
+		for k := 0; k < len(text); k += 2 {
+			bars, spaces := itfNW[text[k]-'0'], itfNW[text[k+1]-'0']
+			for e := 0; e < 5; e++ {
+				run(true, bars[e] == 'W')
+				run(false, spaces[e] == 'W')
+			}
+		}
+		b.WriteString("11101")
+	default:
+		t.Fatalf("unknown symbology %q", symbology)
+	}
+	return b.String()
+}
+
+type renderOptions struct {
+	module   float64 // pixels per module
+	angle    float64 // degrees, counter-clockwise
+	blur     float64 // gaussian sigma in pixels (0 = sharp)
+	noise    float64 // gaussian noise sigma (0..255 scale)
+	gradient float64 // brightness drop from left to right (uneven lighting)
+	jpeg     int     // JPEG quality (0 = keep lossless)
+}
+
+// render draws a barcode on a larger white "label", applies the distortions and
+// returns the resulting image (a JPEG round trip when opts.jpeg > 0).
+func render(t *testing.T, symbology, text string, opts renderOptions) image.Image {
+	t.Helper()
+	modules := modulesFor(t, symbology, text)
+
+	quiet := 12.0
+	barW := (float64(len(modules)) + 2*quiet) * opts.module
+	barH := barW * 0.45
+	canvas := int(math.Hypot(barW, barH)) + 40
+
+	rad := opts.angle * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	c := float64(canvas) / 2
+
+	pix := make([]float64, canvas*canvas)
+	for y := 0; y < canvas; y++ {
+		for x := 0; x < canvas; x++ {
+			// Inverse-rotate each canvas pixel into barcode space, 4x4 supersampled for antialiasing.
+			sum := 0.0
+			for sy := 0; sy < 4; sy++ {
+				for sx := 0; sx < 4; sx++ {
+					px := float64(x) + (float64(sx)+0.5)/4 - c
+					py := float64(y) + (float64(sy)+0.5)/4 - c
+					u := px*cos + py*sin + barW/2
+					v := -px*sin + py*cos + barH/2
+					value := 1.0 // white
+					if v >= 0 && v < barH && u >= 0 && u < barW {
+						m := int(math.Floor(u/opts.module - quiet))
+						if m >= 0 && m < len(modules) && modules[m] == '1' {
+							value = 0.08 // ink is never perfectly black
+						}
+					}
+					sum += value
+				}
+			}
+			pix[y*canvas+x] = sum / 16 * 255
+		}
+	}
+
+	if opts.blur > 0 {
+		pix = gaussianBlur(pix, canvas, opts.blur)
+	}
+
+	rng := rand.New(rand.NewSource(int64(len(text)) * 7919))
+	img := image.NewGray(image.Rect(0, 0, canvas, canvas))
+	for y := 0; y < canvas; y++ {
+		for x := 0; x < canvas; x++ {
+			v := pix[y*canvas+x]
+			v *= 1 - opts.gradient*float64(x)/float64(canvas)
+			v += rng.NormFloat64() * opts.noise
+			img.SetGray(x, y, color.Gray{Y: uint8(math.Max(0, math.Min(255, v)))})
+		}
+	}
+
+	if opts.jpeg == 0 {
+		return img
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.jpeg}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	decoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode jpeg: %v", err)
+	}
+	return decoded
+}
+
+func gaussianBlur(pix []float64, size int, sigma float64) []float64 {
+	radius := int(math.Ceil(sigma * 3))
+	kernel := make([]float64, 2*radius+1)
+	total := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+
+	pass := func(src []float64, horizontal bool) []float64 {
+		dst := make([]float64, len(src))
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				sum := 0.0
+				for k, weight := range kernel {
+					xx, yy := x, y
+					if horizontal {
+						xx = min(max(x+k-radius, 0), size-1)
+					} else {
+						yy = min(max(y+k-radius, 0), size-1)
+					}
+					sum += src[yy*size+xx] * weight
+				}
+				dst[y*size+x] = sum
+			}
+		}
+		return dst
+	}
+	return pass(pass(pix, true), false)
+}
+
+func TestDecodeGeneratedImages(t *testing.T) {
+	tests := []struct {
+		name      string
+		symbology string
+		text      string
+		code      string
+		opts      renderOptions
+	}{
+		{"ean13 sharp", SymbologyEAN13, "4006381333931", "4006381333931", renderOptions{module: 3}},
+		{"ean13 rotated 90", SymbologyEAN13, "5449000000996", "5449000000996", renderOptions{module: 3, angle: 90}},
+		{"ean13 upside down", SymbologyEAN13, "3017620422003", "3017620422003", renderOptions{module: 3, angle: 180}},
+		{"ean13 tilted and blurred", SymbologyEAN13, "4006381333931", "4006381333931", renderOptions{module: 3, angle: 23, blur: 1.2, noise: 6, jpeg: 75}},
+		{"ean13 uneven lighting", SymbologyEAN13, "7622210449283", "7622210449283", renderOptions{module: 3, angle: -8, blur: 0.8, gradient: 0.55, noise: 4, jpeg: 80}},
+		{"ean8", SymbologyEAN8, "96385074", "96385074", renderOptions{module: 3, angle: 12, blur: 0.8, jpeg: 85}},
+		{"upc-a", SymbologyUPCA, "036000291452", "036000291452", renderOptions{module: 3, angle: 270, blur: 1, noise: 5, jpeg: 80}},
+		{"upc-e expands to upc-a", SymbologyUPCE, "01234565", "012345000065", renderOptions{module: 3, angle: 37, blur: 0.8, jpeg: 85}},
+		{"itf-14 with indicator 0", SymbologyITF, "00012345678905", "0012345678905", renderOptions{module: 2.5, angle: 4, blur: 0.7, jpeg: 85}},
+		{"itf-14 case code", SymbologyITF, "10012345678902", "10012345678902", renderOptions{module: 2.5, angle: 135, blur: 0.6, jpeg: 85}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img := render(t, tc.symbology, tc.text, tc.opts)
+
+			got, err := Decode(img)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			want := Result{Symbology: tc.symbology, Text: tc.text, Code: tc.code}
+			if got != want {
+				t.Fatalf("Decode = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeNoBarcode(t *testing.T) {
+	// Text-like noise and a blank page must not produce a (checksum-valid) false positive.
+	rng := rand.New(rand.NewSource(42))
+	img := image.NewGray(image.Rect(0, 0, 400, 300))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+		if rng.Intn(9) == 0 {
+			img.Pix[i] = uint8(rng.Intn(120))
+		}
+	}
+
+	if _, err := Decode(img); err != ErrNotFound {
+		t.Fatalf("Decode(noise) error = %v, want ErrNotFound", err)
+	}
+	if _, err := Decode(image.NewGray(image.Rect(0, 0, 200, 200))); err != ErrNotFound {
+		t.Fatalf("Decode(blank) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	img := render(t, SymbologyEAN13, "4006381333931", renderOptions{module: 2, angle: 5})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+
+	got, err := DecodeBytes(buf.Bytes())
+	if err != nil || got.Code != "4006381333931" {
+		t.Fatalf("DecodeBytes(png) = %+v, %v", got, err)
+	}
+
+	if _, err := DecodeBytes([]byte("GIF89a not really")); err != ErrUnsupportedImage {
+		t.Fatalf("DecodeBytes(gif) error = %v, want ErrUnsupportedImage", err)
+	}
+
+	// A valid PNG header that claims a gigantic canvas is rejected before decoding pixels.
+	huge := image.NewGray(image.Rect(0, 0, 1, 1))
+	buf.Reset()
+	if err := png.Encode(&buf, huge); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	data := buf.Bytes()
+	// IHDR width/height live at bytes 16..23; patch them to 50000 x 50000 and fix the chunk CRC.
+	copy(data[16:24], []byte{0, 0, 0xC3, 0x50, 0, 0, 0xC3, 0x50})
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	if _, err := DecodeBytes(data); err != ErrImageTooLarge {
+		t.Fatalf("DecodeBytes(huge) error = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestExpandUPCE(t *testing.T) {
+	tests := map[string]string{
+		"01234565": "012345000065", // last digit 5..9
+		"04252614": "042100005264", // last digit 0..2
+		"01234531": "012300000451", // last digit 3 (digits 4,5 follow)
+		"01234541": "012340000051", // last digit 4 (digit 5 follows)
+	}
+	for upce, want := range tests {
+		if got := expandUPCE(upce); got != want {
+			t.Errorf("expandUPCE(%q) = %q, want %q", upce, got, want)
+		}
+	}
+}
+
+// corpusCases are written to testdata/corpus with -update. They are renders, not photos: camera
+// shots of real packaging go in testdata/photos (see TestDecodePhotos) so -update never touches them.
+var corpusCases = []struct {
+	file      string
+	symbology string
+	text      string
+	opts      renderOptions
+}{
+	{"ean_13_4006381333931_tilted.jpg", SymbologyEAN13, "4006381333931", renderOptions{module: 2.5, angle: 17, blur: 1, noise: 5, jpeg: 70}},
+	{"ean_8_96385074_blurred.jpg", SymbologyEAN8, "96385074", renderOptions{module: 3, angle: -30, blur: 1.4, noise: 3, jpeg: 70}},
+	{"upc_a_036000291452_shadow.jpg", SymbologyUPCA, "036000291452", renderOptions{module: 2.5, angle: 95, blur: 0.8, gradient: 0.5, noise: 4, jpeg: 70}},
+	{"upc_e_01234565_upside_down.png", SymbologyUPCE, "01234565", renderOptions{module: 3, angle: 190, blur: 0.6}},
+	{"itf_10012345678902_case.jpg", SymbologyITF, "10012345678902", renderOptions{module: 2, angle: 60, blur: 0.6, noise: 3, jpeg: 75}},
+}
+
+func TestDecodeCorpus(t *testing.T) {
+	dir := filepath.Join("testdata", "corpus")
+	if *update {
+		writeCorpus(t, dir)
+	}
+
+	if len(decodeDir(t, dir)) == 0 {
+		t.Fatal("empty corpus (run go test -run TestDecodeCorpus -update)")
+	}
+}
+
+// TestDecodePhotos decodes camera photos of real packaging ("<symbology>_<text>[_note].jpg|png").
+// The renders above can't reproduce everything a phone does (perspective, glare, curved cans).
+// The set must cover every symbology plus a rotated and a blurred shot.
+func TestDecodePhotos(t *testing.T) {
+	missing := missingPhotos(decodeDir(t, filepath.Join("testdata", "photos")))
+	if len(missing) == len(requiredPhotos) {
+		t.Skipf("no photos in testdata/photos yet (need %s)", strings.Join(missing, ", "))
+	}
+	if len(missing) > 0 {
+		t.Fatalf("testdata/photos is missing %s", strings.Join(missing, ", "))
+	}
+}
+
+// requiredPhotos is what the photo set must cover: a file name prefix (symbology) or a
+// "_note" marker (capture condition).
+var requiredPhotos = []string{
+	SymbologyEAN13 + "_", SymbologyEAN8 + "_", SymbologyUPCA + "_", SymbologyUPCE + "_", SymbologyITF + "_",
+	"_rotated", "_blurred",
+}
+
+// missingPhotos lists the requiredPhotos entries no file name covers.
+func missingPhotos(names []string) []string {
+	missing := []string{}
+	for _, required := range requiredPhotos {
+		covered := false
+		for _, name := range names {
+			if (strings.HasSuffix(required, "_") && strings.HasPrefix(name, required)) ||
+				(strings.HasPrefix(required, "_") && strings.Contains(name, required)) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, strings.Trim(required, "_"))
+		}
+	}
+	return missing
+}
+
+// decodeDir decodes every image in dir against the expectation in its file name and
+// returns the names of the images it checked.
+func decodeDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read %s: %v", dir, err)
+	}
+
+	images := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+			continue
+		}
+		images = append(images, name)
+		t.Run(name, func(t *testing.T) {
+			symbology, text, ok := parseCorpusName(strings.TrimSuffix(name, ext))
+			if !ok {
+				t.Fatalf("corpus file %q must be named <symbology>_<text>[_note]%s", name, ext)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+
+			got, err := DecodeBytes(data)
+			if err != nil {
+				t.Fatalf("DecodeBytes: %v", err)
+			}
+			if got.Symbology != symbology || got.Text != text {
+				t.Fatalf("DecodeBytes = %+v, want %s %s", got, symbology, text)
+			}
+		})
+	}
+	return images
+}
+
+// parseCorpusName splits "upc_a_036000291452_note" into ("upc_a", "036000291452").
+func parseCorpusName(base string) (string, string, bool) {
+	for _, symbology := range []string{SymbologyEAN13, SymbologyEAN8, SymbologyUPCA, SymbologyUPCE, SymbologyITF} {
+		rest, ok := strings.CutPrefix(base, symbology+"_")
+		if !ok {
+			continue
+		}
+		text, _, _ := strings.Cut(rest, "_")
+		return symbology, text, text != ""
+	}
+	return "", "", false
+}
+
+func writeCorpus(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, tc := range corpusCases {
+		opts := tc.opts
+		quality := opts.jpeg
+		opts.jpeg = 0 // encode once, below, so the file is the lossy artifact itself
+
+		img := render(t, tc.symbology, tc.text, opts)
+		var buf bytes.Buffer
+		var err error
+		if strings.HasSuffix(tc.file, ".png") {
+			err = png.Encode(&buf, img)
+		} else {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+		}
+		if err != nil {
+			t.Fatalf("encode %s: %v", tc.file, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, tc.file), buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("write %s: %v", tc.file, err)
+		}
+		fmt.Fprintf(os.Stderr, "wrote %s (%d bytes)\n", tc.file, buf.Len())
+	}
+}
@@ -0,0 +1,388 @@
+package scanner
+
+import (
+	"math"
+	"strings"
+)
+
+// Tolerances for matching measured widths against ideal module patterns.
+// Units are "modules" (the width of the narrowest bar).
+const (
+	maxElementVariance = 0.7  // one bar/space may be off by up to 0.7 module (blur, ink spread)
+	maxDigitVariance   = 0.48 // average error per module across a digit
+	minQuietModules    = 3.0  // light margin required before/after EAN/UPC guards
+	itfQuietModules    = 5.0  // ITF needs a wider margin (its bars are easier to fake)
+	itfWideRatio       = 1.5  // a wide ITF element is at least 1.5x the widest narrow one
+	itfDigits          = 14   // ITF-14 (GTIN-14 on outer cases) is the only ITF length we accept
+)
+
+// lgPatterns holds the EAN/UPC digit widths (space, bar, space, bar for L/G codes;
+// the same widths read bar-first for R codes). 0..9 are L (odd parity), 10..19 are G (even parity, L reversed).
+var lgPatterns = func() [20][4]float64 {
+	l := [10][4]float64{
+		{3, 2, 1, 1}, {2, 2, 2, 1}, {2, 1, 2, 2}, {1, 4, 1, 1}, {1, 1, 3, 2},
+		{1, 2, 3, 1}, {1, 1, 1, 4}, {1, 3, 1, 2}, {1, 2, 1, 3}, {3, 1, 1, 2},
+	}
+	var all [20][4]float64
+	for d, p := range l {
+		all[d] = p
+		all[d+10] = [4]float64{p[3], p[2], p[1], p[0]}
+	}
+	return all
+}()
+
+// ean13FirstDigit maps the L/G parity of the 6 left digits (bit set = G, first digit is the high bit)
+// to the implied leading digit of an EAN-13.
+var ean13FirstDigit = map[int]int{
+	0x00: 0, 0x0B: 1, 0x0D: 2, 0x0E: 3, 0x13: 4, 0x19: 5, 0x1C: 6, 0x15: 7, 0x16: 8, 0x1A: 9,
+}
+
+// upceParity holds the UPC-E parity patterns per number system (0 and 1), indexed by check digit.
+var upceParity = [2][10]int{
+	{0x38, 0x34, 0x32, 0x31, 0x2C, 0x26, 0x23, 0x2A, 0x29, 0x25},
+	{0x07, 0x0B, 0x0D, 0x0E, 0x13, 0x19, 0x1C, 0x15, 0x16, 0x1A},
+}
+
+// itfPatterns maps the wide-element positions (bit 4 = first element) of an ITF digit to its value.
+var itfPatterns = map[int]int{
+	0b00110: 0, 0b10001: 1, 0b01001: 2, 0b11000: 3, 0b00101: 4,
+	0b10100: 5, 0b01100: 6, 0b00011: 7, 0b10010: 8, 0b01010: 9,
+}
+
+// decoders run in order at each dark run; the longer EAN-13 goes before EAN-8/UPC-E.
+var decoders = []func(w []float64, i int) (Result, bool){decodeEAN13, decodeEAN8, decodeUPCE, decodeITF}
+
+// decodeRuns tries every symbology at every dark run and returns each valid read.
+// widths alternate colors, starting with a dark run when firstDark is true.
+func decodeRuns(widths []float64, firstDark bool) []Result {
+	var results []Result
+	start := 0
+	if !firstDark {
+		start = 1 // barcodes start with a bar
+	}
+
+	for i := start; i < len(widths); i += 2 {
+		// The quiet-zone checks stop a decoder from matching inside another symbol,
+		// so every dark run can be tried without tracking where the last symbol ended.
+		for _, decode := range decoders {
+			if res, ok := decode(widths, i); ok {
+				results = append(results, res)
+				break
+			}
+		}
+	}
+	return results
+}
+
+// Layout (in runs) of each EAN/UPC symbol: guard + digits*4 + guard (+ digits*4 + guard).
+const (
+	ean13Runs = 3 + 6*4 + 5 + 6*4 + 3 // 59 runs, 95 modules
+	ean8Runs  = 3 + 4*4 + 5 + 4*4 + 3 // 43 runs, 67 modules
+	upceRuns  = 3 + 6*4 + 6           // 33 runs, 51 modules
+)
+
+func decodeEAN13(w []float64, i int) (Result, bool) {
+	module, ok := symbolModule(w, i, ean13Runs, 95)
+	if !ok {
+		return Result{}, false
+	}
+
+	digits := make([]byte, 0, 13)
+	parity := 0
+	pos := i + 3
+	for k := 0; k < 6; k++ {
+		d, ok := matchDigit(w[pos:pos+4], 20)
+		if !ok {
+			return Result{}, false
+		}
+		if d >= 10 {
+			parity |= 1 << (5 - k)
+			d -= 10
+		}
+		digits = append(digits, byte('0'+d))
+		pos += 4
+	}
+	first, ok := ean13FirstDigit[parity]
+	if !ok {
+		return Result{}, false
+	}
+	if !isGuard(w[pos:pos+5], module) {
+		return Result{}, false
+	}
+	pos += 5
+	for k := 0; k < 6; k++ {
+		d, ok := matchDigit(w[pos:pos+4], 10)
+		if !ok {
+			return Result{}, false
+		}
+		digits = append(digits, byte('0'+d))
+		pos += 4
+	}
+
+	code := string(rune('0'+first)) + string(digits)
+	if !isGTINValid(code) {
+		return Result{}, false
+	}
+	// A leading 0 means the same symbol is a UPC-A (the 0 is not printed).
+	if first == 0 {
+		return Result{Symbology: SymbologyUPCA, Text: code[1:], Code: code[1:]}, true
+	}
+	return Result{Symbology: SymbologyEAN13, Text: code, Code: code}, true
+}
+
+func decodeEAN8(w []float64, i int) (Result, bool) {
+	module, ok := symbolModule(w, i, ean8Runs, 67)
+	if !ok {
+		return Result{}, false
+	}
+
+	digits := make([]byte, 0, 8)
+	pos := i + 3
+	for half := 0; half < 2; half++ {
+		if half == 1 {
+			if !isGuard(w[pos:pos+5], module) {
+				return Result{}, false
+			}
+			pos += 5
+		}
+		for k := 0; k < 4; k++ {
+			// EAN-8 only uses L codes on the left and R codes on the right (same widths).
+			d, ok := matchDigit(w[pos:pos+4], 10)
+			if !ok {
+				return Result{}, false
+			}
+			digits = append(digits, byte('0'+d))
+			pos += 4
+		}
+	}
+
+	code := string(digits)
+	if !isGTINValid(code) {
+		return Result{}, false
+	}
+	return Result{Symbology: SymbologyEAN8, Text: code, Code: code}, true
+}
+
+func decodeUPCE(w []float64, i int) (Result, bool) {
+	module, ok := symbolModule(w, i, upceRuns, 51)
+	if !ok {
+		return Result{}, false
+	}
+
+	digits := make([]byte, 0, 6)
+	parity := 0
+	pos := i + 3
+	for k := 0; k < 6; k++ {
+		d, ok := matchDigit(w[pos:pos+4], 20)
+		if !ok {
+			return Result{}, false
+		}
+		if d >= 10 {
+			parity |= 1 << (5 - k)
+			d -= 10
+		}
+		digits = append(digits, byte('0'+d))
+		pos += 4
+	}
+	if !isGuard(w[pos:pos+6], module) {
+		return Result{}, false
+	}
+
+	// The parity pattern encodes the number system and the check digit (neither has its own bars).
+	for numberSystem, patterns := range upceParity {
+		for check, pattern := range patterns {
+			if pattern != parity {
+				continue
+			}
+			text := string(rune('0'+numberSystem)) + string(digits) + string(rune('0'+check))
+			code := expandUPCE(text)
+			if !isGTINValid(code) {
+				return Result{}, false
+			}
+			return Result{Symbology: SymbologyUPCE, Text: text, Code: code}, true
+		}
+	}
+	return Result{}, false
+}
+
+// expandUPCE converts an 8-digit UPC-E (number system + 6 digits + check) to its 12-digit UPC-A.
+// Products are stored under the UPC-A form, so this is what the lookup needs.
+func expandUPCE(upce string) string {
+	body := upce[1:7]
+	var b strings.Builder
+	b.WriteByte(upce[0])
+	switch last := body[5]; last {
+	case '0', '1', '2':
+		b.WriteString(body[0:2])
+		b.WriteByte(last)
+		b.WriteString("0000")
+		b.WriteString(body[2:5])
+	case '3':
+		b.WriteString(body[0:3])
+		b.WriteString("00000")
+		b.WriteString(body[3:5])
+	case '4':
+		b.WriteString(body[0:4])
+		b.WriteString("00000")
+		b.WriteByte(body[4])
+	default:
+		b.WriteString(body[0:5])
+		b.WriteString("0000")
+		b.WriteByte(last)
+	}
+	b.WriteByte(upce[7])
+	return b.String()
+}
+
+// decodeITF reads an Interleaved 2 of 5 symbol: a narrow start pattern (bar, space, bar, space),
+// digit pairs (5 bars = first digit, 5 interleaved spaces = second digit), then a wide-narrow-narrow stop.
+func decodeITF(w []float64, i int) (Result, bool) {
+	if i+4+10+3 > len(w) {
+		return Result{}, false
+	}
+	narrow := (w[i] + w[i+1] + w[i+2] + w[i+3]) / 4
+	for _, x := range w[i : i+4] {
+		if x < narrow*0.5 || x > narrow*1.5 {
+			return Result{}, false
+		}
+	}
+	if i > 0 && w[i-1] < narrow*itfQuietModules {
+		return Result{}, false
+	}
+
+	digits := make([]byte, 0, itfDigits)
+	pos := i + 4
+	for len(digits) < itfDigits {
+		if pos+10 > len(w) {
+			return Result{}, false
+		}
+		var bars, spaces [5]float64
+		for k := 0; k < 5; k++ {
+			bars[k] = w[pos+2*k]
+			spaces[k] = w[pos+2*k+1]
+		}
+		a, okA := matchITFDigit(bars)
+		b, okB := matchITFDigit(spaces)
+		if !okA || !okB {
+			return Result{}, false
+		}
+		digits = append(digits, byte('0'+a), byte('0'+b))
+		pos += 10
+	}
+
+	// Stop pattern: wide bar, narrow space, narrow bar, then the quiet zone (or the image edge).
+	if pos+3 > len(w) {
+		return Result{}, false
+	}
+	if w[pos] < narrow*itfWideRatio || w[pos+1] > narrow*1.5 || w[pos+2] > narrow*1.5 {
+		return Result{}, false
+	}
+	if pos+3 < len(w) && w[pos+3] < narrow*itfQuietModules {
+		return Result{}, false
+	}
+
+	text := string(digits)
+	if !isGTINValid(text) {
+		return Result{}, false
+	}
+	// Indicator digit 0 means "same product as the EAN-13 inside"; look that up instead.
+	code := text
+	if text[0] == '0' {
+		code = text[1:]
+	}
+	return Result{Symbology: SymbologyITF, Text: text, Code: code}, true
+}
+
+// matchITFDigit finds the two wide elements among five (ratio-independent: wide may be 2x..3x).
+func matchITFDigit(widths [5]float64) (int, bool) {
+	idx := []int{0, 1, 2, 3, 4}
+	// Sort indexes by width, widest first (5 elements, insertion sort is plenty).
+	for a := 1; a < len(idx); a++ {
+		for b := a; b > 0 && widths[idx[b]] > widths[idx[b-1]]; b-- {
+			idx[b], idx[b-1] = idx[b-1], idx[b]
+		}
+	}
+	if widths[idx[1]] < widths[idx[2]]*itfWideRatio {
+		return 0, false // no clear split between wide and narrow
+	}
+	pattern := 1<<(4-idx[0]) | 1<<(4-idx[1])
+	d, ok := itfPatterns[pattern]
+	return d, ok
+}
+
+// symbolModule checks the start guard (3 single-module runs), the quiet zones and the overall
+// length of an EAN/UPC symbol starting at run i, and returns the module width.
+func symbolModule(w []float64, i, runs int, modules float64) (float64, bool) {
+	if i+runs > len(w) {
+		return 0, false
+	}
+	total := 0.0
+	for _, x := range w[i : i+runs] {
+		total += x
+	}
+	module := total / modules
+	if !isGuard(w[i:i+3], module) {
+		return 0, false
+	}
+	// The light margins keep us from "finding" a symbol that starts inside another one.
+	if i > 0 && w[i-1] < module*minQuietModules {
+		return 0, false
+	}
+	if end := i + runs; end < len(w) && w[end] < module*minQuietModules {
+		return 0, false
+	}
+	return module, true
+}
+
+// isGuard checks that every run of a guard pattern is about one module wide.
+func isGuard(runs []float64, module float64) bool {
+	for _, x := range runs {
+		if math.Abs(x-module) > module*maxElementVariance {
+			return false
+		}
+	}
+	return true
+}
+
+// matchDigit returns the best lgPatterns entry (among the first n) for four runs.
+// Widths are normalized by the digit's own total (always 7 modules), which absorbs
+// perspective and slight scale changes across the symbol.
+func matchDigit(runs []float64, n int) (int, bool) {
+	total := runs[0] + runs[1] + runs[2] + runs[3]
+	if total <= 0 {
+		return 0, false
+	}
+	unit := total / 7
+
+	best, bestVariance := -1, math.MaxFloat64
+	for d := 0; d < n; d++ {
+		variance := 0.0
+		ok := true
+		for k := 0; k < 4; k++ {
+			diff := math.Abs(runs[k]/unit - lgPatterns[d][k])
+			if diff > maxElementVariance {
+				ok = false
+				break
+			}
+			variance += diff
+		}
+		if ok && variance < bestVariance {
+			best, bestVariance = d, variance
+		}
+	}
+	if best < 0 || bestVariance/7 > maxDigitVariance {
+		return 0, false
+	}
+	return best, true
+}
+
+// isGTINValid checks the mod-10 check digit shared by EAN-8/13, UPC-A and GTIN-14.
+func isGTINValid(code string) bool {
+	sum := 0
+	weight := 3 // weights alternate 3,1,3,... starting right of the check digit
+	for i := len(code) - 2; i >= 0; i-- {
+		sum += int(code[i]-'0') * weight
+		weight = 4 - weight
+	}
+	return (10-sum%10)%10 == int(code[len(code)-1]-'0')
+}
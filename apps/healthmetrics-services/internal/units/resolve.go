@@ -0,0 +1,73 @@
+package units
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// contextKey caches the resolved system on the Gin context, so handlers that convert in
+// several places (or call shared lookups) read the profile at most once per request.
+const contextKey = "unitSystem"
+
+var getUserUnitsFunc = getUserUnits // default: real DB fetch
+
+// Resolve picks the unit system for this request.
+// Precedence (first valid wins):
+// 1) ?units=metric|imperial (one-off override, e.g. a share link)
+// 2) X-User-Units header (sent by the TS server)
+// 3) users.units_preference on the profile
+// 4) metric
+func Resolve(c *gin.Context, pool *pgxpool.Pool) System {
+	if cached, ok := c.Get(contextKey); ok {
+		if system, ok := cached.(System); ok {
+			return system
+		}
+	}
+
+	system := resolve(c, pool)
+	c.Set(contextKey, system)
+	return system
+}
+
+func resolve(c *gin.Context, pool *pgxpool.Pool) System {
+	if system, ok := Parse(c.Query("units")); ok {
+		return system
+	}
+	if system, ok := Parse(c.GetHeader("X-User-Units")); ok {
+		return system
+	}
+
+	// Only hit the DB when auth told us who the user is.
+	userID := c.GetString("userID")
+	if userID != "" && pool != nil {
+		system, err := getUserUnitsFunc(c.Request.Context(), pool, userID)
+		if err != nil {
+			// Best-effort: metric values are always in the response anyway.
+			log.Printf("units_read_error request_id=%s user_id=%s err=%v", c.GetHeader("X-Request-ID"), userID, err)
+		} else if system != "" {
+			return system
+		}
+	}
+	return DefaultSystem
+}
+
+// getUserUnits loads users.units_preference ("" when there is no profile yet).
+func getUserUnits(ctx context.Context, pool *pgxpool.Pool, userID string) (System, error) {
+	const query = `SELECT units_preference::text FROM users WHERE id = $1`
+
+	var raw string
+	if err := pool.QueryRow(ctx, query, userID).Scan(&raw); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("query user units: %w", err)
+	}
+	system, _ := Parse(raw)
+	return system, nil
+}
@@ -0,0 +1,149 @@
+// Package units converts the canonical metric values we store (g, ml, kg, km, kcal) into the
+// user's preferred unit system for responses. Storage and the canonical response fields stay
+// metric; converted values are added alongside them as {value, unit} pairs.
+package units
+
+import (
+	"math"
+	"strings"
+)
+
+// System is a unit system, matching the users.units_preference enum.
+type System string
+
+const (
+	Metric   System = "metric"
+	Imperial System = "imperial" // US customary: oz, lb, mi, fl oz
+)
+
+// DefaultSystem is used when neither the request nor the profile says otherwise.
+const DefaultSystem = Metric
+
+// Exact conversion factors (international avoirdupois pound/ounce, international mile, US fluid ounce).
+const (
+	GramsPerOunce            = 28.349523125
+	KilogramsPerPound        = 0.45359237
+	KilometersPerMile        = 1.609344
+	MillilitersPerFluidOunce = 29.5735295625
+	KilojoulesPerKcal        = 4.184
+)
+
+// Unit symbols used in Quantity.Unit.
+const (
+	Gram       = "g"
+	Ounce      = "oz"
+	Kilogram   = "kg"
+	Pound      = "lb"
+	Kilometer  = "km"
+	Mile       = "mi"
+	Milliliter = "ml"
+	FluidOunce = "fl oz"
+	Kcal       = "kcal"
+)
+
+// decimals is the rounding applied per unit (half away from zero, see Round).
+// Roughly label precision: a 30 g serving is 1.1 oz, a 5.25 km run is 3.26 mi.
+var decimals = map[string]int{
+	Gram:       1,
+	Ounce:      1,
+	Kilogram:   1,
+	Pound:      1,
+	Kilometer:  2,
+	Mile:       2,
+	Milliliter: 0,
+	FluidOunce: 1,
+	Kcal:       0,
+}
+
+// Quantity is a converted value for display, e.g. {"value": 1.1, "unit": "oz"}.
+type Quantity struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Parse reads a unit system name (case-insensitive); ok=false for anything else.
+func Parse(raw string) (System, bool) {
+	switch System(strings.ToLower(strings.TrimSpace(raw))) {
+	case Metric:
+		return Metric, true
+	case Imperial:
+		return Imperial, true
+	}
+	return "", false
+}
+
+// Round rounds value to the precision of unit, half away from zero (2.25 -> 2.3, -2.25 -> -2.3).
+// Units without a rule are rounded to 2 decimals.
+func Round(value float64, unit string) float64 {
+	places, ok := decimals[unit]
+	if !ok {
+		places = 2
+	}
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+// Mass is a food amount: grams (metric) or ounces (imperial).
+func Mass(grams float64, system System) Quantity {
+	if system == Imperial {
+		return quantity(grams/GramsPerOunce, Ounce)
+	}
+	return quantity(grams, Gram)
+}
+
+// BodyWeight is a person's weight: kilograms (metric) or pounds (imperial).
+func BodyWeight(kilograms float64, system System) Quantity {
+	if system == Imperial {
+		return quantity(kilograms/KilogramsPerPound, Pound)
+	}
+	return quantity(kilograms, Kilogram)
+}
+
+// Distance is kilometers (metric) or miles (imperial).
+func Distance(kilometers float64, system System) Quantity {
+	if system == Imperial {
+		return quantity(kilometers/KilometersPerMile, Mile)
+	}
+	return quantity(kilometers, Kilometer)
+}
+
+// Volume is a liquid amount: milliliters (metric) or US fluid ounces (imperial).
+func Volume(milliliters float64, system System) Quantity {
+	if system == Imperial {
+		return quantity(milliliters/MillilitersPerFluidOunce, FluidOunce)
+	}
+	return quantity(milliliters, Milliliter)
+}
+
+// Energy is kcal in both systems (US labels use Calories too); only the rounding applies.
+func Energy(kcal float64, _ System) Quantity {
+	return quantity(kcal, Kcal)
+}
+
+// Amount converts a food serving or quantity stored as (value, unit) where unit is the
+// food_items.serving_size_unit: "ml" is a volume, anything else grams.
+func Amount(value float64, unit string, system System) Quantity {
+	if strings.EqualFold(strings.TrimSpace(unit), Milliliter) {
+		return Volume(value, system)
+	}
+	return Mass(value, system)
+}
+
+// KilojoulesToKcal converts energy as reported by devices (WHOOP) to kcal.
+func KilojoulesToKcal(kilojoules float64) float64 {
+	return kilojoules / KilojoulesPerKcal
+}
+
+// PoundsToKilograms converts body weights stored in pounds (weight_entries) to kg.
+func PoundsToKilograms(pounds float64) float64 {
+	return pounds * KilogramsPerPound
+}
+
+// MetersToKilometers converts device distances to the km we store.
+func MetersToKilometers(meters float64) float64 {
+	return meters / 1000
+}
+
+func quantity(value float64, unit string) Quantity {
+	return Quantity{Value: Round(value, unit), Unit: unit}
+}
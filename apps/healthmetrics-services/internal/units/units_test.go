@@ -0,0 +1,125 @@
+package units
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Quantity
+		want Quantity
+	}{
+		{"grams", Mass(30.04, Metric), Quantity{30, Gram}},
+		{"ounces", Mass(30, Imperial), Quantity{1.1, Ounce}},
+		{"one ounce", Mass(GramsPerOunce, Imperial), Quantity{1, Ounce}},
+		{"pounds", BodyWeight(80, Imperial), Quantity{176.4, Pound}},
+		{"kilograms", BodyWeight(80.26, Metric), Quantity{80.3, Kilogram}},
+		{"miles", Distance(5.25, Imperial), Quantity{3.26, Mile}},
+		{"marathon", Distance(42.195, Imperial), Quantity{26.22, Mile}},
+		{"kilometers", Distance(5.255, Metric), Quantity{5.26, Kilometer}},
+		{"fluid ounces", Volume(330, Imperial), Quantity{11.2, FluidOunce}},
+		{"milliliters", Volume(330.4, Metric), Quantity{330, Milliliter}},
+		{"energy", Energy(227.5, Imperial), Quantity{228, Kcal}},
+		{"ml serving", Amount(250, "ml", Imperial), Quantity{8.5, FluidOunce}},
+		{"gram serving", Amount(40, "g", Imperial), Quantity{1.4, Ounce}},
+		{"unknown unit is mass", Amount(40, "", Metric), Quantity{40, Gram}},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	// Half away from zero, per-unit precision.
+	tests := []struct {
+		value float64
+		unit  string
+		want  float64
+	}{
+		{2.25, Ounce, 2.3},
+		{-2.25, Ounce, -2.3},
+		{0.5, Milliliter, 1},
+		{1.125, "cups", 1.13}, // unknown unit: 2 decimals
+		{3.14159, Mile, 3.14},
+	}
+	for _, tt := range tests {
+		if got := Round(tt.value, tt.unit); got != tt.want {
+			t.Errorf("Round(%v, %q) = %v, want %v", tt.value, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceConversions(t *testing.T) {
+	if got := KilojoulesToKcal(4184); got != 1000 {
+		t.Errorf("KilojoulesToKcal = %v", got)
+	}
+	if got := MetersToKilometers(5250); got != 5.25 {
+		t.Errorf("MetersToKilometers = %v", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	origGet := getUserUnitsFunc
+	defer func() { getUserUnitsFunc = origGet }()
+
+	profileReads := 0
+	profile := Imperial
+	var profileErr error
+	getUserUnitsFunc = func(_ context.Context, _ *pgxpool.Pool, _ string) (System, error) {
+		profileReads++
+		return profile, profileErr
+	}
+
+	resolve := func(target, header, userID string) System {
+		gin.SetMode(gin.TestMode)
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		if header != "" {
+			c.Request.Header.Set("X-User-Units", header)
+		}
+		if userID != "" {
+			c.Set("userID", userID)
+		}
+		first := Resolve(c, &pgxpool.Pool{})
+		if second := Resolve(c, &pgxpool.Pool{}); second != first {
+			t.Fatalf("second Resolve disagreed: %s vs %s", first, second)
+		}
+		return first
+	}
+
+	tests := []struct {
+		name           string
+		target, header string
+		userID         string
+		want           System
+		reads          int
+	}{
+		{"query wins", "/?units=Metric", "imperial", "user_1", Metric, 0},
+		{"header", "/", "imperial", "user_1", Imperial, 0},
+		{"invalid query falls through", "/?units=furlongs", "", "user_1", Imperial, 1},
+		{"profile", "/", "", "user_1", Imperial, 1}, // cached: read once for two Resolve calls
+		{"anonymous", "/", "", "", Metric, 0},
+	}
+	for _, tt := range tests {
+		profileReads = 0
+		if got := resolve(tt.target, tt.header, tt.userID); got != tt.want || profileReads != tt.reads {
+			t.Errorf("%s: got %s with %d profile reads, want %s with %d", tt.name, got, profileReads, tt.want, tt.reads)
+		}
+	}
+
+	profileErr = errors.New("db down")
+	if got := resolve("/", "", "user_1"); got != Metric {
+		t.Errorf("profile errors should fall back to metric, got %s", got)
+	}
+}
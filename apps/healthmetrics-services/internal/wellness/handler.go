@@ -0,0 +1,120 @@
+// Package wellness serves the user's body and activity data (synced WHOOP workouts, weight
+// entries) with display values in the user's unit system next to the metric fields.
+package wellness
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"healthmetrics-services/internal/httpx"
+	"healthmetrics-services/internal/units"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// NewListWorkoutsHandler serves GET /v1/wellness/workouts?limit=50&units=metric|imperial.
+// Newest workout first; distance_display is km or mi depending on the resolved unit system.
+// Example: {"units": "imperial", "workouts": [{"distance_km": 5.25, "distance_display": {"value": 3.26, "unit": "mi"}, ...}]}
+func NewListWorkoutsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID") // set by auth middleware after session validation
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+
+		limit, ok := parseLimit(c)
+		if !ok {
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		workouts, err := listWorkoutsFunc(c.Request.Context(), pool, userID, limit)
+		if err != nil {
+			requestID := c.GetHeader("X-Request-ID")
+			log.Printf("wellness_workouts_read_error request_id=%s user_id=%s err=%v", requestID, userID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load workouts")
+			return
+		}
+
+		system := units.Resolve(c, pool)
+		for i := range workouts {
+			if workouts[i].DistanceKm != nil {
+				distance := units.Distance(*workouts[i].DistanceKm, system)
+				workouts[i].DistanceDisplay = &distance
+			}
+		}
+		c.JSON(200, gin.H{"units": system, "workouts": workouts})
+	}
+}
+
+// NewListWeightHandler serves GET /v1/wellness/weight?limit=50&units=metric|imperial.
+// Newest entry first; weight_display / muscle_mass_display are kg or lb.
+// Example: {"units": "imperial", "entries": [{"weight_kg": 68.04, "weight_display": {"value": 150, "unit": "lb"}, ...}]}
+func NewListWeightHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" {
+			httpx.WriteError(c, 401, "UNAUTHORIZED", "Missing authenticated user")
+			return
+		}
+
+		limit, ok := parseLimit(c)
+		if !ok {
+			return
+		}
+
+		pool, ok := httpx.PoolFromContext(c)
+		if !ok {
+			return
+		}
+
+		entries, err := listWeightEntriesFunc(c.Request.Context(), pool, userID, limit)
+		if err != nil {
+			requestID := c.GetHeader("X-Request-ID")
+			log.Printf("wellness_weight_read_error request_id=%s user_id=%s err=%v", requestID, userID, err)
+			httpx.WriteError(c, 500, "INTERNAL_ERROR", "Failed to load weight entries")
+			return
+		}
+
+		system := units.Resolve(c, pool)
+		for i := range entries {
+			entries[i].WeightDisplay = bodyWeight(entries[i].WeightKg, system)
+			entries[i].MuscleMassDisplay = bodyWeight(entries[i].MuscleMassKg, system)
+		}
+		c.JSON(200, gin.H{"units": system, "entries": entries})
+	}
+}
+
+// parseLimit reads ?limit= (default 50) and writes a 400 when it is out of range.
+func parseLimit(c *gin.Context) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return defaultListLimit, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		httpx.WriteError(c, 400, "INVALID_REQUEST", fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+		return 0, false
+	}
+	return limit, true
+}
+
+// bodyWeight is the display value for an optional weight (nil stays nil).
+func bodyWeight(kilograms *float64, system units.System) *units.Quantity {
+	if kilograms == nil {
+		return nil
+	}
+	weight := units.BodyWeight(*kilograms, system)
+	return &weight
+}
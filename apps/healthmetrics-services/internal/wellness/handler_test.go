@@ -0,0 +1,123 @@
+package wellness
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/units"
+)
+
+func newRouter(userID string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", &pgxpool.Pool{}) // zero-value pool is enough for stubbed helpers
+		if userID != "" {
+			c.Set("userID", userID)
+		}
+		c.Next()
+	})
+	router.GET("/v1/wellness/workouts", NewListWorkoutsHandler())
+	router.GET("/v1/wellness/weight", NewListWeightHandler())
+	return router
+}
+
+func floatPtr(value float64) *float64 { return &value }
+
+func TestListWorkoutsHandler_DistanceDisplay(t *testing.T) {
+	orig := listWorkoutsFunc
+	defer func() { listWorkoutsFunc = orig }()
+	listWorkoutsFunc = func(_ context.Context, _ *pgxpool.Pool, userID string, limit int) ([]Workout, error) {
+		if userID != "user_1" || limit != defaultListLimit {
+			t.Fatalf("unexpected user %q / limit %d", userID, limit)
+		}
+		return []Workout{
+			{ID: "w_1", Provider: "whoop", StartAt: time.Date(2026, 10, 18, 7, 0, 0, 0, time.UTC), DistanceKm: floatPtr(5.25)},
+			{ID: "w_2", Provider: "whoop", StartAt: time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC)}, // strength session, no distance
+		}, nil
+	}
+
+	cases := []struct {
+		query string
+		want  units.Quantity
+	}{
+		{"?units=imperial", units.Quantity{Value: 3.26, Unit: units.Mile}},
+		{"?units=metric", units.Quantity{Value: 5.25, Unit: units.Kilometer}},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		newRouter("user_1").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/wellness/workouts"+tc.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tc.query, rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Workouts []Workout `json:"workouts"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if len(body.Workouts) != 2 || *body.Workouts[0].DistanceKm != 5.25 {
+			t.Fatalf("%s: expected the metric field unchanged, got %+v", tc.query, body.Workouts)
+		}
+		if got := body.Workouts[0].DistanceDisplay; got == nil || *got != tc.want {
+			t.Fatalf("%s: expected %+v, got %+v", tc.query, tc.want, got)
+		}
+		if body.Workouts[1].DistanceDisplay != nil {
+			t.Fatalf("%s: expected no display without a distance, got %+v", tc.query, body.Workouts[1].DistanceDisplay)
+		}
+	}
+}
+
+func TestListWeightHandler_BodyWeightDisplay(t *testing.T) {
+	orig := listWeightEntriesFunc
+	defer func() { listWeightEntriesFunc = orig }()
+	listWeightEntriesFunc = func(context.Context, *pgxpool.Pool, string, int) ([]WeightEntry, error) {
+		return []WeightEntry{{ID: "e_1", Date: "2026-10-18", WeightKg: poundsToKilograms(floatPtr(150))}}, nil
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/wellness/weight", nil)
+	req.Header.Set("X-User-Units", "imperial")
+	newRouter("user_1").ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Units   string        `json:"units"`
+		Entries []WeightEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	entry := body.Entries[0]
+	if body.Units != "imperial" || *entry.WeightKg != 68.04 {
+		t.Fatalf("unexpected entry %+v (units %s)", entry, body.Units)
+	}
+	if entry.WeightDisplay == nil || *entry.WeightDisplay != (units.Quantity{Value: 150, Unit: units.Pound}) {
+		t.Fatalf("expected 150 lb, got %+v", entry.WeightDisplay)
+	}
+	if entry.MuscleMassDisplay != nil {
+		t.Fatalf("expected no muscle mass display, got %+v", entry.MuscleMassDisplay)
+	}
+}
+
+func TestListHandlers_Validation(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newRouter("").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/wellness/workouts", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	newRouter("user_1").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/wellness/weight?limit=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
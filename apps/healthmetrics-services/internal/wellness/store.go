@@ -0,0 +1,130 @@
+package wellness
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"healthmetrics-services/internal/units"
+)
+
+// Swappable DB helpers so tests can run without Postgres.
+var (
+	listWorkoutsFunc      = listWorkouts
+	listWeightEntriesFunc = listWeightEntries
+)
+
+// Workout is one synced device workout (integration_workout), metric as stored.
+type Workout struct {
+	ID              string          `json:"id"`
+	Provider        string          `json:"provider"` // "whoop"
+	SportName       *string         `json:"sport_name"`
+	StartAt         time.Time       `json:"start_at"`
+	EndAt           time.Time       `json:"end_at"`
+	LocalDate       string          `json:"local_date"` // YYYY-MM-DD in the workout's own timezone
+	Strain          *float64        `json:"strain"`
+	CaloriesKcal    *float64        `json:"calories_kcal"`
+	DistanceKm      *float64        `json:"distance_km"`
+	DistanceDisplay *units.Quantity `json:"distance_display,omitempty"` // km or mi; nil without a distance
+}
+
+// WeightEntry is one body measurement (weight_entries). The table stores pounds; the
+// canonical response fields are kilograms like every other metric field.
+type WeightEntry struct {
+	ID                string          `json:"id"`
+	Date              string          `json:"date"` // YYYY-MM-DD
+	WeightKg          *float64        `json:"weight_kg"`
+	WeightDisplay     *units.Quantity `json:"weight_display,omitempty"` // kg or lb; nil without a weight
+	BodyFatPercentage *float64        `json:"body_fat_percentage"`
+	MuscleMassKg      *float64        `json:"muscle_mass_kg"`
+	MuscleMassDisplay *units.Quantity `json:"muscle_mass_display,omitempty"`
+}
+
+// listWorkouts returns a user's synced workouts, newest first (deleted upstream ones excluded).
+func listWorkouts(ctx context.Context, pool *pgxpool.Pool, userID string, limit int) ([]Workout, error) {
+	const query = `
+		SELECT
+			w.id, i.provider::text, w.sport_name, w.start_at, w.end_at, w.local_date::text,
+			w.strain, w.calories_kcal, w.distance_km
+		FROM integration_workout w
+		JOIN integration i ON i.id = w.integration_id
+		WHERE i.user_id = $1 AND w.deleted_at IS NULL
+		ORDER BY w.start_at DESC
+		LIMIT $2
+	`
+
+	rows, err := pool.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []Workout{}
+	for rows.Next() {
+		var workout Workout
+		if err := rows.Scan(
+			&workout.ID,
+			&workout.Provider,
+			&workout.SportName,
+			&workout.StartAt,
+			&workout.EndAt,
+			&workout.LocalDate,
+			&workout.Strain,
+			&workout.CaloriesKcal,
+			&workout.DistanceKm,
+		); err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, workout)
+	}
+	return workouts, rows.Err()
+}
+
+// listWeightEntries returns a user's weight entries, newest date first.
+func listWeightEntries(ctx context.Context, pool *pgxpool.Pool, userID string, limit int) ([]WeightEntry, error) {
+	const query = `
+		SELECT
+			id, date::text, weight_lbs::float8, body_fat_percentage::float8, muscle_mass_lbs::float8
+		FROM weight_entries
+		WHERE user_id = $1
+		ORDER BY date DESC, created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := pool.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []WeightEntry{}
+	for rows.Next() {
+		var entry WeightEntry
+		var weightLbs, muscleMassLbs *float64
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.Date,
+			&weightLbs,
+			&entry.BodyFatPercentage,
+			&muscleMassLbs,
+		); err != nil {
+			return nil, err
+		}
+		entry.WeightKg = poundsToKilograms(weightLbs)
+		entry.MuscleMassKg = poundsToKilograms(muscleMassLbs)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// poundsToKilograms converts a stored weight to the canonical kg, kept to the column's
+// 2 decimals (150 lb -> 68.04 kg). nil stays nil.
+func poundsToKilograms(pounds *float64) *float64 {
+	if pounds == nil {
+		return nil
+	}
+	kilograms := math.Round(units.PoundsToKilograms(*pounds)*100) / 100
+	return &kilograms
+}
@@ -9,10 +9,20 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
+
+	"healthmetrics-services/internal/retry"
 )
 
 const whoopAPIBase = "https://api.prod.whoop.com/developer"
 
+// whoopTimeLayout is the ISO 8601 form WHOOP uses for start/end filters (UTC, milliseconds).
+const whoopTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// defaultSyncOverlap is how far before the watermark an incremental sync starts again, so records
+// WHOOP rescored after we fetched them (sleep/recovery are updated for a while) are picked up.
+const defaultSyncOverlap = 48 * time.Hour
+
 const (
 	resourceProfile         = "profile"
 	resourceBodyMeasurement = "body_measurement"
@@ -22,6 +32,26 @@ const (
 	resourceWorkout         = "workout"
 )
 
+// whoopCollections are the paginated endpoints, in sync order.
+var whoopCollections = []struct {
+	path         string
+	resourceType string
+}{
+	{"/v2/cycle", resourceCycle},
+	{"/v2/recovery", resourceRecovery},
+	{"/v2/activity/sleep", resourceSleep},
+	{"/v2/activity/workout", resourceWorkout},
+}
+
+// reconciledResources are checked for upstream deletions after each incremental window. They
+// are filtered by their start time, which the normalized rows keep (recovery has none; its
+// deletions arrive by webhook).
+var reconciledResources = map[string]bool{
+	resourceCycle:   true,
+	resourceSleep:   true,
+	resourceWorkout: true,
+}
+
 type collectionResponse struct {
 	Records   []map[string]any `json:"records"`
 	NextToken string           `json:"next_token"`
@@ -99,14 +129,44 @@ func (s *Service) fetchWhoopObject(ctx context.Context, accessToken, path string
 
 func (s *Service) fetchWhoopCollection(ctx context.Context, integrationID, accessToken, path, resourceType string) error {
 	// Collection endpoints return a list of records + next_token for pagination.
-	// We cap pages to avoid over-fetching on initial sync.
+	// Incremental: once a resource has a watermark (end of the last complete window), we only ask
+	// for records from watermark - overlap to now, and follow next_token to the end.
+	// First sync (no watermark): newest pages only, capped to avoid over-fetching.
 	const pageLimit = 25
-	const maxPages = 5
+	const firstSyncMaxPages = 5
+
+	watermark, err := s.DB.GetSyncWatermark(ctx, integrationID, resourceType)
+	if err != nil {
+		return err
+	}
+
+	// Fixed upper bound: records created while we page don't shift the pages, and the next
+	// sync starts from here.
+	windowEnd := time.Now().UTC()
+	window := url.Values{}
+	window.Set("end", windowEnd.Format(whoopTimeLayout))
+
+	maxPages := firstSyncMaxPages
+	var windowStart time.Time
+	if watermark != nil {
+		windowStart = watermark.Add(-s.syncOverlap()).UTC()
+		window.Set("start", windowStart.Format(whoopTimeLayout))
+		maxPages = 0 // no cap
+	}
+
+	// A complete window (incremental syncs) lists every record WHOOP still has in it, so stored
+	// records missing from it were deleted upstream. Capped first syncs can't tell.
+	reconcile := watermark != nil && reconciledResources[resourceType]
+	var seenIDs []string
 
 	nextToken := ""
-	for page := 0; page < maxPages; page++ {
+	stored := 0
+	for page := 0; maxPages == 0 || page < maxPages; page++ {
 		params := url.Values{}
 		params.Set("limit", strconv.Itoa(pageLimit))
+		for key, values := range window {
+			params[key] = values
+		}
 		if nextToken != "" {
 			params.Set("next_token", nextToken)
 		}
@@ -121,25 +181,20 @@ func (s *Service) fetchWhoopCollection(ctx context.Context, integrationID, acces
 			return err
 		}
 
-		for _, record := range payload.Records {
-			sourceID := extractSourceID(record, resourceType)
-			if sourceID == "" {
-				continue
-			}
-			// Store each record as raw JSON (jsonb).
-			recordBytes, err := json.Marshal(record)
-			if err != nil {
-				return err
-			}
-			if err := s.DB.UpsertIntegrationRawEvent(ctx, integrationID, resourceType, sourceID, recordBytes); err != nil {
-				return err
-			}
-
-			// Normalize into provider-agnostic tables (sleep/recovery/workout/cycle).
-			if err := s.normalizeWhoopRecord(ctx, integrationID, resourceType, record, string(recordBytes)); err != nil {
-				return err
+		count, err := s.storeCollectionPage(ctx, s.DB, integrationID, resourceType, payload.Records)
+		if err != nil {
+			return err
+		}
+		stored += count
+		if reconcile {
+			for _, record := range payload.Records {
+				if sourceID := extractSourceID(record, ""); sourceID != "" {
+					seenIDs = append(seenIDs, sourceID)
+				}
 			}
 		}
+		// Running total for the sync job's "importing your history" progress.
+		reportSyncProgress(ctx, resourceType, stored)
 
 		if payload.NextToken == "" {
 			break
@@ -147,17 +202,78 @@ func (s *Service) fetchWhoopCollection(ctx context.Context, integrationID, acces
 		nextToken = payload.NextToken
 	}
 
-	// After all sleep rows are upserted, mark the longest sleep per day as primary.
+	if reconcile {
+		missing, err := s.DB.TombstoneMissingRecords(ctx, integrationID, resourceType, windowStart, windowEnd, seenIDs)
+		if err != nil {
+			return err
+		}
+		if missing > 0 {
+			s.logWithRequestID(RequestIDFromContext(ctx), "whoop sync tombstoned integration=%s resource=%s missing=%d", integrationID, resourceType, missing)
+		}
+	}
+
+	// After all sleep rows are upserted (and deleted ones tombstoned), mark the longest sleep per day as primary.
 	if resourceType == resourceSleep {
 		if err := s.DB.UpdatePrimarySleep(ctx, integrationID); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	// Advance the watermark only after the whole window is stored; a failed sync retries it.
+	return s.DB.UpdateSyncWatermark(ctx, integrationID, resourceType, windowEnd)
+}
+
+// storeCollectionPage stores one page of collection records (raw + normalized) through db and
+// returns how many were stored (records without an id are skipped).
+func (s *Service) storeCollectionPage(ctx context.Context, db DB, integrationID, resourceType string, records []map[string]any) (int, error) {
+	stored := 0
+	for _, record := range records {
+		sourceID := extractSourceID(record, resourceType)
+		if sourceID == "" {
+			continue
+		}
+		// Store each record as raw JSON (jsonb).
+		recordBytes, err := json.Marshal(record)
+		if err != nil {
+			return stored, err
+		}
+		if err := db.UpsertIntegrationRawEvent(ctx, integrationID, resourceType, sourceID, recordBytes); err != nil {
+			return stored, err
+		}
+
+		// Normalize into provider-agnostic tables (sleep/recovery/workout/cycle).
+		if err := s.normalizeWhoopRecord(ctx, db, integrationID, resourceType, record, string(recordBytes)); err != nil {
+			return stored, err
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// syncOverlap is Service.SyncOverlap, or the default when unset.
+func (s *Service) syncOverlap() time.Duration {
+	if s.SyncOverlap > 0 {
+		return s.SyncOverlap
+	}
+	return defaultSyncOverlap
 }
 
 func (s *Service) fetchWhoop(ctx context.Context, accessToken, path string, params url.Values) ([]byte, error) {
+	// Transient failures (timeouts, 429/5xx) are retried with the shared policy;
+	// a zero Retry policy means a single attempt.
+	var payload []byte
+	err := s.Retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		payload, err = s.fetchWhoopOnce(ctx, accessToken, path, params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (s *Service) fetchWhoopOnce(ctx context.Context, accessToken, path string, params url.Values) ([]byte, error) {
 	// Build full URL + query params.
 	endpoint := fmt.Sprintf("%s%s", whoopAPIBase, path)
 	if len(params) > 0 {
@@ -173,6 +289,13 @@ func (s *Service) fetchWhoop(ctx context.Context, accessToken, path string, para
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Accept", "application/json")
 
+	// Take a slot from the shared quota (blocks while WHOOP has us paused).
+	if s.RateLimiter != nil {
+		if err := s.RateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	resp, err := s.HTTPClient.Do(req)
 	if err != nil {
 		s.recordWhoopAPIMetrics(err)
@@ -180,13 +303,19 @@ func (s *Service) fetchWhoop(ctx context.Context, accessToken, path string, para
 	}
 	defer resp.Body.Close()
 
+	// Every response carries the app's remaining quota; a 429 pauses all callers.
+	if s.RateLimiter != nil {
+		s.RateLimiter.Observe(resp)
+	}
+
 	if resp.StatusCode == http.StatusUnauthorized {
 		err := errors.New("whoop unauthorized")
 		s.recordWhoopAPIMetrics(err)
 		return nil, err
 	}
 	if resp.StatusCode >= 400 {
-		err := fmt.Errorf("whoop api error: %s", resp.Status)
+		// Typed error keeps the status + Retry-After for the retry policy.
+		err := retry.NewHTTPError("whoop", resp)
 		s.recordWhoopAPIMetrics(err)
 		return nil, err
 	}
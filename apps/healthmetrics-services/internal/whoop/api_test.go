@@ -0,0 +1,153 @@
+package whoop
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pagingTransport serves a collection with `pages` pages (one record each) and records the
+// query of every request. failPage > 0 answers that page with a 500.
+type pagingTransport struct {
+	pages    int
+	failPage int
+	queries  []url.Values
+}
+
+func (p *pagingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	p.queries = append(p.queries, query)
+
+	page := 1
+	if token := query.Get("next_token"); token != "" {
+		page, _ = strconv.Atoi(token)
+	}
+	if page == p.failPage {
+		return jsonResponse(500, `{"error":"boom"}`), nil
+	}
+	next := ""
+	if page < p.pages {
+		next = strconv.Itoa(page + 1)
+	}
+	// Newest first, like WHOOP: page n holds a record from n days before Oct 28.
+	start := time.Date(2026, 10, 28-page, 7, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	return jsonResponse(200, fmt.Sprintf(`{"records":[{"id":"record_%d","start":%q}],"next_token":%q}`, page, start, next)), nil
+}
+
+func TestFetchWhoopCollection_FirstSyncIsCapped(t *testing.T) {
+	transport := &pagingTransport{pages: 20}
+	db := &fakeDB{}
+	service := &Service{DB: db, HTTPClient: &http.Client{Transport: transport}}
+
+	before := time.Now()
+	if err := service.fetchWhoopCollection(context.Background(), "integration_1", "token", "/v2/activity/workout", resourceWorkout); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	if len(transport.queries) != 5 {
+		t.Fatalf("expected the first sync to stop at 5 pages, got %d", len(transport.queries))
+	}
+	first := transport.queries[0]
+	if first.Get("start") != "" || first.Get("end") == "" {
+		t.Fatalf("first sync: expected only an end bound, got %v", first)
+	}
+	watermark, ok := db.watermarks[resourceWorkout]
+	if !ok || watermark.Before(before) {
+		t.Fatalf("expected the watermark to be set to the window end, got %v", db.watermarks)
+	}
+	if len(db.reconcileCalls) != 0 {
+		t.Fatalf("a capped first sync must not reconcile deletions, got %v", db.reconcileCalls)
+	}
+}
+
+func TestFetchWhoopCollection_IncrementalWindow(t *testing.T) {
+	transport := &pagingTransport{pages: 8}
+	lastSync := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	db := &fakeDB{watermarks: map[string]time.Time{resourceWorkout: lastSync}}
+	service := &Service{DB: db, HTTPClient: &http.Client{Transport: transport}, SyncOverlap: 6 * time.Hour}
+
+	if err := service.fetchWhoopCollection(context.Background(), "integration_1", "token", "/v2/activity/workout", resourceWorkout); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	// No page cap: all 8 pages, same window on every page.
+	if len(transport.queries) != 8 || len(db.rawEvents) != 8 {
+		t.Fatalf("expected 8 pages / records, got %d / %d", len(transport.queries), len(db.rawEvents))
+	}
+	for _, query := range transport.queries {
+		if query.Get("start") != "2026-10-01T06:00:00.000Z" {
+			t.Fatalf("expected start = watermark - overlap, got %q", query.Get("start"))
+		}
+		if query.Get("end") != transport.queries[0].Get("end") {
+			t.Fatalf("window end moved between pages")
+		}
+	}
+	end, err := time.Parse(whoopTimeLayout, transport.queries[0].Get("end"))
+	if err != nil {
+		t.Fatalf("parse end: %v", err)
+	}
+	if !db.watermarks[resourceWorkout].Truncate(time.Millisecond).Equal(end) {
+		t.Fatalf("expected watermark %s to equal the window end %s", db.watermarks[resourceWorkout], end)
+	}
+}
+
+func TestFetchWhoopCollection_FailureKeepsWatermark(t *testing.T) {
+	transport := &pagingTransport{pages: 4, failPage: 3}
+	lastSync := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	db := &fakeDB{watermarks: map[string]time.Time{resourceWorkout: lastSync}}
+	service := &Service{DB: db, HTTPClient: &http.Client{Transport: transport}}
+
+	if err := service.fetchWhoopCollection(context.Background(), "integration_1", "token", "/v2/activity/workout", resourceWorkout); err == nil {
+		t.Fatalf("expected the failed page to fail the fetch")
+	}
+	if !db.watermarks[resourceWorkout].Equal(lastSync) {
+		t.Fatalf("watermark should not move after a failed window, got %s", db.watermarks[resourceWorkout])
+	}
+	if len(db.reconcileCalls) != 0 {
+		t.Fatalf("an incomplete window must not reconcile deletions, got %v", db.reconcileCalls)
+	}
+	// Default overlap when unset.
+	if start := transport.queries[0].Get("start"); start != "2026-09-29T12:00:00.000Z" {
+		t.Fatalf("expected the default 48h overlap, got %q", start)
+	}
+}
+
+func TestFetchWhoopCollection_ReconcilesIncrementalWindow(t *testing.T) {
+	transport := &pagingTransport{pages: 3}
+	lastSync := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	db := &fakeDB{watermarks: map[string]time.Time{resourceSleep: lastSync, resourceRecovery: lastSync}}
+	service := &Service{DB: db, HTTPClient: &http.Client{Transport: transport}, SyncOverlap: 6 * time.Hour}
+
+	if err := service.fetchWhoopCollection(context.Background(), "integration_1", "token", "/v2/activity/sleep", resourceSleep); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	// Every id WHOOP returned for the window is kept; the rest of the window is tombstoned.
+	if len(db.reconcileCalls) != 1 {
+		t.Fatalf("expected one reconciliation, got %v", db.reconcileCalls)
+	}
+	call := db.reconcileCalls[0]
+	if call.resourceType != resourceSleep || strings.Join(call.seenIDs, ",") != "record_1,record_2,record_3" {
+		t.Fatalf("unexpected reconciliation: %+v", call)
+	}
+	if !call.start.Equal(lastSync.Add(-6*time.Hour)) || !call.end.Equal(db.watermarks[resourceSleep]) {
+		t.Fatalf("expected the fetched window, got %s - %s", call.start, call.end)
+	}
+	if db.primarySleepUpdates != 1 {
+		t.Fatalf("expected primary sleep to be recomputed after reconciling, got %d", db.primarySleepUpdates)
+	}
+
+	// Recovery has no start time to reconcile by.
+	transport.queries = nil
+	if err := service.fetchWhoopCollection(context.Background(), "integration_1", "token", "/v2/recovery", resourceRecovery); err != nil {
+		t.Fatalf("fetch recovery: %v", err)
+	}
+	if len(db.reconcileCalls) != 1 {
+		t.Fatalf("recovery should not be reconciled, got %v", db.reconcileCalls)
+	}
+}
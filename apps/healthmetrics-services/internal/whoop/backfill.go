@@ -0,0 +1,337 @@
+package whoop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SyncJobBackfill walks every collection back to the user's first record. It is queued after
+// the initial import (which only fetches the newest pages) and via POST /internal/whoop/backfill.
+const SyncJobBackfill = "backfill"
+
+// defaultBackfillPageDelay spaces backfill requests out (~60/min), well under WHOOP's per-app
+// limit, so scheduled syncs still have room while a long history is imported.
+const defaultBackfillPageDelay = time.Second
+
+// Backfill states per resource (BackfillResourceStatus.State).
+const (
+	backfillPending = "pending"
+	backfillRunning = "running"
+	backfillDone    = "done"
+)
+
+// BackfillCursor is a resource's backfill position (the backfill_* columns of
+// integration_sync_cursor). It is saved after every page so a crash or deploy resumes
+// from NextToken instead of starting over.
+type BackfillCursor struct {
+	ResourceType string
+	EndAt        *time.Time // upper bound fixed when the backfill started (nil = not started)
+	NextToken    *string
+	Records      int
+	OldestAt     *time.Time // start of the oldest record stored so far
+	CompletedAt  *time.Time
+}
+
+// Backfill imports the full history of every collection, resuming each from its cursor.
+// Resources already completed are skipped, so re-running a finished backfill is a no-op.
+func (s *Service) Backfill(ctx context.Context, userID, integrationID string) error {
+	start := time.Now()
+	requestID := RequestIDFromContext(ctx)
+	// History can wait: leave the tail of each quota window to regular syncs and webhooks.
+	ctx = WithLowPriority(ctx)
+
+	accessToken, err := s.accessToken(ctx, integrationID)
+	if err != nil {
+		s.logWithRequestID(requestID, "whoop backfill failed user=%s integration=%s err=%v", userID, integrationID, err)
+		return err
+	}
+
+	for _, collection := range whoopCollections {
+		if err := s.backfillCollection(ctx, integrationID, accessToken, collection.path, collection.resourceType); err != nil {
+			err = fmt.Errorf("%s backfill: %w", collection.resourceType, err)
+			s.logWithRequestID(requestID, "whoop backfill failed user=%s integration=%s err=%v", userID, integrationID, err)
+			return err
+		}
+	}
+
+	s.logWithRequestID(requestID, "whoop backfill completed user=%s integration=%s duration_ms=%d", userID, integrationID, time.Since(start).Milliseconds())
+	return nil
+}
+
+func (s *Service) backfillCollection(ctx context.Context, integrationID, accessToken, path, resourceType string) error {
+	const pageLimit = 25
+
+	cursor, err := s.DB.GetBackfillCursor(ctx, integrationID, resourceType)
+	if err != nil {
+		return err
+	}
+	if cursor.CompletedAt != nil {
+		return nil
+	}
+	if cursor.EndAt == nil {
+		// First run: pin the window end so resumed pages (next_token) page the same result set.
+		now := time.Now().UTC()
+		cursor.EndAt = &now
+	}
+	reportSyncProgress(ctx, resourceType, cursor.Records)
+
+	for {
+		params := url.Values{}
+		params.Set("limit", strconv.Itoa(pageLimit))
+		params.Set("end", cursor.EndAt.UTC().Format(whoopTimeLayout))
+		if cursor.NextToken != nil {
+			params.Set("next_token", *cursor.NextToken)
+		}
+
+		body, err := s.fetchWhoop(ctx, accessToken, path, params)
+		if err != nil {
+			return err
+		}
+
+		var payload collectionResponse
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return err
+		}
+
+		// The page, the cursor past it and its record count commit together: a crash either
+		// loses all three (the page is fetched again) or none, so Records never counts it twice.
+		next := cursor
+		err = s.DB.InTx(ctx, func(tx DB) error {
+			stored, err := s.storeCollectionPage(ctx, tx, integrationID, resourceType, payload.Records)
+			if err != nil {
+				return err
+			}
+
+			next.Records += stored
+			if oldest := oldestRecordStart(payload.Records); oldest != nil && (next.OldestAt == nil || oldest.Before(*next.OldestAt)) {
+				next.OldestAt = oldest
+			}
+			if payload.NextToken == "" {
+				now := time.Now().UTC()
+				next.CompletedAt = &now
+				next.NextToken = nil
+			} else {
+				nextToken := payload.NextToken
+				next.NextToken = &nextToken
+			}
+			return tx.SaveBackfillCursor(ctx, integrationID, next)
+		})
+		if err != nil {
+			return err
+		}
+		cursor = next
+		reportSyncProgress(ctx, resourceType, cursor.Records)
+
+		if cursor.CompletedAt != nil {
+			break
+		}
+		if err := sleepContext(ctx, s.backfillPageDelay()); err != nil {
+			return err
+		}
+	}
+
+	// After all sleep rows are upserted, mark the longest sleep per day as primary.
+	if resourceType == resourceSleep {
+		if err := s.DB.UpdatePrimarySleep(ctx, integrationID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillPageDelay is Service.BackfillPageDelay, or the default when unset.
+func (s *Service) backfillPageDelay() time.Duration {
+	if s.BackfillPageDelay > 0 {
+		return s.BackfillPageDelay
+	}
+	return defaultBackfillPageDelay
+}
+
+// oldestRecordStart returns the earliest start (created_at for records without one) on a page.
+func oldestRecordStart(records []map[string]any) *time.Time {
+	var oldest *time.Time
+	for _, record := range records {
+		value := getString(record, "start")
+		if value == "" {
+			value = getString(record, "created_at")
+		}
+		if value == "" {
+			continue
+		}
+		startAt, err := parseTime(value)
+		if err != nil {
+			continue
+		}
+		if oldest == nil || startAt.Before(*oldest) {
+			startAt = startAt.UTC()
+			oldest = &startAt
+		}
+	}
+	return oldest
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type BackfillRequest struct {
+	UserID string `json:"userId"`
+}
+
+func (s *Service) BackfillHandler(w http.ResponseWriter, r *http.Request) {
+	// This handler:
+	// 1) validates input + loads the connected integration
+	// 2) queues a backfill job (or returns the one already queued/running)
+	var req BackfillRequest
+	requestID := requestIDFromHeaders(r)
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == "" {
+		http.Error(w, "missing fields", http.StatusBadRequest)
+		return
+	}
+
+	ctx := WithRequestID(r.Context(), requestID)
+
+	integration, err := s.DB.GetIntegration(ctx, req.UserID, "whoop")
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "integration not found", http.StatusNotFound)
+			return
+		}
+		s.logWithRequestID(requestID, "whoop db error (get integration) user=%s err=%v", req.UserID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	if integration.Status != "connected" {
+		http.Error(w, "integration not connected", http.StatusConflict)
+		return
+	}
+
+	jobID, err := s.DB.EnqueueSyncJob(ctx, integration.ID, SyncJobBackfill)
+	if err != nil {
+		s.logWithRequestID(requestID, "whoop db error (enqueue sync job) user=%s integration=%s err=%v", req.UserID, integration.ID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.logWithRequestID(requestID, "whoop sync job queued user=%s integration=%s job=%s kind=%s", req.UserID, integration.ID, jobID, SyncJobBackfill)
+	if s.Jobs != nil {
+		s.Jobs.Wake()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "queued", "job_id": jobID})
+}
+
+// BackfillResourceStatus is one collection's backfill progress.
+type BackfillResourceStatus struct {
+	Resource    string     `json:"resource"`
+	State       string     `json:"state"` // pending / running / done
+	Records     int        `json:"records"`
+	OldestAt    *time.Time `json:"oldest_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+type BackfillStatusResponse struct {
+	IntegrationStatus string                   `json:"integration_status"`
+	Done              bool                     `json:"done"`
+	Resources         []BackfillResourceStatus `json:"resources"`
+	Job               *SyncJob                 `json:"job"`
+}
+
+func (s *Service) BackfillStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// This handler:
+	// 1) loads the user's integration
+	// 2) returns per-resource backfill progress + the latest backfill job
+	requestID := requestIDFromHeaders(r)
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		http.Error(w, "missing fields", http.StatusBadRequest)
+		return
+	}
+
+	ctx := WithRequestID(r.Context(), requestID)
+
+	integration, err := s.DB.GetIntegration(ctx, userID, "whoop")
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "integration not found", http.StatusNotFound)
+			return
+		}
+		s.logWithRequestID(requestID, "whoop db error (get integration) user=%s err=%v", userID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	cursors, err := s.DB.ListBackfillCursors(ctx, integration.ID)
+	if err != nil {
+		s.logWithRequestID(requestID, "whoop db error (list backfill cursors) user=%s integration=%s err=%v", userID, integration.ID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	response := BackfillStatusResponse{IntegrationStatus: integration.Status, Done: true}
+	byResource := make(map[string]BackfillCursor, len(cursors))
+	for _, cursor := range cursors {
+		byResource[cursor.ResourceType] = cursor
+	}
+	// Always list every collection, in sync order (pending until its backfill starts).
+	for _, collection := range whoopCollections {
+		cursor := byResource[collection.resourceType]
+		status := BackfillResourceStatus{
+			Resource:    collection.resourceType,
+			State:       backfillPending,
+			Records:     cursor.Records,
+			OldestAt:    cursor.OldestAt,
+			CompletedAt: cursor.CompletedAt,
+		}
+		switch {
+		case cursor.CompletedAt != nil:
+			status.State = backfillDone
+		case cursor.EndAt != nil:
+			status.State = backfillRunning
+		}
+		if status.State != backfillDone {
+			response.Done = false
+		}
+		response.Resources = append(response.Resources, status)
+	}
+
+	job, err := s.DB.GetLatestSyncJob(ctx, integration.ID, SyncJobBackfill)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		s.logWithRequestID(requestID, "whoop db error (get sync job) user=%s integration=%s err=%v", userID, integration.ID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	default:
+		if job.Progress == nil {
+			job.Progress = map[string]int{}
+		}
+		response.Job = &job
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,179 @@
+package whoop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newBackfillService(t *testing.T, transport http.RoundTripper) (*Service, *fakeDB) {
+	t.Helper()
+	setEncryptionKey(t)
+
+	accessEnc, err := Encrypt("access_token")
+	if err != nil {
+		t.Fatalf("encrypt access token: %v", err)
+	}
+	db := &fakeDB{
+		hasToken: true,
+		tokenRecord: IntegrationTokenRecord{
+			AccessTokenEncrypted: accessEnc,
+			ExpiresAt:            ptrTime(time.Now().Add(time.Hour)),
+		},
+	}
+	service := &Service{
+		DB:                db,
+		HTTPClient:        &http.Client{Transport: transport},
+		BackfillPageDelay: time.Nanosecond,
+	}
+	return service, db
+}
+
+func TestBackfill_WalksAllPages(t *testing.T) {
+	transport := &pagingTransport{pages: 12}
+	service, db := newBackfillService(t, transport)
+
+	if err := service.Backfill(context.Background(), "user_1", "integration_1"); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+
+	// No page cap: 12 pages for each of the 4 collections, all with the same pinned end.
+	if len(transport.queries) != 48 {
+		t.Fatalf("expected 48 requests, got %d", len(transport.queries))
+	}
+	for _, query := range transport.queries {
+		if query.Get("start") != "" || query.Get("end") == "" {
+			t.Fatalf("backfill should only bound the end, got %v", query)
+		}
+	}
+	for _, collection := range whoopCollections {
+		cursor := db.backfillCursors[collection.resourceType]
+		if cursor.CompletedAt == nil || cursor.NextToken != nil || cursor.Records != 12 {
+			t.Fatalf("%s: expected a completed cursor with 12 records, got %+v", collection.resourceType, cursor)
+		}
+		if cursor.OldestAt == nil || !cursor.OldestAt.Equal(time.Date(2026, 10, 16, 7, 0, 0, 0, time.UTC)) {
+			t.Fatalf("%s: unexpected oldest date %v", collection.resourceType, cursor.OldestAt)
+		}
+	}
+	// Backfill doesn't touch the incremental watermark.
+	if len(db.watermarks) != 0 {
+		t.Fatalf("unexpected watermarks: %v", db.watermarks)
+	}
+
+	// A finished backfill is a no-op.
+	transport.queries = nil
+	if err := service.Backfill(context.Background(), "user_1", "integration_1"); err != nil {
+		t.Fatalf("second backfill: %v", err)
+	}
+	if len(transport.queries) != 0 {
+		t.Fatalf("expected no requests after completion, got %d", len(transport.queries))
+	}
+}
+
+func TestBackfill_ResumesFromCursor(t *testing.T) {
+	transport := &pagingTransport{pages: 6, failPage: 4}
+	service, db := newBackfillService(t, transport)
+
+	if err := service.Backfill(context.Background(), "user_1", "integration_1"); err == nil {
+		t.Fatalf("expected the failing page to stop the backfill")
+	}
+	cursor := db.backfillCursors[resourceCycle]
+	if cursor.NextToken == nil || *cursor.NextToken != "4" || cursor.Records != 3 || cursor.CompletedAt != nil {
+		t.Fatalf("expected the cursor to stop before page 4, got %+v", cursor)
+	}
+	pinnedEnd := *cursor.EndAt
+
+	// "Deploy": the next run continues at page 4 with the same window.
+	transport.failPage = 0
+	transport.queries = nil
+	if err := service.Backfill(context.Background(), "user_1", "integration_1"); err != nil {
+		t.Fatalf("resumed backfill: %v", err)
+	}
+	if first := transport.queries[0]; first.Get("next_token") != "4" || first.Get("end") != pinnedEnd.Format(whoopTimeLayout) {
+		t.Fatalf("expected to resume at next_token=4 with the pinned end, got %v", first)
+	}
+	cursor = db.backfillCursors[resourceCycle]
+	if cursor.CompletedAt == nil || cursor.Records != 6 {
+		t.Fatalf("expected cycle to complete with 6 records, got %+v", cursor)
+	}
+}
+
+func TestBackfill_FailedCursorSaveRollsBackPage(t *testing.T) {
+	transport := &pagingTransport{pages: 6}
+	service, db := newBackfillService(t, transport)
+	// "Crash" while saving the cursor past page 3 of cycles.
+	db.failCursorSave = func(cursor BackfillCursor) error {
+		if cursor.ResourceType == resourceCycle && cursor.NextToken != nil && *cursor.NextToken == "4" {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+
+	if err := service.Backfill(context.Background(), "user_1", "integration_1"); err == nil {
+		t.Fatalf("expected the failed cursor save to stop the backfill")
+	}
+	// Page 3 went with its cursor: only pages 1-2 are stored and counted.
+	cursor := db.backfillCursors[resourceCycle]
+	if cursor.NextToken == nil || *cursor.NextToken != "3" || cursor.Records != 2 || len(db.rawEvents) != 2 {
+		t.Fatalf("expected page 3 to be rolled back, got %+v with %d raw events", cursor, len(db.rawEvents))
+	}
+
+	db.failCursorSave = nil
+	if err := service.Backfill(context.Background(), "user_1", "integration_1"); err != nil {
+		t.Fatalf("resumed backfill: %v", err)
+	}
+	// The re-fetched page is counted once.
+	cursor = db.backfillCursors[resourceCycle]
+	if cursor.CompletedAt == nil || cursor.Records != 6 {
+		t.Fatalf("expected cycle to complete with 6 records, got %+v", cursor)
+	}
+}
+
+func TestBackfillStatusHandler(t *testing.T) {
+	done := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	oldest := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	token := "next"
+	db := &fakeDB{
+		backfillCursors: map[string]BackfillCursor{
+			resourceCycle: {ResourceType: resourceCycle, EndAt: &done, Records: 1200, OldestAt: &oldest, CompletedAt: &done},
+			resourceSleep: {ResourceType: resourceSleep, EndAt: &done, NextToken: &token, Records: 300},
+		},
+		latestJob: &SyncJob{ID: "job_2", Kind: SyncJobBackfill, Status: syncJobRunning},
+	}
+	service := &Service{DB: db}
+
+	rec := httptest.NewRecorder()
+	service.BackfillStatusHandler(rec, httptest.NewRequest("GET", "/internal/whoop/backfill/status?userId=user_1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var response BackfillStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if response.Done || response.Job == nil || response.Job.ID != "job_2" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	want := map[string]string{
+		resourceCycle:    backfillDone,
+		resourceRecovery: backfillPending,
+		resourceSleep:    backfillRunning,
+		resourceWorkout:  backfillPending,
+	}
+	if len(response.Resources) != 4 {
+		t.Fatalf("expected all 4 resources, got %+v", response.Resources)
+	}
+	for _, resource := range response.Resources {
+		if resource.State != want[resource.Resource] {
+			t.Fatalf("%s: expected %s, got %s", resource.Resource, want[resource.Resource], resource.State)
+		}
+	}
+	if cycle := response.Resources[0]; cycle.Records != 1200 || cycle.OldestAt == nil || !cycle.OldestAt.Equal(oldest) {
+		t.Fatalf("unexpected cycle status: %+v", cycle)
+	}
+}
@@ -5,17 +5,23 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
+
+	"healthmetrics-services/internal/retry"
 )
 
 type ExchangeRequest struct {
 	UserID      string `json:"userId"`
 	Code        string `json:"code"`
 	RedirectURI string `json:"redirectUri"`
+	// State is the raw state from the callback URL (issued by AuthorizeHandler).
+	State string `json:"state"`
 }
 
 type SyncRequest struct {
@@ -37,6 +43,10 @@ type TokenResponse struct {
 // ErrMissingToken is returned when an integration has no stored tokens.
 var ErrMissingToken = errors.New("missing token")
 
+// ErrTokenRevoked is returned when WHOOP rejects the refresh token (revoked by the user,
+// expired from disuse, or rotated elsewhere): only reconnecting fixes it.
+var ErrTokenRevoked = errors.New("whoop refresh token revoked")
+
 type Service struct {
 	// DB implements the integration persistence contract (upserts + status).
 	DB DB
@@ -50,15 +60,41 @@ type Service struct {
 	// Metrics holds in-memory counters for observability.
 	Metrics *Metrics
 
+	// Retry is the policy for WHOOP API reads (zero value = single attempt).
+	Retry retry.Policy
+
+	// RateLimiter is shared by every WHOOP API read of this instance (nil = unthrottled).
+	RateLimiter *RateLimiter
+
+	// Jobs is woken when a sync job is queued (the SyncJobWorker; nil = rely on its poll).
+	Jobs JobWaker
+
+	// Webhooks is woken when a webhook event is queued (the WebhookWorker; nil = rely on its poll).
+	Webhooks JobWaker
+
+	// SyncOverlap is how far before each resource's watermark an incremental sync starts
+	// (catches rescored records; zero = defaultSyncOverlap).
+	SyncOverlap time.Duration
+
+	// BackfillPageDelay spaces out backfill requests (zero = defaultBackfillPageDelay).
+	BackfillPageDelay time.Duration
+
 	ClientID     string
 	ClientSecret string
 	TokenURL     string
 	RedirectURL  string
+	// AuthURL is WHOOP's authorization endpoint (empty = defaultAuthURL).
+	AuthURL string
+
+	// Syncs running in this process by integration id; concurrent callers share the result.
+	syncMu  sync.Mutex
+	syncing map[string]*inflightSync
 }
 
 type DB interface {
 	UpsertIntegration(ctx context.Context, userID, provider string) (integrationID string, err error)
 	UpsertIntegrationToken(ctx context.Context, integrationID string, accessEnc string, refreshEnc *string, expiresAt time.Time, scopes []string) error
+	RotateIntegrationToken(ctx context.Context, integrationID string, previousRefreshEnc *string, accessEnc string, refreshEnc *string, expiresAt time.Time, scopes []string) (swapped bool, err error)
 	MarkIntegrationConnected(ctx context.Context, integrationID string) error
 	GetIntegration(ctx context.Context, userID, provider string) (IntegrationRecord, error)
 	HasIntegrationToken(ctx context.Context, integrationID string) (bool, error)
@@ -74,15 +110,44 @@ type DB interface {
 	UpsertIntegrationConnection(ctx context.Context, integrationID, providerUserID string) error
 	DeleteIntegrationTokens(ctx context.Context, integrationID string) error
 	MarkIntegrationDisconnected(ctx context.Context, integrationID string) error
+	CreateOAuthState(ctx context.Context, userID, provider, stateHash, codeVerifierEnc string, expiresAt time.Time) error
+	ConsumeOAuthState(ctx context.Context, userID, provider, stateHash string) (codeVerifierEnc *string, err error)
+	GetSyncWatermark(ctx context.Context, integrationID, resourceType string) (*time.Time, error)
+	UpdateSyncWatermark(ctx context.Context, integrationID, resourceType string, syncedAt time.Time) error
+	GetBackfillCursor(ctx context.Context, integrationID, resourceType string) (BackfillCursor, error)
+	ListBackfillCursors(ctx context.Context, integrationID string) ([]BackfillCursor, error)
+	SaveBackfillCursor(ctx context.Context, integrationID string, cursor BackfillCursor) error
+	// InTx runs fn against a transaction (see Store.InTx).
+	InTx(ctx context.Context, fn func(tx DB) error) error
+	EnqueueSyncJob(ctx context.Context, integrationID, kind string) (jobID string, err error)
+	ClaimSyncJob(ctx context.Context, lease time.Duration) (SyncJob, error)
+	UpdateSyncJobProgress(ctx context.Context, jobID, resource string, records int) error
+	CompleteSyncJob(ctx context.Context, jobID string) error
+	FailSyncJob(ctx context.Context, jobID, lastError string, retryAt *time.Time) error
+	GetLatestSyncJob(ctx context.Context, integrationID, kind string) (SyncJob, error)
+	GetIntegrationByProviderUserID(ctx context.Context, provider, providerUserID string) (IntegrationRecord, error)
+	TombstoneIntegrationRecord(ctx context.Context, integrationID, resourceType, sourceID string) error
+	TombstoneMissingRecords(ctx context.Context, integrationID, resourceType string, windowStart, windowEnd time.Time, seenIDs []string) (int, error)
+	InsertWebhookEvent(ctx context.Context, provider string, event WebhookEvent) (inserted bool, err error)
+	ClaimWebhookEvent(ctx context.Context, lease time.Duration) (WebhookEvent, error)
+	CompleteWebhookEvent(ctx context.Context, eventID string) error
+	FailWebhookEvent(ctx context.Context, eventID, lastError string, retryAt *time.Time) error
+	MarkIntegrationError(ctx context.Context, integrationID, reason string) error
+	ClaimExpiringIntegrations(ctx context.Context, provider string, within time.Duration, limit int) ([]ConnectedIntegration, error)
+	ClaimDueIntegration(ctx context.Context, provider string, interval time.Duration, jitter float64, activeWithin time.Duration) (ConnectedIntegration, error)
+	RescheduleIntegrationSync(ctx context.Context, integrationID string, at time.Time) error
+	AcquireIntegrationLock(ctx context.Context, integrationID, name, owner string, lease time.Duration) (acquired bool, err error)
+	ReleaseIntegrationLock(ctx context.Context, integrationID, name, owner string) error
 }
 
 func (s *Service) ExchangeHandler(w http.ResponseWriter, r *http.Request) {
 	// TODO: add internal auth middleware (API key + cookie JWT)
 	// This handler:
-	// 1) validates input
-	// 2) exchanges the auth code for tokens
+	// 1) validates input + consumes the OAuth state (single use)
+	// 2) exchanges the auth code for tokens (with the PKCE verifier)
 	// 3) encrypts + stores tokens
 	// 4) marks the integration as connected
+	// 5) queues the initial history import (runs in the background, not in this request)
 	var req ExchangeRequest
 	requestID := requestIDFromHeaders(r)
 
@@ -96,7 +161,7 @@ func (s *Service) ExchangeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate the request fields
-	if req.UserID == "" || req.Code == "" || req.RedirectURI == "" {
+	if req.UserID == "" || req.Code == "" || req.RedirectURI == "" || req.State == "" {
 		s.recordExchangeMetrics(errors.New("missing fields"))
 		http.Error(w, "missing fields", http.StatusBadRequest)
 		return
@@ -113,7 +178,23 @@ func (s *Service) ExchangeHandler(w http.ResponseWriter, r *http.Request) {
 
 	ctx := WithRequestID(r.Context(), requestID)
 
-	token, err := s.exchangeToken(ctx, req.Code, req.RedirectURI)
+	// Consume the state before the code exchange: a replayed or forged callback fails here,
+	// and a state can't be used twice even if the exchange below fails.
+	codeVerifier, err := s.consumeOAuthState(ctx, req.UserID, req.State)
+	if err != nil {
+		if errors.Is(err, ErrInvalidState) {
+			s.logWithRequestID(requestID, "whoop oauth state rejected user=%s", req.UserID)
+			s.recordExchangeMetrics(err)
+			http.Error(w, "invalid or expired state", http.StatusBadRequest)
+			return
+		}
+		s.logWithRequestID(requestID, "whoop db error (consume oauth state) user=%s err=%v", req.UserID, err)
+		s.recordExchangeMetrics(err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := s.exchangeToken(ctx, req.Code, req.RedirectURI, codeVerifier)
 	if err != nil {
 		// We check s.Logger != nil to avoid a nil pointer panic.
 		// In Go, calling a method on a nil pointer (like s.Logger.Printf) will crash the program, this guard makes logging optional — if no logger was injected, the handler still works its just a safety check
@@ -177,7 +258,9 @@ func (s *Service) ExchangeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: enqueue initial sync job
+	// Queue the history import; the worker picks it up right away and the UI polls
+	// GET /internal/whoop/sync/status for progress.
+	s.enqueueInitialSync(ctx, req.UserID, integrationID)
 
 	s.recordExchangeMetrics(nil)
 	w.WriteHeader(http.StatusOK)
@@ -239,7 +322,7 @@ func (s *Service) SyncHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-func (s *Service) exchangeToken(ctx context.Context, code, redirectURI string) (*TokenResponse, error) {
+func (s *Service) exchangeToken(ctx context.Context, code, redirectURI, codeVerifier string) (*TokenResponse, error) {
 	// Build the OAuth token exchange payload.
 	form := url.Values{}
 	form.Set("grant_type", "authorization_code")
@@ -247,6 +330,10 @@ func (s *Service) exchangeToken(ctx context.Context, code, redirectURI string) (
 	form.Set("client_id", s.ClientID)
 	form.Set("client_secret", s.ClientSecret)
 	form.Set("redirect_uri", redirectURI)
+	// PKCE: proves this is the client that started the authorization.
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
 
 	// Send the request to WHOOP's token endpoint.
 	req, err := http.NewRequestWithContext(ctx, "POST", s.TokenURL, strings.NewReader(form.Encode()))
@@ -360,7 +447,7 @@ func (s *Service) refreshToken(ctx context.Context, refreshToken string) (*Token
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		refreshErr = errors.New("whoops server error: refresh token endpoint returned error")
+		refreshErr = refreshTokenError(resp)
 		return nil, refreshErr
 	}
 
@@ -373,121 +460,202 @@ func (s *Service) refreshToken(ctx context.Context, refreshToken string) (*Token
 	return &token, nil
 }
 
+// refreshTokenError classifies a failed refresh. OAuth's invalid_grant means the grant is gone
+// (ErrTokenRevoked); anything else stays a retry.HTTPError, so 429/5xx count as transient.
+func refreshTokenError(resp *http.Response) error {
+	var body struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body)
+	if body.Error == "invalid_grant" {
+		if body.Description != "" {
+			return fmt.Errorf("%w: %s", ErrTokenRevoked, body.Description)
+		}
+		return ErrTokenRevoked
+	}
+	return retry.NewHTTPError("whoop token", resp)
+}
+
 // SyncIntegration runs the core sync flow for a given integration.
 // It is reused by both the HTTP handler and the scheduled sync job.
+// Syncs of one integration never overlap: a caller that finds one running in this process
+// waits for it and returns its result; one running on another replica (the "sync" lease)
+// is waited out, then this sync runs (incremental, so it only fetches what's new).
 func (s *Service) SyncIntegration(ctx context.Context, userID, integrationID string) error {
+	call, leader := s.joinSync(integrationID)
+	if !leader {
+		s.logWithRequestID(RequestIDFromContext(ctx), "whoop sync joined in-flight user=%s integration=%s", userID, integrationID)
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
 	start := time.Now()
-	logAndReturn := func(err error) error {
-		s.logSyncStatus(ctx, userID, integrationID, time.Since(start).Milliseconds(), err)
+	err := s.withIntegrationLock(ctx, integrationID, lockSync, syncLockLease, func(ctx context.Context) error {
+		return s.syncIntegration(ctx, integrationID)
+	})
+	if err != nil {
+		s.recordSyncError(ctx, integrationID, err)
+	}
+	s.logSyncStatus(ctx, userID, integrationID, time.Since(start).Milliseconds(), err)
+	s.finishSync(integrationID, call, err)
+	return err
+}
+
+// syncIntegration is one sync, run while holding the integration's sync lease.
+func (s *Service) syncIntegration(ctx context.Context, integrationID string) error {
+	// Load (and refresh if needed) the access token.
+	accessToken, err := s.accessToken(ctx, integrationID)
+	if err != nil {
 		return err
 	}
 
+	// Fetch WHOOP data and store raw payloads.
+	if err := s.fetchAndStoreWhoopData(ctx, integrationID, accessToken); err != nil {
+		return fmt.Errorf("fetch whoop data: %w", err)
+	}
+
+	// Update last_sync_at for UI + monitoring.
+	if err := s.DB.UpdateIntegrationLastSync(ctx, integrationID, time.Now()); err != nil {
+		return fmt.Errorf("update last sync: %w", err)
+	}
+
+	// Clear last_error on successful sync.
+	_ = s.DB.UpsertIntegrationLastError(ctx, integrationID, nil)
+	return nil
+}
+
+// accessToken loads the integration's decrypted access token, refreshing it first when it
+// expires within a minute (the refreshed tokens are stored). Shared by sync and backfill.
+func (s *Service) accessToken(ctx context.Context, integrationID string) (string, error) {
 	// Confirm a token row exists (fast guard for missing tokens).
 	hasToken, err := s.DB.HasIntegrationToken(ctx, integrationID)
 	if err != nil {
-		err = fmt.Errorf("check token: %w", err)
-		s.recordSyncError(ctx, integrationID, err)
-		return logAndReturn(err)
+		return "", fmt.Errorf("check token: %w", err)
 	}
 	if !hasToken {
-		s.recordSyncError(ctx, integrationID, ErrMissingToken)
-		return logAndReturn(ErrMissingToken)
+		return "", ErrMissingToken
 	}
 
 	// Load encrypted tokens + expiry from DB.
 	tokenRecord, err := s.DB.GetIntegrationToken(ctx, integrationID)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
-			s.recordSyncError(ctx, integrationID, ErrMissingToken)
-			return logAndReturn(ErrMissingToken)
+			return "", ErrMissingToken
 		}
-		err = fmt.Errorf("get token: %w", err)
-		s.recordSyncError(ctx, integrationID, err)
-		return logAndReturn(err)
+		return "", fmt.Errorf("get token: %w", err)
+	}
+
+	// If token is expired (or near expiry), refresh it first.
+	if tokenExpiresWithin(tokenRecord, time.Minute) {
+		return s.refreshStoredToken(ctx, integrationID, time.Minute)
 	}
 
 	// Decrypt access token so we can call WHOOP.
 	accessToken, err := Decrypt(tokenRecord.AccessTokenEncrypted)
 	if err != nil {
-		err = fmt.Errorf("decrypt access token: %w", err)
-		s.recordSyncError(ctx, integrationID, err)
-		return logAndReturn(err)
+		return "", fmt.Errorf("decrypt access token: %w", err)
 	}
+	return accessToken, nil
+}
 
-	// Refresh token is optional (only returned with offline scope).
-	var refreshToken string
-	if tokenRecord.RefreshTokenEncrypted != nil {
-		refreshToken, err = Decrypt(*tokenRecord.RefreshTokenEncrypted)
+// tokenExpiresWithin reports whether the access token expires within d (no expiry = never).
+func tokenExpiresWithin(record IntegrationTokenRecord, d time.Duration) bool {
+	return record.ExpiresAt != nil && record.ExpiresAt.Before(time.Now().Add(d))
+}
+
+// refreshStoredToken refreshes the integration's tokens if they expire within d and returns
+// the current access token. WHOOP rotates the refresh token on every refresh (the old one
+// stops working), so concurrent refreshes would break the connection:
+//   - the "token" lease lets one caller refresh at a time, across replicas
+//   - the token is re-read under the lease; if someone else refreshed meanwhile, theirs is used
+//   - the new tokens are stored compare-and-swap against the refresh token we used, so a
+//     refresh that raced anyway (lease expired) can't overwrite a newer rotation
+func (s *Service) refreshStoredToken(ctx context.Context, integrationID string, d time.Duration) (string, error) {
+	var accessToken string
+	err := s.withIntegrationLock(ctx, integrationID, lockToken, tokenLockLease, func(ctx context.Context) error {
+		tokenRecord, err := s.DB.GetIntegrationToken(ctx, integrationID)
 		if err != nil {
-			err = fmt.Errorf("decrypt refresh token: %w", err)
-			s.recordSyncError(ctx, integrationID, err)
-			return logAndReturn(err)
+			if errors.Is(err, ErrNotFound) {
+				return ErrMissingToken
+			}
+			return fmt.Errorf("get token: %w", err)
 		}
-	}
 
-	// If token is expired (or near expiry), refresh it first.
-	if tokenRecord.ExpiresAt != nil && tokenRecord.ExpiresAt.Before(time.Now().Add(1*time.Minute)) {
-		if refreshToken == "" {
-			s.recordSyncError(ctx, integrationID, ErrMissingToken)
-			return logAndReturn(ErrMissingToken)
+		// Refreshed by another caller while we waited for the lease.
+		if !tokenExpiresWithin(tokenRecord, d) {
+			accessToken, err = Decrypt(tokenRecord.AccessTokenEncrypted)
+			if err != nil {
+				return fmt.Errorf("decrypt access token: %w", err)
+			}
+			return nil
+		}
+
+		// Refresh token is optional (only returned with offline scope).
+		if tokenRecord.RefreshTokenEncrypted == nil {
+			return ErrMissingToken
+		}
+		refreshToken, err := Decrypt(*tokenRecord.RefreshTokenEncrypted)
+		if err != nil {
+			return fmt.Errorf("decrypt refresh token: %w", err)
 		}
 
 		refreshed, err := s.refreshToken(ctx, refreshToken)
 		if err != nil {
-			err = fmt.Errorf("refresh token: %w", err)
-			s.recordSyncError(ctx, integrationID, err)
-			return logAndReturn(err)
+			if errors.Is(err, ErrTokenRevoked) {
+				s.markTokenRevoked(ctx, integrationID, err)
+			}
+			return fmt.Errorf("refresh token: %w", err)
 		}
 
 		accessEnc, err := Encrypt(refreshed.AccessToken)
 		if err != nil {
-			err = fmt.Errorf("encrypt access token: %w", err)
-			s.recordSyncError(ctx, integrationID, err)
-			return logAndReturn(err)
+			return fmt.Errorf("encrypt access token: %w", err)
 		}
 
 		var refreshEnc *string
 		if refreshed.RefreshToken != "" {
 			enc, err := Encrypt(refreshed.RefreshToken)
 			if err != nil {
-				err = fmt.Errorf("encrypt refresh token: %w", err)
-				s.recordSyncError(ctx, integrationID, err)
-				return logAndReturn(err)
+				return fmt.Errorf("encrypt refresh token: %w", err)
 			}
 			refreshEnc = &enc
 		}
 
 		expiresAt := time.Now().Add(time.Duration(refreshed.ExpiresIn) * time.Second)
-		if err := s.DB.UpsertIntegrationToken(ctx, integrationID, accessEnc, refreshEnc, expiresAt, strings.Fields(refreshed.Scope)); err != nil {
-			err = fmt.Errorf("upsert refreshed tokens: %w", err)
-			s.recordSyncError(ctx, integrationID, err)
-			return logAndReturn(err)
+		swapped, err := s.DB.RotateIntegrationToken(ctx, integrationID, tokenRecord.RefreshTokenEncrypted, accessEnc, refreshEnc, expiresAt, strings.Fields(refreshed.Scope))
+		if err != nil {
+			return fmt.Errorf("store refreshed tokens: %w", err)
+		}
+		if !swapped {
+			return errTokenRotated
 		}
 
 		accessToken = refreshed.AccessToken
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
+	return accessToken, nil
+}
 
-	// Fetch WHOOP data and store raw payloads.
-	if err := s.fetchAndStoreWhoopData(ctx, integrationID, accessToken); err != nil {
-		err = fmt.Errorf("fetch whoop data: %w", err)
-		s.recordSyncError(ctx, integrationID, err)
-		return logAndReturn(err)
+// markTokenRevoked flags the integration as needing a reconnect (status 'error' with the
+// reason in last_error), so the UI can ask the user and background work stops trying.
+func (s *Service) markTokenRevoked(ctx context.Context, integrationID string, err error) {
+	reason := err.Error()
+	if len(reason) > 500 {
+		reason = reason[:500]
 	}
-
-	// Update last_sync_at for UI + monitoring.
-	if err := s.DB.UpdateIntegrationLastSync(ctx, integrationID, time.Now()); err != nil {
-		err = fmt.Errorf("update last sync: %w", err)
-		s.recordSyncError(ctx, integrationID, err)
-		return logAndReturn(err)
+	if dbErr := s.DB.MarkIntegrationError(ctx, integrationID, reason); dbErr != nil {
+		s.logWithRequestID(RequestIDFromContext(ctx), "whoop mark integration error failed integration=%s err=%v", integrationID, dbErr)
+		return
 	}
-
-	// Clear last_error on successful sync.
-	_ = s.DB.UpsertIntegrationLastError(ctx, integrationID, nil)
-
-	durationMs := time.Since(start).Milliseconds()
-	s.logSyncStatus(ctx, userID, integrationID, durationMs, nil)
-
-	return nil
+	s.logWithRequestID(RequestIDFromContext(ctx), "whoop token revoked integration=%s status=error reason=%q", integrationID, reason)
 }
 
 func (s *Service) recordSyncError(ctx context.Context, integrationID string, err error) {
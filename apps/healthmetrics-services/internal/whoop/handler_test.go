@@ -5,9 +5,13 @@ import (
 	"encoding/base64"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"healthmetrics-services/internal/retry"
 )
 
 type tokenCall struct {
@@ -33,6 +37,78 @@ type fakeDB struct {
 	rawEvents        []rawEventCall
 	lastSyncAt       *time.Time
 	providerUserID   string
+
+	// Sync jobs: enqueued kinds, jobs to hand out from ClaimSyncJob, and recorded outcomes.
+	enqueuedJobs  []string
+	claimableJobs []SyncJob
+	jobProgress   map[string]int
+	completedJobs []string
+	failedJobs    []failJobCall
+	latestJob     *SyncJob
+
+	// Pending OAuth states by state hash.
+	oauthStates map[string]oauthStateRow
+
+	// Sync watermarks by resource type.
+	watermarks map[string]time.Time
+
+	// Backfill cursors by resource type; failCursorSave, when set, can fail a save (e.g. to
+	// roll back the transaction of a backfill page).
+	backfillCursors map[string]BackfillCursor
+	failCursorSave  func(cursor BackfillCursor) error
+
+	// Webhooks: integrations by WHOOP user id, queued events (deduped by key), events to hand out
+	// from ClaimWebhookEvent, recorded outcomes and tombstoned records.
+	providerIntegrations map[string]IntegrationRecord
+	webhookEvents        []WebhookEvent
+	claimableEvents      []WebhookEvent
+	completedEvents      []string
+	failedEvents         []failJobCall
+	tombstones           []rawEventCall
+	primarySleepUpdates  int
+
+	// Reconciled sync windows.
+	reconcileCalls []reconcileCall
+
+	// Token rotations (compare-and-swap refreshes) and integration leases (owner by
+	// "integration/name"); lockWaits counts acquires refused because someone else held it.
+	// mu guards the token and lock state, which concurrent tests share between goroutines.
+	mu               sync.Mutex
+	rotateTokenCalls []tokenCall
+	locks            map[string]string
+	lockWaits        int
+
+	// Scheduler: integrations to hand out from ClaimDueIntegration and next_sync_at set
+	// by RescheduleIntegrationSync (guarded by mu too).
+	dueIntegrations []ConnectedIntegration
+	rescheduled     map[string]time.Time
+
+	// Token refresher: expiring integrations (soonest expiry first) handed out by
+	// ClaimExpiringIntegrations, the poll that last took each one, the batches taken, and the
+	// reasons recorded by MarkIntegrationError.
+	expiringIntegrations []ConnectedIntegration
+	refreshAttempts      map[string]int
+	refreshPolls         int
+	claimedExpiring      [][]string
+	integrationErrors    map[string]string
+}
+
+type reconcileCall struct {
+	resourceType string
+	start, end   time.Time
+	seenIDs      []string
+}
+
+type oauthStateRow struct {
+	userID      string
+	verifierEnc *string
+	expiresAt   time.Time
+}
+
+type failJobCall struct {
+	jobID     string
+	lastError string
+	retryAt   *time.Time
 }
 
 func (f *fakeDB) UpsertIntegration(ctx context.Context, userID, provider string) (string, error) {
@@ -68,9 +144,119 @@ func (f *fakeDB) UpdateIntegrationLastSync(ctx context.Context, integrationID st
 }
 
 func (f *fakeDB) GetIntegrationToken(ctx context.Context, integrationID string) (IntegrationTokenRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return f.tokenRecord, nil
 }
 
+func (f *fakeDB) RotateIntegrationToken(ctx context.Context, integrationID string, previousRefreshEnc *string, accessEnc string, refreshEnc *string, expiresAt time.Time, scopes []string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current := f.tokenRecord.RefreshTokenEncrypted
+	if (current == nil) != (previousRefreshEnc == nil) || (current != nil && *current != *previousRefreshEnc) {
+		return false, nil
+	}
+	f.rotateTokenCalls = append(f.rotateTokenCalls, tokenCall{
+		integrationID: integrationID,
+		accessEnc:     accessEnc,
+		refreshEnc:    refreshEnc,
+		expiresAt:     expiresAt,
+		scopes:        scopes,
+	})
+	f.tokenRecord.AccessTokenEncrypted = accessEnc
+	if refreshEnc != nil {
+		f.tokenRecord.RefreshTokenEncrypted = refreshEnc
+	}
+	f.tokenRecord.ExpiresAt = &expiresAt
+	f.tokenRecord.Scopes = scopes
+	return true, nil
+}
+
+func (f *fakeDB) ClaimDueIntegration(ctx context.Context, provider string, interval time.Duration, jitter float64, activeWithin time.Duration) (ConnectedIntegration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.dueIntegrations) == 0 {
+		return ConnectedIntegration{}, ErrNotFound
+	}
+	integration := f.dueIntegrations[0]
+	f.dueIntegrations = f.dueIntegrations[1:]
+	return integration, nil
+}
+
+func (f *fakeDB) RescheduleIntegrationSync(ctx context.Context, integrationID string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rescheduled == nil {
+		f.rescheduled = map[string]time.Time{}
+	}
+	f.rescheduled[integrationID] = at
+	return nil
+}
+
+func (f *fakeDB) ClaimExpiringIntegrations(ctx context.Context, provider string, within time.Duration, limit int) ([]ConnectedIntegration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Same order as the SQL: never tried first, then least recently tried, then expiry.
+	due := append([]ConnectedIntegration(nil), f.expiringIntegrations...)
+	sort.SliceStable(due, func(i, j int) bool {
+		pollI, triedI := f.refreshAttempts[due[i].ID]
+		pollJ, triedJ := f.refreshAttempts[due[j].ID]
+		if triedI != triedJ {
+			return !triedI
+		}
+		return pollI < pollJ
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	if f.refreshAttempts == nil {
+		f.refreshAttempts = map[string]int{}
+	}
+	f.refreshPolls++
+	ids := make([]string, 0, len(due))
+	for _, integration := range due {
+		f.refreshAttempts[integration.ID] = f.refreshPolls
+		ids = append(ids, integration.ID)
+	}
+	f.claimedExpiring = append(f.claimedExpiring, ids)
+	return due, nil
+}
+
+func (f *fakeDB) MarkIntegrationError(ctx context.Context, integrationID, reason string) error {
+	if f.integrationErrors == nil {
+		f.integrationErrors = map[string]string{}
+	}
+	f.integrationErrors[integrationID] = reason
+	return nil
+}
+
+func (f *fakeDB) AcquireIntegrationLock(ctx context.Context, integrationID, name, owner string, lease time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := integrationID + "/" + name
+	if holder, ok := f.locks[key]; ok && holder != owner {
+		f.lockWaits++
+		return false, nil
+	}
+	if f.locks == nil {
+		f.locks = map[string]string{}
+	}
+	f.locks[key] = owner
+	return true, nil
+}
+
+func (f *fakeDB) ReleaseIntegrationLock(ctx context.Context, integrationID, name, owner string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := integrationID + "/" + name
+	if f.locks[key] == owner {
+		delete(f.locks, key)
+	}
+	return nil
+}
+
 func (f *fakeDB) UpsertIntegrationRawEvent(ctx context.Context, integrationID, resourceType, sourceID string, payload []byte) error {
 	f.rawEvents = append(f.rawEvents, rawEventCall{
 		resourceType: resourceType,
@@ -96,6 +282,7 @@ func (f *fakeDB) UpsertIntegrationCycle(ctx context.Context, data IntegrationCyc
 }
 
 func (f *fakeDB) UpdatePrimarySleep(ctx context.Context, integrationID string) error {
+	f.primarySleepUpdates++
 	return nil
 }
 
@@ -116,6 +303,172 @@ func (f *fakeDB) MarkIntegrationDisconnected(ctx context.Context, integrationID
 	return nil
 }
 
+func (f *fakeDB) CreateOAuthState(ctx context.Context, userID, provider, stateHash, codeVerifierEnc string, expiresAt time.Time) error {
+	if f.oauthStates == nil {
+		f.oauthStates = map[string]oauthStateRow{}
+	}
+	for hash, row := range f.oauthStates {
+		if row.userID == userID {
+			delete(f.oauthStates, hash)
+		}
+	}
+	f.oauthStates[stateHash] = oauthStateRow{userID: userID, verifierEnc: &codeVerifierEnc, expiresAt: expiresAt}
+	return nil
+}
+
+func (f *fakeDB) ConsumeOAuthState(ctx context.Context, userID, provider, stateHash string) (*string, error) {
+	row, ok := f.oauthStates[stateHash]
+	if !ok || row.userID != userID || !row.expiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	delete(f.oauthStates, stateHash)
+	return row.verifierEnc, nil
+}
+
+func (f *fakeDB) GetSyncWatermark(ctx context.Context, integrationID, resourceType string) (*time.Time, error) {
+	if syncedAt, ok := f.watermarks[resourceType]; ok {
+		return &syncedAt, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeDB) UpdateSyncWatermark(ctx context.Context, integrationID, resourceType string, syncedAt time.Time) error {
+	if f.watermarks == nil {
+		f.watermarks = map[string]time.Time{}
+	}
+	f.watermarks[resourceType] = syncedAt
+	return nil
+}
+
+func (f *fakeDB) GetBackfillCursor(ctx context.Context, integrationID, resourceType string) (BackfillCursor, error) {
+	if cursor, ok := f.backfillCursors[resourceType]; ok {
+		return cursor, nil
+	}
+	return BackfillCursor{ResourceType: resourceType}, nil
+}
+
+func (f *fakeDB) ListBackfillCursors(ctx context.Context, integrationID string) ([]BackfillCursor, error) {
+	var cursors []BackfillCursor
+	for _, cursor := range f.backfillCursors {
+		cursors = append(cursors, cursor)
+	}
+	return cursors, nil
+}
+
+func (f *fakeDB) SaveBackfillCursor(ctx context.Context, integrationID string, cursor BackfillCursor) error {
+	if f.failCursorSave != nil {
+		if err := f.failCursorSave(cursor); err != nil {
+			return err
+		}
+	}
+	if f.backfillCursors == nil {
+		f.backfillCursors = map[string]BackfillCursor{}
+	}
+	f.backfillCursors[cursor.ResourceType] = cursor
+	return nil
+}
+
+// InTx rolls back the raw events and backfill cursors fn wrote when it fails.
+func (f *fakeDB) InTx(ctx context.Context, fn func(tx DB) error) error {
+	rawEvents := len(f.rawEvents)
+	cursors := map[string]BackfillCursor{}
+	for resourceType, cursor := range f.backfillCursors {
+		cursors[resourceType] = cursor
+	}
+	if err := fn(f); err != nil {
+		f.rawEvents = f.rawEvents[:rawEvents]
+		f.backfillCursors = cursors
+		return err
+	}
+	return nil
+}
+
+func (f *fakeDB) EnqueueSyncJob(ctx context.Context, integrationID, kind string) (string, error) {
+	f.enqueuedJobs = append(f.enqueuedJobs, kind)
+	return "job_1", nil
+}
+
+func (f *fakeDB) ClaimSyncJob(ctx context.Context, lease time.Duration) (SyncJob, error) {
+	if len(f.claimableJobs) == 0 {
+		return SyncJob{}, ErrNotFound
+	}
+	job := f.claimableJobs[0]
+	f.claimableJobs = f.claimableJobs[1:]
+	return job, nil
+}
+
+func (f *fakeDB) UpdateSyncJobProgress(ctx context.Context, jobID, resource string, records int) error {
+	if f.jobProgress == nil {
+		f.jobProgress = map[string]int{}
+	}
+	f.jobProgress[resource] = records
+	return nil
+}
+
+func (f *fakeDB) CompleteSyncJob(ctx context.Context, jobID string) error {
+	f.completedJobs = append(f.completedJobs, jobID)
+	return nil
+}
+
+func (f *fakeDB) FailSyncJob(ctx context.Context, jobID, lastError string, retryAt *time.Time) error {
+	f.failedJobs = append(f.failedJobs, failJobCall{jobID: jobID, lastError: lastError, retryAt: retryAt})
+	return nil
+}
+
+func (f *fakeDB) GetLatestSyncJob(ctx context.Context, integrationID, kind string) (SyncJob, error) {
+	if f.latestJob == nil || f.latestJob.Kind != kind {
+		return SyncJob{}, ErrNotFound
+	}
+	return *f.latestJob, nil
+}
+
+func (f *fakeDB) GetIntegrationByProviderUserID(ctx context.Context, provider, providerUserID string) (IntegrationRecord, error) {
+	record, ok := f.providerIntegrations[providerUserID]
+	if !ok {
+		return IntegrationRecord{}, ErrNotFound
+	}
+	return record, nil
+}
+
+func (f *fakeDB) TombstoneIntegrationRecord(ctx context.Context, integrationID, resourceType, sourceID string) error {
+	f.tombstones = append(f.tombstones, rawEventCall{resourceType: resourceType, sourceID: sourceID})
+	return nil
+}
+
+func (f *fakeDB) TombstoneMissingRecords(ctx context.Context, integrationID, resourceType string, windowStart, windowEnd time.Time, seenIDs []string) (int, error) {
+	f.reconcileCalls = append(f.reconcileCalls, reconcileCall{resourceType: resourceType, start: windowStart, end: windowEnd, seenIDs: seenIDs})
+	return 0, nil
+}
+
+func (f *fakeDB) InsertWebhookEvent(ctx context.Context, provider string, event WebhookEvent) (bool, error) {
+	for _, existing := range f.webhookEvents {
+		if existing.EventKey == event.EventKey {
+			return false, nil
+		}
+	}
+	f.webhookEvents = append(f.webhookEvents, event)
+	return true, nil
+}
+
+func (f *fakeDB) ClaimWebhookEvent(ctx context.Context, lease time.Duration) (WebhookEvent, error) {
+	if len(f.claimableEvents) == 0 {
+		return WebhookEvent{}, ErrNotFound
+	}
+	event := f.claimableEvents[0]
+	f.claimableEvents = f.claimableEvents[1:]
+	return event, nil
+}
+
+func (f *fakeDB) CompleteWebhookEvent(ctx context.Context, eventID string) error {
+	f.completedEvents = append(f.completedEvents, eventID)
+	return nil
+}
+
+func (f *fakeDB) FailWebhookEvent(ctx context.Context, eventID, lastError string, retryAt *time.Time) error {
+	f.failedEvents = append(f.failedEvents, failJobCall{jobID: eventID, lastError: lastError, retryAt: retryAt})
+	return nil
+}
+
 type stubTransport struct{}
 
 func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -202,8 +555,8 @@ func TestSyncIntegration_Success_NoRefresh(t *testing.T) {
 		t.Fatalf("expected provider user id to be stored, got %q", db.providerUserID)
 	}
 
-	if len(db.upsertTokenCalls) != 0 {
-		t.Fatalf("did not expect token refresh, got %d upserts", len(db.upsertTokenCalls))
+	if len(db.upsertTokenCalls) != 0 || len(db.rotateTokenCalls) != 0 {
+		t.Fatalf("did not expect token refresh, got %d upserts / %d rotations", len(db.upsertTokenCalls), len(db.rotateTokenCalls))
 	}
 }
 
@@ -243,11 +596,14 @@ func TestSyncIntegration_RefreshesExpiredToken(t *testing.T) {
 		t.Fatalf("sync integration: %v", err)
 	}
 
-	if len(db.upsertTokenCalls) != 1 {
-		t.Fatalf("expected token refresh upsert, got %d", len(db.upsertTokenCalls))
+	if len(db.rotateTokenCalls) != 1 {
+		t.Fatalf("expected token refresh rotation, got %d", len(db.rotateTokenCalls))
+	}
+	if len(db.locks) != 0 {
+		t.Fatalf("expected leases to be released, got %v", db.locks)
 	}
 
-	decrypted, err := Decrypt(db.upsertTokenCalls[0].accessEnc)
+	decrypted, err := Decrypt(db.rotateTokenCalls[0].accessEnc)
 	if err != nil {
 		t.Fatalf("decrypt refreshed token: %v", err)
 	}
@@ -273,3 +629,35 @@ func TestSyncIntegration_MissingToken(t *testing.T) {
 func ptrTime(value time.Time) *time.Time {
 	return &value
 }
+
+// flakyTransport answers the first call with 503 and delegates to stubTransport afterwards.
+type flakyTransport struct {
+	calls int
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+	if f.calls == 1 {
+		return jsonResponse(503, `{"error":"unavailable"}`), nil
+	}
+	return (&stubTransport{}).RoundTrip(req)
+}
+
+func TestFetchWhoop_RetriesTransientError(t *testing.T) {
+	transport := &flakyTransport{}
+	service := &Service{
+		HTTPClient: &http.Client{Transport: transport},
+		Retry:      retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}
+
+	payload, err := service.fetchWhoopObject(context.Background(), "access_token", "/v2/user/profile/basic")
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if payload["user_id"] != "whoop_user_1" {
+		t.Fatalf("unexpected payload: %v", payload)
+	}
+	if transport.calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", transport.calls)
+	}
+}
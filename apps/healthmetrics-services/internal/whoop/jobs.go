@@ -0,0 +1,276 @@
+package whoop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// SyncJobInitial is the history import queued after a successful OAuth exchange.
+const SyncJobInitial = "initial"
+
+// Job statuses (IntegrationSyncJobStatus in Prisma).
+const (
+	syncJobQueued    = "queued"
+	syncJobRunning   = "running"
+	syncJobSucceeded = "succeeded"
+	syncJobFailed    = "failed"
+)
+
+// Retry backoff for failed jobs: 1m, 4m, 16m, then capped at 1h.
+const (
+	syncJobBaseBackoff = time.Minute
+	syncJobMaxBackoff  = time.Hour
+)
+
+// JobWaker is notified when a job is enqueued so the worker starts it right away
+// instead of on its next poll.
+type JobWaker interface {
+	Wake()
+}
+
+// SyncJobWorker runs queued integration_sync_job rows in the background.
+// Jobs live in Postgres, so a restart (or a crash mid-import) picks them up again:
+// a running job whose lease expired is claimed like a queued one.
+type SyncJobWorker struct {
+	Service *Service
+
+	// PollInterval is the fallback poll for due retries and jobs enqueued by other instances.
+	PollInterval time.Duration
+
+	// JobTimeout bounds a single attempt; Lease must outlast it so a live attempt isn't re-claimed.
+	JobTimeout time.Duration
+	Lease      time.Duration
+
+	wake chan struct{}
+}
+
+// NewSyncJobWorker returns a worker with default timings.
+func NewSyncJobWorker(service *Service) *SyncJobWorker {
+	return &SyncJobWorker{
+		Service:      service,
+		PollInterval: 30 * time.Second,
+		JobTimeout:   30 * time.Minute,
+		Lease:        35 * time.Minute,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Wake nudges the worker loop; it never blocks (a pending wake already covers this one).
+func (w *SyncJobWorker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes due jobs until ctx is cancelled.
+func (w *SyncJobWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.RunDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue claims and runs jobs one at a time until none are due; returns how many ran.
+func (w *SyncJobWorker) RunDue(ctx context.Context) int {
+	ran := 0
+	for ctx.Err() == nil {
+		job, err := w.Service.DB.ClaimSyncJob(ctx, w.Lease)
+		if errors.Is(err, ErrNotFound) {
+			return ran
+		}
+		if err != nil {
+			w.Service.logWithRequestID("", "whoop sync job claim error err=%v", err)
+			return ran
+		}
+		w.runJob(ctx, job)
+		ran++
+	}
+	return ran
+}
+
+// runJob runs one attempt of a claimed job and records the outcome.
+func (w *SyncJobWorker) runJob(ctx context.Context, job SyncJob) {
+	s := w.Service
+	requestID := "sync_job_" + job.ID
+
+	// A crash can leave a job claimed past its last attempt; don't run it again.
+	if job.Attempts > job.MaxAttempts {
+		w.finish(requestID, job, errors.New("max attempts exceeded"), false)
+		return
+	}
+
+	s.logWithRequestID(requestID, "whoop sync job started user=%s integration=%s kind=%s attempt=%d/%d",
+		job.UserID, job.IntegrationID, job.Kind, job.Attempts, job.MaxAttempts)
+
+	runCtx, cancel := context.WithTimeout(ctx, w.JobTimeout)
+	defer cancel()
+	runCtx = WithRequestID(runCtx, requestID)
+	runCtx = WithSyncProgress(runCtx, func(resource string, records int) {
+		// Progress is best effort: a failed write shouldn't fail the import.
+		if err := s.DB.UpdateSyncJobProgress(runCtx, job.ID, resource, records); err != nil {
+			s.logWithRequestID(requestID, "whoop sync job progress error resource=%s err=%v", resource, err)
+		}
+	})
+
+	var err error
+	switch job.Kind {
+	case SyncJobBackfill:
+		err = s.Backfill(runCtx, job.UserID, job.IntegrationID)
+	default:
+		err = s.SyncIntegration(runCtx, job.UserID, job.IntegrationID)
+	}
+	// Missing or revoked tokens won't fix themselves (the user has to reconnect); everything else is retried.
+	w.finish(requestID, job, err, !errors.Is(err, ErrMissingToken) && !errors.Is(err, ErrTokenRevoked) && job.Attempts < job.MaxAttempts)
+}
+
+// finish stores the attempt result. It uses its own context so a timed-out attempt is still recorded.
+func (w *SyncJobWorker) finish(requestID string, job SyncJob, err error, retryable bool) {
+	s := w.Service
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err == nil {
+		if err := s.DB.CompleteSyncJob(ctx, job.ID); err != nil {
+			s.logWithRequestID(requestID, "whoop sync job complete error err=%v", err)
+			return
+		}
+		s.logWithRequestID(requestID, "whoop sync job succeeded user=%s integration=%s kind=%s", job.UserID, job.IntegrationID, job.Kind)
+		return
+	}
+
+	msg := err.Error()
+	if len(msg) > 500 {
+		msg = msg[:500]
+	}
+
+	var retryAt *time.Time
+	if retryable {
+		next := time.Now().Add(syncJobBackoff(job.Attempts))
+		retryAt = &next
+	}
+	if dbErr := s.DB.FailSyncJob(ctx, job.ID, msg, retryAt); dbErr != nil {
+		s.logWithRequestID(requestID, "whoop sync job fail error err=%v", dbErr)
+		return
+	}
+	if retryAt != nil {
+		s.logWithRequestID(requestID, "whoop sync job retry user=%s integration=%s attempt=%d retry_at=%s err=%v",
+			job.UserID, job.IntegrationID, job.Attempts, retryAt.UTC().Format(time.RFC3339), err)
+		return
+	}
+	s.logWithRequestID(requestID, "whoop sync job failed user=%s integration=%s attempt=%d err=%v", job.UserID, job.IntegrationID, job.Attempts, err)
+}
+
+// syncJobBackoff is the delay before retrying after the given (1-based) attempt.
+func syncJobBackoff(attempt int) time.Duration {
+	delay := syncJobBaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 4
+		if delay >= syncJobMaxBackoff {
+			return syncJobMaxBackoff
+		}
+	}
+	return delay
+}
+
+// enqueueInitialSync queues the history import after an exchange: the initial job (newest
+// records, so the app has data quickly) and then the full backfill. Failure is logged, not
+// returned: the tokens are stored, so the scheduled sync still catches up.
+func (s *Service) enqueueInitialSync(ctx context.Context, userID, integrationID string) {
+	requestID := RequestIDFromContext(ctx)
+	for _, kind := range []string{SyncJobInitial, SyncJobBackfill} {
+		jobID, err := s.DB.EnqueueSyncJob(ctx, integrationID, kind)
+		if err != nil {
+			s.logWithRequestID(requestID, "whoop db error (enqueue sync job) user=%s integration=%s kind=%s err=%v", userID, integrationID, kind, err)
+			return
+		}
+		s.logWithRequestID(requestID, "whoop sync job queued user=%s integration=%s job=%s kind=%s", userID, integrationID, jobID, kind)
+	}
+
+	if s.Jobs != nil {
+		s.Jobs.Wake()
+	}
+}
+
+// SyncStatusResponse lets the UI show "importing your history" while the initial job runs.
+type SyncStatusResponse struct {
+	IntegrationStatus string   `json:"integration_status"`
+	Importing         bool     `json:"importing"`
+	Job               *SyncJob `json:"job"`
+}
+
+func (s *Service) SyncStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// This handler:
+	// 1) loads the user's integration
+	// 2) returns its latest initial sync job (status, attempts, per-resource progress)
+	requestID := requestIDFromHeaders(r)
+	userID := r.URL.Query().Get("userId")
+	if userID == "" {
+		http.Error(w, "missing fields", http.StatusBadRequest)
+		return
+	}
+
+	ctx := WithRequestID(r.Context(), requestID)
+
+	integration, err := s.DB.GetIntegration(ctx, userID, "whoop")
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "integration not found", http.StatusNotFound)
+			return
+		}
+		s.logWithRequestID(requestID, "whoop db error (get integration) user=%s err=%v", userID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	response := SyncStatusResponse{IntegrationStatus: integration.Status}
+	job, err := s.DB.GetLatestSyncJob(ctx, integration.ID, SyncJobInitial)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// Connected before sync jobs existed, or never connected.
+	case err != nil:
+		s.logWithRequestID(requestID, "whoop db error (get sync job) user=%s integration=%s err=%v", userID, integration.ID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	default:
+		if job.Progress == nil {
+			job.Progress = map[string]int{}
+		}
+		response.Job = &job
+		response.Importing = job.Kind == SyncJobInitial && (job.Status == syncJobQueued || job.Status == syncJobRunning)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// syncProgressKey carries the progress callback through SyncIntegration (like the request ID).
+type syncProgressKey struct{}
+
+// SyncProgressFunc receives the number of records of a resource stored so far.
+type SyncProgressFunc func(resource string, records int)
+
+// WithSyncProgress attaches a progress callback for the sync run.
+func WithSyncProgress(ctx context.Context, fn SyncProgressFunc) context.Context {
+	return context.WithValue(ctx, syncProgressKey{}, fn)
+}
+
+// reportSyncProgress calls the callback on ctx, if any (manual/scheduled syncs have none).
+func reportSyncProgress(ctx context.Context, resource string, records int) {
+	if fn, ok := ctx.Value(syncProgressKey{}).(SyncProgressFunc); ok && fn != nil {
+		fn(resource, records)
+	}
+}
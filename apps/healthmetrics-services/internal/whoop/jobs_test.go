@@ -0,0 +1,206 @@
+package whoop
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingWaker struct {
+	wakes int
+}
+
+func (c *countingWaker) Wake() {
+	c.wakes++
+}
+
+func TestExchangeHandler_EnqueuesInitialSync(t *testing.T) {
+	setEncryptionKey(t)
+
+	db := &fakeDB{}
+	waker := &countingWaker{}
+	service := &Service{
+		DB:          db,
+		HTTPClient:  &http.Client{Transport: &stubTransport{}},
+		TokenURL:    "https://token.local/oauth/oauth2/token",
+		RedirectURL: "https://app.local/integrations/whoop/callback",
+		Jobs:        waker,
+	}
+
+	db.oauthStates = map[string]oauthStateRow{hashState("state_1"): {userID: "user_1", expiresAt: time.Now().Add(time.Minute)}}
+	body := `{"userId":"user_1","code":"code_1","redirectUri":"https://app.local/integrations/whoop/callback","state":"state_1"}`
+	rec := httptest.NewRecorder()
+	service.ExchangeHandler(rec, httptest.NewRequest("POST", "/internal/whoop/oauth/exchange", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// Newest records first, then the full history.
+	if len(db.enqueuedJobs) != 2 || db.enqueuedJobs[0] != SyncJobInitial || db.enqueuedJobs[1] != SyncJobBackfill {
+		t.Fatalf("expected initial + backfill jobs, got %v", db.enqueuedJobs)
+	}
+	if waker.wakes != 1 {
+		t.Fatalf("expected the worker to be woken once, got %d", waker.wakes)
+	}
+	// The import runs in the worker, not in the request.
+	if len(db.rawEvents) != 0 {
+		t.Fatalf("exchange should not fetch WHOOP data, got %d raw events", len(db.rawEvents))
+	}
+}
+
+func TestSyncJobWorker_CompletesAndReportsProgress(t *testing.T) {
+	setEncryptionKey(t)
+
+	accessEnc, err := Encrypt("access_token")
+	if err != nil {
+		t.Fatalf("encrypt access token: %v", err)
+	}
+
+	db := &fakeDB{
+		hasToken: true,
+		tokenRecord: IntegrationTokenRecord{
+			AccessTokenEncrypted: accessEnc,
+			ExpiresAt:            ptrTime(time.Now().Add(10 * time.Minute)),
+		},
+		claimableJobs: []SyncJob{{ID: "job_1", IntegrationID: "integration_1", UserID: "user_1", Kind: SyncJobInitial, Attempts: 1, MaxAttempts: 5}},
+	}
+	worker := NewSyncJobWorker(&Service{DB: db, HTTPClient: &http.Client{Transport: &stubTransport{}}})
+
+	if ran := worker.RunDue(context.Background()); ran != 1 {
+		t.Fatalf("expected 1 job to run, got %d", ran)
+	}
+	if len(db.completedJobs) != 1 || len(db.failedJobs) != 0 {
+		t.Fatalf("expected job to complete, got completed=%v failed=%v", db.completedJobs, db.failedJobs)
+	}
+	for _, resource := range []string{resourceCycle, resourceRecovery, resourceSleep, resourceWorkout} {
+		if db.jobProgress[resource] != 1 {
+			t.Fatalf("expected progress 1 for %s, got %v", resource, db.jobProgress)
+		}
+	}
+}
+
+func TestSyncJobWorker_RetriesWithBackoff(t *testing.T) {
+	setEncryptionKey(t)
+
+	accessEnc, err := Encrypt("access_token")
+	if err != nil {
+		t.Fatalf("encrypt access token: %v", err)
+	}
+
+	db := &fakeDB{
+		hasToken: true,
+		tokenRecord: IntegrationTokenRecord{
+			AccessTokenEncrypted: accessEnc,
+			ExpiresAt:            ptrTime(time.Now().Add(10 * time.Minute)),
+		},
+		claimableJobs: []SyncJob{{ID: "job_1", IntegrationID: "integration_1", UserID: "user_1", Kind: SyncJobInitial, Attempts: 2, MaxAttempts: 5}},
+	}
+	// WHOOP is down: every call returns 503 (zero Retry policy = single attempt).
+	unavailable := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(503, `{"error":"unavailable"}`), nil
+	})
+	worker := NewSyncJobWorker(&Service{DB: db, HTTPClient: &http.Client{Transport: unavailable}})
+
+	before := time.Now()
+	worker.RunDue(context.Background())
+
+	if len(db.failedJobs) != 1 {
+		t.Fatalf("expected one failed attempt, got %v", db.failedJobs)
+	}
+	failed := db.failedJobs[0]
+	if failed.retryAt == nil {
+		t.Fatalf("expected a retry to be scheduled")
+	}
+	// Second attempt -> 4 minute backoff.
+	if delay := failed.retryAt.Sub(before); delay < 4*time.Minute || delay > 4*time.Minute+time.Second {
+		t.Fatalf("expected ~4m backoff, got %s", delay)
+	}
+	if failed.lastError == "" {
+		t.Fatalf("expected last error to be recorded")
+	}
+}
+
+func TestSyncJobWorker_GivesUp(t *testing.T) {
+	cases := []struct {
+		name string
+		job  SyncJob
+		db   *fakeDB
+	}{
+		// Disconnected before the job ran: retrying can't help.
+		{name: "missing token", job: SyncJob{ID: "job_1", Attempts: 1, MaxAttempts: 5}, db: &fakeDB{hasToken: false}},
+		{name: "last attempt", job: SyncJob{ID: "job_1", Attempts: 5, MaxAttempts: 5}, db: &fakeDB{hasToken: false}},
+		{name: "past max attempts", job: SyncJob{ID: "job_1", Attempts: 6, MaxAttempts: 5}, db: &fakeDB{hasToken: true}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.db.claimableJobs = []SyncJob{tc.job}
+			worker := NewSyncJobWorker(&Service{DB: tc.db, HTTPClient: &http.Client{Transport: &stubTransport{}}})
+			worker.RunDue(context.Background())
+
+			if len(tc.db.failedJobs) != 1 || tc.db.failedJobs[0].retryAt != nil {
+				t.Fatalf("expected a final failure, got %+v", tc.db.failedJobs)
+			}
+		})
+	}
+}
+
+func TestSyncJobBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1: time.Minute,
+		2: 4 * time.Minute,
+		3: 16 * time.Minute,
+		4: time.Hour,
+		9: time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := syncJobBackoff(attempt); got != want {
+			t.Fatalf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+}
+
+func TestSyncStatusHandler_Importing(t *testing.T) {
+	db := &fakeDB{latestJob: &SyncJob{
+		ID:       "job_1",
+		Kind:     SyncJobInitial,
+		Status:   syncJobRunning,
+		Progress: map[string]int{resourceCycle: 25},
+	}}
+	service := &Service{DB: db}
+
+	rec := httptest.NewRecorder()
+	service.SyncStatusHandler(rec, httptest.NewRequest("GET", "/internal/whoop/sync/status?userId=user_1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var response SyncStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !response.Importing || response.Job == nil || response.Job.Progress[resourceCycle] != 25 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	// Finished import: job still returned, importing false.
+	db.latestJob.Status = syncJobSucceeded
+	rec = httptest.NewRecorder()
+	service.SyncStatusHandler(rec, httptest.NewRequest("GET", "/internal/whoop/sync/status?userId=user_1", nil))
+	response = SyncStatusResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if response.Importing {
+		t.Fatalf("expected importing=false after success")
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
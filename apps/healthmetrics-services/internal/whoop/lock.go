@@ -0,0 +1,125 @@
+package whoop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Integration lease names (integration_lock.name).
+const (
+	lockSync  = "sync"  // one sync of an integration at a time, across replicas
+	lockToken = "token" // one token refresh at a time (WHOOP rotates the refresh token)
+)
+
+const (
+	// syncLockLease is extended every third of the lease while the sync runs, so it only
+	// matters when a replica dies mid-sync.
+	syncLockLease = 5 * time.Minute
+	// tokenLockLease covers one refresh request (the HTTP client times out well before).
+	tokenLockLease = time.Minute
+)
+
+// lockRetryInterval is how often a waiting caller retries a held lease (shortened in tests).
+var lockRetryInterval = time.Second
+
+// errTokenRotated means the stored tokens changed while we refreshed them (our lease expired
+// and another caller rotated them). The next attempt reads the stored tokens again.
+var errTokenRotated = errors.New("whoop tokens rotated concurrently")
+
+// inflightSync is a SyncIntegration run that other callers in this process can wait on.
+type inflightSync struct {
+	done chan struct{}
+	err  error
+}
+
+// joinSync registers a sync of integrationID. It returns leader=false with the running
+// sync when one is already in flight in this process.
+func (s *Service) joinSync(integrationID string) (call *inflightSync, leader bool) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	if call, ok := s.syncing[integrationID]; ok {
+		return call, false
+	}
+	if s.syncing == nil {
+		s.syncing = map[string]*inflightSync{}
+	}
+	call = &inflightSync{done: make(chan struct{})}
+	s.syncing[integrationID] = call
+	return call, true
+}
+
+// finishSync publishes the leader's result to everyone waiting on it.
+func (s *Service) finishSync(integrationID string, call *inflightSync, err error) {
+	s.syncMu.Lock()
+	delete(s.syncing, integrationID)
+	s.syncMu.Unlock()
+
+	call.err = err
+	close(call.done)
+}
+
+// withIntegrationLock runs fn while holding the named lease on the integration. It waits for
+// a lease held elsewhere (another replica, or a caller in this process) to be released or to
+// expire. While fn runs the lease is extended; if it can't be (lost to a takeover, DB down),
+// fn's context is cancelled so it stops before the new holder gets going.
+func (s *Service) withIntegrationLock(ctx context.Context, integrationID, name string, lease time.Duration, fn func(ctx context.Context) error) error {
+	owner, err := randomToken()
+	if err != nil {
+		return fmt.Errorf("lock owner: %w", err)
+	}
+
+	for {
+		acquired, err := s.DB.AcquireIntegrationLock(ctx, integrationID, name, owner, lease)
+		if err != nil {
+			return fmt.Errorf("acquire %s lock: %w", name, err)
+		}
+		if acquired {
+			break
+		}
+		if err := sleepContext(ctx, lockRetryInterval); err != nil {
+			return fmt.Errorf("wait for %s lock: %w", name, err)
+		}
+	}
+
+	lockCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Heartbeat: extend the lease until fn returns.
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				extended, err := s.DB.AcquireIntegrationLock(lockCtx, integrationID, name, owner, lease)
+				if err != nil || !extended {
+					s.logWithRequestID(RequestIDFromContext(ctx), "whoop lock lost integration=%s lock=%s err=%v", integrationID, name, err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(lockCtx)
+
+	// Stop the heartbeat before releasing so it can't re-take the lease afterwards.
+	close(stop)
+	<-stopped
+
+	// Release even if ctx is done (shutdown, client gone): otherwise others wait out the lease.
+	releaseCtx, releaseCancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer releaseCancel()
+	if releaseErr := s.DB.ReleaseIntegrationLock(releaseCtx, integrationID, name, owner); releaseErr != nil {
+		s.logWithRequestID(RequestIDFromContext(ctx), "whoop lock release failed integration=%s lock=%s err=%v", integrationID, name, releaseErr)
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package whoop
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// lockedLog collects log output written from several goroutines.
+type lockedLog struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (l *lockedLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *lockedLog) contains(s string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Contains(l.buf.String(), s)
+}
+
+// waitFor polls cond until it holds or the test times out.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// gatedTransport serves stubTransport responses, holding token refreshes until released.
+type gatedTransport struct {
+	refreshes atomic.Int32
+	release   chan struct{}
+}
+
+func (g *gatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "token.local" {
+		g.refreshes.Add(1)
+		<-g.release
+	}
+	return (&stubTransport{}).RoundTrip(req)
+}
+
+func expiredTokenDB(t *testing.T) *fakeDB {
+	t.Helper()
+	setEncryptionKey(t)
+	accessEnc, err := Encrypt("expired_access")
+	if err != nil {
+		t.Fatalf("encrypt access token: %v", err)
+	}
+	refreshEnc, err := Encrypt("refresh_token")
+	if err != nil {
+		t.Fatalf("encrypt refresh token: %v", err)
+	}
+	return &fakeDB{
+		hasToken: true,
+		tokenRecord: IntegrationTokenRecord{
+			AccessTokenEncrypted:  accessEnc,
+			RefreshTokenEncrypted: &refreshEnc,
+			ExpiresAt:             ptrTime(time.Now().Add(-time.Minute)),
+		},
+	}
+}
+
+func refreshingService(db *fakeDB, transport http.RoundTripper, logs *lockedLog) *Service {
+	service := &Service{
+		DB:           db,
+		HTTPClient:   &http.Client{Transport: transport},
+		TokenURL:     "https://token.local/oauth/oauth2/token",
+		ClientID:     "client",
+		ClientSecret: "secret",
+	}
+	if logs != nil {
+		service.Logger = log.New(logs, "", 0)
+	}
+	return service
+}
+
+func TestAccessToken_RefreshesOnceAcrossReplicas(t *testing.T) {
+	defer func(interval time.Duration) { lockRetryInterval = interval }(lockRetryInterval)
+	lockRetryInterval = time.Millisecond
+
+	// Two services sharing one database behave like two replicas.
+	db := expiredTokenDB(t)
+	transport := &gatedTransport{release: make(chan struct{})}
+	first := refreshingService(db, transport, nil)
+	second := refreshingService(db, transport, nil)
+
+	results := make(chan string, 2)
+	errs := make(chan error, 2)
+	run := func(service *Service) {
+		token, err := service.accessToken(context.Background(), "integration_1")
+		results <- token
+		errs <- err
+	}
+
+	go run(first)
+	waitFor(t, "the first refresh", func() bool { return transport.refreshes.Load() == 1 })
+	go run(second)
+	waitFor(t, "the second replica to wait for the lease", func() bool {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		return db.lockWaits > 0
+	})
+	close(transport.release)
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("access token: %v", err)
+		}
+		if token := <-results; token != "new_access" {
+			t.Fatalf("expected the refreshed token, got %q", token)
+		}
+	}
+	// The waiting replica re-read the token under the lease instead of refreshing again.
+	if transport.refreshes.Load() != 1 || len(db.rotateTokenCalls) != 1 {
+		t.Fatalf("expected one refresh, got %d requests / %d rotations", transport.refreshes.Load(), len(db.rotateTokenCalls))
+	}
+}
+
+func TestAccessToken_RotationConflictKeepsNewerTokens(t *testing.T) {
+	db := expiredTokenDB(t)
+	newerRefresh := "rotated_elsewhere"
+	// Someone else rotates the tokens while our refresh is in flight (our lease expired).
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		db.mu.Lock()
+		db.tokenRecord.RefreshTokenEncrypted = &newerRefresh
+		db.mu.Unlock()
+		return (&stubTransport{}).RoundTrip(req)
+	})
+	service := refreshingService(db, transport, nil)
+
+	if _, err := service.accessToken(context.Background(), "integration_1"); !errors.Is(err, errTokenRotated) {
+		t.Fatalf("expected errTokenRotated, got %v", err)
+	}
+	if len(db.rotateTokenCalls) != 0 || *db.tokenRecord.RefreshTokenEncrypted != newerRefresh {
+		t.Fatalf("expected the newer tokens to be kept, got %d rotations", len(db.rotateTokenCalls))
+	}
+}
+
+func TestSyncIntegration_ConcurrentCallersShareResult(t *testing.T) {
+	db := expiredTokenDB(t)
+	transport := &gatedTransport{release: make(chan struct{})}
+	logs := &lockedLog{}
+	service := refreshingService(db, transport, logs)
+
+	errs := make(chan error, 2)
+	go func() { errs <- service.SyncIntegration(context.Background(), "user_1", "integration_1") }()
+	waitFor(t, "the first sync", func() bool { return transport.refreshes.Load() == 1 })
+	go func() { errs <- service.SyncIntegration(context.Background(), "user_1", "integration_1") }()
+	waitFor(t, "the second caller to join", func() bool { return logs.contains("whoop sync joined in-flight") })
+	close(transport.release)
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("sync integration: %v", err)
+		}
+	}
+	if len(db.rawEvents) != 6 || transport.refreshes.Load() != 1 {
+		t.Fatalf("expected a single sync, got %d raw events / %d refreshes", len(db.rawEvents), transport.refreshes.Load())
+	}
+	if len(db.locks) != 0 {
+		t.Fatalf("expected leases to be released, got %v", db.locks)
+	}
+}
@@ -28,10 +28,19 @@ type Metrics struct {
 
 	WhoopAPITotal   uint64
 	WhoopAPIFailure uint64
+
+	// Shared WHOOP quota (see RateLimiter): 429s seen, time spent waiting, and the
+	// last reported budget (remaining -1 = no response seen yet; times are unix seconds).
+	RateLimitedTotal     uint64
+	RateLimitWaitMs      uint64
+	RateLimitRemaining   int64
+	RateLimitResetAt     int64
+	RateLimitPausedUntil int64
+	RateBudgetErrorTotal uint64 // shared budget unreachable; requests went out paced locally only
 }
 
 func NewMetrics() *Metrics {
-	return &Metrics{}
+	return &Metrics{RateLimitRemaining: -1}
 }
 
 func (m *Metrics) RecordExchange(err error) {
@@ -74,6 +83,31 @@ func (m *Metrics) RecordWhoopAPI(err error) {
 	}
 }
 
+func (m *Metrics) RecordRateLimited() {
+	atomic.AddUint64(&m.RateLimitedTotal, 1)
+}
+
+func (m *Metrics) RecordRateBudgetError() {
+	atomic.AddUint64(&m.RateBudgetErrorTotal, 1)
+}
+
+func (m *Metrics) RecordRateLimitWait(waited time.Duration) {
+	atomic.AddUint64(&m.RateLimitWaitMs, uint64(waited.Milliseconds()))
+}
+
+func (m *Metrics) RecordRateLimitState(remaining int, resetAt, pausedUntil time.Time) {
+	atomic.StoreInt64(&m.RateLimitRemaining, int64(remaining))
+	atomic.StoreInt64(&m.RateLimitResetAt, unixOrZero(resetAt))
+	atomic.StoreInt64(&m.RateLimitPausedUntil, unixOrZero(pausedUntil))
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
 // Snapshot returns a stable view of counters for debugging.
 func (m *Metrics) Snapshot() map[string]any {
 	total := atomic.LoadUint64(&m.SyncTotal)
@@ -97,6 +131,12 @@ func (m *Metrics) Snapshot() map[string]any {
 		"disconnect_failure": atomic.LoadUint64(&m.DisconnectFailure),
 		"whoop_api_total":    atomic.LoadUint64(&m.WhoopAPITotal),
 		"whoop_api_failure":  atomic.LoadUint64(&m.WhoopAPIFailure),
+		"whoop_rate_limited_total":      atomic.LoadUint64(&m.RateLimitedTotal),
+		"whoop_rate_limit_wait_ms":      atomic.LoadUint64(&m.RateLimitWaitMs),
+		"whoop_rate_budget_error_total": atomic.LoadUint64(&m.RateBudgetErrorTotal),
+		"whoop_rate_limit_remaining":    atomic.LoadInt64(&m.RateLimitRemaining),
+		"whoop_rate_limit_reset_at":     atomic.LoadInt64(&m.RateLimitResetAt),
+		"whoop_rate_limit_paused_until": atomic.LoadInt64(&m.RateLimitPausedUntil),
 	}
 }
 
@@ -8,26 +8,28 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"healthmetrics-services/internal/units"
 )
 
 // normalizeWhoopRecord maps a raw WHOOP record into provider-agnostic tables.
 // We store full payload JSON in extras so we can support future providers with extra fields.
-func (s *Service) normalizeWhoopRecord(ctx context.Context, integrationID, resourceType string, record map[string]any, extras string) error {
+func (s *Service) normalizeWhoopRecord(ctx context.Context, db DB, integrationID, resourceType string, record map[string]any, extras string) error {
 	switch resourceType {
 	case resourceSleep:
-		return s.normalizeSleep(ctx, integrationID, record, extras)
+		return s.normalizeSleep(ctx, db, integrationID, record, extras)
 	case resourceRecovery:
-		return s.normalizeRecovery(ctx, integrationID, record, extras)
+		return s.normalizeRecovery(ctx, db, integrationID, record, extras)
 	case resourceWorkout:
-		return s.normalizeWorkout(ctx, integrationID, record, extras)
+		return s.normalizeWorkout(ctx, db, integrationID, record, extras)
 	case resourceCycle:
-		return s.normalizeCycle(ctx, integrationID, record, extras)
+		return s.normalizeCycle(ctx, db, integrationID, record, extras)
 	default:
 		return nil
 	}
 }
 
-func (s *Service) normalizeSleep(ctx context.Context, integrationID string, record map[string]any, extras string) error {
+func (s *Service) normalizeSleep(ctx context.Context, db DB, integrationID string, record map[string]any, extras string) error {
 	startStr := getString(record, "start")
 	endStr := getString(record, "end")
 	if startStr == "" || endStr == "" {
@@ -63,7 +65,7 @@ func (s *Service) normalizeSleep(ctx context.Context, integrationID string, reco
 	}
 
 	extrasCopy := extras
-	return s.DB.UpsertIntegrationSleep(ctx, IntegrationSleepRecord{
+	return db.UpsertIntegrationSleep(ctx, IntegrationSleepRecord{
 		IntegrationID:         integrationID,
 		ExternalID:            externalID,
 		StartAt:               startAt,
@@ -78,7 +80,7 @@ func (s *Service) normalizeSleep(ctx context.Context, integrationID string, reco
 	})
 }
 
-func (s *Service) normalizeRecovery(ctx context.Context, integrationID string, record map[string]any, extras string) error {
+func (s *Service) normalizeRecovery(ctx context.Context, db DB, integrationID string, record map[string]any, extras string) error {
 	createdAtStr := getString(record, "created_at")
 	if createdAtStr == "" {
 		return nil
@@ -110,7 +112,7 @@ func (s *Service) normalizeRecovery(ctx context.Context, integrationID string, r
 	}
 
 	extrasCopy := extras
-	return s.DB.UpsertIntegrationRecovery(ctx, IntegrationRecoveryRecord{
+	return db.UpsertIntegrationRecovery(ctx, IntegrationRecoveryRecord{
 		IntegrationID:         integrationID,
 		ExternalID:            externalID,
 		LocalDate:             localDate,
@@ -123,7 +125,7 @@ func (s *Service) normalizeRecovery(ctx context.Context, integrationID string, r
 	})
 }
 
-func (s *Service) normalizeCycle(ctx context.Context, integrationID string, record map[string]any, extras string) error {
+func (s *Service) normalizeCycle(ctx context.Context, db DB, integrationID string, record map[string]any, extras string) error {
 	startStr := getString(record, "start")
 	endStr := getString(record, "end")
 	if startStr == "" || endStr == "" {
@@ -157,7 +159,7 @@ func (s *Service) normalizeCycle(ctx context.Context, integrationID string, reco
 	}
 
 	extrasCopy := extras
-	return s.DB.UpsertIntegrationCycle(ctx, IntegrationCycleRecord{
+	return db.UpsertIntegrationCycle(ctx, IntegrationCycleRecord{
 		IntegrationID:         integrationID,
 		ExternalID:            externalID,
 		StartAt:               startAt,
@@ -172,7 +174,7 @@ func (s *Service) normalizeCycle(ctx context.Context, integrationID string, reco
 	})
 }
 
-func (s *Service) normalizeWorkout(ctx context.Context, integrationID string, record map[string]any, extras string) error {
+func (s *Service) normalizeWorkout(ctx context.Context, db DB, integrationID string, record map[string]any, extras string) error {
 	startStr := getString(record, "start")
 	endStr := getString(record, "end")
 	if startStr == "" || endStr == "" {
@@ -211,7 +213,7 @@ func (s *Service) normalizeWorkout(ctx context.Context, integrationID string, re
 	}
 
 	extrasCopy := extras
-	return s.DB.UpsertIntegrationWorkout(ctx, IntegrationWorkoutRecord{
+	return db.UpsertIntegrationWorkout(ctx, IntegrationWorkoutRecord{
 		IntegrationID:         integrationID,
 		ExternalID:            externalID,
 		StartAt:               startAt,
@@ -338,16 +340,11 @@ func floatPtrWithOk(value float64, ok bool) *float64 {
 	return &value
 }
 
+// WHOOP reports meters and kilojoules; we store km and kcal (shared factors live in units).
 func convertMetersToKm(value float64) float64 {
-	if value == 0 {
-		return 0
-	}
-	return value / 1000
+	return units.MetersToKilometers(value)
 }
 
 func convertKilojoulesToKcal(value float64) float64 {
-	if value == 0 {
-		return 0
-	}
-	return value / 4.184
+	return units.KilojoulesToKcal(value)
 }
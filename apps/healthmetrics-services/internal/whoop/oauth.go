@@ -0,0 +1,171 @@
+package whoop
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultAuthURL is WHOOP's authorization endpoint (Service.AuthURL overrides it).
+const defaultAuthURL = "https://api.prod.whoop.com/oauth/oauth2/auth"
+
+// oauthStateTTL is how long the user has to finish the WHOOP consent screen.
+const oauthStateTTL = 10 * time.Minute
+
+// whoopScopes are everything the sync reads; offline returns a refresh token.
+var whoopScopes = []string{
+	"read:profile",
+	"read:body_measurement",
+	"read:cycles",
+	"read:recovery",
+	"read:sleep",
+	"read:workout",
+	"offline",
+}
+
+// ErrInvalidState is returned when the OAuth state is unknown, expired, already used or
+// belongs to another user.
+var ErrInvalidState = errors.New("invalid oauth state")
+
+type AuthorizeRequest struct {
+	UserID string `json:"userId"`
+}
+
+type AuthorizeResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (s *Service) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	// This handler:
+	// 1) generates a random state (CSRF token) and a PKCE code verifier
+	// 2) stores the state hashed + the verifier encrypted, with a short expiry
+	// 3) returns the WHOOP authorization URL (state + S256 code challenge)
+	// The raw state only travels in the URL; ExchangeHandler consumes it once.
+	var req AuthorizeRequest
+	requestID := requestIDFromHeaders(r)
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID == "" {
+		http.Error(w, "missing fields", http.StatusBadRequest)
+		return
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		http.Error(w, "state generation failed", http.StatusInternalServerError)
+		return
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		http.Error(w, "state generation failed", http.StatusInternalServerError)
+		return
+	}
+
+	// The verifier is a secret until the exchange, so it's encrypted like the tokens.
+	verifierEnc, err := Encrypt(verifier)
+	if err != nil {
+		http.Error(w, "encrypt failed", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := WithRequestID(r.Context(), requestID)
+	expiresAt := time.Now().Add(oauthStateTTL)
+
+	// Replaces any earlier pending state for this user (only the latest "Connect" click is valid).
+	if err := s.DB.CreateOAuthState(ctx, req.UserID, "whoop", hashState(state), verifierEnc, expiresAt); err != nil {
+		s.logWithRequestID(requestID, "whoop db error (create oauth state) user=%s err=%v", req.UserID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := s.authorizationURL(state, pkceChallenge(verifier))
+	if err != nil {
+		s.logWithRequestID(requestID, "whoop authorize url error user=%s err=%v", req.UserID, err)
+		http.Error(w, "invalid auth url", http.StatusInternalServerError)
+		return
+	}
+	s.logWithRequestID(requestID, "whoop oauth started user=%s", req.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(AuthorizeResponse{URL: authURL, ExpiresAt: expiresAt})
+}
+
+// consumeOAuthState deletes the matching unexpired state for the user and returns its PKCE
+// verifier ("" for states issued before PKCE). Unknown, expired or reused states are ErrInvalidState.
+func (s *Service) consumeOAuthState(ctx context.Context, userID, state string) (string, error) {
+	verifierEnc, err := s.DB.ConsumeOAuthState(ctx, userID, "whoop", hashState(state))
+	if errors.Is(err, ErrNotFound) {
+		return "", ErrInvalidState
+	}
+	if err != nil {
+		return "", err
+	}
+	if verifierEnc == nil {
+		return "", nil
+	}
+	verifier, err := Decrypt(*verifierEnc)
+	if err != nil {
+		return "", fmt.Errorf("decrypt code verifier: %w", err)
+	}
+	return verifier, nil
+}
+
+// authorizationURL builds the consent URL the browser is redirected to.
+func (s *Service) authorizationURL(state, challenge string) (string, error) {
+	base := s.AuthURL
+	if base == "" {
+		base = defaultAuthURL
+	}
+	authURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	params := authURL.Query()
+	params.Set("response_type", "code")
+	params.Set("client_id", s.ClientID)
+	params.Set("redirect_uri", s.RedirectURL)
+	params.Set("scope", strings.Join(whoopScopes, " "))
+	params.Set("state", state)
+	params.Set("code_challenge", challenge)
+	params.Set("code_challenge_method", "S256")
+	authURL.RawQuery = params.Encode()
+	return authURL.String(), nil
+}
+
+// randomToken returns 32 random bytes, base64url without padding (43 chars, valid as a
+// PKCE verifier and as a state value).
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashState is what integration_oauth_state.state_hash stores (sha256 hex, same as the TS app used).
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// pkceChallenge is the S256 code challenge: base64url(sha256(verifier)) without padding (RFC 7636).
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,164 @@
+package whoop
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRedirectURL = "https://app.local/integrations/whoop/callback"
+
+// tokenFormTransport records the form posted to the token endpoint.
+type tokenFormTransport struct {
+	forms []url.Values
+}
+
+func (t *tokenFormTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "token.local" {
+		body, _ := io.ReadAll(req.Body)
+		form, _ := url.ParseQuery(string(body))
+		t.forms = append(t.forms, form)
+	}
+	return (&stubTransport{}).RoundTrip(req)
+}
+
+func newOAuthService(db *fakeDB, transport http.RoundTripper) *Service {
+	return &Service{
+		DB:          db,
+		HTTPClient:  &http.Client{Transport: transport},
+		ClientID:    "client",
+		TokenURL:    "https://token.local/oauth/oauth2/token",
+		RedirectURL: testRedirectURL,
+	}
+}
+
+func authorize(t *testing.T, service *Service, userID string) *url.URL {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	service.AuthorizeHandler(rec, httptest.NewRequest("POST", "/internal/whoop/oauth/authorize", strings.NewReader(`{"userId":"`+userID+`"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("authorize: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var response AuthorizeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("decode authorize: %v", err)
+	}
+	authURL, err := url.Parse(response.URL)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	return authURL
+}
+
+func exchange(service *Service, userID, state string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(ExchangeRequest{UserID: userID, Code: "code_1", RedirectURI: testRedirectURL, State: state})
+	rec := httptest.NewRecorder()
+	service.ExchangeHandler(rec, httptest.NewRequest("POST", "/internal/whoop/oauth/exchange", strings.NewReader(string(body))))
+	return rec
+}
+
+func TestAuthorizeHandler_BuildsURL(t *testing.T) {
+	setEncryptionKey(t)
+
+	db := &fakeDB{}
+	authURL := authorize(t, newOAuthService(db, &stubTransport{}), "user_1")
+
+	if authURL.Host != "api.prod.whoop.com" || authURL.Path != "/oauth/oauth2/auth" {
+		t.Fatalf("unexpected auth url: %s", authURL)
+	}
+	query := authURL.Query()
+	if query.Get("client_id") != "client" || query.Get("redirect_uri") != testRedirectURL || query.Get("response_type") != "code" {
+		t.Fatalf("unexpected params: %v", query)
+	}
+	if query.Get("scope") != "read:profile read:body_measurement read:cycles read:recovery read:sleep read:workout offline" {
+		t.Fatalf("unexpected scope: %q", query.Get("scope"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("expected an S256 code challenge: %v", query)
+	}
+
+	// Only the hash is stored, with a ~10 minute expiry.
+	state := query.Get("state")
+	row, ok := db.oauthStates[hashState(state)]
+	if !ok || len(db.oauthStates) != 1 {
+		t.Fatalf("expected the hashed state to be stored, got %v", db.oauthStates)
+	}
+	if ttl := time.Until(row.expiresAt); ttl < 9*time.Minute || ttl > oauthStateTTL {
+		t.Fatalf("unexpected expiry in %s", ttl)
+	}
+}
+
+func TestExchangeHandler_ConsumesStateWithPKCE(t *testing.T) {
+	setEncryptionKey(t)
+
+	db := &fakeDB{}
+	transport := &tokenFormTransport{}
+	service := newOAuthService(db, transport)
+	authURL := authorize(t, service, "user_1")
+	state := authURL.Query().Get("state")
+
+	if rec := exchange(service, "user_1", state); rec.Code != http.StatusOK {
+		t.Fatalf("exchange: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(transport.forms) != 1 {
+		t.Fatalf("expected one token request, got %d", len(transport.forms))
+	}
+	verifier := transport.forms[0].Get("code_verifier")
+	if verifier == "" || pkceChallenge(verifier) != authURL.Query().Get("code_challenge") {
+		t.Fatalf("code_verifier %q does not match the challenge", verifier)
+	}
+
+	// Single use: the same callback again is rejected without calling WHOOP.
+	if rec := exchange(service, "user_1", state); rec.Code != http.StatusBadRequest {
+		t.Fatalf("replayed state: expected 400, got %d", rec.Code)
+	}
+	if len(transport.forms) != 1 {
+		t.Fatalf("replay should not reach the token endpoint")
+	}
+}
+
+func TestExchangeHandler_RejectsBadState(t *testing.T) {
+	setEncryptionKey(t)
+
+	cases := []struct {
+		name   string
+		userID string
+		state  func(db *fakeDB, issued string) string
+	}{
+		{name: "missing", userID: "user_1", state: func(db *fakeDB, issued string) string { return "" }},
+		{name: "unknown", userID: "user_1", state: func(db *fakeDB, issued string) string { return "forged" }},
+		{name: "other user", userID: "user_2", state: func(db *fakeDB, issued string) string { return issued }},
+		{name: "expired", userID: "user_1", state: func(db *fakeDB, issued string) string {
+			row := db.oauthStates[hashState(issued)]
+			row.expiresAt = time.Now().Add(-time.Second)
+			db.oauthStates[hashState(issued)] = row
+			return issued
+		}},
+		{name: "superseded", userID: "user_1", state: func(db *fakeDB, issued string) string {
+			// A second "Connect" click replaces the first state.
+			_ = db.CreateOAuthState(context.Background(), "user_1", "whoop", hashState("newer"), "", time.Now().Add(time.Minute))
+			return issued
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &fakeDB{}
+			transport := &tokenFormTransport{}
+			service := newOAuthService(db, transport)
+			issued := authorize(t, service, "user_1").Query().Get("state")
+
+			if rec := exchange(service, tc.userID, tc.state(db, issued)); rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if len(transport.forms) != 0 || len(db.upsertTokenCalls) != 0 {
+				t.Fatalf("rejected state should not exchange or store tokens")
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package whoop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"healthmetrics-services/ratelimiter"
+)
+
+// WHOOP reports the app's quota on every response (limits are per app, across all users):
+// X-RateLimit-Limit: 100, 100;window=60, 10000;window=86400
+// X-RateLimit-Remaining: 98 (requests left in the window closest to running out)
+// X-RateLimit-Reset: 45 (seconds until that window resets)
+const (
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// defaultRateLimitPause is how long to stop after a 429 that says nothing about when to retry.
+const defaultRateLimitPause = time.Minute
+
+// ErrRateLimited is returned when the quota is exhausted for longer than the caller can wait.
+// Jobs retry it with their backoff.
+var ErrRateLimited = errors.New("whoop rate limited")
+
+// RateLimiter keeps every WHOOP API request under the app's quota:
+//   - a token bucket paces this instance's requests (the same bucket the per-user API limiter uses)
+//   - an optional per-minute budget in Postgres (Shared) caps all replicas together
+//   - X-RateLimit-* headers pause all callers when WHOOP says the quota is used up
+//   - a 429 pauses all callers until Retry-After / reset
+//   - low-priority requests (backfill) yield when the remaining quota drops to Reserve, so
+//     scheduled syncs and webhooks still get through while a long history imports
+type RateLimiter struct {
+	mu     sync.Mutex
+	bucket *ratelimiter.TokenBucket
+	rate   int64 // requests per second (bucket refill)
+
+	// Reserve is the remaining quota kept for normal-priority requests.
+	Reserve int
+
+	// Shared and SharedPerMinute cap requests across every replica (nil/0 = this instance only).
+	// The local bucket alone would let N replicas send N times the quota.
+	Shared          SharedBudget
+	SharedPerMinute int
+
+	pausedUntil time.Time // nobody sends before this (429 or quota exhausted)
+	remaining   int       // last X-RateLimit-Remaining (-1 = unknown)
+	resetAt     time.Time // when that window resets
+
+	metrics *Metrics
+	now     func() time.Time // swapped in tests
+}
+
+// NewRateLimiter paces requests at perSecond with bursts up to burst. metrics may be nil.
+func NewRateLimiter(perSecond, burst int, reserve int, metrics *Metrics) *RateLimiter {
+	if perSecond < 1 {
+		perSecond = 1 // the token bucket refills in whole tokens per second
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		bucket:    ratelimiter.NewTokenBucket(float64(burst), float64(perSecond)),
+		rate:      int64(perSecond),
+		Reserve:   reserve,
+		remaining: -1,
+		metrics:   metrics,
+		now:       time.Now,
+	}
+}
+
+// SharedBudget is a request budget shared by every replica (Store in production).
+type SharedBudget interface {
+	TakeRateBudget(ctx context.Context, provider string, perMinute int) (bool, error)
+}
+
+// rateLimitPriorityKey marks requests that should yield to regular syncs.
+type rateLimitPriorityKey struct{}
+
+// WithLowPriority marks WHOOP requests made with ctx as background work (the backfill).
+func WithLowPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rateLimitPriorityKey{}, true)
+}
+
+func isLowPriority(ctx context.Context) bool {
+	low, _ := ctx.Value(rateLimitPriorityKey{}).(bool)
+	return low
+}
+
+// Wait blocks until a request may be sent. It returns ErrRateLimited right away when the
+// quota is paused past ctx's deadline (no point holding a job that can't finish), and
+// ctx.Err() if ctx ends while waiting.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	start := l.now()
+	defer func() {
+		if waited := l.now().Sub(start); waited > 0 && l.metrics != nil {
+			l.metrics.RecordRateLimitWait(waited)
+		}
+	}()
+
+	for {
+		delay, err := l.reserve(ctx)
+		if err != nil {
+			return err
+		}
+		if delay == 0 {
+			// This instance may send; check the budget all replicas share.
+			if delay, err = l.reserveShared(ctx); err != nil || delay == 0 {
+				return err
+			}
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// reserveShared takes a request from the shared per-minute budget and returns 0, or returns
+// how long to wait for the next minute. A DB error fails open: the local bucket and WHOOP's
+// own headers still pace requests, and a sync shouldn't stop because the budget row is slow.
+func (l *RateLimiter) reserveShared(ctx context.Context) (time.Duration, error) {
+	if l.Shared == nil || l.SharedPerMinute <= 0 {
+		return 0, nil
+	}
+
+	ok, err := l.Shared.TakeRateBudget(ctx, "whoop", l.SharedPerMinute)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if l.metrics != nil {
+			l.metrics.RecordRateBudgetError()
+		}
+		return 0, nil
+	}
+	if ok {
+		return 0, nil
+	}
+
+	now := l.now()
+	next := now.Truncate(time.Minute).Add(time.Minute)
+	if deadline, ok := ctx.Deadline(); ok && next.After(deadline) {
+		return 0, fmt.Errorf("%w: shared budget resets at %s", ErrRateLimited, next.UTC().Format(time.RFC3339))
+	}
+	return next.Sub(now), nil
+}
+
+// reserve takes a token and returns 0, or returns how long to wait before trying again.
+func (l *RateLimiter) reserve(ctx context.Context) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	until := l.pausedUntil
+	// Background work leaves the last Reserve requests of the window to everyone else.
+	if isLowPriority(ctx) && l.remaining >= 0 && l.remaining <= l.Reserve && l.resetAt.After(until) {
+		until = l.resetAt
+	}
+	if now.Before(until) {
+		if deadline, ok := ctx.Deadline(); ok && until.After(deadline) {
+			return 0, fmt.Errorf("%w: quota resets at %s", ErrRateLimited, until.UTC().Format(time.RFC3339))
+		}
+		return until.Sub(now), nil
+	}
+
+	if l.bucket.Allow() {
+		if l.remaining > 0 {
+			l.remaining-- // until the response tells us the real number
+		}
+		return 0, nil
+	}
+	return time.Second / time.Duration(l.rate), nil
+}
+
+// Observe updates the limiter from a WHOOP response (any status).
+func (l *RateLimiter) Observe(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	remaining, hasRemaining := parseRateLimitInt(resp.Header.Get(rateLimitRemainingHeader))
+	reset, hasReset := parseRateLimitInt(resp.Header.Get(rateLimitResetHeader))
+
+	if hasRemaining {
+		l.remaining = remaining
+	}
+	if hasReset {
+		l.resetAt = now.Add(time.Duration(reset) * time.Second)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// Stop every caller, not just this request: Retry-After, else the window reset.
+		pause := defaultRateLimitPause
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(strings.TrimSpace(retryAfter)); err == nil && seconds >= 0 {
+				pause = time.Duration(seconds) * time.Second
+			}
+		} else if hasReset && reset > 0 {
+			pause = time.Duration(reset) * time.Second
+		}
+		l.pause(now.Add(pause))
+		l.remaining = 0
+		if l.metrics != nil {
+			l.metrics.RecordRateLimited()
+		}
+	case hasRemaining && remaining <= 0 && hasReset:
+		// Quota used up: wait for the reset instead of collecting a 429.
+		l.pause(l.resetAt)
+	}
+
+	if l.metrics != nil {
+		l.metrics.RecordRateLimitState(l.remaining, l.resetAt, l.pausedUntil)
+	}
+}
+
+func (l *RateLimiter) pause(until time.Time) {
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// parseRateLimitInt reads a header like "98" or "100, 100;window=60" (first value).
+func parseRateLimitInt(value string) (int, bool) {
+	value, _, _ = strings.Cut(value, ",")
+	value, _, _ = strings.Cut(value, ";")
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+	return parsed, true
+}
@@ -0,0 +1,157 @@
+package whoop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"healthmetrics-services/internal/retry"
+)
+
+// quotaResponse is a WHOOP response carrying rate limit headers.
+func quotaResponse(status int, remaining, reset string) *http.Response {
+	resp := jsonResponse(status, `{"records":[]}`)
+	resp.Header.Set(rateLimitRemainingHeader, remaining)
+	resp.Header.Set(rateLimitResetHeader, reset)
+	return resp
+}
+
+func TestRateLimiter_PausesOn429(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 10, 0, NewMetrics())
+	limiter.now = func() time.Time { return now }
+
+	resp := quotaResponse(http.StatusTooManyRequests, "0", "40")
+	resp.Header.Set("Retry-After", "30")
+	limiter.Observe(resp)
+
+	// Every caller waits out the Retry-After, not just the request that got the 429.
+	delay, err := limiter.reserve(context.Background())
+	if err != nil || delay != 30*time.Second {
+		t.Fatalf("expected a 30s wait, got %v / %v", delay, err)
+	}
+
+	// A caller that can't wait that long fails fast so its job can retry later.
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(10*time.Second))
+	defer cancel()
+	if _, err := limiter.reserve(ctx); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+
+	snapshot := limiter.metrics.Snapshot()
+	if snapshot["whoop_rate_limited_total"] != uint64(1) || snapshot["whoop_rate_limit_remaining"] != int64(0) {
+		t.Fatalf("unexpected metrics: %v", snapshot)
+	}
+
+	now = now.Add(31 * time.Second)
+	if delay, err := limiter.reserve(context.Background()); err != nil || delay != 0 {
+		t.Fatalf("expected to send after the pause, got %v / %v", delay, err)
+	}
+}
+
+func TestRateLimiter_BackfillYieldsReserve(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 10, 5, nil)
+	limiter.now = func() time.Time { return now }
+	limiter.Observe(quotaResponse(http.StatusOK, "5", "20"))
+
+	// Backfill waits for the window to reset; regular syncs use the reserve.
+	if delay, err := limiter.reserve(WithLowPriority(context.Background())); err != nil || delay != 20*time.Second {
+		t.Fatalf("expected backfill to wait 20s, got %v / %v", delay, err)
+	}
+	if delay, err := limiter.reserve(context.Background()); err != nil || delay != 0 {
+		t.Fatalf("expected a regular sync to go through, got %v / %v", delay, err)
+	}
+
+	// Quota used up: everyone waits for the reset instead of collecting 429s.
+	limiter.Observe(quotaResponse(http.StatusOK, "0", "15"))
+	if delay, err := limiter.reserve(context.Background()); err != nil || delay != 15*time.Second {
+		t.Fatalf("expected a 15s wait, got %v / %v", delay, err)
+	}
+}
+
+// fakeBudget is the shared per-minute budget with a fixed number of requests left.
+type fakeBudget struct {
+	left  int
+	err   error
+	taken int
+}
+
+func (b *fakeBudget) TakeRateBudget(context.Context, string, int) (bool, error) {
+	if b.err != nil {
+		return false, b.err
+	}
+	if b.left == 0 {
+		return false, nil
+	}
+	b.left--
+	b.taken++
+	return true, nil
+}
+
+func TestRateLimiter_SharedBudget(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 40, 0, time.UTC)
+	budget := &fakeBudget{left: 1}
+	limiter := NewRateLimiter(1, 10, 0, NewMetrics())
+	limiter.now = func() time.Time { return now }
+	limiter.Shared = budget
+	limiter.SharedPerMinute = 90
+
+	if err := limiter.Wait(context.Background()); err != nil || budget.taken != 1 {
+		t.Fatalf("expected the request to take from the shared budget, got %v (taken=%d)", err, budget.taken)
+	}
+
+	// Other replicas used the rest of the minute: wait for the next one (20s away).
+	if delay, err := limiter.reserveShared(context.Background()); err != nil || delay != 20*time.Second {
+		t.Fatalf("expected a 20s wait, got %v / %v", delay, err)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(5*time.Second))
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestRateLimiter_SharedBudgetErrorFailsOpen(t *testing.T) {
+	limiter := NewRateLimiter(1, 10, 0, NewMetrics())
+	limiter.Shared = &fakeBudget{err: errors.New("db down")}
+	limiter.SharedPerMinute = 90
+
+	// The local bucket still paces the request; a slow budget row doesn't stop syncing.
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("expected the request to go out, got %v", err)
+	}
+	if got := limiter.metrics.Snapshot()["whoop_rate_budget_error_total"]; got != uint64(1) {
+		t.Fatalf("expected the budget error to be counted, got %v", got)
+	}
+}
+
+func TestFetchWhoop_RetriesAfterRateLimit(t *testing.T) {
+	calls := 0
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			resp := quotaResponse(http.StatusTooManyRequests, "0", "0")
+			resp.Header.Set("Retry-After", "0")
+			return resp, nil
+		}
+		return quotaResponse(http.StatusOK, "42", "30"), nil
+	})
+	metrics := NewMetrics()
+	service := &Service{
+		HTTPClient:  &http.Client{Transport: transport},
+		Metrics:     metrics,
+		RateLimiter: NewRateLimiter(1, 10, 0, metrics),
+		Retry:       retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}
+
+	if _, err := service.fetchWhoop(context.Background(), "token", "/v2/activity/sleep", nil); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	snapshot := metrics.Snapshot()
+	if calls != 2 || snapshot["whoop_rate_limited_total"] != uint64(1) || snapshot["whoop_rate_limit_remaining"] != int64(42) {
+		t.Fatalf("unexpected calls %d / metrics %v", calls, snapshot)
+	}
+}
@@ -0,0 +1,107 @@
+package whoop
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"healthmetrics-services/internal/retry"
+)
+
+// TokenRefresher renews WHOOP tokens before they expire, so webhook fetches and syncs find a
+// valid access token and refresh tokens don't lapse from disuse between syncs. It uses the
+// same refresh path as SyncIntegration (token lease, compare-and-swap store), so replicas
+// running it at once, or a sync refreshing meanwhile, refresh each token only once.
+//   - revoked (invalid_grant): the integration is marked 'error' with the reason
+//   - transient (network, 429/5xx): left as is; the next run tries again while the token
+//     is still valid
+type TokenRefresher struct {
+	Service *Service
+
+	// PollInterval is how often expiring tokens are looked for.
+	PollInterval time.Duration
+
+	// RefreshAhead: tokens expiring within this are refreshed. Several polls fit inside it,
+	// so a transient failure gets retried before the token actually expires.
+	RefreshAhead time.Duration
+
+	// BatchSize bounds the integrations refreshed per poll. Each poll takes the least recently
+	// tried tokens, so more failing tokens than BatchSize still let the others through.
+	BatchSize int
+
+	// RefreshTimeout bounds one integration's refresh.
+	RefreshTimeout time.Duration
+}
+
+// NewTokenRefresher returns a refresher with default timings (every 5m, 20m ahead).
+func NewTokenRefresher(service *Service) *TokenRefresher {
+	return &TokenRefresher{
+		Service:        service,
+		PollInterval:   5 * time.Minute,
+		RefreshAhead:   20 * time.Minute,
+		BatchSize:      100,
+		RefreshTimeout: 30 * time.Second,
+	}
+}
+
+// Run refreshes expiring tokens until ctx is cancelled.
+func (r *TokenRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.RunDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue refreshes one batch of expiring tokens; returns how many were refreshed.
+func (r *TokenRefresher) RunDue(ctx context.Context) int {
+	s := r.Service
+	integrations, err := s.DB.ClaimExpiringIntegrations(ctx, "whoop", r.RefreshAhead, r.BatchSize)
+	if err != nil {
+		s.logWithRequestID("", "whoop token refresher list error err=%v", err)
+		return 0
+	}
+
+	refreshed := 0
+	for _, integration := range integrations {
+		if ctx.Err() != nil {
+			break
+		}
+		if r.refresh(ctx, integration) {
+			refreshed++
+		}
+	}
+	return refreshed
+}
+
+// refresh renews one integration's tokens and logs how it went.
+func (r *TokenRefresher) refresh(ctx context.Context, integration ConnectedIntegration) bool {
+	s := r.Service
+	requestID := "token_refresh_" + integration.ID
+
+	refreshCtx, cancel := context.WithTimeout(WithRequestID(ctx, requestID), r.RefreshTimeout)
+	defer cancel()
+
+	// Re-checks the expiry under the token lease: a sync may have refreshed it already.
+	_, err := s.refreshStoredToken(refreshCtx, integration.ID, r.RefreshAhead)
+	switch {
+	case err == nil:
+		s.logWithRequestID(requestID, "whoop token refreshed user=%s integration=%s", integration.UserID, integration.ID)
+		return true
+	case errors.Is(err, ErrTokenRevoked):
+		// refreshStoredToken already marked the integration 'error'.
+		s.logWithRequestID(requestID, "whoop token refresh revoked user=%s integration=%s err=%v", integration.UserID, integration.ID, err)
+	case retry.IsTransient(err):
+		s.logWithRequestID(requestID, "whoop token refresh retry user=%s integration=%s err=%v", integration.UserID, integration.ID, err)
+	default:
+		s.logWithRequestID(requestID, "whoop token refresh failed user=%s integration=%s err=%v", integration.UserID, integration.ID, err)
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package whoop
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tokenEndpoint answers every refresh with the given status and body.
+func tokenEndpoint(status int, body string) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(status, body), nil
+	}
+}
+
+func TestTokenRefresher_RenewsExpiringTokens(t *testing.T) {
+	db := expiredTokenDB(t)
+	db.tokenRecord.ExpiresAt = ptrTime(time.Now().Add(10 * time.Minute)) // still valid, inside RefreshAhead
+	db.expiringIntegrations = []ConnectedIntegration{{ID: "integration_1", UserID: "user_1"}}
+	refresher := NewTokenRefresher(refreshingService(db, &stubTransport{}, nil))
+
+	if refreshed := refresher.RunDue(context.Background()); refreshed != 1 {
+		t.Fatalf("expected 1 refresh, got %d", refreshed)
+	}
+	if len(db.rotateTokenCalls) != 1 || !db.tokenRecord.ExpiresAt.After(time.Now().Add(refresher.RefreshAhead)) {
+		t.Fatalf("expected the tokens to be renewed, got %d rotations (expires %s)", len(db.rotateTokenCalls), db.tokenRecord.ExpiresAt)
+	}
+
+	// Renewed tokens aren't refreshed again (a second replica listing the same row).
+	if refreshed := refresher.RunDue(context.Background()); refreshed != 1 || len(db.rotateTokenCalls) != 1 {
+		t.Fatalf("expected no second refresh, got %d rotations", len(db.rotateTokenCalls))
+	}
+}
+
+func TestTokenRefresher_ClassifiesFailures(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		body    string
+		revoked bool
+	}{
+		{name: "revoked", status: 400, body: `{"error":"invalid_grant","error_description":"refresh token revoked"}`, revoked: true},
+		{name: "unavailable", status: 503, body: `{"error":"temporarily_unavailable"}`},
+		{name: "rate limited", status: 429, body: `{}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := expiredTokenDB(t)
+			db.expiringIntegrations = []ConnectedIntegration{{ID: "integration_1", UserID: "user_1"}}
+			refresher := NewTokenRefresher(refreshingService(db, tokenEndpoint(tc.status, tc.body), nil))
+
+			if refreshed := refresher.RunDue(context.Background()); refreshed != 0 || len(db.rotateTokenCalls) != 0 {
+				t.Fatalf("expected no refresh, got %d / %d rotations", refreshed, len(db.rotateTokenCalls))
+			}
+			reason, marked := db.integrationErrors["integration_1"]
+			if marked != tc.revoked {
+				t.Fatalf("expected marked=%t, got %v", tc.revoked, db.integrationErrors)
+			}
+			if tc.revoked && !strings.Contains(reason, "refresh token revoked") {
+				t.Fatalf("expected the reason to be stored, got %q", reason)
+			}
+			if len(db.locks) != 0 {
+				t.Fatalf("expected the token lease to be released, got %v", db.locks)
+			}
+		})
+	}
+}
+
+func TestTokenRefresher_FailingTokensDontStarveOthers(t *testing.T) {
+	// Three expiring tokens, a batch of two, and WHOOP failing every refresh: the third token
+	// still gets its turn on the next poll instead of waiting behind the first two forever.
+	db := expiredTokenDB(t)
+	db.expiringIntegrations = []ConnectedIntegration{
+		{ID: "integration_1", UserID: "user_1"},
+		{ID: "integration_2", UserID: "user_2"},
+		{ID: "integration_3", UserID: "user_3"},
+	}
+	refresher := NewTokenRefresher(refreshingService(db, tokenEndpoint(503, `{"error":"temporarily_unavailable"}`), nil))
+	refresher.BatchSize = 2
+
+	refresher.RunDue(context.Background())
+	refresher.RunDue(context.Background())
+
+	if len(db.claimedExpiring) != 2 || strings.Join(db.claimedExpiring[1], ",") != "integration_3,integration_1" {
+		t.Fatalf("expected the untried token first on the second poll, got %v", db.claimedExpiring)
+	}
+}
@@ -0,0 +1,137 @@
+package whoop
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// SyncScheduler runs the periodic sync of every connected WHOOP integration. Every replica
+// runs one; they share the work through integration.next_sync_at:
+//   - claiming an integration moves its next_sync_at one jittered Interval ahead (SKIP LOCKED),
+//     so each integration is synced once per interval no matter how many replicas poll
+//   - at most Workers syncs run at once on this instance (the shared RateLimiter keeps them
+//     all under WHOOP's quota)
+//   - users who used the app recently are claimed first when many integrations are due
+type SyncScheduler struct {
+	Service *Service
+
+	// Interval between syncs of one integration; Jitter spreads it by ± that fraction.
+	Interval time.Duration
+	Jitter   float64
+
+	// Workers bounds the syncs running at once on this instance.
+	Workers int
+
+	// PollInterval is how often due integrations are looked for.
+	PollInterval time.Duration
+
+	// SyncTimeout bounds one integration's sync.
+	SyncTimeout time.Duration
+
+	// RetryDelay is how soon a failed sync is tried again (instead of a full Interval).
+	RetryDelay time.Duration
+
+	// ActiveWithin: users with a session used this recently get priority.
+	ActiveWithin time.Duration
+}
+
+// NewSyncScheduler returns a scheduler with default timings (every 12h ±10%, 4 workers).
+func NewSyncScheduler(service *Service) *SyncScheduler {
+	return &SyncScheduler{
+		Service:      service,
+		Interval:     12 * time.Hour,
+		Jitter:       0.1,
+		Workers:      4,
+		PollInterval: time.Minute,
+		SyncTimeout:  10 * time.Minute,
+		RetryDelay:   30 * time.Minute,
+		ActiveWithin: 7 * 24 * time.Hour,
+	}
+}
+
+// Run syncs due integrations until ctx is cancelled (SIGTERM). It then stops claiming,
+// cancels the syncs in flight and returns once they have been handed back.
+func (w *SyncScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.RunDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue claims due integrations and syncs them, at most Workers at a time, until none are
+// due or ctx is cancelled. It returns how many it claimed, after all of them have finished.
+func (w *SyncScheduler) RunDue(ctx context.Context) int {
+	workers := w.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	claimed := 0
+	for {
+		// Wait for a free worker before claiming, so nothing is claimed and left waiting.
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return claimed
+		}
+		if ctx.Err() != nil { // a slot freed up by the shutdown itself
+			return claimed
+		}
+
+		integration, err := w.Service.DB.ClaimDueIntegration(ctx, "whoop", w.Interval, w.Jitter, w.ActiveWithin)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) && ctx.Err() == nil {
+				w.Service.logWithRequestID("", "whoop scheduler claim error err=%v", err)
+			}
+			return claimed
+		}
+		claimed++
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			w.sync(ctx, integration)
+		}()
+	}
+}
+
+// sync runs one scheduled sync. A failed sync is retried after RetryDelay; one cut short by
+// shutdown is made due again right away so another replica picks it up.
+func (w *SyncScheduler) sync(ctx context.Context, integration ConnectedIntegration) {
+	s := w.Service
+	requestID := "scheduled_sync_" + integration.ID
+
+	syncCtx, cancel := context.WithTimeout(WithRequestID(ctx, requestID), w.SyncTimeout)
+	defer cancel()
+
+	// SyncIntegration logs the outcome and records last_error.
+	err := s.SyncIntegration(syncCtx, integration.UserID, integration.ID)
+	if err == nil {
+		return
+	}
+
+	retryAt := time.Now().Add(w.RetryDelay)
+	if ctx.Err() != nil {
+		retryAt = time.Now()
+	}
+	// Own context: the claim has to be handed back even when ctx is already cancelled.
+	rescheduleCtx, rescheduleCancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer rescheduleCancel()
+	if err := s.DB.RescheduleIntegrationSync(rescheduleCtx, integration.ID, retryAt); err != nil {
+		s.logWithRequestID(requestID, "whoop scheduler reschedule error integration=%s err=%v", integration.ID, err)
+	}
+}
@@ -0,0 +1,98 @@
+package whoop
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func schedulerService(t *testing.T, transport http.RoundTripper, due int) (*SyncScheduler, *fakeDB) {
+	t.Helper()
+	setEncryptionKey(t)
+	accessEnc, err := Encrypt("access_token")
+	if err != nil {
+		t.Fatalf("encrypt access token: %v", err)
+	}
+	db := &fakeDB{
+		hasToken:    true,
+		tokenRecord: IntegrationTokenRecord{AccessTokenEncrypted: accessEnc, ExpiresAt: ptrTime(time.Now().Add(time.Hour))},
+	}
+	for i := 1; i <= due; i++ {
+		db.dueIntegrations = append(db.dueIntegrations, ConnectedIntegration{ID: fmt.Sprintf("integration_%d", i), UserID: fmt.Sprintf("user_%d", i)})
+	}
+	scheduler := NewSyncScheduler(&Service{DB: db, HTTPClient: &http.Client{Transport: transport}})
+	scheduler.Workers = 2
+	return scheduler, db
+}
+
+func TestSyncScheduler_BoundsWorkersAndRetriesFailures(t *testing.T) {
+	var running, peak atomic.Int32
+	// Each sync fails on its first request, after holding it long enough to overlap.
+	unavailable := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return jsonResponse(503, `{"error":"unavailable"}`), nil
+	})
+	scheduler, db := schedulerService(t, unavailable, 5)
+
+	before := time.Now()
+	if claimed := scheduler.RunDue(context.Background()); claimed != 5 {
+		t.Fatalf("expected 5 integrations to be claimed, got %d", claimed)
+	}
+	if peak.Load() != 2 {
+		t.Fatalf("expected at most 2 syncs at once, got %d", peak.Load())
+	}
+	// Failed syncs come back after RetryDelay instead of a full interval.
+	if len(db.rescheduled) != 5 {
+		t.Fatalf("expected every failed sync to be rescheduled, got %v", db.rescheduled)
+	}
+	for id, at := range db.rescheduled {
+		if at.Before(before.Add(scheduler.RetryDelay)) || at.After(time.Now().Add(scheduler.RetryDelay)) {
+			t.Fatalf("expected %s to retry after %s, got %s", id, scheduler.RetryDelay, at)
+		}
+	}
+}
+
+func TestSyncScheduler_ShutdownHandsBackSyncsInFlight(t *testing.T) {
+	var started atomic.Int32
+	// Requests hang until the sync is cancelled.
+	hanging := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		started.Add(1)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	scheduler, db := schedulerService(t, hanging, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- scheduler.RunDue(ctx) }()
+	waitFor(t, "both workers to start", func() bool { return started.Load() == 2 })
+
+	shutdownAt := time.Now()
+	cancel()
+	if claimed := <-done; claimed != 2 {
+		t.Fatalf("expected only the 2 running syncs to be claimed, got %d", claimed)
+	}
+	// Interrupted syncs are due again right away; the third was never claimed.
+	if len(db.rescheduled) != 2 || len(db.dueIntegrations) != 1 {
+		t.Fatalf("expected 2 syncs handed back and 1 left due, got %v / %v", db.rescheduled, db.dueIntegrations)
+	}
+	for id, at := range db.rescheduled {
+		if at.Before(shutdownAt) || at.After(time.Now()) {
+			t.Fatalf("expected %s to be due now, got %s", id, at)
+		}
+	}
+	if len(db.locks) != 0 {
+		t.Fatalf("expected leases to be released, got %v", db.locks)
+	}
+}
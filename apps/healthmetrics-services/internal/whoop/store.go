@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -29,6 +30,34 @@ type ConnectedIntegration struct {
 	UserID string
 }
 
+// SyncJob is a row of integration_sync_job (see jobs.go for the worker).
+type SyncJob struct {
+	ID              string         `json:"id"`
+	IntegrationID   string         `json:"integration_id"`
+	UserID          string         `json:"-"`
+	Kind            string         `json:"kind"`
+	Status          string         `json:"status"`
+	Attempts        int            `json:"attempts"`
+	MaxAttempts     int            `json:"max_attempts"`
+	Progress        map[string]int `json:"progress"`
+	CurrentResource *string        `json:"current_resource"`
+	LastError       *string        `json:"last_error"`
+	RunAt           time.Time      `json:"run_at"`
+	StartedAt       *time.Time     `json:"started_at"`
+	FinishedAt      *time.Time     `json:"finished_at"`
+}
+
+// WebhookEvent is a row of integration_webhook_event (see webhook.go for the worker).
+type WebhookEvent struct {
+	ID             string
+	EventKey       string // WHOOP trace_id (dedupe key)
+	ProviderUserID string
+	Type           string // sleep.updated / workout.deleted / ...
+	ResourceID     string
+	Attempts       int
+	MaxAttempts    int
+}
+
 type IntegrationSleepRecord struct {
 	IntegrationID         string
 	ExternalID            string
@@ -85,13 +114,40 @@ type IntegrationCycleRecord struct {
 
 // Store implements the whoop.DB interface using pgxpool.
 type Store struct {
-	pool *pgxpool.Pool
+	pool querier
+}
+
+// querier is what Store needs from a connection: a *pgxpool.Pool, or the pgx.Tx of InTx
+// (whose Begin opens a savepoint).
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 }
 
 func NewStore(pool *pgxpool.Pool) *Store {
 	return &Store{pool: pool}
 }
 
+// InTx runs fn with a DB whose writes commit together when fn returns nil, and are rolled
+// back otherwise.
+func (s *Store) InTx(ctx context.Context, fn func(tx DB) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&Store{pool: tx}); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
 // UpsertIntegration creates or updates the integration row and returns its id (integration_id)
 func (s *Store) UpsertIntegration(ctx context.Context, userID, provider string) (string, error) {
 	// Cast provider to the enum type to satisfy Postgres.
@@ -151,6 +207,38 @@ func (s *Store) UpsertIntegrationToken(
 	return nil
 }
 
+// RotateIntegrationToken stores refreshed tokens only if the stored refresh token is still
+// previousRefreshEnc, the one that was just used (compare-and-swap). Returns false when
+// another caller rotated the tokens first; their tokens are kept.
+func (s *Store) RotateIntegrationToken(
+	ctx context.Context,
+	integrationID string,
+	previousRefreshEnc *string,
+	accessEnc string,
+	refreshEnc *string,
+	expiresAt time.Time,
+	scopes []string,
+) (bool, error) {
+	// Ciphertexts use a random nonce, so equal ciphertext means this exact stored token.
+	// A refresh response without a new refresh token keeps the current one.
+	const query = `
+		UPDATE integration_token
+		SET
+			access_token_encrypted = $3,
+			refresh_token_encrypted = COALESCE($4, refresh_token_encrypted),
+			expires_at = $5,
+			scopes = $6,
+			updated_at = now()
+		WHERE integration_id = $1
+			AND refresh_token_encrypted IS NOT DISTINCT FROM $2
+	`
+	tag, err := s.pool.Exec(ctx, query, integrationID, previousRefreshEnc, accessEnc, refreshEnc, expiresAt, scopes)
+	if err != nil {
+		return false, fmt.Errorf("rotate integration token: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
 // MarkIntegrationConnected marks integration status as connected.
 func (s *Store) MarkIntegrationConnected(ctx context.Context, integrationID string) error {
 	// Simple status flip so UI can show "Connected".
@@ -251,7 +339,8 @@ func (s *Store) GetIntegrationToken(ctx context.Context, integrationID string) (
 // UpsertIntegrationRawEvent stores raw WHOOP payloads by resource type + source id.
 func (s *Store) UpsertIntegrationRawEvent(ctx context.Context, integrationID, resourceType, sourceID string, payload []byte) error {
 	// Store raw WHOOP payloads. Unique key is (integration_id, source_id, resource_type).
-	// Upsert prevents duplicates on repeated syncs.
+	// Upsert prevents duplicates on repeated syncs. A record we just fetched exists upstream,
+	// so any earlier tombstone is cleared.
 	const query = `
 		INSERT INTO integration_raw_event (
 			integration_id,
@@ -260,7 +349,7 @@ func (s *Store) UpsertIntegrationRawEvent(ctx context.Context, integrationID, re
 			source_id
 		) VALUES ($1, $2, $3, $4)
 		ON CONFLICT (integration_id, source_id, resource_type) DO UPDATE
-		SET payload = EXCLUDED.payload
+		SET payload = EXCLUDED.payload, deleted_at = NULL
 	`
 
 	if _, err := s.pool.Exec(ctx, query, integrationID, resourceType, payload, sourceID); err != nil {
@@ -272,6 +361,7 @@ func (s *Store) UpsertIntegrationRawEvent(ctx context.Context, integrationID, re
 // UpsertIntegrationSleep stores normalized sleep data (one row per sleep session).
 func (s *Store) UpsertIntegrationSleep(ctx context.Context, data IntegrationSleepRecord) error {
 	// Sleep rows are unique per integration + external_id (WHOOP sleep id).
+	// An upsert means the record exists upstream, so a tombstone is cleared.
 	const query = `
 		INSERT INTO integration_sleep (
 			integration_id,
@@ -297,6 +387,7 @@ func (s *Store) UpsertIntegrationSleep(ctx context.Context, data IntegrationSlee
 			is_nap = EXCLUDED.is_nap,
 			is_primary = EXCLUDED.is_primary,
 			extras = EXCLUDED.extras,
+			deleted_at = NULL,
 			updated_at = now()
 	`
 
@@ -323,19 +414,21 @@ func (s *Store) UpsertIntegrationSleep(ctx context.Context, data IntegrationSlee
 // UpdatePrimarySleep marks the longest sleep per local_date as primary.
 func (s *Store) UpdatePrimarySleep(ctx context.Context, integrationID string) error {
 	// We rank sleeps by duration per local_date and set is_primary on the longest row.
+	// Deleted sleeps are ranked apart and never primary, so the next-longest one takes over.
 	const query = `
 		WITH ranked AS (
 			SELECT
 				id,
+				deleted_at IS NULL AS live,
 				ROW_NUMBER() OVER (
-					PARTITION BY integration_id, local_date
+					PARTITION BY integration_id, local_date, deleted_at IS NULL
 					ORDER BY duration_seconds DESC
 				) AS rn
 			FROM integration_sleep
 			WHERE integration_id = $1
 		)
 		UPDATE integration_sleep AS s
-		SET is_primary = (ranked.rn = 1)
+		SET is_primary = (ranked.live AND ranked.rn = 1)
 		FROM ranked
 		WHERE s.id = ranked.id
 	`
@@ -370,6 +463,7 @@ func (s *Store) UpsertIntegrationRecovery(ctx context.Context, data IntegrationR
 			resting_hr_bpm = EXCLUDED.resting_hr_bpm,
 			spo2_pct = EXCLUDED.spo2_pct,
 			extras = EXCLUDED.extras,
+			deleted_at = NULL,
 			updated_at = now()
 	`
 
@@ -419,6 +513,7 @@ func (s *Store) UpsertIntegrationWorkout(ctx context.Context, data IntegrationWo
 			calories_kcal = EXCLUDED.calories_kcal,
 			distance_km = EXCLUDED.distance_km,
 			extras = EXCLUDED.extras,
+			deleted_at = NULL,
 			updated_at = now()
 	`
 
@@ -470,6 +565,7 @@ func (s *Store) UpsertIntegrationCycle(ctx context.Context, data IntegrationCycl
 			avg_hr_bpm = EXCLUDED.avg_hr_bpm,
 			max_hr_bpm = EXCLUDED.max_hr_bpm,
 			extras = EXCLUDED.extras,
+			deleted_at = NULL,
 			updated_at = now()
 	`
 
@@ -556,18 +652,100 @@ func (s *Store) MarkIntegrationDisconnected(ctx context.Context, integrationID s
 	return nil
 }
 
-// ListConnectedIntegrations returns all connected integrations for a provider.
-func (s *Store) ListConnectedIntegrations(ctx context.Context, provider string) ([]ConnectedIntegration, error) {
-	// We only schedule syncs for integrations marked as "connected".
+// ClaimDueIntegration takes the next connected integration due for a scheduled sync and moves
+// its next_sync_at one interval ahead, randomized by ±jitter (0.1 = ±10%) so integrations
+// connected together drift apart. Returns ErrNotFound when nothing is due.
+//   - SKIP LOCKED + the moved next_sync_at: replicas never claim the same integration twice
+//     in one interval
+//   - users with a session used within activeWithin go first, then the longest overdue
+//   - integrations still running their initial import are left to that job
+func (s *Store) ClaimDueIntegration(ctx context.Context, provider string, interval time.Duration, jitter float64, activeWithin time.Duration) (ConnectedIntegration, error) {
 	const query = `
-		SELECT id, user_id
-		FROM integration
-		WHERE provider = $1::"IntegrationProvider" AND status = 'connected'
+		WITH next AS (
+			SELECT i.id
+			FROM integration i
+			WHERE i.provider = $1::"IntegrationProvider"
+				AND i.status = 'connected'
+				AND (i.next_sync_at IS NULL OR i.next_sync_at <= now())
+				AND NOT EXISTS (
+					SELECT 1
+					FROM integration_sync_job j
+					WHERE j.integration_id = i.id
+						AND j.kind = 'initial'
+						AND j.status IN ('queued', 'running')
+				)
+			ORDER BY
+				EXISTS (
+					SELECT 1
+					FROM session se
+					WHERE se.user_id = i.user_id
+						AND se.updated_at > now() - make_interval(secs => $4)
+				) DESC,
+				i.next_sync_at ASC NULLS FIRST
+			LIMIT 1
+			FOR UPDATE OF i SKIP LOCKED
+		)
+		UPDATE integration i
+		SET next_sync_at = now() + make_interval(secs => $2 * (1 + $3 * (2 * random() - 1)))
+		FROM next
+		WHERE i.id = next.id
+		RETURNING i.id, i.user_id
 	`
 
-	rows, err := s.pool.Query(ctx, query, provider)
+	var row ConnectedIntegration
+	err := s.pool.QueryRow(ctx, query, provider, interval.Seconds(), jitter, activeWithin.Seconds()).Scan(&row.ID, &row.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("list integrations: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ConnectedIntegration{}, ErrNotFound
+		}
+		return ConnectedIntegration{}, fmt.Errorf("claim due integration: %w", err)
+	}
+	return row, nil
+}
+
+// RescheduleIntegrationSync sets when the scheduler next syncs the integration (retry after a
+// failure, or hand back an interrupted sync at shutdown).
+func (s *Store) RescheduleIntegrationSync(ctx context.Context, integrationID string, at time.Time) error {
+	const query = `
+		UPDATE integration
+		SET next_sync_at = $2
+		WHERE id = $1
+	`
+	if _, err := s.pool.Exec(ctx, query, integrationID, at); err != nil {
+		return fmt.Errorf("reschedule integration sync: %w", err)
+	}
+	return nil
+}
+
+// ClaimExpiringIntegrations returns connected integrations whose access token expires within
+// `within` (or already has) and that have a refresh token, and stamps last_refresh_attempt_at
+// on them. Tokens never tried come first, then the least recently tried (soonest expiry breaks
+// ties), so a batch of tokens that keep failing can't hide the rest behind it forever.
+// SKIP LOCKED lets replicas polling at once take different rows.
+func (s *Store) ClaimExpiringIntegrations(ctx context.Context, provider string, within time.Duration, limit int) ([]ConnectedIntegration, error) {
+	const query = `
+		WITH due AS (
+			SELECT t.id
+			FROM integration i
+			JOIN integration_token t ON t.integration_id = i.id
+			WHERE i.provider = $1::"IntegrationProvider"
+				AND i.status = 'connected'
+				AND t.refresh_token_encrypted IS NOT NULL
+				AND t.expires_at < now() + make_interval(secs => $2)
+			ORDER BY t.last_refresh_attempt_at NULLS FIRST, t.expires_at
+			LIMIT $3
+			FOR UPDATE OF t SKIP LOCKED
+		)
+		UPDATE integration_token t
+		SET last_refresh_attempt_at = now()
+		FROM due, integration i
+		WHERE t.id = due.id AND i.id = t.integration_id
+		RETURNING i.id, i.user_id
+	`
+
+	rows, err := s.pool.Query(ctx, query, provider, within.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim expiring integrations: %w", err)
 	}
 	defer rows.Close()
 
@@ -584,3 +762,675 @@ func (s *Store) ListConnectedIntegrations(ctx context.Context, provider string)
 	}
 	return items, nil
 }
+
+// MarkIntegrationError sets status 'error' and stores the reason in last_error (the UI shows
+// both and asks the user to reconnect). The scheduler and refresher skip the integration until
+// a new exchange marks it connected again.
+func (s *Store) MarkIntegrationError(ctx context.Context, integrationID, reason string) error {
+	const query = `
+		WITH updated AS (
+			UPDATE integration
+			SET status = 'error', updated_at = now()
+			WHERE id = $1
+			RETURNING id
+		)
+		INSERT INTO integration_connection (integration_id, last_error)
+		SELECT id, $2 FROM updated
+		ON CONFLICT (integration_id) DO UPDATE
+		SET last_error = EXCLUDED.last_error
+	`
+	if _, err := s.pool.Exec(ctx, query, integrationID, reason); err != nil {
+		return fmt.Errorf("mark integration error: %w", err)
+	}
+	return nil
+}
+
+// EnqueueSyncJob queues a job for the integration and returns its id.
+// If the same kind is already queued/running, that job's id is returned instead (one active
+// job per integration + kind, enforced by a partial unique index).
+func (s *Store) EnqueueSyncJob(ctx context.Context, integrationID, kind string) (string, error) {
+	const insert = `
+		INSERT INTO integration_sync_job (integration_id, kind)
+		VALUES ($1, $2)
+		ON CONFLICT (integration_id, kind) WHERE status IN ('queued', 'running') DO NOTHING
+		RETURNING id
+	`
+	var id string
+	err := s.pool.QueryRow(ctx, insert, integrationID, kind).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("enqueue sync job: %w", err)
+	}
+
+	// DO NOTHING returns no row: reuse the active job.
+	const existing = `
+		SELECT id
+		FROM integration_sync_job
+		WHERE integration_id = $1 AND kind = $2 AND status IN ('queued', 'running')
+		LIMIT 1
+	`
+	if err := s.pool.QueryRow(ctx, existing, integrationID, kind).Scan(&id); err != nil {
+		return "", fmt.Errorf("find active sync job: %w", err)
+	}
+	return id, nil
+}
+
+// ClaimSyncJob takes the oldest due job (queued with run_at <= now, or running with an expired
+// lease after a crash) and leases it to this worker. Returns ErrNotFound when nothing is due.
+// SKIP LOCKED lets several service instances poll the same table without double-claiming.
+func (s *Store) ClaimSyncJob(ctx context.Context, lease time.Duration) (SyncJob, error) {
+	const query = `
+		WITH next AS (
+			SELECT id
+			FROM integration_sync_job
+			WHERE (status = 'queued' AND run_at <= now())
+				OR (status = 'running' AND locked_until < now())
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE integration_sync_job j
+		SET
+			status = 'running',
+			attempts = j.attempts + 1,
+			locked_until = now() + make_interval(secs => $1),
+			started_at = COALESCE(j.started_at, now()),
+			updated_at = now()
+		FROM next, integration i
+		WHERE j.id = next.id AND i.id = j.integration_id
+		RETURNING j.id, j.integration_id, i.user_id, j.kind, j.status::text, j.attempts, j.max_attempts, j.run_at, j.started_at
+	`
+
+	var job SyncJob
+	err := s.pool.QueryRow(ctx, query, lease.Seconds()).Scan(
+		&job.ID,
+		&job.IntegrationID,
+		&job.UserID,
+		&job.Kind,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.RunAt,
+		&job.StartedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SyncJob{}, ErrNotFound
+		}
+		return SyncJob{}, fmt.Errorf("claim sync job: %w", err)
+	}
+	return job, nil
+}
+
+// UpdateSyncJobProgress records how many records of a resource the job has imported so far.
+func (s *Store) UpdateSyncJobProgress(ctx context.Context, jobID, resource string, records int) error {
+	const query = `
+		UPDATE integration_sync_job
+		SET
+			progress = progress || jsonb_build_object($2::text, $3::int),
+			current_resource = $2,
+			updated_at = now()
+		WHERE id = $1
+	`
+	if _, err := s.pool.Exec(ctx, query, jobID, resource, records); err != nil {
+		return fmt.Errorf("update sync job progress: %w", err)
+	}
+	return nil
+}
+
+// CompleteSyncJob marks a job succeeded and releases its lease.
+func (s *Store) CompleteSyncJob(ctx context.Context, jobID string) error {
+	const query = `
+		UPDATE integration_sync_job
+		SET
+			status = 'succeeded',
+			current_resource = NULL,
+			last_error = NULL,
+			locked_until = NULL,
+			finished_at = now(),
+			updated_at = now()
+		WHERE id = $1
+	`
+	if _, err := s.pool.Exec(ctx, query, jobID); err != nil {
+		return fmt.Errorf("complete sync job: %w", err)
+	}
+	return nil
+}
+
+// FailSyncJob records a failed attempt. With retryAt the job goes back to the queue for that
+// time; without it the job is failed for good.
+func (s *Store) FailSyncJob(ctx context.Context, jobID, lastError string, retryAt *time.Time) error {
+	const query = `
+		UPDATE integration_sync_job
+		SET
+			status = CASE WHEN $3::timestamptz IS NULL THEN 'failed' ELSE 'queued' END::"IntegrationSyncJobStatus",
+			run_at = COALESCE($3::timestamptz, run_at),
+			finished_at = CASE WHEN $3::timestamptz IS NULL THEN now() END,
+			last_error = $2,
+			locked_until = NULL,
+			updated_at = now()
+		WHERE id = $1
+	`
+	if _, err := s.pool.Exec(ctx, query, jobID, lastError, retryAt); err != nil {
+		return fmt.Errorf("fail sync job: %w", err)
+	}
+	return nil
+}
+
+// GetLatestSyncJob returns the most recently created job of a kind for the integration.
+func (s *Store) GetLatestSyncJob(ctx context.Context, integrationID, kind string) (SyncJob, error) {
+	const query = `
+		SELECT
+			id, integration_id, kind, status::text, attempts, max_attempts, progress,
+			current_resource, last_error, run_at, started_at, finished_at
+		FROM integration_sync_job
+		WHERE integration_id = $1 AND kind = $2
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	var job SyncJob
+	err := s.pool.QueryRow(ctx, query, integrationID, kind).Scan(
+		&job.ID,
+		&job.IntegrationID,
+		&job.Kind,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.Progress,
+		&job.CurrentResource,
+		&job.LastError,
+		&job.RunAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SyncJob{}, ErrNotFound
+		}
+		return SyncJob{}, fmt.Errorf("get latest sync job: %w", err)
+	}
+	return job, nil
+}
+
+// CreateOAuthState stores a pending OAuth state for the user, replacing earlier ones.
+func (s *Store) CreateOAuthState(ctx context.Context, userID, provider, stateHash, codeVerifierEnc string, expiresAt time.Time) error {
+	// Only the latest "Connect" attempt is valid; delete + insert in one transaction.
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin oauth state: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	const deleteQuery = `
+		DELETE FROM integration_oauth_state
+		WHERE user_id = $1 AND provider = $2::"IntegrationProvider"
+	`
+	if _, err := tx.Exec(ctx, deleteQuery, userID, provider); err != nil {
+		return fmt.Errorf("delete oauth states: %w", err)
+	}
+
+	const insertQuery = `
+		INSERT INTO integration_oauth_state (user_id, provider, state_hash, code_verifier_encrypted, expires_at)
+		VALUES ($1, $2::"IntegrationProvider", $3, $4, $5)
+	`
+	if _, err := tx.Exec(ctx, insertQuery, userID, provider, stateHash, codeVerifierEnc, expiresAt); err != nil {
+		return fmt.Errorf("insert oauth state: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit oauth state: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOAuthState deletes the user's unexpired state with this hash and returns its encrypted
+// PKCE verifier. DELETE ... RETURNING makes it single use even with concurrent callbacks:
+// only one of them gets the row. Returns ErrNotFound when nothing matches.
+func (s *Store) ConsumeOAuthState(ctx context.Context, userID, provider, stateHash string) (*string, error) {
+	const query = `
+		DELETE FROM integration_oauth_state
+		WHERE state_hash = $1
+			AND user_id = $2
+			AND provider = $3::"IntegrationProvider"
+			AND expires_at > now()
+		RETURNING code_verifier_encrypted
+	`
+
+	var verifierEnc *string
+	err := s.pool.QueryRow(ctx, query, stateHash, userID, provider).Scan(&verifierEnc)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("consume oauth state: %w", err)
+	}
+	return verifierEnc, nil
+}
+
+// GetSyncWatermark returns the end of the last complete sync window for a resource
+// (nil = never synced, so the next sync is a first sync).
+func (s *Store) GetSyncWatermark(ctx context.Context, integrationID, resourceType string) (*time.Time, error) {
+	const query = `
+		SELECT last_synced_at
+		FROM integration_sync_cursor
+		WHERE integration_id = $1 AND resource_type = $2
+	`
+
+	var syncedAt *time.Time
+	err := s.pool.QueryRow(ctx, query, integrationID, resourceType).Scan(&syncedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get sync watermark: %w", err)
+	}
+	return syncedAt, nil
+}
+
+// UpdateSyncWatermark moves a resource's watermark after its window was fetched completely.
+func (s *Store) UpdateSyncWatermark(ctx context.Context, integrationID, resourceType string, syncedAt time.Time) error {
+	// GREATEST: a slow sync finishing after a newer one must not move the watermark back.
+	const query = `
+		INSERT INTO integration_sync_cursor (integration_id, resource_type, last_synced_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (integration_id, resource_type) DO UPDATE
+		SET
+			last_synced_at = GREATEST(integration_sync_cursor.last_synced_at, EXCLUDED.last_synced_at),
+			updated_at = now()
+	`
+	if _, err := s.pool.Exec(ctx, query, integrationID, resourceType, syncedAt.UTC()); err != nil {
+		return fmt.Errorf("update sync watermark: %w", err)
+	}
+	return nil
+}
+
+// GetBackfillCursor returns a resource's backfill position (zero cursor = not started).
+func (s *Store) GetBackfillCursor(ctx context.Context, integrationID, resourceType string) (BackfillCursor, error) {
+	const query = `
+		SELECT resource_type, backfill_end_at, backfill_next_token, backfill_records, backfill_oldest_at, backfill_completed_at
+		FROM integration_sync_cursor
+		WHERE integration_id = $1 AND resource_type = $2
+	`
+
+	var cursor BackfillCursor
+	err := s.pool.QueryRow(ctx, query, integrationID, resourceType).Scan(
+		&cursor.ResourceType,
+		&cursor.EndAt,
+		&cursor.NextToken,
+		&cursor.Records,
+		&cursor.OldestAt,
+		&cursor.CompletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return BackfillCursor{ResourceType: resourceType}, nil
+		}
+		return BackfillCursor{}, fmt.Errorf("get backfill cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+// ListBackfillCursors returns the backfill position of every resource that has a cursor row.
+func (s *Store) ListBackfillCursors(ctx context.Context, integrationID string) ([]BackfillCursor, error) {
+	const query = `
+		SELECT resource_type, backfill_end_at, backfill_next_token, backfill_records, backfill_oldest_at, backfill_completed_at
+		FROM integration_sync_cursor
+		WHERE integration_id = $1
+	`
+
+	rows, err := s.pool.Query(ctx, query, integrationID)
+	if err != nil {
+		return nil, fmt.Errorf("list backfill cursors: %w", err)
+	}
+	defer rows.Close()
+
+	var cursors []BackfillCursor
+	for rows.Next() {
+		var cursor BackfillCursor
+		if err := rows.Scan(
+			&cursor.ResourceType,
+			&cursor.EndAt,
+			&cursor.NextToken,
+			&cursor.Records,
+			&cursor.OldestAt,
+			&cursor.CompletedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan backfill cursor: %w", err)
+		}
+		cursors = append(cursors, cursor)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("iterate backfill cursors: %w", rows.Err())
+	}
+	return cursors, nil
+}
+
+// SaveBackfillCursor stores a resource's backfill position (the sync watermark is left alone).
+func (s *Store) SaveBackfillCursor(ctx context.Context, integrationID string, cursor BackfillCursor) error {
+	const query = `
+		INSERT INTO integration_sync_cursor (
+			integration_id,
+			resource_type,
+			backfill_end_at,
+			backfill_next_token,
+			backfill_records,
+			backfill_oldest_at,
+			backfill_completed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (integration_id, resource_type) DO UPDATE
+		SET
+			backfill_end_at = EXCLUDED.backfill_end_at,
+			backfill_next_token = EXCLUDED.backfill_next_token,
+			backfill_records = EXCLUDED.backfill_records,
+			backfill_oldest_at = EXCLUDED.backfill_oldest_at,
+			backfill_completed_at = EXCLUDED.backfill_completed_at,
+			updated_at = now()
+	`
+	if _, err := s.pool.Exec(ctx, query,
+		integrationID,
+		cursor.ResourceType,
+		cursor.EndAt,
+		cursor.NextToken,
+		cursor.Records,
+		cursor.OldestAt,
+		cursor.CompletedAt,
+	); err != nil {
+		return fmt.Errorf("save backfill cursor: %w", err)
+	}
+	return nil
+}
+
+// GetIntegrationByProviderUserID finds the integration connected to a provider account
+// (integration_connection.provider_user_id, stored during sync). Returns ErrNotFound when unknown.
+func (s *Store) GetIntegrationByProviderUserID(ctx context.Context, provider, providerUserID string) (IntegrationRecord, error) {
+	const query = `
+		SELECT i.id, i.status::text
+		FROM integration i
+		JOIN integration_connection c ON c.integration_id = i.id
+		WHERE i.provider = $1::"IntegrationProvider" AND c.provider_user_id = $2
+		LIMIT 1
+	`
+
+	var record IntegrationRecord
+	if err := s.pool.QueryRow(ctx, query, provider, providerUserID).Scan(&record.ID, &record.Status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return IntegrationRecord{}, ErrNotFound
+		}
+		return IntegrationRecord{}, fmt.Errorf("get integration by provider user: %w", err)
+	}
+	return record, nil
+}
+
+// integrationTables maps resource types to their normalized table.
+var integrationTables = map[string]string{
+	resourceSleep:    "integration_sleep",
+	resourceRecovery: "integration_recovery",
+	resourceWorkout:  "integration_workout",
+	resourceCycle:    "integration_cycle",
+}
+
+// TombstoneIntegrationRecord marks a record deleted in WHOOP: deleted_at is set on the raw event
+// and the normalized row (both keep their data). Recovery rows are keyed by cycle_id but WHOOP
+// identifies recoveries by their sleep id, so recovery also matches on the payload's sleep_id.
+func (s *Store) TombstoneIntegrationRecord(ctx context.Context, integrationID, resourceType, sourceID string) error {
+	table, ok := integrationTables[resourceType]
+	if !ok {
+		return fmt.Errorf("tombstone: unknown resource type %q", resourceType)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tombstone: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	const rawQuery = `
+		UPDATE integration_raw_event
+		SET deleted_at = COALESCE(deleted_at, now())
+		WHERE integration_id = $1 AND resource_type = $2
+			AND (source_id = $3 OR ($2 = 'recovery' AND payload->>'sleep_id' = $3))
+	`
+	if _, err := tx.Exec(ctx, rawQuery, integrationID, resourceType, sourceID); err != nil {
+		return fmt.Errorf("tombstone raw event: %w", err)
+	}
+
+	// Table name comes from integrationTables, never from input.
+	normalizedQuery := fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = COALESCE(deleted_at, now()), updated_at = now()
+		WHERE integration_id = $1
+			AND (external_id = $2 OR ($3::text = 'recovery' AND extras->>'sleep_id' = $2))
+	`, table)
+	if _, err := tx.Exec(ctx, normalizedQuery, integrationID, sourceID, resourceType); err != nil {
+		return fmt.Errorf("tombstone %s: %w", table, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tombstone: %w", err)
+	}
+	return nil
+}
+
+// TombstoneMissingRecords tombstones the records of a resource that start strictly inside
+// (windowStart, windowEnd) but were not returned by WHOOP for that window (seenIDs), i.e. were
+// deleted upstream. Only resources with a start_at (cycle/sleep/workout) can be reconciled.
+// Returns the number of rows tombstoned.
+func (s *Store) TombstoneMissingRecords(ctx context.Context, integrationID, resourceType string, windowStart, windowEnd time.Time, seenIDs []string) (int, error) {
+	table, ok := integrationTables[resourceType]
+	if !ok || resourceType == resourceRecovery {
+		return 0, fmt.Errorf("reconcile: unsupported resource type %q", resourceType)
+	}
+	if seenIDs == nil {
+		seenIDs = []string{} // NULL would make NOT (= ANY) match nothing
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin reconcile: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Table name comes from integrationTables, never from input.
+	normalizedQuery := fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = now(), updated_at = now()
+		WHERE integration_id = $1
+			AND deleted_at IS NULL
+			AND start_at > $2 AND start_at < $3
+			AND NOT (external_id = ANY($4))
+		RETURNING external_id
+	`, table)
+	rows, err := tx.Query(ctx, normalizedQuery, integrationID, windowStart.UTC(), windowEnd.UTC(), seenIDs)
+	if err != nil {
+		return 0, fmt.Errorf("reconcile %s: %w", table, err)
+	}
+	var missing []string
+	for rows.Next() {
+		var externalID string
+		if err := rows.Scan(&externalID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan reconciled %s: %w", table, err)
+		}
+		missing = append(missing, externalID)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return 0, fmt.Errorf("iterate reconciled %s: %w", table, rows.Err())
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	const rawQuery = `
+		UPDATE integration_raw_event
+		SET deleted_at = COALESCE(deleted_at, now())
+		WHERE integration_id = $1 AND resource_type = $2 AND source_id = ANY($3)
+	`
+	if _, err := tx.Exec(ctx, rawQuery, integrationID, resourceType, missing); err != nil {
+		return 0, fmt.Errorf("reconcile raw events: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit reconcile: %w", err)
+	}
+	return len(missing), nil
+}
+
+// InsertWebhookEvent queues a webhook delivery. Returns false when an event with the same key was
+// already received (WHOOP redelivery or a replayed request), in which case nothing is queued.
+func (s *Store) InsertWebhookEvent(ctx context.Context, provider string, event WebhookEvent) (bool, error) {
+	const query = `
+		INSERT INTO integration_webhook_event (provider, event_key, provider_user_id, event_type, resource_id)
+		VALUES ($1::"IntegrationProvider", $2, $3, $4, $5)
+		ON CONFLICT (provider, event_key) DO NOTHING
+	`
+	tag, err := s.pool.Exec(ctx, query, provider, event.EventKey, event.ProviderUserID, event.Type, event.ResourceID)
+	if err != nil {
+		return false, fmt.Errorf("insert webhook event: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// TakeRateBudget takes one request from the provider's per-minute budget shared by every
+// replica. ok=false means this minute's perMinute requests are already used.
+// The window is the wall-clock minute on the DB server, so all replicas agree on it.
+func (s *Store) TakeRateBudget(ctx context.Context, provider string, perMinute int) (bool, error) {
+	const query = `
+		INSERT INTO integration_rate_budget (provider, window_start, used, updated_at)
+		VALUES ($1::"IntegrationProvider", date_trunc('minute', now()), 1, now())
+		ON CONFLICT (provider) DO UPDATE SET
+			used = CASE
+				WHEN integration_rate_budget.window_start = EXCLUDED.window_start THEN integration_rate_budget.used + 1
+				ELSE 1
+			END,
+			window_start = EXCLUDED.window_start,
+			updated_at = now()
+		WHERE integration_rate_budget.window_start <> EXCLUDED.window_start
+			OR integration_rate_budget.used < $2
+		RETURNING used
+	`
+
+	var used int
+	err := s.pool.QueryRow(ctx, query, provider, perMinute).Scan(&used)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) { // the row exists and this window is full
+			return false, nil
+		}
+		return false, fmt.Errorf("take rate budget: %w", err)
+	}
+	return true, nil
+}
+
+// ClaimWebhookEvent leases the oldest due webhook event (same rules as ClaimSyncJob).
+// Returns ErrNotFound when nothing is due.
+func (s *Store) ClaimWebhookEvent(ctx context.Context, lease time.Duration) (WebhookEvent, error) {
+	const query = `
+		WITH next AS (
+			SELECT id
+			FROM integration_webhook_event
+			WHERE (status = 'queued' AND run_at <= now())
+				OR (status = 'running' AND locked_until < now())
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE integration_webhook_event e
+		SET
+			status = 'running',
+			attempts = e.attempts + 1,
+			locked_until = now() + make_interval(secs => $1),
+			updated_at = now()
+		FROM next
+		WHERE e.id = next.id
+		RETURNING e.id, e.event_key, e.provider_user_id, e.event_type, e.resource_id, e.attempts, e.max_attempts
+	`
+
+	var event WebhookEvent
+	err := s.pool.QueryRow(ctx, query, lease.Seconds()).Scan(
+		&event.ID,
+		&event.EventKey,
+		&event.ProviderUserID,
+		&event.Type,
+		&event.ResourceID,
+		&event.Attempts,
+		&event.MaxAttempts,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return WebhookEvent{}, ErrNotFound
+		}
+		return WebhookEvent{}, fmt.Errorf("claim webhook event: %w", err)
+	}
+	return event, nil
+}
+
+// CompleteWebhookEvent marks a webhook event processed.
+func (s *Store) CompleteWebhookEvent(ctx context.Context, eventID string) error {
+	const query = `
+		UPDATE integration_webhook_event
+		SET
+			status = 'succeeded',
+			last_error = NULL,
+			locked_until = NULL,
+			processed_at = now(),
+			updated_at = now()
+		WHERE id = $1
+	`
+	if _, err := s.pool.Exec(ctx, query, eventID); err != nil {
+		return fmt.Errorf("complete webhook event: %w", err)
+	}
+	return nil
+}
+
+// FailWebhookEvent records a failed attempt (same retry semantics as FailSyncJob).
+func (s *Store) FailWebhookEvent(ctx context.Context, eventID, lastError string, retryAt *time.Time) error {
+	const query = `
+		UPDATE integration_webhook_event
+		SET
+			status = CASE WHEN $3::timestamptz IS NULL THEN 'failed' ELSE 'queued' END::"IntegrationSyncJobStatus",
+			run_at = COALESCE($3::timestamptz, run_at),
+			processed_at = CASE WHEN $3::timestamptz IS NULL THEN now() END,
+			last_error = $2,
+			locked_until = NULL,
+			updated_at = now()
+		WHERE id = $1
+	`
+	if _, err := s.pool.Exec(ctx, query, eventID, lastError, retryAt); err != nil {
+		return fmt.Errorf("fail webhook event: %w", err)
+	}
+	return nil
+}
+
+// AcquireIntegrationLock takes (or extends, for the same owner) the named lease on an
+// integration. Returns false while another owner holds an unexpired lease.
+func (s *Store) AcquireIntegrationLock(ctx context.Context, integrationID, name, owner string, lease time.Duration) (bool, error) {
+	// The conflict update only applies when the lease is ours or has expired; otherwise
+	// no row is affected.
+	const query = `
+		INSERT INTO integration_lock (integration_id, name, owner, locked_until)
+		VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+		ON CONFLICT (integration_id, name) DO UPDATE
+		SET owner = EXCLUDED.owner, locked_until = EXCLUDED.locked_until
+		WHERE integration_lock.owner = EXCLUDED.owner OR integration_lock.locked_until < now()
+	`
+	tag, err := s.pool.Exec(ctx, query, integrationID, name, owner, lease.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("acquire integration lock: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseIntegrationLock drops the lease if owner still holds it.
+func (s *Store) ReleaseIntegrationLock(ctx context.Context, integrationID, name, owner string) error {
+	const query = `
+		DELETE FROM integration_lock
+		WHERE integration_id = $1 AND name = $2 AND owner = $3
+	`
+	if _, err := s.pool.Exec(ctx, query, integrationID, name, owner); err != nil {
+		return fmt.Errorf("release integration lock: %w", err)
+	}
+	return nil
+}
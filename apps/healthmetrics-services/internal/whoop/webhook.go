@@ -0,0 +1,401 @@
+package whoop
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"healthmetrics-services/internal/retry"
+)
+
+// WHOOP signs every webhook with the app's client secret:
+// X-WHOOP-Signature = base64(HMAC-SHA256(timestamp + raw body)), timestamp in ms since epoch.
+const (
+	webhookSignatureHeader = "X-WHOOP-Signature"
+	webhookTimestampHeader = "X-WHOOP-Signature-Timestamp"
+)
+
+// webhookMaxSkew is how old (or far in the future) a signed timestamp may be. Older deliveries
+// are rejected, so a captured request can't be replayed later; within the window the event key
+// dedupes it.
+const webhookMaxSkew = 5 * time.Minute
+
+// webhookMaxBody caps the request body (WHOOP's events are ~200 bytes).
+const webhookMaxBody = 64 << 10
+
+// Webhook actions (the part after the dot in the event type).
+const (
+	webhookUpdated = "updated"
+	webhookDeleted = "deleted"
+)
+
+// webhookResources are the event type prefixes WHOOP sends, mapped to our resource types.
+var webhookResources = map[string]string{
+	"sleep":    resourceSleep,
+	"recovery": resourceRecovery,
+	"workout":  resourceWorkout,
+}
+
+// ErrInvalidSignature is returned for webhooks with a missing/wrong signature or a stale timestamp.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// webhookPayload is WHOOP's event body. id is a UUID for v2 sleep/workout events (the sleep id
+// for recovery events); user_id is numeric.
+type webhookPayload struct {
+	UserID  any    `json:"user_id"`
+	ID      any    `json:"id"`
+	Type    string `json:"type"`
+	TraceID string `json:"trace_id"`
+}
+
+func (s *Service) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// This handler:
+	// 1) verifies the signature + timestamp (the signature is the only auth on this route)
+	// 2) queues the event once per key (redeliveries/replays are acknowledged, not re-queued)
+	// 3) returns right away; WebhookWorker fetches/tombstones the record in the background
+	requestID := requestIDFromHeaders(r)
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBody))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if s.ClientSecret == "" {
+		s.logWithRequestID(requestID, "whoop webhook rejected reason=missing_client_secret")
+		http.Error(w, "webhooks not configured", http.StatusInternalServerError)
+		return
+	}
+	if err := verifyWebhookSignature(s.ClientSecret, r.Header, body, time.Now()); err != nil {
+		s.logWithRequestID(requestID, "whoop webhook rejected err=%v", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(body)))
+	decoder.UseNumber() // keep numeric ids exact
+	var payload webhookPayload
+	if err := decoder.Decode(&payload); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	event := WebhookEvent{
+		EventKey:       payload.TraceID,
+		ProviderUserID: anyToString(payload.UserID),
+		Type:           payload.Type,
+		ResourceID:     anyToString(payload.ID),
+	}
+	if event.ProviderUserID == "" || event.ResourceID == "" || event.Type == "" {
+		http.Error(w, "missing fields", http.StatusBadRequest)
+		return
+	}
+
+	// Event types we don't sync (e.g. future ones) are acknowledged so WHOOP doesn't retry them.
+	if _, _, ok := parseWebhookType(event.Type); !ok {
+		s.logWithRequestID(requestID, "whoop webhook ignored type=%s provider_user=%s", event.Type, event.ProviderUserID)
+		writeWebhookStatus(w, http.StatusOK, "ignored")
+		return
+	}
+
+	// Without a trace id, the signed timestamp makes the key unique per delivery.
+	if event.EventKey == "" {
+		event.EventKey = fmt.Sprintf("%s:%s:%s", event.Type, event.ResourceID, r.Header.Get(webhookTimestampHeader))
+	}
+
+	ctx := WithRequestID(r.Context(), requestID)
+	inserted, err := s.DB.InsertWebhookEvent(ctx, "whoop", event)
+	if err != nil {
+		// 5xx: WHOOP retries the delivery.
+		s.logWithRequestID(requestID, "whoop db error (insert webhook event) type=%s provider_user=%s err=%v", event.Type, event.ProviderUserID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !inserted {
+		s.logWithRequestID(requestID, "whoop webhook duplicate type=%s provider_user=%s key=%s", event.Type, event.ProviderUserID, event.EventKey)
+		writeWebhookStatus(w, http.StatusOK, "duplicate")
+		return
+	}
+
+	s.logWithRequestID(requestID, "whoop webhook queued type=%s provider_user=%s resource_id=%s", event.Type, event.ProviderUserID, event.ResourceID)
+	if s.Webhooks != nil {
+		s.Webhooks.Wake()
+	}
+	writeWebhookStatus(w, http.StatusAccepted, "queued")
+}
+
+func writeWebhookStatus(w http.ResponseWriter, status int, value string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": value})
+}
+
+// verifyWebhookSignature checks the HMAC signature and that the signed timestamp is recent.
+func verifyWebhookSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get(webhookTimestampHeader)
+	signature := header.Get(webhookSignatureHeader)
+	if timestamp == "" || signature == "" {
+		return fmt.Errorf("%w: missing headers", ErrInvalidSignature)
+	}
+
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp", ErrInvalidSignature)
+	}
+	skew := now.Sub(time.UnixMilli(millis))
+	if skew > webhookMaxSkew || skew < -webhookMaxSkew {
+		return fmt.Errorf("%w: timestamp outside %s", ErrInvalidSignature, webhookMaxSkew)
+	}
+
+	provided, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: bad encoding", ErrInvalidSignature)
+	}
+	if !hmac.Equal(provided, webhookSignature(secret, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// webhookSignature is the raw HMAC-SHA256 of timestamp + body.
+func webhookSignature(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// parseWebhookType splits "sleep.updated" into (resourceSleep, "updated").
+func parseWebhookType(eventType string) (string, string, bool) {
+	prefix, action, ok := strings.Cut(eventType, ".")
+	if !ok || (action != webhookUpdated && action != webhookDeleted) {
+		return "", "", false
+	}
+	resourceType, ok := webhookResources[prefix]
+	if !ok {
+		return "", "", false
+	}
+	return resourceType, action, true
+}
+
+// ProcessWebhookEvent applies one event: re-fetch + store the record on update, tombstone it on
+// delete. Both are idempotent, and an update whose record is gone (404) is a delete, so events
+// can be retried or processed out of order.
+func (s *Service) ProcessWebhookEvent(ctx context.Context, event WebhookEvent) error {
+	requestID := RequestIDFromContext(ctx)
+
+	resourceType, action, ok := parseWebhookType(event.Type)
+	if !ok {
+		return nil
+	}
+
+	integration, err := s.DB.GetIntegrationByProviderUserID(ctx, "whoop", event.ProviderUserID)
+	if errors.Is(err, ErrNotFound) {
+		// Not (or no longer) one of our users, or connected before the profile sync stored the id.
+		s.logWithRequestID(requestID, "whoop webhook skipped reason=unknown_user provider_user=%s type=%s", event.ProviderUserID, event.Type)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if integration.Status != "connected" {
+		s.logWithRequestID(requestID, "whoop webhook skipped reason=not_connected integration=%s type=%s", integration.ID, event.Type)
+		return nil
+	}
+
+	if action == webhookDeleted {
+		return s.tombstoneWhoopRecord(ctx, integration.ID, resourceType, event.ResourceID)
+	}
+
+	accessToken, err := s.accessToken(ctx, integration.ID)
+	if err != nil {
+		return err
+	}
+
+	record, err := s.fetchWebhookRecord(ctx, accessToken, resourceType, event.ResourceID)
+	if isWhoopNotFound(err) {
+		// Deleted after the update was sent.
+		return s.tombstoneWhoopRecord(ctx, integration.ID, resourceType, event.ResourceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.storeCollectionPage(ctx, s.DB, integration.ID, resourceType, []map[string]any{record}); err != nil {
+		return err
+	}
+	if resourceType == resourceSleep {
+		if err := s.DB.UpdatePrimarySleep(ctx, integration.ID); err != nil {
+			return err
+		}
+	}
+	s.logWithRequestID(requestID, "whoop webhook applied integration=%s type=%s resource_id=%s", integration.ID, event.Type, event.ResourceID)
+	return nil
+}
+
+// fetchWebhookRecord loads the single record an event refers to. Recovery events carry the sleep
+// id, so the sleep is fetched first for its cycle_id.
+func (s *Service) fetchWebhookRecord(ctx context.Context, accessToken, resourceType, resourceID string) (map[string]any, error) {
+	switch resourceType {
+	case resourceSleep:
+		return s.fetchWhoopObject(ctx, accessToken, "/v2/activity/sleep/"+url.PathEscape(resourceID))
+	case resourceWorkout:
+		return s.fetchWhoopObject(ctx, accessToken, "/v2/activity/workout/"+url.PathEscape(resourceID))
+	case resourceRecovery:
+		sleep, err := s.fetchWhoopObject(ctx, accessToken, "/v2/activity/sleep/"+url.PathEscape(resourceID))
+		if err != nil {
+			return nil, err
+		}
+		cycleID := anyToString(sleep["cycle_id"])
+		if cycleID == "" {
+			return nil, fmt.Errorf("sleep %s has no cycle_id", resourceID)
+		}
+		return s.fetchWhoopObject(ctx, accessToken, "/v2/cycle/"+url.PathEscape(cycleID)+"/recovery")
+	default:
+		return nil, fmt.Errorf("unsupported webhook resource %q", resourceType)
+	}
+}
+
+// tombstoneWhoopRecord soft-deletes a record deleted upstream and re-picks the primary sleep if needed.
+func (s *Service) tombstoneWhoopRecord(ctx context.Context, integrationID, resourceType, resourceID string) error {
+	if err := s.DB.TombstoneIntegrationRecord(ctx, integrationID, resourceType, resourceID); err != nil {
+		return err
+	}
+	if resourceType == resourceSleep {
+		if err := s.DB.UpdatePrimarySleep(ctx, integrationID); err != nil {
+			return err
+		}
+	}
+	s.logWithRequestID(RequestIDFromContext(ctx), "whoop webhook tombstoned integration=%s resource=%s resource_id=%s", integrationID, resourceType, resourceID)
+	return nil
+}
+
+// isWhoopNotFound reports whether err is a 404 from the WHOOP API.
+func isWhoopNotFound(err error) bool {
+	var httpErr *retry.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+}
+
+// WebhookWorker processes queued integration_webhook_event rows. It runs separately from the
+// SyncJobWorker so a long backfill doesn't delay webhook updates.
+type WebhookWorker struct {
+	Service *Service
+
+	// PollInterval is the fallback poll for due retries and events received by other instances.
+	PollInterval time.Duration
+
+	// EventTimeout bounds a single attempt; Lease must outlast it.
+	EventTimeout time.Duration
+	Lease        time.Duration
+
+	wake chan struct{}
+}
+
+// NewWebhookWorker returns a worker with default timings.
+func NewWebhookWorker(service *Service) *WebhookWorker {
+	return &WebhookWorker{
+		Service:      service,
+		PollInterval: 30 * time.Second,
+		EventTimeout: 2 * time.Minute,
+		Lease:        5 * time.Minute,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Wake nudges the worker loop; it never blocks.
+func (w *WebhookWorker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes due events until ctx is cancelled.
+func (w *WebhookWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.RunDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue claims and processes events until none are due; returns how many ran.
+func (w *WebhookWorker) RunDue(ctx context.Context) int {
+	ran := 0
+	for ctx.Err() == nil {
+		event, err := w.Service.DB.ClaimWebhookEvent(ctx, w.Lease)
+		if errors.Is(err, ErrNotFound) {
+			return ran
+		}
+		if err != nil {
+			w.Service.logWithRequestID("", "whoop webhook claim error err=%v", err)
+			return ran
+		}
+		w.runEvent(ctx, event)
+		ran++
+	}
+	return ran
+}
+
+// runEvent runs one attempt of a claimed event and records the outcome (retries use the sync job backoff).
+func (w *WebhookWorker) runEvent(ctx context.Context, event WebhookEvent) {
+	s := w.Service
+	requestID := "webhook_" + event.ID
+
+	var err error
+	if event.Attempts > event.MaxAttempts {
+		err = errors.New("max attempts exceeded")
+	} else {
+		runCtx, cancel := context.WithTimeout(ctx, w.EventTimeout)
+		err = s.ProcessWebhookEvent(WithRequestID(runCtx, requestID), event)
+		cancel()
+	}
+
+	finishCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err == nil {
+		if dbErr := s.DB.CompleteWebhookEvent(finishCtx, event.ID); dbErr != nil {
+			s.logWithRequestID(requestID, "whoop webhook complete error err=%v", dbErr)
+		}
+		return
+	}
+
+	msg := err.Error()
+	if len(msg) > 500 {
+		msg = msg[:500]
+	}
+	var retryAt *time.Time
+	if !errors.Is(err, ErrMissingToken) && !errors.Is(err, ErrTokenRevoked) && event.Attempts < event.MaxAttempts {
+		next := time.Now().Add(syncJobBackoff(event.Attempts))
+		retryAt = &next
+	}
+	if dbErr := s.DB.FailWebhookEvent(finishCtx, event.ID, msg, retryAt); dbErr != nil {
+		s.logWithRequestID(requestID, "whoop webhook fail error err=%v", dbErr)
+		return
+	}
+	if retryAt != nil {
+		s.logWithRequestID(requestID, "whoop webhook retry type=%s attempt=%d retry_at=%s err=%v",
+			event.Type, event.Attempts, retryAt.UTC().Format(time.RFC3339), err)
+		return
+	}
+	s.logWithRequestID(requestID, "whoop webhook failed type=%s attempt=%d err=%v", event.Type, event.Attempts, err)
+}
@@ -0,0 +1,182 @@
+package whoop
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "client_secret"
+
+// signedWebhook builds a WHOOP webhook request signed at the given time.
+func signedWebhook(body string, signedAt time.Time, secret string) *http.Request {
+	timestamp := strconv.FormatInt(signedAt.UnixMilli(), 10)
+	req := httptest.NewRequest("POST", "/webhooks/whoop", strings.NewReader(body))
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, base64.StdEncoding.EncodeToString(webhookSignature(secret, timestamp, []byte(body))))
+	return req
+}
+
+func TestWebhookHandler_VerifiesAndQueues(t *testing.T) {
+	body := `{"user_id":10129,"id":"sleep-uuid-1","type":"sleep.updated","trace_id":"trace_1"}`
+
+	cases := []struct {
+		name    string
+		request func() *http.Request
+	}{
+		{name: "wrong secret", request: func() *http.Request { return signedWebhook(body, time.Now(), "other_secret") }},
+		{name: "stale timestamp", request: func() *http.Request { return signedWebhook(body, time.Now().Add(-10*time.Minute), testWebhookSecret) }},
+		{name: "tampered body", request: func() *http.Request {
+			req := signedWebhook(body, time.Now(), testWebhookSecret)
+			req.Body = io.NopCloser(strings.NewReader(strings.Replace(body, "10129", "10130", 1)))
+			return req
+		}},
+		{name: "unsigned", request: func() *http.Request {
+			return httptest.NewRequest("POST", "/webhooks/whoop", strings.NewReader(body))
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &fakeDB{}
+			service := &Service{DB: db, ClientSecret: testWebhookSecret}
+			rec := httptest.NewRecorder()
+			service.WebhookHandler(rec, tc.request())
+			if rec.Code != http.StatusUnauthorized || len(db.webhookEvents) != 0 {
+				t.Fatalf("expected 401 and nothing queued, got %d / %v", rec.Code, db.webhookEvents)
+			}
+		})
+	}
+
+	db := &fakeDB{}
+	waker := &countingWaker{}
+	service := &Service{DB: db, ClientSecret: testWebhookSecret, Webhooks: waker}
+
+	rec := httptest.NewRecorder()
+	service.WebhookHandler(rec, signedWebhook(body, time.Now(), testWebhookSecret))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(db.webhookEvents) != 1 || waker.wakes != 1 {
+		t.Fatalf("expected one queued event and a wake, got %v / %d", db.webhookEvents, waker.wakes)
+	}
+	event := db.webhookEvents[0]
+	if event.ProviderUserID != "10129" || event.ResourceID != "sleep-uuid-1" || event.Type != "sleep.updated" || event.EventKey != "trace_1" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	// Redelivery (new signature, same trace id) is acknowledged but not queued again.
+	rec = httptest.NewRecorder()
+	service.WebhookHandler(rec, signedWebhook(body, time.Now().Add(time.Second), testWebhookSecret))
+	if rec.Code != http.StatusOK || len(db.webhookEvents) != 1 {
+		t.Fatalf("expected duplicate to be acknowledged once, got %d / %d events", rec.Code, len(db.webhookEvents))
+	}
+}
+
+// webhookTransport serves single WHOOP records; missing ids return 404.
+type webhookTransport struct {
+	records map[string]string
+	paths   []string
+}
+
+func (w *webhookTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := strings.TrimPrefix(req.URL.Path, "/developer")
+	w.paths = append(w.paths, path)
+	if body, ok := w.records[path]; ok {
+		return jsonResponse(200, body), nil
+	}
+	return jsonResponse(404, `{"error":"not found"}`), nil
+}
+
+func newWebhookService(t *testing.T, transport http.RoundTripper) (*Service, *fakeDB) {
+	t.Helper()
+	setEncryptionKey(t)
+	accessEnc, err := Encrypt("access_token")
+	if err != nil {
+		t.Fatalf("encrypt access token: %v", err)
+	}
+	db := &fakeDB{
+		hasToken:    true,
+		tokenRecord: IntegrationTokenRecord{AccessTokenEncrypted: accessEnc, ExpiresAt: ptrTime(time.Now().Add(time.Hour))},
+		providerIntegrations: map[string]IntegrationRecord{
+			"10129": {ID: "integration_1", Status: "connected"},
+		},
+	}
+	return &Service{DB: db, HTTPClient: &http.Client{Transport: transport}}, db
+}
+
+func TestProcessWebhookEvent(t *testing.T) {
+	transport := &webhookTransport{records: map[string]string{
+		"/v2/activity/sleep/sleep-1": `{"id":"sleep-1","cycle_id":93845,"start":"2026-10-17T22:00:00.000Z","end":"2026-10-18T06:00:00.000Z"}`,
+		"/v2/cycle/93845/recovery":   `{"cycle_id":93845,"sleep_id":"sleep-1","created_at":"2026-10-18T06:10:00.000Z","score":{"recovery_score":71}}`,
+	}}
+	service, db := newWebhookService(t, transport)
+	ctx := context.Background()
+
+	// Recovery: fetched through the sleep's cycle and stored under the cycle id.
+	if err := service.ProcessWebhookEvent(ctx, WebhookEvent{ProviderUserID: "10129", Type: "recovery.updated", ResourceID: "sleep-1"}); err != nil {
+		t.Fatalf("recovery.updated: %v", err)
+	}
+	if len(db.rawEvents) != 1 || db.rawEvents[0] != (rawEventCall{resourceType: resourceRecovery, sourceID: "93845"}) {
+		t.Fatalf("expected the recovery to be stored, got %v", db.rawEvents)
+	}
+
+	// Sleep update: stored, then primary sleep recomputed.
+	if err := service.ProcessWebhookEvent(ctx, WebhookEvent{ProviderUserID: "10129", Type: "sleep.updated", ResourceID: "sleep-1"}); err != nil {
+		t.Fatalf("sleep.updated: %v", err)
+	}
+	if len(db.rawEvents) != 2 || db.rawEvents[1].sourceID != "sleep-1" || db.primarySleepUpdates != 1 {
+		t.Fatalf("expected the sleep to be stored, got %v (primary updates %d)", db.rawEvents, db.primarySleepUpdates)
+	}
+
+	// Delete: tombstoned without calling WHOOP.
+	calls := len(transport.paths)
+	if err := service.ProcessWebhookEvent(ctx, WebhookEvent{ProviderUserID: "10129", Type: "workout.deleted", ResourceID: "workout-1"}); err != nil {
+		t.Fatalf("workout.deleted: %v", err)
+	}
+	if len(transport.paths) != calls || len(db.tombstones) != 1 || db.tombstones[0] != (rawEventCall{resourceType: resourceWorkout, sourceID: "workout-1"}) {
+		t.Fatalf("expected a tombstone and no fetch, got %v", db.tombstones)
+	}
+
+	// Update for a record deleted since (404): tombstoned as well.
+	if err := service.ProcessWebhookEvent(ctx, WebhookEvent{ProviderUserID: "10129", Type: "workout.updated", ResourceID: "workout-2"}); err != nil {
+		t.Fatalf("workout.updated (gone): %v", err)
+	}
+	if len(db.tombstones) != 2 || db.tombstones[1].sourceID != "workout-2" {
+		t.Fatalf("expected the missing workout to be tombstoned, got %v", db.tombstones)
+	}
+
+	// Unknown WHOOP user: nothing to do.
+	if err := service.ProcessWebhookEvent(ctx, WebhookEvent{ProviderUserID: "999", Type: "sleep.deleted", ResourceID: "sleep-9"}); err != nil {
+		t.Fatalf("unknown user: %v", err)
+	}
+	if len(db.tombstones) != 2 {
+		t.Fatalf("unknown user should be skipped, got %v", db.tombstones)
+	}
+}
+
+func TestWebhookWorker_RetriesFailures(t *testing.T) {
+	unavailable := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(503, `{"error":"unavailable"}`), nil
+	})
+	service, db := newWebhookService(t, unavailable)
+	db.claimableEvents = []WebhookEvent{
+		{ID: "event_1", ProviderUserID: "10129", Type: "sleep.updated", ResourceID: "sleep-1", Attempts: 1, MaxAttempts: 5},
+		{ID: "event_2", ProviderUserID: "10129", Type: "sleep.deleted", ResourceID: "sleep-2", Attempts: 1, MaxAttempts: 5},
+	}
+
+	if ran := NewWebhookWorker(service).RunDue(context.Background()); ran != 2 {
+		t.Fatalf("expected 2 events to run, got %d", ran)
+	}
+	if len(db.failedEvents) != 1 || db.failedEvents[0].jobID != "event_1" || db.failedEvents[0].retryAt == nil {
+		t.Fatalf("expected event_1 to be retried, got %+v", db.failedEvents)
+	}
+	if len(db.completedEvents) != 1 || db.completedEvents[0] != "event_2" {
+		t.Fatalf("expected event_2 to complete, got %v", db.completedEvents)
+	}
+}
@@ -4,7 +4,14 @@ import (
 	"context"
 	"healthmetrics-services/internal/auth"
 	"healthmetrics-services/internal/barcode"
+	"healthmetrics-services/internal/contribution"
 	"healthmetrics-services/internal/db"
+	"healthmetrics-services/internal/dedupe"
+	"healthmetrics-services/internal/diary"
+	"healthmetrics-services/internal/label"
+	"healthmetrics-services/internal/recall"
+	"healthmetrics-services/internal/recipe"
+	"healthmetrics-services/internal/retry"
 	"healthmetrics-services/ratelimiter"
 	"log"
 	"net/http"
@@ -16,11 +23,12 @@ import (
 	"syscall"
 	"time"
 
+	"healthmetrics-services/internal/wellness"
 	"healthmetrics-services/internal/whoop"
 
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
-	"github.com/openfoodfacts/openfoodfacts-go"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type limiterEntry struct {
@@ -74,10 +82,15 @@ func getDBConfig() db.Config {
 	}
 }
 
+// whoopWebhookPath is called by WHOOP directly: no API key, session or X-Request-ID.
+// The handler verifies WHOOP's signature instead.
+const whoopWebhookPath = "/webhooks/whoop"
+
 func getAuthConfig() auth.Config {
 	return auth.Config{
-		APIKey:     os.Getenv("BARCODE_SERVICE_API_KEY"),
-		CookieName: os.Getenv("BETTER_AUTH_COOKIE_NAME"),
+		APIKey:      os.Getenv("BARCODE_SERVICE_API_KEY"),
+		CookieName:  os.Getenv("BETTER_AUTH_COOKIE_NAME"),
+		PublicPaths: []string{whoopWebhookPath},
 	}
 }
 
@@ -133,6 +146,7 @@ func getOpenFoodFactsConfig() (time.Duration, string, barcode.RetryConfig, strin
 		MaxAttempts: 3,                      // total attempts including the first call
 		BaseDelay:   200 * time.Millisecond, // starting backoff delay
 		MaxDelay:    2 * time.Second,        // cap for exponential backoff
+		Budget:      8 * time.Second,        // total time across attempts + waits
 	}
 
 	if value := os.Getenv("OPENFOODFACTS_RETRY_MAX_ATTEMPTS"); value != "" { // max attempts override
@@ -153,9 +167,185 @@ func getOpenFoodFactsConfig() (time.Duration, string, barcode.RetryConfig, strin
 		}
 	}
 
+	if value := os.Getenv("OPENFOODFACTS_RETRY_BUDGET"); value != "" { // total budget override
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			retryCfg.Budget = parsed
+		}
+	}
+
 	return timeout, userAgent, retryCfg, baseURL // pass settings back to main
 }
 
+func getBreakerConfig() barcode.BreakerConfig {
+	// Defaults: open after 5 consecutive upstream failures, probe again after 30s.
+	cfg := barcode.BreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+
+	if value := os.Getenv("OPENFOODFACTS_BREAKER_FAILURE_THRESHOLD"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			cfg.FailureThreshold = parsed
+		}
+	}
+
+	if value := os.Getenv("OPENFOODFACTS_BREAKER_OPEN_TIMEOUT"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			cfg.OpenTimeout = parsed
+		}
+	}
+
+	return cfg
+}
+
+func getHealthScoreWeights() barcode.HealthWeights {
+	// Defaults favor Nutri-Score; override with e.g. HEALTH_SCORE_WEIGHTS="nutriscore=0.5,sugar=0.3,sodium=0.2,nova=0".
+	value := os.Getenv("HEALTH_SCORE_WEIGHTS")
+	weights, err := barcode.ParseHealthWeights(value)
+	if err != nil {
+		log.Printf("startup_config_error key=HEALTH_SCORE_WEIGHTS value=%q err=%v (using defaults)", value, err)
+		return barcode.DefaultHealthWeights
+	}
+	return weights
+}
+
+// recallFeedConfig is the optional recall ingester setup (Source nil = disabled).
+type recallFeedConfig struct {
+	Source   recall.Source
+	Interval time.Duration
+	Lookback time.Duration
+}
+
+func getRecallFeedConfig() recallFeedConfig {
+	// Defaults: poll every 6h, alert on scans/logs up to 90 days before a recall was published.
+	cfg := recallFeedConfig{
+		Interval: 6 * time.Hour,
+		Lookback: recall.DefaultLookback,
+	}
+
+	// RECALL_FEED_URL wins over RECALL_FEED_FILE; neither set = no recall alerts.
+	if url := os.Getenv("RECALL_FEED_URL"); url != "" {
+		cfg.Source = recall.HTTPSource{Feed: os.Getenv("RECALL_FEED_NAME"), URL: url, Client: &http.Client{Timeout: 30 * time.Second}}
+	} else if path := os.Getenv("RECALL_FEED_FILE"); path != "" {
+		cfg.Source = recall.FileSource{Path: path}
+	}
+
+	if value := os.Getenv("RECALL_FEED_INTERVAL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			cfg.Interval = parsed
+		}
+	}
+
+	if value := os.Getenv("RECALL_LOOKBACK_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			cfg.Lookback = time.Duration(parsed) * 24 * time.Hour
+		}
+	}
+
+	return cfg
+}
+
+// contributionWriteConfig is the OpenFoodFacts write-back setup (Writer nil = disabled;
+// contributions are still collected, reviewed and cached, just not pushed to OFF).
+type contributionWriteConfig struct {
+	Writer      *contribution.OFFWriter
+	Interval    time.Duration
+	MaxAttempts int
+}
+
+func getContributionWriteConfig(userAgent string) contributionWriteConfig {
+	// Defaults: look for due write-backs every 15 minutes, give up after 8 attempts (~1.5 days).
+	cfg := contributionWriteConfig{
+		Interval:    15 * time.Minute,
+		MaxAttempts: contribution.DefaultMaxAttempts,
+	}
+
+	// App account credentials; both are required to write.
+	user := os.Getenv("OPENFOODFACTS_WRITE_USER")
+	password := os.Getenv("OPENFOODFACTS_WRITE_PASSWORD")
+	if user != "" && password != "" {
+		baseURL := os.Getenv("OPENFOODFACTS_WRITE_URL") // e.g. https://world.openfoodfacts.net for the sandbox
+		if baseURL == "" {
+			baseURL = contribution.DefaultOFFWriteURL
+		}
+		cfg.Writer = &contribution.OFFWriter{
+			BaseURL:    baseURL,
+			UserID:     user,
+			Password:   password,
+			UserAgent:  userAgent,
+			HTTPClient: &http.Client{Timeout: 30 * time.Second}, // photo uploads are slower than reads
+			Sandbox:    strings.Contains(baseURL, "openfoodfacts.net"),
+		}
+	}
+
+	if value := os.Getenv("CONTRIBUTION_SUBMIT_INTERVAL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			cfg.Interval = parsed
+		}
+	}
+
+	if value := os.Getenv("CONTRIBUTION_SUBMIT_MAX_ATTEMPTS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			cfg.MaxAttempts = parsed
+		}
+	}
+
+	return cfg
+}
+
+func getFoodDedupeInterval() time.Duration {
+	// Default: look for duplicate food items once a day; "off" disables the detector.
+	value := os.Getenv("FOOD_DEDUPE_INTERVAL")
+	if value == "off" {
+		return 0
+	}
+	if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+		return parsed
+	}
+	return 24 * time.Hour
+}
+
+func getWhoopBackfillPageDelay() time.Duration {
+	// Default: one backfill request per second (~60/min, under WHOOP's per-app limit).
+	if parsed, err := time.ParseDuration(os.Getenv("WHOOP_BACKFILL_PAGE_DELAY")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return time.Second
+}
+
+func getWhoopRateLimit() (perSecond, burst, reserve int) {
+	// Defaults: 1 request/s with bursts of 10 (at most 70 in any minute, under WHOOP's default
+	// 100/min per app), and backfills yield once 10 requests are left in the window.
+	perSecond, burst, reserve = 1, 10, 10
+	if parsed, err := strconv.Atoi(os.Getenv("WHOOP_RATE_LIMIT_PER_SECOND")); err == nil && parsed > 0 {
+		perSecond = parsed
+	}
+	if parsed, err := strconv.Atoi(os.Getenv("WHOOP_RATE_LIMIT_BURST")); err == nil && parsed > 0 {
+		burst = parsed
+	}
+	if parsed, err := strconv.Atoi(os.Getenv("WHOOP_RATE_LIMIT_RESERVE")); err == nil && parsed >= 0 {
+		reserve = parsed
+	}
+	return perSecond, burst, reserve
+}
+
+func getWhoopSharedRateLimit() int {
+	// Default: 90 requests per minute across every replica, under WHOOP's 100/min per app.
+	// 0 turns the shared budget off (single replica: the local bucket is enough).
+	if parsed, err := strconv.Atoi(os.Getenv("WHOOP_RATE_LIMIT_PER_MINUTE")); err == nil && parsed >= 0 {
+		return parsed
+	}
+	return 90
+}
+
+func getWhoopSyncOverlap() time.Duration {
+	// Default: re-fetch the last 48h of each resource on every sync (WHOOP rescores sleep/recovery).
+	if parsed, err := time.ParseDuration(os.Getenv("WHOOP_SYNC_OVERLAP")); err == nil && parsed > 0 {
+		return parsed
+	}
+	return 48 * time.Hour
+}
+
 func validateStartupConfig(authCfg auth.Config) {
 	// These env vars are required for auth middleware to function.
 	required := map[string]string{
@@ -186,8 +376,20 @@ func logStartupSummary(authCfg auth.Config, capacity, refillRate float64, timeou
 	log.Printf("startup_config rate_limit_capacity=%.2f rate_limit_refill_rate=%.2f",
 		capacity, refillRate)
 	// Log OpenFoodFacts config to confirm timeouts and retry policy.
-	log.Printf("startup_config off_timeout=%s off_user_agent_set=%t off_retry_attempts=%d off_retry_base=%s off_retry_max=%s off_base_url=%s",
-		timeout, userAgent != "", retryCfg.MaxAttempts, retryCfg.BaseDelay, retryCfg.MaxDelay, baseURL)
+	log.Printf("startup_config off_timeout=%s off_user_agent_set=%t off_retry_attempts=%d off_retry_base=%s off_retry_max=%s off_retry_budget=%s off_base_url=%s",
+		timeout, userAgent != "", retryCfg.MaxAttempts, retryCfg.BaseDelay, retryCfg.MaxDelay, retryCfg.Budget, baseURL)
+}
+
+// allowMetricsAccess restricts metrics to internal callers that know the service API key.
+func allowMetricsAccess(c *gin.Context, authCfg auth.Config) bool {
+	if authCfg.APIKey == "" || c.GetHeader("X-API-Key") != authCfg.APIKey {
+		c.JSON(403, gin.H{"error": map[string]interface{}{
+			"code":    "FORBIDDEN",
+			"message": "Metrics access denied",
+		}})
+		return false
+	}
+	return true
 }
 
 func (s *limiterStore) Cleanup(ttl time.Duration) {
@@ -230,44 +432,90 @@ func main() {
 
 	whoopStore := whoop.NewStore(pool)
 	whoopMetrics := whoop.NewMetrics()
+	// One limiter for every WHOOP read (scheduled syncs, backfills, webhooks): the quota is per app.
+	whoopPerSecond, whoopBurst, whoopReserve := getWhoopRateLimit()
+	whoopLimiter := whoop.NewRateLimiter(whoopPerSecond, whoopBurst, whoopReserve, whoopMetrics)
+	// The per-second bucket is per process; the per-minute budget in Postgres caps all replicas together.
+	whoopLimiter.Shared = whoopStore
+	whoopLimiter.SharedPerMinute = getWhoopSharedRateLimit()
 	whoopService := &whoop.Service{
-		DB:           whoopStore,
-		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
-		Logger:       log.New(os.Stdout, "", log.LstdFlags),
-		Metrics:      whoopMetrics,
-		ClientID:     os.Getenv("WHOOP_CLIENT_ID"),
-		ClientSecret: os.Getenv("WHOOP_CLIENT_SECRET"),
-		TokenURL:     os.Getenv("WHOOP_TOKEN_URL"),
-		RedirectURL:  os.Getenv("WHOOP_REDIRECT_URL"),
+		DB:                whoopStore,
+		HTTPClient:        &http.Client{Timeout: 10 * time.Second},
+		Logger:            log.New(os.Stdout, "", log.LstdFlags),
+		Metrics:           whoopMetrics,
+		RateLimiter:       whoopLimiter,
+		ClientID:          os.Getenv("WHOOP_CLIENT_ID"),
+		ClientSecret:      os.Getenv("WHOOP_CLIENT_SECRET"),
+		TokenURL:          os.Getenv("WHOOP_TOKEN_URL"),
+		RedirectURL:       os.Getenv("WHOOP_REDIRECT_URL"),
+		AuthURL:           os.Getenv("WHOOP_AUTH_URL"), // optional; defaults to WHOOP's production endpoint
+		SyncOverlap:       getWhoopSyncOverlap(),
+		BackfillPageDelay: getWhoopBackfillPageDelay(),
+		// Background reads can afford longer waits than a barcode scan.
+		Retry: retry.Policy{
+			MaxAttempts: 4,
+			BaseDelay:   500 * time.Millisecond,
+			MaxDelay:    10 * time.Second,
+			Budget:      2 * time.Minute,
+		},
 	}
 	log.Printf("startup_config whoop_key_set=%t", os.Getenv("WHOOP_TOKEN_ENCRYPTION_KEY") != "")
 
-	// Scheduled WHOOP sync (2x/day). Manual sync still available via API.
-	// This reuses the same SyncIntegration flow as the manual "Sync now" button.
-	go func() { // goroutine: runs this scheduler loop in the background without blocking the HTTP server
-		ticker := time.NewTicker(12 * time.Hour) // run twice per day
-		defer ticker.Stop()
-
-		for range ticker.C {
-			// Give the batch a reasonable window to finish before timing out.
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
-
-			integrations, err := whoopStore.ListConnectedIntegrations(ctx, "whoop")
-			if err != nil {
-				log.Printf("whoop scheduler list error: %v", err)
-				cancel()
-				continue
-			}
-
-			for _, integration := range integrations {
-				if err := whoopService.SyncIntegration(ctx, integration.UserID, integration.ID); err != nil {
-					log.Printf("whoop scheduler sync error user=%s integration=%s err=%v", integration.UserID, integration.ID, err)
-				}
-			}
+	// Durable WHOOP sync jobs (initial history import after connecting). The exchange handler
+	// wakes the worker; the poll picks up retries and jobs left over from a restart.
+	// Every background worker below runs on workerCtx and is waited for on SIGTERM, so none of
+	// them is still using the pool when it closes.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
 
-			cancel()
-		}
-	}()
+	whoopJobs := whoop.NewSyncJobWorker(whoopService)
+	whoopService.Jobs = whoopJobs
+	runWorker(whoopJobs.Run)
+
+	// WHOOP webhook events (queued by the webhook handler) are processed in the background.
+	whoopWebhooks := whoop.NewWebhookWorker(whoopService)
+	whoopService.Webhooks = whoopWebhooks
+	runWorker(whoopWebhooks.Run)
+
+	// Scheduled WHOOP sync: each connected integration every 12h (jittered), claimed through
+	// integration.next_sync_at so every replica can run the scheduler without syncing anything
+	// twice. Manual sync is still available via API. Stopped on SIGTERM (below).
+	whoopScheduler := whoop.NewSyncScheduler(whoopService)
+	if workers, err := strconv.Atoi(os.Getenv("WHOOP_SYNC_WORKERS")); err == nil && workers > 0 {
+		whoopScheduler.Workers = workers
+	}
+	runWorker(whoopScheduler.Run)
+
+	// Renew WHOOP tokens ahead of expiry so webhook fetches find a valid token and unused
+	// refresh tokens don't lapse; revoked ones flag the integration for a reconnect.
+	runWorker(whoop.NewTokenRefresher(whoopService).Run)
+
+	// Recall feed ingester: store recalls, alert users who scanned/logged an affected product.
+	recallCfg := getRecallFeedConfig()
+	if recallCfg.Source == nil {
+		log.Printf("startup_config recall_feed=disabled")
+	} else {
+		log.Printf("startup_config recall_feed=%s interval=%s lookback=%s", recallCfg.Source.Name(), recallCfg.Interval, recallCfg.Lookback)
+		ingester := recall.Ingester{Pool: pool, Source: recallCfg.Source, Lookback: recallCfg.Lookback}
+		// Runs at startup too, so a fresh deploy doesn't wait a full interval for alerts.
+		runWorker(func(ctx context.Context) {
+			runEvery(ctx, pool, "recall_ingest", recallCfg.Interval, 10*time.Minute, func(ctx context.Context) {
+				result, err := ingester.Run(ctx)
+				if err != nil {
+					log.Printf("recall_ingest_error source=%s err=%v", recallCfg.Source.Name(), err)
+				}
+				log.Printf("recall_ingest source=%s recalls=%d invalid=%d alerts=%d new_alerts=%d",
+					recallCfg.Source.Name(), result.Recalls, result.Invalid, result.Alerts, result.NewAlerts)
+			})
+		})
+	}
 
 	capacity, refillRate := getRateLimitConfig() // read rate-limit settings (or defaults)
 	cacheTTL := getCacheTTL()                    // read cache TTL (days -> duration)
@@ -278,8 +526,8 @@ func main() {
 	})
 	router.Use(requestid.New())
 	router.Use(func(c *gin.Context) {
-		// Skip the request ID requirement for health checks.
-		if c.Request.URL.Path == "/healthz" {
+		// Skip the request ID requirement for health checks and provider webhooks.
+		if c.Request.URL.Path == "/healthz" || c.Request.URL.Path == whoopWebhookPath {
 			c.Next()
 			return
 		}
@@ -366,27 +614,132 @@ func main() {
 		// Request is allowed, continue to the handler.
 		c.Next()
 	})
-	api := openfoodfacts.NewClient("world", "", "")
 	timeout, userAgent, retryCfg, baseURL := getOpenFoodFactsConfig()
-	api.Timeout(timeout)
-	if userAgent != "" {
-		api.UserAgent(userAgent)
-	}
-	if baseURL != "" && strings.Contains(baseURL, "openfoodfacts.net") {
-		// The library only supports live vs sandbox; net maps to sandbox.
-		api.Sandbox()
-	}
+	// Locale-aware OFF client: queries <country>.openfoodfacts.org with the user's language.
+	// A ".net" base URL still maps to the sandbox.
+	offClient := barcode.NewOFFClient(timeout, userAgent, baseURL)
+	// Circuit breaker: stop calling OFF after repeated failures and serve cached rows (degraded) instead.
+	breakerCfg := getBreakerConfig()
+	offBreaker := barcode.NewCircuitBreaker(breakerCfg)
+	api := barcode.WithCircuitBreaker(offClient, offBreaker)
+	log.Printf("startup_config off_breaker_threshold=%d off_breaker_open_timeout=%s",
+		breakerCfg.FailureThreshold, breakerCfg.OpenTimeout)
 
 	router.GET("/healthz", func(c *gin.Context) {
+		// Liveness stays "ok" while OFF is down (we can still serve cached rows);
+		// the dependency block tells operators whether we're in degraded mode.
 		c.JSON(200, gin.H{
 			"status":    "ok",
 			"message":   "Health check successful",
 			"timestamp": time.Now().Format(time.RFC3339),
+			"dependencies": gin.H{
+				"openfoodfacts": gin.H{"circuit": offBreaker.State()},
+			},
 		})
 	})
 
+	// OpenFoodFacts write-back for approved product contributions.
+	contributionCfg := getContributionWriteConfig(userAgent)
+	if contributionCfg.Writer == nil {
+		log.Printf("startup_config off_write_back=disabled")
+	} else {
+		log.Printf("startup_config off_write_back=%s sandbox=%t interval=%s max_attempts=%d",
+			contributionCfg.Writer.BaseURL, contributionCfg.Writer.Sandbox, contributionCfg.Interval, contributionCfg.MaxAttempts)
+		submitter := contribution.Submitter{Pool: pool, Writer: contributionCfg.Writer, MaxAttempts: contributionCfg.MaxAttempts}
+		// Runs at startup too, so approvals from before a deploy don't wait a full interval.
+		runWorker(func(ctx context.Context) {
+			runEvery(ctx, pool, "contribution_submit", contributionCfg.Interval, 10*time.Minute, func(ctx context.Context) {
+				result, err := submitter.Run(ctx)
+				if err != nil {
+					log.Printf("contribution_submit_run_error err=%v", err)
+				}
+				if result != (contribution.SubmitResult{}) {
+					log.Printf("contribution_submit_run submitted=%d retrying=%d failed=%d", result.Submitted, result.Retrying, result.Failed)
+				}
+			})
+		})
+	}
+
+	// Duplicate food item detector: proposes merge candidates for admins (never merges by itself).
+	if dedupeInterval := getFoodDedupeInterval(); dedupeInterval == 0 {
+		log.Printf("startup_config food_dedupe=disabled")
+	} else {
+		log.Printf("startup_config food_dedupe_interval=%s", dedupeInterval)
+		detector := dedupe.Detector{Pool: pool}
+		// Runs at startup too, so a fresh deploy has a review queue.
+		runWorker(func(ctx context.Context) {
+			runEvery(ctx, pool, "food_dedupe", dedupeInterval, 30*time.Minute, func(ctx context.Context) {
+				result, err := detector.Run(ctx)
+				if err != nil {
+					log.Printf("food_dedupe_error err=%v", err)
+					return
+				}
+				log.Printf("food_dedupe items=%d candidates=%d new_candidates=%d removed=%d skipped_blocks=%d",
+					result.Items, result.Candidates, result.NewCandidates, result.Removed, result.SkippedBlocks)
+			})
+		})
+	}
+
+	// Shared barcode lookup (cache -> OpenFoodFacts) for endpoints that take a barcode.
+	barcodeResolver := barcode.Resolver{API: api, Retry: retryCfg, CacheTTL: cacheTTL}
+
 	// This comes from the frontend when the user scans a barcode
-	router.GET("/v1/barcodes/:code", barcode.NewHandler(&api, retryCfg, cacheTTL))
+	router.GET("/v1/barcodes/:code", barcode.NewHandler(api, retryCfg, cacheTTL))
+
+	// Photo upload -> pure-Go barcode decoder -> same lookup as above (returns the symbology too).
+	router.POST("/v1/barcodes/decode", barcode.NewDecodeImageHandler(barcodeResolver))
+
+	// Product history: what changed in food_items, when, and who/what changed it.
+	router.GET("/v1/barcodes/:code/revisions", barcode.NewRevisionsHandler())
+
+	// Healthier same-category products from our cache, ranked by a configurable health score.
+	healthWeights := getHealthScoreWeights()
+	log.Printf("startup_config health_score_weights=nutriscore:%g,sugar:%g,sodium:%g,nova:%g",
+		healthWeights.Nutriscore, healthWeights.Sugar, healthWeights.Sodium, healthWeights.Nova)
+	router.GET("/v1/barcodes/:code/alternatives", barcode.NewAlternativesHandler(barcodeResolver, healthWeights))
+
+	// Regulatory nutrition panels (FDA Nutrition Facts or EU declaration) as SVG/HTML/JSON.
+	router.GET("/v1/barcodes/:code/label", label.NewBarcodeLabelHandler(barcodeResolver))
+	router.GET("/v1/food-items/:id/label", label.NewFoodItemLabelHandler())
+
+	// Recipe nutrition: resolve ingredient lines against food_items, total per recipe + per serving.
+	router.POST("/v1/recipes/analyze", recipe.NewAnalyzeHandler())
+
+	// Log a scanned (or picked) food straight into the diary: same lookup as GET /v1/barcodes/:code,
+	// then diary_entries + barcode_scans in one transaction (idempotent via Idempotency-Key).
+	router.POST("/v1/diary/entries", diary.NewCreateEntryHandler(barcodeResolver))
+
+	// Daily/weekly nutrition totals vs targets, with consistent gaps over the range.
+	router.GET("/v1/diary/summary", diary.NewSummaryHandler())
+
+	// Synced WHOOP workouts and weight entries, with km/mi and kg/lb display values.
+	router.GET("/v1/wellness/workouts", wellness.NewListWorkoutsHandler())
+	router.GET("/v1/wellness/weight", wellness.NewListWeightHandler())
+
+	// Recall alerts for products the user scanned or logged (see recall ingester above).
+	router.GET("/v1/recalls/alerts", recall.NewListAlertsHandler())
+	router.POST("/v1/recalls/alerts/:id/acknowledge", recall.NewAcknowledgeAlertHandler())
+
+	// Missing products: after a NOT_FOUND scan the user can submit the label (+ photos) for review.
+	router.POST("/v1/contributions", contribution.NewCreateHandler())
+	router.GET("/v1/contributions", contribution.NewListHandler())
+	router.POST("/v1/contributions/:id/photos", contribution.NewUploadPhotoHandler())
+
+	// Moderation (users.is_admin only): review queue, label photos, approve/reject.
+	admin := router.Group("/v1/admin", auth.RequireAdmin())
+	admin.GET("/contributions", contribution.NewAdminListHandler())
+	admin.GET("/contributions/:id/photos/:kind", contribution.NewAdminPhotoHandler())
+	admin.POST("/contributions/:id/review", contribution.NewReviewHandler())
+
+	// Duplicate food items: detector proposals, dismiss, and merge (re-points diary/meal plans/scans).
+	admin.GET("/food-items/merge-candidates", dedupe.NewListCandidatesHandler())
+	admin.POST("/food-items/merge-candidates/:id/dismiss", dedupe.NewDismissCandidateHandler())
+	admin.POST("/food-items/merge", dedupe.NewMergeHandler())
+
+	// This comes from the frontend when the user clicks "Connect WHOOP" (returns the consent URL)
+	router.POST("/internal/whoop/oauth/authorize", func(c *gin.Context) {
+		whoopService.AuthorizeHandler(c.Writer, c.Request)
+	})
 
 	// This comes from the frontend when the user completes the WHOOP OAuth flow
 	router.POST("/internal/whoop/oauth/exchange", func(c *gin.Context) {
@@ -398,6 +751,26 @@ func main() {
 		whoopService.SyncHandler(c.Writer, c.Request)
 	})
 
+	// Polled by the frontend while the initial import runs ("importing your history").
+	router.GET("/internal/whoop/sync/status", func(c *gin.Context) {
+		whoopService.SyncStatusHandler(c.Writer, c.Request)
+	})
+
+	// Full-history import for users who connected before backfills existed (queued, runs in the background).
+	router.POST("/internal/whoop/backfill", func(c *gin.Context) {
+		whoopService.BackfillHandler(c.Writer, c.Request)
+	})
+
+	// Per-resource backfill progress (records imported, oldest date reached, done/running).
+	router.GET("/internal/whoop/backfill/status", func(c *gin.Context) {
+		whoopService.BackfillStatusHandler(c.Writer, c.Request)
+	})
+
+	// This comes from WHOOP (sleep/recovery/workout updated or deleted); signature-verified
+	router.POST(whoopWebhookPath, func(c *gin.Context) {
+		whoopService.WebhookHandler(c.Writer, c.Request)
+	})
+
 	// This comes from the frontend when the user wants to disconnect their WHOOP data
 	router.POST("/internal/whoop/disconnect", func(c *gin.Context) {
 		whoopService.DisconnectHandler(c.Writer, c.Request)
@@ -405,12 +778,7 @@ func main() {
 
 	// Lightweight in-memory metrics for WHOOP observability.
 	router.GET("/internal/whoop/metrics", func(c *gin.Context) {
-		// Restrict metrics to internal callers that know the service API key.
-		if authCfg.APIKey == "" || c.GetHeader("X-API-Key") != authCfg.APIKey {
-			c.JSON(403, gin.H{"error": map[string]interface{}{
-				"code":    "FORBIDDEN",
-				"message": "Metrics access denied",
-			}})
+		if !allowMetricsAccess(c, authCfg) {
 			return
 		}
 
@@ -421,6 +789,14 @@ func main() {
 		c.JSON(200, whoopService.Metrics.Snapshot())
 	})
 
+	// Barcode lookup metrics (OpenFoodFacts circuit breaker state + counters).
+	router.GET("/internal/barcode/metrics", func(c *gin.Context) {
+		if !allowMetricsAccess(c, authCfg) {
+			return
+		}
+		c.JSON(200, gin.H{"openfoodfacts_breaker": offBreaker.Snapshot()})
+	})
+
 	// Print a safe config summary after we compute all config values.
 	logStartupSummary(authCfg, capacity, refillRate, timeout, userAgent, retryCfg, baseURL)
 
@@ -451,4 +827,47 @@ func main() {
 		// Log shutdown errors so we can diagnose hung requests.
 		log.Printf("shutdown_error err=%v", err)
 	}
+
+	// Stop the background workers before the deferred pool.Close: scheduled syncs in flight are
+	// cancelled and handed back to another replica, and cancelled sync jobs/webhook events are
+	// retried later like a failed attempt. Periodic jobs stop and release their lock.
+	stopWorkers()
+	workersStopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersStopped)
+	}()
+	select {
+	case <-workersStopped:
+	case <-shutdownCtx.Done():
+		log.Printf("shutdown_error background workers did not stop in time")
+	}
+}
+
+// runEvery runs fn now and then every interval until ctx ends, each run limited to timeout.
+// Runs hold the Postgres advisory lock named lockName, so with several replicas only one of
+// them runs the job at a time; the others skip that tick.
+func runEvery(ctx context.Context, pool *pgxpool.Pool, lockName string, interval, timeout time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, timeout)
+		ran, err := db.WithAdvisoryLock(runCtx, pool, lockName, func(ctx context.Context) error {
+			fn(ctx)
+			return nil
+		})
+		cancel()
+		if err != nil {
+			log.Printf("background_job_lock_error job=%s err=%v", lockName, err)
+		} else if !ran {
+			log.Printf("background_job_skipped job=%s reason=running_elsewhere", lockName)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,25 @@
+-- AlterTable
+ALTER TABLE "users" ADD COLUMN     "country" TEXT,
+ADD COLUMN     "locale" TEXT;
+
+-- CreateTable
+CREATE TABLE "food_item_localizations" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "food_item_id" TEXT NOT NULL,
+    "language" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "ingredients_text" TEXT,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "food_item_localizations_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "food_item_localizations_food_item_id_idx" ON "food_item_localizations"("food_item_id");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "food_item_localizations_food_item_id_language_key" ON "food_item_localizations"("food_item_id", "language");
+
+-- AddForeignKey
+ALTER TABLE "food_item_localizations" ADD CONSTRAINT "food_item_localizations_food_item_id_fkey" FOREIGN KEY ("food_item_id") REFERENCES "food_items"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,78 @@
+-- CreateTable
+CREATE TABLE "food_item_revisions" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "food_item_id" TEXT NOT NULL,
+    "revision" INTEGER NOT NULL,
+    "change_source" TEXT NOT NULL,
+    "changed_by" TEXT,
+    "changes" JSONB NOT NULL,
+    "snapshot" JSONB NOT NULL,
+    "previous" JSONB,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "food_item_revisions_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "food_item_revisions_food_item_id_created_at_idx" ON "food_item_revisions"("food_item_id", "created_at");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "food_item_revisions_food_item_id_revision_key" ON "food_item_revisions"("food_item_id", "revision");
+
+-- AddForeignKey
+ALTER TABLE "food_item_revisions" ADD CONSTRAINT "food_item_revisions_food_item_id_fkey" FOREIGN KEY ("food_item_id") REFERENCES "food_items"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- Revision trigger (manual)
+-- Records one row per insert/update that actually changes a tracked column.
+-- Writers tag the change with:
+--   SELECT set_config('app.change_source', 'user_correction', true),
+--          set_config('app.changed_by', '<user id>', true);
+-- Untagged writes are recorded with change_source = 'app'.
+CREATE OR REPLACE FUNCTION record_food_item_revision() RETURNS trigger AS $$
+DECLARE
+    new_row JSONB := to_jsonb(NEW) - 'created_at' - 'updated_at';
+    old_row JSONB;
+    diff JSONB;
+    next_revision INTEGER;
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        old_row := to_jsonb(OLD) - 'created_at' - 'updated_at';
+        SELECT COALESCE(jsonb_object_agg(n.key, jsonb_build_object('from', o.value, 'to', n.value)), '{}'::jsonb)
+        INTO diff
+        FROM jsonb_each(new_row) AS n
+        JOIN jsonb_each(old_row) AS o ON o.key = n.key
+        WHERE n.value IS DISTINCT FROM o.value;
+
+        -- Refreshes that only bump updated_at are not revisions.
+        IF diff = '{}'::jsonb THEN
+            RETURN NEW;
+        END IF;
+    ELSE
+        SELECT jsonb_object_agg(n.key, jsonb_build_object('from', NULL, 'to', n.value))
+        INTO diff
+        FROM jsonb_each(new_row) AS n;
+    END IF;
+
+    SELECT COALESCE(MAX("revision"), 0) + 1
+    INTO next_revision
+    FROM "food_item_revisions"
+    WHERE "food_item_id" = NEW."id";
+
+    INSERT INTO "food_item_revisions" ("food_item_id", "revision", "change_source", "changed_by", "changes", "snapshot", "previous")
+    VALUES (
+        NEW."id",
+        next_revision,
+        COALESCE(NULLIF(current_setting('app.change_source', true), ''), 'app'),
+        NULLIF(current_setting('app.changed_by', true), ''),
+        diff,
+        new_row,
+        old_row
+    );
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "food_items_record_revision"
+AFTER INSERT OR UPDATE ON "food_items"
+FOR EACH ROW EXECUTE FUNCTION record_food_item_revision();
@@ -0,0 +1,25 @@
+-- AlterTable
+ALTER TABLE "food_items" ADD COLUMN     "data_quality_flags" TEXT[] DEFAULT ARRAY[]::TEXT[],
+ADD COLUMN     "data_quality_score" INTEGER;
+
+-- CreateTable
+CREATE TABLE "food_item_quarantine" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "barcode" TEXT NOT NULL,
+    "source" "FoodSource" NOT NULL,
+    "source_id" TEXT,
+    "payload" JSONB NOT NULL,
+    "quality_score" INTEGER NOT NULL,
+    "quality_flags" TEXT[],
+    "seen_count" INTEGER NOT NULL DEFAULT 1,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "food_item_quarantine_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "food_item_quarantine_barcode_key" ON "food_item_quarantine"("barcode");
+
+-- CreateIndex
+CREATE INDEX "food_item_quarantine_updated_at_idx" ON "food_item_quarantine"("updated_at");
@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "diary_entries" ADD COLUMN     "idempotency_key" TEXT;
+
+-- CreateIndex
+CREATE UNIQUE INDEX "diary_entries_user_id_idempotency_key_key" ON "diary_entries"("user_id", "idempotency_key");
@@ -0,0 +1,7 @@
+-- AlterTable
+ALTER TABLE "food_items" ADD COLUMN     "category_tags" TEXT[] DEFAULT ARRAY[]::TEXT[],
+ADD COLUMN     "nova_group" INTEGER,
+ADD COLUMN     "nutriscore_grade" TEXT;
+
+-- CreateIndex
+CREATE INDEX "food_items_category_tags_idx" ON "food_items" USING GIN ("category_tags");
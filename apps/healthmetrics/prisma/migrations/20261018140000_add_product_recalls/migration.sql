@@ -0,0 +1,50 @@
+-- CreateTable
+CREATE TABLE "product_recalls" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "source" TEXT NOT NULL,
+    "external_id" TEXT NOT NULL,
+    "title" TEXT NOT NULL,
+    "reason" TEXT,
+    "brand" TEXT,
+    "product_pattern" TEXT,
+    "barcodes" TEXT[] DEFAULT ARRAY[]::TEXT[],
+    "url" TEXT,
+    "published_at" TIMESTAMP(3) NOT NULL,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "product_recalls_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "recall_alerts" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "user_id" TEXT NOT NULL,
+    "recall_id" TEXT NOT NULL,
+    "food_item_ids" TEXT[] DEFAULT ARRAY[]::TEXT[],
+    "matched_by" TEXT NOT NULL,
+    "exposures" TEXT[] DEFAULT ARRAY[]::TEXT[],
+    "last_exposure_at" TIMESTAMP(3) NOT NULL,
+    "acknowledged_at" TIMESTAMP(3),
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "recall_alerts_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "product_recalls_source_external_id_key" ON "product_recalls"("source", "external_id");
+
+-- CreateIndex
+CREATE INDEX "product_recalls_published_at_idx" ON "product_recalls"("published_at");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "recall_alerts_user_id_recall_id_key" ON "recall_alerts"("user_id", "recall_id");
+
+-- CreateIndex
+CREATE INDEX "recall_alerts_user_id_acknowledged_at_idx" ON "recall_alerts"("user_id", "acknowledged_at");
+
+-- AddForeignKey
+ALTER TABLE "recall_alerts" ADD CONSTRAINT "recall_alerts_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "recall_alerts" ADD CONSTRAINT "recall_alerts_recall_id_fkey" FOREIGN KEY ("recall_id") REFERENCES "product_recalls"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,74 @@
+-- CreateEnum
+CREATE TYPE "ContributionStatus" AS ENUM ('pending', 'approved', 'rejected', 'submitted', 'failed');
+
+-- CreateTable
+CREATE TABLE "product_contributions" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "user_id" TEXT NOT NULL,
+    "barcode" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "brand" TEXT,
+    "quantity" TEXT,
+    "serving_size" TEXT,
+    "serving_size_g" DECIMAL(10,2),
+    "ingredients" TEXT,
+    "language" TEXT NOT NULL DEFAULT 'en',
+    "calories_per_100g" DECIMAL(10,2) NOT NULL,
+    "protein_g" DECIMAL(10,2) NOT NULL,
+    "carbs_g" DECIMAL(10,2) NOT NULL,
+    "fat_g" DECIMAL(10,2) NOT NULL,
+    "fiber_g" DECIMAL(10,2),
+    "sugar_g" DECIMAL(10,2),
+    "sodium_mg" DECIMAL(10,2),
+    "data_quality_flags" TEXT[] DEFAULT ARRAY[]::TEXT[],
+    "status" "ContributionStatus" NOT NULL DEFAULT 'pending',
+    "review_note" TEXT,
+    "reviewed_by" TEXT,
+    "reviewed_at" TIMESTAMP(3),
+    "food_item_id" TEXT,
+    "off_attempts" INTEGER NOT NULL DEFAULT 0,
+    "off_last_error" TEXT,
+    "off_next_attempt_at" TIMESTAMP(3),
+    "off_submitted_at" TIMESTAMP(3),
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "product_contributions_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "contribution_photos" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "contribution_id" TEXT NOT NULL,
+    "kind" TEXT NOT NULL,
+    "content_type" TEXT NOT NULL,
+    "data" BYTEA NOT NULL,
+    "off_uploaded_at" TIMESTAMP(3),
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "contribution_photos_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "product_contributions_user_id_created_at_idx" ON "product_contributions"("user_id", "created_at");
+
+-- CreateIndex
+CREATE INDEX "product_contributions_barcode_idx" ON "product_contributions"("barcode");
+
+-- CreateIndex
+CREATE INDEX "product_contributions_status_off_next_attempt_at_idx" ON "product_contributions"("status", "off_next_attempt_at");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "contribution_photos_contribution_id_kind_key" ON "contribution_photos"("contribution_id", "kind");
+
+-- AddForeignKey
+ALTER TABLE "product_contributions" ADD CONSTRAINT "product_contributions_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "product_contributions" ADD CONSTRAINT "product_contributions_reviewed_by_fkey" FOREIGN KEY ("reviewed_by") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "product_contributions" ADD CONSTRAINT "product_contributions_food_item_id_fkey" FOREIGN KEY ("food_item_id") REFERENCES "food_items"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "contribution_photos" ADD CONSTRAINT "contribution_photos_contribution_id_fkey" FOREIGN KEY ("contribution_id") REFERENCES "product_contributions"("id") ON DELETE CASCADE ON UPDATE CASCADE;
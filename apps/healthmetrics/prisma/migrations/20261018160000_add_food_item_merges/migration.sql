@@ -0,0 +1,78 @@
+-- CreateEnum
+CREATE TYPE "MergeCandidateStatus" AS ENUM ('open', 'merged', 'dismissed');
+
+-- AlterTable
+ALTER TABLE "food_items" ADD COLUMN "merged_into_id" TEXT,
+ADD COLUMN "merged_at" TIMESTAMP(3);
+
+-- AlterTable
+ALTER TABLE "diary_entries" ADD COLUMN "original_food_item_id" TEXT;
+
+-- CreateTable
+CREATE TABLE "food_item_merge_candidates" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "food_item_a_id" TEXT NOT NULL,
+    "food_item_b_id" TEXT NOT NULL,
+    "suggested_survivor_id" TEXT NOT NULL,
+    "score" DECIMAL(5,4) NOT NULL,
+    "name_similarity" DECIMAL(5,4) NOT NULL,
+    "brand_similarity" DECIMAL(5,4) NOT NULL,
+    "nutrient_distance" DECIMAL(5,4) NOT NULL,
+    "status" "MergeCandidateStatus" NOT NULL DEFAULT 'open',
+    "resolved_by" TEXT,
+    "resolved_at" TIMESTAMP(3),
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "food_item_merge_candidates_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "food_item_merges" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "survivor_id" TEXT NOT NULL,
+    "duplicate_id" TEXT NOT NULL,
+    "merged_by" TEXT,
+    "diary_entry_ids" TEXT[] DEFAULT ARRAY[]::TEXT[],
+    "meal_plan_ids" TEXT[] DEFAULT ARRAY[]::TEXT[],
+    "barcode_scan_ids" TEXT[] DEFAULT ARRAY[]::TEXT[],
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "food_item_merges_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "food_items_merged_into_id_idx" ON "food_items"("merged_into_id");
+
+-- CreateIndex
+CREATE UNIQUE INDEX "food_item_merge_candidates_food_item_a_id_food_item_b_id_key" ON "food_item_merge_candidates"("food_item_a_id", "food_item_b_id");
+
+-- CreateIndex
+CREATE INDEX "food_item_merge_candidates_status_score_idx" ON "food_item_merge_candidates"("status", "score");
+
+-- CreateIndex
+CREATE INDEX "food_item_merges_survivor_id_idx" ON "food_item_merges"("survivor_id");
+
+-- CreateIndex
+CREATE INDEX "food_item_merges_duplicate_id_idx" ON "food_item_merges"("duplicate_id");
+
+-- AddForeignKey
+ALTER TABLE "food_items" ADD CONSTRAINT "food_items_merged_into_id_fkey" FOREIGN KEY ("merged_into_id") REFERENCES "food_items"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "food_item_merge_candidates" ADD CONSTRAINT "food_item_merge_candidates_food_item_a_id_fkey" FOREIGN KEY ("food_item_a_id") REFERENCES "food_items"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "food_item_merge_candidates" ADD CONSTRAINT "food_item_merge_candidates_food_item_b_id_fkey" FOREIGN KEY ("food_item_b_id") REFERENCES "food_items"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "food_item_merge_candidates" ADD CONSTRAINT "food_item_merge_candidates_resolved_by_fkey" FOREIGN KEY ("resolved_by") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "food_item_merges" ADD CONSTRAINT "food_item_merges_survivor_id_fkey" FOREIGN KEY ("survivor_id") REFERENCES "food_items"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "food_item_merges" ADD CONSTRAINT "food_item_merges_duplicate_id_fkey" FOREIGN KEY ("duplicate_id") REFERENCES "food_items"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "food_item_merges" ADD CONSTRAINT "food_item_merges_merged_by_fkey" FOREIGN KEY ("merged_by") REFERENCES "users"("id") ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1,35 @@
+-- CreateEnum
+CREATE TYPE "IntegrationSyncJobStatus" AS ENUM ('queued', 'running', 'succeeded', 'failed');
+
+-- CreateTable
+CREATE TABLE "integration_sync_job" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "integration_id" TEXT NOT NULL,
+    "kind" TEXT NOT NULL,
+    "status" "IntegrationSyncJobStatus" NOT NULL DEFAULT 'queued',
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "max_attempts" INTEGER NOT NULL DEFAULT 5,
+    "run_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "locked_until" TIMESTAMP(3),
+    "progress" JSONB NOT NULL DEFAULT '{}',
+    "current_resource" TEXT,
+    "last_error" TEXT,
+    "started_at" TIMESTAMP(3),
+    "finished_at" TIMESTAMP(3),
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "integration_sync_job_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "integration_sync_job_status_run_at_idx" ON "integration_sync_job"("status", "run_at");
+
+-- CreateIndex
+CREATE INDEX "integration_sync_job_integration_id_idx" ON "integration_sync_job"("integration_id");
+
+-- One active job per integration + kind, so a repeated OAuth exchange doesn't queue a second import.
+CREATE UNIQUE INDEX "integration_sync_job_active_key" ON "integration_sync_job"("integration_id", "kind") WHERE "status" IN ('queued', 'running');
+
+-- AddForeignKey
+ALTER TABLE "integration_sync_job" ADD CONSTRAINT "integration_sync_job_integration_id_fkey" FOREIGN KEY ("integration_id") REFERENCES "integration"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "integration_oauth_state" ADD COLUMN "code_verifier_encrypted" TEXT;
@@ -0,0 +1,17 @@
+-- CreateTable
+CREATE TABLE "integration_sync_cursor" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "integration_id" TEXT NOT NULL,
+    "resource_type" TEXT NOT NULL,
+    "last_synced_at" TIMESTAMP(3),
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "integration_sync_cursor_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "integration_sync_cursor_integration_id_resource_type_key" ON "integration_sync_cursor"("integration_id", "resource_type");
+
+-- AddForeignKey
+ALTER TABLE "integration_sync_cursor" ADD CONSTRAINT "integration_sync_cursor_integration_id_fkey" FOREIGN KEY ("integration_id") REFERENCES "integration"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,6 @@
+-- AlterTable
+ALTER TABLE "integration_sync_cursor" ADD COLUMN "backfill_end_at" TIMESTAMP(3),
+ADD COLUMN "backfill_next_token" TEXT,
+ADD COLUMN "backfill_records" INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN "backfill_oldest_at" TIMESTAMP(3),
+ADD COLUMN "backfill_completed_at" TIMESTAMP(3);
@@ -0,0 +1,32 @@
+-- AlterTable
+ALTER TABLE "integration_raw_event" ADD COLUMN "deleted_at" TIMESTAMP(3);
+
+-- CreateIndex
+CREATE INDEX "integration_connection_provider_user_id_idx" ON "integration_connection"("provider_user_id");
+
+-- CreateTable
+CREATE TABLE "integration_webhook_event" (
+    "id" TEXT NOT NULL DEFAULT gen_random_uuid(),
+    "provider" "IntegrationProvider" NOT NULL,
+    "event_key" TEXT NOT NULL,
+    "provider_user_id" TEXT NOT NULL,
+    "event_type" TEXT NOT NULL,
+    "resource_id" TEXT NOT NULL,
+    "status" "IntegrationSyncJobStatus" NOT NULL DEFAULT 'queued',
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "max_attempts" INTEGER NOT NULL DEFAULT 5,
+    "run_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "locked_until" TIMESTAMP(3),
+    "last_error" TEXT,
+    "processed_at" TIMESTAMP(3),
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "integration_webhook_event_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "integration_webhook_event_provider_event_key_key" ON "integration_webhook_event"("provider", "event_key");
+
+-- CreateIndex
+CREATE INDEX "integration_webhook_event_status_run_at_idx" ON "integration_webhook_event"("status", "run_at");
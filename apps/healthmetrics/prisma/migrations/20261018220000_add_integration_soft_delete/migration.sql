@@ -0,0 +1,11 @@
+-- AlterTable
+ALTER TABLE "integration_sleep" ADD COLUMN "deleted_at" TIMESTAMP(3);
+
+-- AlterTable
+ALTER TABLE "integration_recovery" ADD COLUMN "deleted_at" TIMESTAMP(3);
+
+-- AlterTable
+ALTER TABLE "integration_workout" ADD COLUMN "deleted_at" TIMESTAMP(3);
+
+-- AlterTable
+ALTER TABLE "integration_cycle" ADD COLUMN "deleted_at" TIMESTAMP(3);
@@ -0,0 +1,13 @@
+-- CreateTable
+CREATE TABLE "integration_lock" (
+    "integration_id" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "owner" TEXT NOT NULL,
+    "locked_until" TIMESTAMP(3) NOT NULL,
+    "created_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "integration_lock_pkey" PRIMARY KEY ("integration_id","name")
+);
+
+-- AddForeignKey
+ALTER TABLE "integration_lock" ADD CONSTRAINT "integration_lock_integration_id_fkey" FOREIGN KEY ("integration_id") REFERENCES "integration"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,10 @@
+-- AlterTable
+ALTER TABLE "integration" ADD COLUMN "next_sync_at" TIMESTAMP(3);
+
+-- Spread existing connections over the first 12-hour interval instead of syncing them all at once.
+UPDATE "integration"
+SET "next_sync_at" = now() + random() * interval '12 hours'
+WHERE "status" = 'connected';
+
+-- CreateIndex
+CREATE INDEX "integration_provider_status_next_sync_at_idx" ON "integration"("provider", "status", "next_sync_at");
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "diary_entries" ADD COLUMN     "idempotency_fingerprint" TEXT;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "food_item_localizations" ADD COLUMN     "content_language" TEXT;
@@ -0,0 +1,16 @@
+-- HTTP recall feeds used to be stored under "http:<url>"; they now use a fixed name
+-- ("http", or "http:<RECALL_FEED_NAME>"). Move existing rows to the default name so the
+-- next ingest updates them instead of inserting duplicates. If several URLs shipped the
+-- same external_id, only the most recently updated row is moved.
+UPDATE "product_recalls" r
+SET "source" = 'http'
+WHERE r."id" IN (
+    SELECT DISTINCT ON ("external_id") "id"
+    FROM "product_recalls"
+    WHERE "source" LIKE 'http:%'
+    ORDER BY "external_id", "updated_at" DESC
+)
+AND NOT EXISTS (
+    SELECT 1 FROM "product_recalls" o
+    WHERE o."source" = 'http' AND o."external_id" = r."external_id"
+);
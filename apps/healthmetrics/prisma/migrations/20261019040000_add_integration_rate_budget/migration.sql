@@ -0,0 +1,9 @@
+-- CreateTable
+CREATE TABLE "integration_rate_budget" (
+    "provider" "IntegrationProvider" NOT NULL,
+    "window_start" TIMESTAMP(3) NOT NULL,
+    "used" INTEGER NOT NULL DEFAULT 0,
+    "updated_at" TIMESTAMP(3) NOT NULL DEFAULT now(),
+
+    CONSTRAINT "integration_rate_budget_pkey" PRIMARY KEY ("provider")
+);
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "integration_token" ADD COLUMN     "last_refresh_attempt_at" TIMESTAMP(3);
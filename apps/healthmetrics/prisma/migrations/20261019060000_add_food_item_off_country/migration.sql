@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "food_items" ADD COLUMN     "off_country" TEXT;
@@ -238,6 +238,7 @@ export const getTodayExerciseSummary = createServerFn({ method: "GET" })
             where: {
               integrationId: integration.id,
               localDate: targetDate,
+              deletedAt: null, // deleted in WHOOP
             },
           })
         : [];
@@ -317,6 +318,7 @@ export const getExerciseActivity = createServerFn({ method: "GET" })
             where: {
               integrationId: integration.id,
               localDate: targetDate,
+              deletedAt: null, // deleted in WHOOP
             },
             orderBy: { startAt: "desc" },
           })
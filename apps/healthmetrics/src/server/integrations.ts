@@ -1,6 +1,5 @@
 import { createServerFn } from "@tanstack/react-start";
 import { getRequestHeaders } from "@tanstack/react-start/server";
-import { createHash, randomBytes } from "crypto";
 import { z } from "zod";
 import { prisma } from "@/lib/prisma";
 import { auth } from "@/lib/auth-config";
@@ -9,17 +8,6 @@ import { getEnv } from "@/utils/env";
 
 const log = createLogger("server:integrations");
 
-const WHOOP_AUTH_URL = "https://api.prod.whoop.com/oauth/oauth2/auth";
-const WHOOP_SCOPES = [
-  "read:profile",
-  "read:body_measurement",
-  "read:cycles",
-  "read:recovery",
-  "read:sleep",
-  "read:workout",
-  "offline",
-];
-
 const whoopCallbackSchema = z.object({
   code: z.string().min(1),
   state: z.string().min(8),
@@ -35,8 +23,11 @@ export const startWhoopOAuth = createServerFn({ method: "POST" }).handler(
   async () => {
     const env = getEnv();
 
-    if (!env.WHOOP_CLIENT_ID || !env.WHOOP_REDIRECT_URL) {
-      throw new Error("WHOOP_CLIENT_ID and WHOOP_REDIRECT_URL are required");
+    // The Go service generates the state + PKCE verifier and builds the consent URL,
+    // so it can verify both when the callback comes back through the exchange.
+    const serviceUrl = env.GO_SERVICE_URL;
+    if (!serviceUrl) {
+      throw new Error("GO_SERVICE_URL is required for WHOOP integration");
     }
 
     const headers = getRequestHeaders();
@@ -46,38 +37,52 @@ export const startWhoopOAuth = createServerFn({ method: "POST" }).handler(
       throw new Error("Authentication required");
     }
 
-    const state = randomBytes(32).toString("hex");
-    // "state" is the random CSRF (Cross-Site Request Forgery) token we generated in startWhoopOAuth
-    // we hash it so we can store/compare securely without keeping the raw value in DB.
-    const stateHash = createHash("sha256").update(state).digest("hex");
-    const expiresAt = new Date(Date.now() + 10 * 60 * 1000);
+    const requestId = generateRequestId();
+    const cookieHeader = headers.get("cookie") || "";
 
-    await prisma.integrationOAuthState.deleteMany({
-      where: {
-        userId: session.user.id,
-        provider: "whoop",
-      },
-    });
+    const serviceHeaders: Record<string, string> = {
+      Accept: "application/json",
+      "Content-Type": "application/json",
+      "X-Request-ID": requestId,
+      "X-User-ID": session.user.id,
+    };
 
-    await prisma.integrationOAuthState.create({
-      data: {
-        userId: session.user.id,
-        provider: "whoop",
-        stateHash,
-        expiresAt,
+    if (env.BARCODE_SERVICE_API_KEY) {
+      serviceHeaders["X-API-Key"] = env.BARCODE_SERVICE_API_KEY;
+    } else {
+      log.warn(
+        { requestId },
+        "BARCODE_SERVICE_API_KEY not configured - service auth disabled",
+      );
+    }
+
+    if (cookieHeader) {
+      serviceHeaders["Cookie"] = cookieHeader;
+    }
+
+    const response = await fetch(
+      `${serviceUrl}/internal/whoop/oauth/authorize`,
+      {
+        method: "POST",
+        headers: serviceHeaders,
+        body: JSON.stringify({ userId: session.user.id }),
       },
-    });
+    );
 
-    const authUrl = new URL(WHOOP_AUTH_URL);
-    authUrl.searchParams.set("response_type", "code");
-    authUrl.searchParams.set("client_id", env.WHOOP_CLIENT_ID);
-    authUrl.searchParams.set("redirect_uri", env.WHOOP_REDIRECT_URL);
-    authUrl.searchParams.set("scope", WHOOP_SCOPES.join(" "));
-    authUrl.searchParams.set("state", state);
+    if (!response.ok) {
+      const errorText = await response.text();
+      log.error(
+        { requestId, status: response.status, error: errorText },
+        "WHOOP authorize failed",
+      );
+      throw new Error("Failed to start WHOOP connection. Please try again.");
+    }
 
-    log.info({ userId: session.user.id }, "Starting WHOOP OAuth");
+    const { url } = (await response.json()) as { url: string };
 
-    return { url: authUrl.toString() };
+    log.info({ requestId, userId: session.user.id }, "Starting WHOOP OAuth");
+
+    return { url };
   },
 );
 
@@ -105,30 +110,6 @@ export const completeWhoopOAuth = createServerFn({ method: "POST" })
       throw new Error("Authentication required");
     }
 
-    const stateHash = createHash("sha256").update(state).digest("hex");
-    const now = new Date();
-
-    const stateRecord = await prisma.integrationOAuthState.findFirst({
-      where: {
-        userId: session.user.id,
-        provider: "whoop",
-        stateHash,
-        expiresAt: { gt: now },
-      },
-    });
-
-    if (!stateRecord) {
-      log.warn(
-        { userId: session.user.id },
-        "WHOOP OAuth state invalid or expired",
-      );
-      throw new Error("Invalid or expired OAuth state");
-    }
-
-    await prisma.integrationOAuthState.delete({
-      where: { id: stateRecord.id },
-    });
-
     const requestId = generateRequestId();
     const cookieHeader = headers.get("cookie") || "";
 
@@ -161,10 +142,28 @@ export const completeWhoopOAuth = createServerFn({ method: "POST" })
           userId: session.user.id,
           code,
           redirectUri: env.WHOOP_REDIRECT_URL,
+          // Verified and consumed (single use) by the Go service.
+          state,
         }),
       },
     );
 
+    if (response.status === 400) {
+      const errorText = await response.text();
+      if (errorText.includes("state")) {
+        log.warn(
+          { requestId, userId: session.user.id },
+          "WHOOP OAuth state invalid or expired",
+        );
+        throw new Error("Invalid or expired OAuth state");
+      }
+      log.error(
+        { requestId, status: response.status, error: errorText },
+        "WHOOP exchange failed",
+      );
+      throw new Error("Failed to connect WHOOP. Please try again.");
+    }
+
     if (response.status === 401) {
       log.warn(
         { requestId, userId: session.user.id },
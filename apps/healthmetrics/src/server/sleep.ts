@@ -87,6 +87,7 @@ export const getSleepEntry = createServerFn({ method: "GET" })
             integrationId: whoopIntegrationId,
             localDate: dateObj,
             isPrimary: true,
+            deletedAt: null,
           },
           orderBy: {
             durationSeconds: "desc",
@@ -178,6 +179,7 @@ export const getSleepHistory = createServerFn({ method: "GET" })
             integrationId: whoopIntegrationId,
             localDate: { gte: startDate },
             isPrimary: true,
+            deletedAt: null,
           },
           orderBy: { localDate: "desc" },
           select: {
@@ -255,6 +257,7 @@ export const getSleepAverage = createServerFn({ method: "GET" })
               integrationId: whoopIntegrationId,
               localDate: { gte: startDate },
               isPrimary: true,
+              deletedAt: null,
             },
             select: {
               durationSeconds: true,